@@ -2,9 +2,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/fleettest"
+	"github.com/TsekNet/fleet-plan/internal/output"
 )
 
 // ---------- version command ----------
@@ -59,6 +68,15 @@ func TestGlobalFlags(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "max-query-cost flag",
+			args: []string{"--max-query-cost", "250", "version"},
+			check: func(t *testing.T) {
+				if flagMaxQueryCost != 250 {
+					t.Errorf("flagMaxQueryCost: got %d, want 250", flagMaxQueryCost)
+				}
+			},
+		},
 		{
 			name: "no-color flag",
 			args: []string{"--no-color", "version"},
@@ -117,7 +135,7 @@ func TestRootFlagsIncludeAllFlags(t *testing.T) {
 	}
 
 	output := buf.String()
-	for _, flag := range []string{"--team", "--git", "--base", "--env", "--heading", "--verbose", "--detailed-exitcodes"} {
+	for _, flag := range []string{"--team", "--git", "--base", "--env", "--heading", "--verbose", "--detailed-exitcodes", "--max-query-cost", "--no-cache", "--cache-dir"} {
 		if !strings.Contains(output, flag) {
 			t.Errorf("help should mention %s, got:\n%s", flag, output)
 		}
@@ -141,3 +159,373 @@ func TestRequiresAuth(t *testing.T) {
 		t.Errorf("expected URL required error, got: %v", err)
 	}
 }
+
+func TestTraceReplayFlags(t *testing.T) {
+	t.Setenv("FLEET_URL", "")
+	t.Setenv("FLEET_TOKEN", "")
+	t.Setenv("HOME", t.TempDir())
+	fixture := filepath.Join("..", "..", "internal", "api", "testdata", "fleet.har")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "combined",
+			args:    []string{"--trace-http", filepath.Join(t.TempDir(), "out.har"), "--replay", fixture},
+			wantErr: "--trace-http and --replay cannot be combined",
+		},
+		{
+			name:    "missing trace",
+			args:    []string{"--replay", filepath.Join(t.TempDir(), "missing.har")},
+			wantErr: "reading HTTP trace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := buildRootCmd()
+			root.SetArgs(append([]string{"--repo", t.TempDir()}, tt.args...))
+			err := root.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// ---------- --timeout ----------
+
+func TestTimeoutPrintsPartialPlan(t *testing.T) {
+	// A Fleet server whose software endpoint never answers.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fleet/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user": {"email": "ci@example.com", "global_role": "admin"}}`))
+	})
+	mux.HandleFunc("/api/v1/fleet/teams", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"teams": [{"id": 1, "name": "Workstations"}]}`))
+	})
+	mux.HandleFunc("/api/v1/fleet/software/titles", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("FLEET_URL", ts.URL)
+	t.Setenv("FLEET_TOKEN", "tok")
+	t.Setenv("FLEET_PLAN_INSECURE", "1")
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	root := buildRootCmd()
+	root.SetArgs([]string{"--repo", filepath.Join("..", "..", "testdata"), "--team", "Workstations",
+		"--format", "json", "--timeout", "300ms"})
+	err := root.Execute()
+
+	w.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	os.Stdout = old
+
+	if err == nil || err.Error() != "partial plan: --timeout 300ms reached before all Fleet state was fetched" {
+		t.Errorf("err = %v, want the partial plan error", err)
+	}
+	for _, want := range []string{`"partial": true`, `"not_fetched": [`, `"software diff skipped: not fetched before the run was interrupted"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+// ---------- response cache ----------
+
+func TestCacheFlags(t *testing.T) {
+	srv := fleettest.NewServer(&api.FleetState{Teams: []api.Team{{ID: 1, Name: "Workstations"}}})
+	defer srv.Close()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("FLEET_URL", srv.URL)
+	t.Setenv("FLEET_TOKEN", "tok")
+	t.Setenv("FLEET_PLAN_INSECURE", "1")
+	cacheDir := t.TempDir()
+	os.MkdirAll(filepath.Join(home, ".config"), 0o700)
+
+	tests := []struct {
+		name       string
+		args       []string
+		config     string // $HOME/.config/fleet-plan.json
+		wantLabels int    // label requests that reached Fleet
+		wantStats  string // cache summary on stderr; "" for none
+	}{
+		{name: "off by default", wantLabels: 1},
+		{name: "first run", args: []string{"--cache-dir", cacheDir}, wantLabels: 1, wantStats: "0 hits, 0 revalidated, 3 misses"},
+		{name: "second run", args: []string{"--cache-dir", cacheDir}, wantStats: "3 hits, 0 revalidated, 0 misses"},
+		{name: "no cache", args: []string{"--cache-dir", cacheDir, "--no-cache"}, wantLabels: 1},
+		{name: "config key", config: `{"cache":true}`, wantLabels: 1, wantStats: "0 hits, 0 revalidated, 3 misses"},
+		{name: "no cache beats config key", args: []string{"--no-cache"}, config: `{"cache":true}`, wantLabels: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(filepath.Join(home, ".config", "fleet-plan.json"), []byte(tt.config), 0o600)
+			before := len(srv.Requests())
+			stderr := captureStderr(t, func() {
+				captureStdout(t, func() {
+					root := buildRootCmd()
+					root.SetArgs(append([]string{"--repo", filepath.Join("..", "..", "testdata"), "--team", "Workstations", "--format", "json"}, tt.args...))
+					root.Execute()
+				})
+			})
+
+			labelCalls := 0
+			for _, r := range srv.Requests()[before:] {
+				if strings.HasPrefix(r, "GET /api/v1/fleet/labels?") {
+					labelCalls++
+				}
+			}
+			if labelCalls != tt.wantLabels {
+				t.Errorf("label requests = %d, want %d", labelCalls, tt.wantLabels)
+			}
+			line := "Fleet API cache: " + tt.wantStats
+			if tt.wantStats == "" {
+				line = "Fleet API cache:"
+				if strings.Contains(stderr, line) {
+					t.Errorf("stderr reports cache use:\n%s", stderr)
+				}
+			} else if !strings.Contains(stderr, line) {
+				t.Errorf("stderr missing %q:\n%s", line, stderr)
+			}
+		})
+	}
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	old := os.Stderr
+	os.Stderr = f
+	fn()
+	os.Stderr = old
+	data, _ := os.ReadFile(f.Name())
+	return string(data)
+}
+
+// ---------- end to end ----------
+
+// e2eState is the Fleet state the testdata repo is planned against.
+func e2eState() *api.FleetState {
+	return &api.FleetState{
+		Config: map[string]any{"org_info": map[string]any{"org_name": "Old Corp"}},
+		Labels: []api.Label{
+			{ID: 1, Name: "macOS 14+", LabelType: "regular", HostCount: 40},
+			{ID: 2, Name: "Windows 11", LabelType: "regular", HostCount: 25},
+			{ID: 3, Name: "Ubuntu 24.04", LabelType: "regular", HostCount: 8},
+		},
+		Teams: []api.Team{
+			{
+				ID:   1,
+				Name: "Workstations",
+				Policies: []api.Policy{
+					{ID: 1, Name: "[macOS] FileVault Enabled", Query: "SELECT 0;", Platform: "darwin", Critical: true},
+					{ID: 2, Name: "Retired policy", Query: "SELECT 1;"},
+				},
+				Scripts: []api.Script{
+					{ID: 1, Name: "disk-cleanup.sh", TeamID: 1, Content: "#!/bin/bash\necho \"Disk cleanup\""},
+					{ID: 2, Name: "enable-desktop.ps1", TeamID: 1, Content: "Write-Host old"},
+				},
+			},
+			{
+				ID:   2,
+				Name: "Servers",
+				Policies: []api.Policy{{
+					ID:          3,
+					Name:        "[Linux] SSH Root Login Disabled",
+					Description: "Verifies that SSH root login is disabled.",
+					Resolution:  "Edit /etc/ssh/sshd_config and set PermitRootLogin to \"no\", then restart sshd.",
+					Query:       "SELECT 1 FROM augeas WHERE path = '/files/etc/ssh/sshd_config/PermitRootLogin' AND value = 'no';",
+					Platform:    "linux",
+				}},
+			},
+		},
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []fleettest.Option
+		args  []string
+		check func(t *testing.T, plan map[string]output.JSONTeamDiff)
+	}{
+		{
+			name: "team changes",
+			args: []string{"--team", "Workstations", "--team", "Servers"},
+			check: func(t *testing.T, plan map[string]output.JSONTeamDiff) {
+				ws := plan["Workstations"]
+				wantNames(t, "Workstations modified policies", ws.Policies.Modified, "[macOS] FileVault Enabled")
+				wantNames(t, "Workstations deleted policies", ws.Policies.Deleted, "Retired policy")
+				wantNames(t, "Workstations modified scripts", ws.Scripts.Modified, "enable-desktop.ps1")
+				if len(ws.Labels.Missing) != 0 {
+					t.Errorf("missing labels: %+v", ws.Labels.Missing)
+				}
+				srv := plan["Servers"]
+				if n := len(srv.Policies.Added) + len(srv.Policies.Modified) + len(srv.Policies.Deleted); n != 0 {
+					t.Errorf("Servers policies changed: %+v", srv.Policies)
+				}
+				if _, ok := plan["(global)"]; ok {
+					t.Error("global scope diffed with --team")
+				}
+			},
+		},
+		{
+			name: "global config",
+			check: func(t *testing.T, plan map[string]output.JSONTeamDiff) {
+				found := false
+				for _, c := range plan["(global)"].Config {
+					found = found || c.Key == "org_info.org_name" && c.New == "Test Corp"
+				}
+				if !found {
+					t.Errorf("org_name change missing from global config: %+v", plan["(global)"].Config)
+				}
+			},
+		},
+		{
+			name: "no software access",
+			opts: []fleettest.Option{fleettest.WithStatus(http.StatusForbidden, "/api/v1/fleet/software/titles")},
+			args: []string{"--team", "Workstations"},
+			check: func(t *testing.T, plan map[string]output.JSONTeamDiff) {
+				access := plan["Workstations"].Access
+				if access == nil {
+					t.Fatal("no access matrix")
+				}
+				if readable, ok := access.Resources["software"]; !ok || readable {
+					t.Errorf("access = %+v, want software unavailable", access.Resources)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fleettest.NewServer(e2eState(), tt.opts...)
+			defer srv.Close()
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("FLEET_URL", srv.URL)
+			t.Setenv("FLEET_TOKEN", "tok")
+			t.Setenv("FLEET_PLAN_INSECURE", "1")
+
+			out := captureStdout(t, func() {
+				root := buildRootCmd()
+				root.SetArgs(append([]string{"--repo", filepath.Join("..", "..", "testdata"), "--format", "json", "--no-cache"}, tt.args...))
+				if err := root.Execute(); err != nil {
+					t.Errorf("fleet-plan: %v", err)
+				}
+			})
+			var parsed output.JSONDiffOutput
+			if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+				t.Fatalf("parsing plan: %v\n%s", err, out)
+			}
+			plan := make(map[string]output.JSONTeamDiff)
+			for _, team := range parsed.Teams {
+				plan[team.Team] = team
+			}
+			tt.check(t, plan)
+		})
+	}
+}
+
+func wantNames(t *testing.T, what string, changes []output.JSONChange, want ...string) {
+	t.Helper()
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Name)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s = %q, want %q", what, got, want)
+	}
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	old := os.Stdout
+	os.Stdout = f
+	fn()
+	os.Stdout = old
+	data, _ := os.ReadFile(f.Name())
+	return string(data)
+}
+
+// ---------- host command ----------
+
+func TestHostCommand(t *testing.T) {
+	// A Fleet server that knows the token but no hosts.
+	ts := fleettest.NewServer(&api.FleetState{}, fleettest.WithUser(api.User{Email: "ci@example.com", GlobalRole: ptr("observer")}))
+	defer ts.Close()
+	notFleet := httptest.NewServer(http.NotFoundHandler())
+	defer notFleet.Close()
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "identifier required",
+			args:    []string{"host"},
+			wantErr: "accepts 1 arg(s)",
+		},
+		{
+			name:    "requires auth",
+			args:    []string{"host", "C02ABC"},
+			env:     map[string]string{"FLEET_URL": "", "FLEET_TOKEN": ""},
+			wantErr: "URL required",
+		},
+		{
+			name:    "unknown host",
+			args:    []string{"host", "C02ABC"},
+			env:     map[string]string{"FLEET_URL": ts.URL, "FLEET_TOKEN": "tok", "FLEET_PLAN_INSECURE": "1"},
+			wantErr: `no host in ` + ts.URL + ` matches "C02ABC"`,
+		},
+		{
+			name:    "not a Fleet server",
+			args:    []string{"host", "C02ABC"},
+			env:     map[string]string{"FLEET_URL": notFleet.URL, "FLEET_TOKEN": "tok", "FLEET_PLAN_INSECURE": "1"},
+			wantErr: "no Fleet API at " + notFleet.URL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			root := buildRootCmd()
+			root.SetArgs(append(tt.args, "--repo", t.TempDir()))
+			err := root.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
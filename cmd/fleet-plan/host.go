@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/TsekNet/fleet-plan/internal/diff"
+	"github.com/TsekNet/fleet-plan/internal/output"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func hostCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "host <identifier>",
+		Short: "Show what the plan changes on one host (serial, hostname or UUID)",
+		Long: `Looks up a host by serial, hostname or UUID and filters the plan to the
+changes that reach it: global and team policies and queries by platform and
+labels, profiles by scope, software by labels, and team-wide config and
+scripts.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runHost,
+	}
+}
+
+func runHost(cmd *cobra.Command, args []string) error {
+	start := time.Now()
+
+	auth, err := resolveAuth()
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(flagRepo); err != nil || !info.IsDir() {
+		return fmt.Errorf("repo path %q is not a directory", flagRepo)
+	}
+
+	defaultFile, cleanup, err := resolveDefaultFile(flagRepo, flagBase, flagEnv)
+	if err != nil {
+		return err
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	client, err := newClient(cmd, auth)
+	if err != nil {
+		return err
+	}
+	defer saveTrace(client)
+	ctx, stop := signalContext()
+	defer stop()
+	ctx, cancel := planContext(ctx)
+	defer cancel()
+
+	caps, err := discoverServer(ctx, client, auth.URL)
+	if err != nil {
+		return err
+	}
+
+	host, err := client.GetHostByIdentifier(ctx, args[0])
+	if err != nil {
+		reportAPIStats(client)
+		return err
+	}
+	if host == nil {
+		return fmt.Errorf("no host in %s matches %q (serial, hostname or UUID)", auth.URL, args[0])
+	}
+
+	repo, err := parser.ParseRepo(flagRepo, nil, defaultFile)
+	if err != nil {
+		return fmt.Errorf("parsing repo: %w", err)
+	}
+	scope := diff.NewHostScope(*host, repo)
+	fmt.Fprintf(os.Stderr, "Host %s: team %s, platform %s, %d labels\n",
+		host, scope.Team, host.Platform, len(scope.Labels))
+
+	fmt.Fprintf(os.Stderr, "Fetching Fleet state from %s...\n", auth.URL)
+	teams := []string{scope.Team}
+	fetch := diff.FetchScope(repo, teams, true)
+	fetch.Capabilities = caps
+	state, err := client.FetchAll(ctx, fetch)
+	if err != nil {
+		reportAPIStats(client)
+		return err
+	}
+
+	results := diff.Diff(state, repo, teams, nil, diff.WithContext(ctx),
+		diff.WithScriptEnricher(client), diff.WithHostResolver(client), diff.WithVerbose(flagVerbose),
+		diff.WithIncludeGlobal(true), diff.WithQueryCostLimit(flagMaxQueryCost))
+	results = diff.FilterForHost(results, scope, state, repo)
+	elapsed := time.Since(start)
+
+	switch flagFormat {
+	case "json":
+		out, err := output.RenderDiffJSON(results, output.JSONOptions{ServerVersion: serverVersion(state)})
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+	case "markdown":
+		heading := flagHeading
+		if heading == "" {
+			heading = fmt.Sprintf("Planned changes for host %s", host)
+		}
+		fmt.Println(output.RenderDiffMarkdown(results, output.MarkdownOptions{Heading: heading, ServerVersion: serverVersion(state)}))
+	default:
+		fmt.Println(output.RenderDiffTerminal(results, flagVerbose))
+	}
+
+	reportAPIStats(client)
+	fmt.Fprintf(os.Stderr, "Completed in %s\n", elapsed.Round(time.Millisecond))
+
+	if diff.Partial(results) {
+		return fmt.Errorf("partial plan: %s before all Fleet state was fetched", interruption(ctx))
+	}
+
+	if flagDetailedExitCode && output.HasChanges(results) {
+		saveTrace(client) // os.Exit skips deferred calls
+		os.Exit(2)
+	}
+	return nil
+}
@@ -6,10 +6,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -34,6 +37,7 @@ var (
 var (
 	flagURL              string
 	flagToken            string
+	flagContext          string
 	flagRepo             string
 	flagFormat           string
 	flagNoColor          bool
@@ -41,6 +45,26 @@ var (
 	flagTeams            []string
 	flagHeading          string
 	flagDetailedExitCode bool
+	flagMaxQueryCost     int
+	flagTimeout          time.Duration
+
+	// API client tuning; unset values fall back to the config file, then defaults.
+	flagConcurrency    int
+	flagRequestTimeout time.Duration
+	flagRetries        int
+
+	// TLS for private PKI and mTLS; unset values fall back to the config file.
+	flagCACert     string
+	flagClientCert string
+	flagClientKey  string
+
+	// HTTP trace recording and replay, for bug reports and fixtures.
+	flagTraceHTTP string
+	flagReplay    string
+
+	// On-disk cache for slow-changing Fleet responses.
+	flagNoCache  bool
+	flagCacheDir string
 
 	// --git mode flags.
 	flagGit  bool
@@ -64,6 +88,7 @@ Strictly read-only -- GET requests only.`,
 	pf := root.PersistentFlags()
 	pf.StringVar(&flagURL, "url", "", "Fleet server URL (or $FLEET_URL)")
 	pf.StringVar(&flagToken, "token", "", "API token (or $FLEET_TOKEN)")
+	pf.StringVar(&flagContext, "context", "", "config file context to use instead of default_context (also reads fleetctl's ~/.fleet/config)")
 	pf.StringVar(&flagRepo, "repo", ".", "path to fleet-gitops repo")
 	pf.StringVarP(&flagFormat, "format", "f", "terminal", "output format: terminal, json, markdown")
 	pf.BoolVar(&flagNoColor, "no-color", false, "disable color output")
@@ -71,6 +96,19 @@ Strictly read-only -- GET requests only.`,
 	pf.StringSliceVar(&flagTeams, "team", nil, "diff only these teams (repeatable, default: all)")
 	pf.StringVar(&flagHeading, "heading", "", "## heading for markdown output")
 	pf.BoolVar(&flagDetailedExitCode, "detailed-exitcodes", false, "exit 2 when changes detected (0=no changes, 1=error, 2=changes)")
+	pf.IntVar(&flagMaxQueryCost, "max-query-cost", 0, fmt.Sprintf("fail when an added or modified scheduled query's cost score exceeds this (default: warn above %d)", diff.DefaultQueryCostLimit))
+	pf.DurationVar(&flagTimeout, "timeout", 0, "time budget for fetching and diffing; when it runs out a partial plan is printed (default: none)")
+
+	pf.IntVar(&flagConcurrency, "concurrency", 0, fmt.Sprintf("parallel Fleet API requests (default %d)", api.DefaultConcurrency))
+	pf.DurationVar(&flagRequestTimeout, "request-timeout", 0, fmt.Sprintf("timeout per Fleet API request (default %s)", api.DefaultTimeout))
+	pf.IntVar(&flagRetries, "retries", api.DefaultRetries, "retries per Fleet API request on 429, 5xx or dropped connections (0 disables)")
+	pf.StringVar(&flagCACert, "ca-cert", "", "PEM CA bundle to trust for the Fleet server, in addition to system roots")
+	pf.StringVar(&flagClientCert, "client-cert", "", "PEM client certificate for mTLS (use with --client-key)")
+	pf.StringVar(&flagClientKey, "client-key", "", "PEM private key for --client-cert")
+	pf.StringVar(&flagTraceHTTP, "trace-http", "", "record every Fleet API request and response to this HAR file (token redacted)")
+	pf.StringVar(&flagReplay, "replay", "", "answer Fleet API requests from a --trace-http recording instead of the server")
+	pf.BoolVar(&flagNoCache, "no-cache", false, "fetch everything from Fleet even when --cache-dir or the \"cache\" config key is set")
+	pf.StringVar(&flagCacheDir, "cache-dir", "", "cache catalog, label and software title responses in this directory (the \"cache\" config key uses fleet-plan in the user cache directory)")
 
 	// --git mode.
 	pf.BoolVar(&flagGit, "git", false, "enable CI mode: auto-detect changed files, infer affected teams, post MR/PR comment")
@@ -78,6 +116,7 @@ Strictly read-only -- GET requests only.`,
 	pf.StringVar(&flagEnv, "env", "", "path to environment overlay YAML, merged with --base in-memory")
 
 	root.AddCommand(versionCmd())
+	root.AddCommand(hostCmd())
 
 	return root
 }
@@ -85,7 +124,14 @@ Strictly read-only -- GET requests only.`,
 func runDiff(cmd *cobra.Command, _ []string) error {
 	start := time.Now()
 
-	auth, err := config.ResolveAuth(flagURL, flagToken, flagRepo)
+	// ctx ends on Ctrl-C or SIGTERM; planCtx also ends at --timeout. Posting
+	// the MR comment uses ctx, so a timed-out plan is still posted.
+	ctx, stop := signalContext()
+	defer stop()
+	planCtx, cancel := planContext(ctx)
+	defer cancel()
+
+	auth, err := resolveAuth()
 	if err != nil {
 		return err
 	}
@@ -115,7 +161,7 @@ func runDiff(cmd *cobra.Command, _ []string) error {
 
 	if flagGit {
 		ci = git.Detect()
-		resolved, skip := resolveCIScope(ci, flagRepo, flagEnv, &defaultFile, teams)
+		resolved, skip := resolveCIScope(planCtx, ci, flagRepo, flagEnv, &defaultFile, teams)
 		if skip {
 			return nil
 		}
@@ -156,20 +202,29 @@ func runDiff(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
-	client, err := api.NewClient(auth.URL, auth.Token)
+	client, err := newClient(cmd, auth)
+	if err != nil {
+		return err
+	}
+	defer saveTrace(client)
+
+	caps, err := discoverServer(planCtx, client, auth.URL)
 	if err != nil {
 		return err
 	}
-	ctx := context.Background()
 
 	fmt.Fprintf(os.Stderr, "Fetching Fleet state from %s...\n", auth.URL)
 
-	state, err := client.FetchAll(ctx, repo.Global != nil)
+	scope := diff.FetchScope(repo, teams, includeGlobal)
+	scope.Capabilities = caps
+	state, err := client.FetchAll(planCtx, scope)
 	if err != nil {
+		reportAPIStats(client)
 		return err
 	}
 
-	diffOpts := []diff.DiffOption{diff.WithScriptEnricher(client), diff.WithVerbose(flagVerbose), diff.WithIncludeGlobal(includeGlobal)}
+	diffOpts := []diff.DiffOption{diff.WithContext(planCtx), diff.WithScriptEnricher(client), diff.WithHostResolver(client),
+		diff.WithVerbose(flagVerbose), diff.WithIncludeGlobal(includeGlobal), diff.WithQueryCostLimit(flagMaxQueryCost)}
 	if baseline != nil {
 		diffOpts = append(diffOpts, diff.WithBaseline(baseline))
 	}
@@ -182,7 +237,7 @@ func runDiff(cmd *cobra.Command, _ []string) error {
 
 	switch flagFormat {
 	case "json":
-		out, err := output.RenderDiffJSON(results)
+		out, err := output.RenderDiffJSON(results, output.JSONOptions{ServerVersion: serverVersion(state)})
 		if err != nil {
 			return err
 		}
@@ -197,11 +252,13 @@ func runDiff(cmd *cobra.Command, _ []string) error {
 			Heading: heading,
 			Marker:  marker,
 			JobURL:  ci.JobURL(),
+
+			ServerVersion: serverVersion(state),
 		})
 		fmt.Println(mdBody)
 
 		if flagGit && ci.Platform != git.PlatformUnknown {
-			commentURL, err := ci.PostOrUpdateComment(mdBody, marker)
+			commentURL, err := ci.PostOrUpdateComment(ctx, mdBody, marker)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: could not post MR comment: %v\n", err)
 				fmt.Fprintln(os.Stderr, "The diff is printed above.")
@@ -213,15 +270,185 @@ func runDiff(cmd *cobra.Command, _ []string) error {
 		fmt.Println(output.RenderDiffTerminal(results, flagVerbose))
 	}
 
+	reportAPIStats(client)
 	fmt.Fprintf(os.Stderr, "Completed in %s\n", elapsed.Round(time.Millisecond))
 
+	if diff.Partial(results) {
+		return fmt.Errorf("partial plan: %s before all Fleet state was fetched", interruption(planCtx))
+	}
+
+	if flagMaxQueryCost > 0 {
+		if over := diff.QueriesOverCost(results, flagMaxQueryCost); len(over) > 0 {
+			return fmt.Errorf("%d scheduled queries exceed --max-query-cost %d:\n  %s",
+				len(over), flagMaxQueryCost, strings.Join(over, "\n  "))
+		}
+	}
+
 	if flagDetailedExitCode && hasChanges {
+		saveTrace(client) // os.Exit skips deferred calls
 		os.Exit(2)
 	}
 
 	return nil
 }
 
+// resolveAuth resolves the Fleet URL and token. With --replay no request
+// leaves the machine, so neither is required: the URL defaults to the server
+// the trace was recorded against.
+func resolveAuth() (*config.ResolvedAuth, error) {
+	if flagReplay == "" {
+		return config.ResolveAuthContext(flagURL, flagToken, flagContext, flagRepo)
+	}
+	trace, err := api.LoadTrace(flagReplay)
+	if err != nil {
+		return nil, err
+	}
+	auth := &config.ResolvedAuth{URL: flagURL, Token: "replay"}
+	if auth.URL == "" {
+		auth.URL = trace.BaseURL()
+	}
+	return auth, nil
+}
+
+// newClient creates the Fleet API client with concurrency, timeout, retry
+// and TLS settings from flags, falling back to the config file. The response
+// cache is opt-in, with --cache-dir or the "cache" config key, and --no-cache
+// overrides both.
+func newClient(cmd *cobra.Command, auth *config.ResolvedAuth) (*api.Client, error) {
+	flags := config.ClientSettings{Concurrency: flagConcurrency, Timeout: flagRequestTimeout}
+	if cmd.Flags().Changed("retries") {
+		flags.Retries = &flagRetries
+	}
+	settings, err := config.ResolveClientSettings(flags, flagRepo)
+	if err != nil {
+		return nil, err
+	}
+	tlsFiles, err := config.ResolveTLS(config.TLSSettings{
+		CACert: flagCACert, ClientCert: flagClientCert, ClientKey: flagClientKey,
+	}, auth)
+	if err != nil {
+		return nil, err
+	}
+	opts := []api.Option{
+		api.WithConcurrency(settings.Concurrency),
+		api.WithTimeout(settings.Timeout),
+		api.WithTLS(api.TLSFiles(tlsFiles)),
+	}
+	if settings.Retries != nil {
+		opts = append(opts, api.WithRetries(*settings.Retries))
+	}
+	switch {
+	case flagReplay != "" && flagTraceHTTP != "":
+		return nil, fmt.Errorf("--trace-http and --replay cannot be combined")
+	case flagReplay != "":
+		trace, err := api.LoadTrace(flagReplay)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, api.WithReplay(trace))
+	case flagTraceHTTP != "":
+		opts = append(opts, api.WithTrace())
+	}
+	if (flagCacheDir != "" || settings.Cache) && !flagNoCache && flagReplay == "" {
+		dir := flagCacheDir
+		if dir == "" {
+			if dir, err = api.DefaultCacheDir(); err != nil {
+				return nil, fmt.Errorf("%w (use --cache-dir or --no-cache)", err)
+			}
+		}
+		opts = append(opts, api.WithCache(dir))
+	}
+	return api.NewClient(auth.URL, auth.Token, opts...)
+}
+
+// saveTrace writes the --trace-http recording, if one was requested. It runs
+// on failures too, since that is when a trace is most useful.
+func saveTrace(client *api.Client) {
+	if flagTraceHTTP == "" {
+		return
+	}
+	n, err := client.SaveTrace(flagTraceHTTP, version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "HTTP trace: %d requests written to %s\n", n, flagTraceHTTP)
+}
+
+// discoverServer asks Fleet which user the token belongs to, so FetchAll can
+// skip what its roles can't read, and which release the server runs, so the
+// client picks matching endpoints. The /me call also catches a wrong --url
+// before its 404s are mistaken for missing permissions. A failed version
+// check is only a warning.
+func discoverServer(ctx context.Context, client *api.Client, url string) (*api.Capabilities, error) {
+	caps, err := client.GetCapabilities(ctx)
+	if err != nil {
+		reportAPIStats(client)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s before Fleet answered: %w", interruption(ctx), err)
+		}
+		return nil, err
+	}
+	version, err := client.DetectVersion(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; assuming the latest Fleet release\n", err)
+	}
+	role := "global role " + caps.GlobalRole
+	if caps.GlobalRole == "" {
+		role = fmt.Sprintf("team roles on %d teams", len(caps.TeamRoles))
+	}
+	fmt.Fprintf(os.Stderr, "Authenticated to %s (Fleet %s) as %s (%s)\n", url, version, caps.User, role)
+	return caps, nil
+}
+
+// signalContext returns a context cancelled by the first Ctrl-C or SIGTERM,
+// so in-flight requests stop and a partial plan is printed. A second signal
+// gets the default behavior and kills the process.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// planContext bounds fetching and diffing by --timeout, if set.
+func planContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if flagTimeout > 0 {
+		return context.WithTimeout(ctx, flagTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// interruption says why ctx ended, for errors about the work it cut short.
+func interruption(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("--timeout %s reached", flagTimeout)
+	}
+	return "interrupted"
+}
+
+// serverVersion returns the Fleet release to record in output, or "" if it
+// wasn't detected.
+func serverVersion(state *api.FleetState) string {
+	if state.ServerVersion == (api.ServerVersion{}) {
+		return ""
+	}
+	return state.ServerVersion.String()
+}
+
+// reportAPIStats prints one-line summaries of API retries and cache use to
+// stderr, if any.
+func reportAPIStats(client *api.Client) {
+	if summary := client.RetryStats().String(); summary != "" {
+		fmt.Fprintf(os.Stderr, "Fleet API: %s\n", summary)
+	}
+	if summary := client.CacheStats().String(); summary != "" {
+		fmt.Fprintf(os.Stderr, "Fleet API cache: %s\n", summary)
+	}
+}
+
 // resolveDefaultFile returns the path to default.yml to pass to ParseRepo.
 // If base+env are provided, they are merged into a temp file and a cleanup
 // func is returned to delete it. If neither is provided, returns empty string
@@ -304,13 +531,13 @@ func buildHeading(fleetURL string) string {
 // affected teams. Returns the scope and whether the caller should skip the diff
 // (no fleet-relevant files changed). Updates defaultFile in place if global
 // config is affected and no default was explicitly provided.
-func resolveCIScope(ci git.Env, repo, envFile string, defaultFile *string, explicitTeams []string) (git.Scope, bool) {
+func resolveCIScope(ctx context.Context, ci git.Env, repo, envFile string, defaultFile *string, explicitTeams []string) (git.Scope, bool) {
 	if ci.Platform == git.PlatformUnknown {
 		fmt.Fprintln(os.Stderr, "Warning: --git specified but no CI MR/PR context detected; running full diff")
 		return git.Scope{}, false
 	}
 
-	files, err := ci.ChangedFiles()
+	files, err := ci.ChangedFiles(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: could not determine changed files (%v); running full diff\n", err)
 		return git.Scope{}, false
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheTTLs lists the endpoints whose responses are cached and how long a
+// cached response is served without asking Fleet. "{id}" matches one path
+// segment. Everything else, including the state the plan diffs against,
+// is always fetched.
+var cacheTTLs = []struct {
+	path string
+	ttl  time.Duration
+}{
+	{"/api/v1/fleet/software/fleet_maintained_apps", 24 * time.Hour}, // catalog, updated upstream daily at most
+	{"/api/v1/fleet/version", time.Hour},
+	{"/api/v1/fleet/software/titles/{id}", 10 * time.Minute},
+	{"/api/v1/fleet/labels", 5 * time.Minute},
+}
+
+// cacheMaxAge is how long an entry is kept on disk after it was last
+// stored. Older entries are removed when a cache is opened.
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// DefaultCacheDir returns the directory WithCache uses by default:
+// fleet-plan under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locating cache directory: %w", err)
+	}
+	return filepath.Join(dir, "fleet-plan"), nil
+}
+
+// WithCache caches responses from slow-changing endpoints (the
+// fleet-maintained app catalog, labels, software title details and the
+// server version) in dir. Entries are keyed by server URL, endpoint and a
+// hash of the token, and served until their endpoint's TTL runs out; after
+// that they are revalidated with If-None-Match or If-Modified-Since when
+// Fleet sent an ETag or Last-Modified header. The directory is created by
+// NewClient.
+func WithCache(dir string) Option {
+	return func(c *Client) {
+		c.cache = &responseCache{dir: dir, now: time.Now}
+	}
+}
+
+// CacheStats summarizes how cacheable requests were answered.
+type CacheStats struct {
+	Hits        int // served from disk without a request
+	Revalidated int // confirmed unchanged by a 304 Not Modified
+	Misses      int // fetched in full and stored
+}
+
+// String renders the stats for a one-line stderr summary, e.g.
+// "12 hits, 1 revalidated, 3 misses". It returns "" when no
+// cacheable request was made.
+func (s CacheStats) String() string {
+	if s == (CacheStats{}) {
+		return ""
+	}
+	return fmt.Sprintf("%d hits, %d revalidated, %d misses", s.Hits, s.Revalidated, s.Misses)
+}
+
+// CacheStats returns how cacheable requests were answered so far. It is
+// zero when WithCache wasn't set.
+func (c *Client) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	return c.cache.stats
+}
+
+// responseCache is the on-disk store behind cachingTransport.
+type responseCache struct {
+	dir     string
+	tokenID string           // hash of the API token, never the token itself
+	now     func() time.Time // replaced in tests
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+// cacheEntry is one stored response.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Stored       time.Time `json:"stored"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+// open creates the cache directory and removes entries older than
+// cacheMaxAge.
+func (rc *responseCache) open(token string) error {
+	sum := sha256.Sum256([]byte(token))
+	rc.tokenID = hex.EncodeToString(sum[:])
+	// Entries hold org data such as label queries; keep them private.
+	if err := os.MkdirAll(rc.dir, 0o700); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(rc.dir, "*.json"))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && rc.now().Sub(info.ModTime()) > cacheMaxAge {
+			os.Remove(f)
+		}
+	}
+	return nil
+}
+
+// cacheTTL returns how long responses for path may be served from the
+// cache, or 0 if they aren't cached.
+func cacheTTL(path string) time.Duration {
+	for _, e := range cacheTTLs {
+		if matchCachePath(e.path, path) {
+			return e.ttl
+		}
+	}
+	return 0
+}
+
+func matchCachePath(pattern, path string) bool {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] && !(want[i] == "{id}" && got[i] != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// file returns the entry path for a request URL. The token hash is part of
+// the key, so tokens with different roles never share responses.
+func (rc *responseCache) file(url string) string {
+	sum := sha256.Sum256([]byte(rc.tokenID + "\n" + url))
+	return filepath.Join(rc.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the stored entry for url. Missing or unreadable entries are
+// misses.
+func (rc *responseCache) load(url string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(rc.file(url))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.URL != url {
+		return nil, false
+	}
+	return &e, true
+}
+
+// store writes e atomically, so concurrent runs never read half an entry.
+// Failures only cost a future miss and are ignored.
+func (rc *responseCache) store(e *cacheEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(rc.dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || os.Rename(tmp.Name(), rc.file(e.URL)) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (rc *responseCache) count(field *int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	*field++
+}
+
+// cachingTransport answers cacheable GETs from a responseCache and stores
+// successful responses. Other requests pass through untouched.
+type cachingTransport struct {
+	base  http.RoundTripper
+	cache *responseCache
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ttl := cacheTTL(req.URL.Path)
+	if req.Method != http.MethodGet || ttl == 0 {
+		return t.base.RoundTrip(req)
+	}
+	rc := t.cache
+	url := req.URL.String()
+
+	cached, ok := rc.load(url)
+	if ok && rc.now().Sub(cached.Stored) < ttl {
+		rc.count(&rc.stats.Hits)
+		return cached.response(req), nil
+	}
+	if ok && (cached.ETag != "" || cached.LastModified != "") {
+		req = req.Clone(req.Context())
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		cached.Stored = rc.now()
+		rc.store(cached)
+		rc.count(&rc.stats.Revalidated)
+		return cached.response(req), nil
+	case resp.StatusCode != http.StatusOK:
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	rc.store(&cacheEntry{
+		URL:          url,
+		Stored:       rc.now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Body:         body,
+	})
+	rc.count(&rc.stats.Misses)
+	return resp, nil
+}
+
+// response rebuilds a 200 response from the entry.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	header := make(http.Header)
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if e.ETag != "" {
+		header.Set("ETag", e.ETag)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
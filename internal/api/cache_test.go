@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	const token = "s3cret-t0ken"
+
+	tests := []struct {
+		name         string
+		path         string
+		etag         string        // sent by the server, if any
+		advance      time.Duration // between the two runs
+		secondToken  string        // token of the second run; default token
+		firstStatus  int           // status of the first response; default 200
+		wantRequests int32         // requests that reached the server
+		wantRevalid  bool          // second request carried If-None-Match
+		wantStats    CacheStats    // of the second run
+	}{
+		{
+			name:         "fresh hit",
+			path:         "/api/v1/fleet/labels",
+			advance:      time.Minute,
+			wantRequests: 1,
+			wantStats:    CacheStats{Hits: 1},
+		},
+		{
+			name:         "expired without etag",
+			path:         "/api/v1/fleet/labels",
+			advance:      6 * time.Minute,
+			wantRequests: 2,
+			wantStats:    CacheStats{Misses: 1},
+		},
+		{
+			name:         "expired and revalidated",
+			path:         "/api/v1/fleet/labels",
+			etag:         `"v1"`,
+			advance:      6 * time.Minute,
+			wantRequests: 2,
+			wantRevalid:  true,
+			wantStats:    CacheStats{Revalidated: 1},
+		},
+		{
+			name:         "catalog outlives labels",
+			path:         "/api/v1/fleet/software/fleet_maintained_apps",
+			advance:      time.Hour,
+			wantRequests: 1,
+			wantStats:    CacheStats{Hits: 1},
+		},
+		{
+			name:         "title detail",
+			path:         "/api/v1/fleet/software/titles/12",
+			advance:      time.Minute,
+			wantRequests: 1,
+			wantStats:    CacheStats{Hits: 1},
+		},
+		{
+			name:         "other token",
+			path:         "/api/v1/fleet/labels",
+			secondToken:  "other-token",
+			wantRequests: 2,
+			wantStats:    CacheStats{Misses: 1},
+		},
+		{
+			name:         "errors not cached",
+			path:         "/api/v1/fleet/labels",
+			firstStatus:  http.StatusNotFound,
+			wantRequests: 2,
+			wantStats:    CacheStats{Misses: 1},
+		},
+		{
+			name:         "state never cached",
+			path:         "/api/v1/fleet/software/titles",
+			wantRequests: 2,
+		},
+		{
+			name:         "config never cached",
+			path:         "/api/v1/fleet/config",
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			var revalidated atomic.Bool
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := requests.Add(1)
+				if tt.etag != "" {
+					if r.Header.Get("If-None-Match") == tt.etag {
+						revalidated.Store(true)
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+					w.Header().Set("ETag", tt.etag)
+				}
+				if n == 1 && tt.firstStatus != 0 {
+					w.WriteHeader(tt.firstStatus)
+				}
+				w.Write([]byte(`{"labels": [{"id": 7, "name": "Canaries"}]}`))
+			}))
+			defer ts.Close()
+			t.Setenv("FLEET_PLAN_INSECURE", "1")
+
+			dir := t.TempDir()
+			clock := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+			run := func(token string) (*Client, map[string]any) {
+				c, err := NewClient(ts.URL, token, WithCache(dir), WithRetries(0))
+				if err != nil {
+					t.Fatalf("NewClient: %v", err)
+				}
+				c.cache.now = func() time.Time { return clock }
+				var got map[string]any
+				c.get(context.Background(), tt.path, nil, &got)
+				return c, got
+			}
+
+			_, first := run(token)
+			clock = clock.Add(tt.advance)
+			second := token
+			if tt.secondToken != "" {
+				second = tt.secondToken
+			}
+			c, got := run(second)
+
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", n, tt.wantRequests)
+			}
+			if revalidated.Load() != tt.wantRevalid {
+				t.Errorf("revalidated = %v, want %v", revalidated.Load(), tt.wantRevalid)
+			}
+			if s := c.CacheStats(); s != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", s, tt.wantStats)
+			}
+			if tt.firstStatus == 0 && len(got) != len(first) {
+				t.Errorf("second run decoded %v, want %v", got, first)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+			for _, f := range files {
+				data, _ := os.ReadFile(f)
+				if strings.Contains(string(data), token) {
+					t.Errorf("%s contains the API token", f)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheStatsString(t *testing.T) {
+	tests := []struct {
+		stats CacheStats
+		want  string
+	}{
+		{CacheStats{}, ""},
+		{CacheStats{Hits: 12, Revalidated: 1, Misses: 3}, "12 hits, 1 revalidated, 3 misses"},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.stats, got, tt.want)
+		}
+	}
+}
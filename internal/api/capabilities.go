@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Resource is a kind of Fleet state a plan reads.
+type Resource string
+
+const (
+	ResourceConfig     Resource = "config"
+	ResourcePolicies   Resource = "policies"
+	ResourceQueries    Resource = "queries"
+	ResourceSoftware   Resource = "software"
+	ResourceProfiles   Resource = "profiles"
+	ResourceScripts    Resource = "scripts"
+	ResourceAppStore   Resource = "app store apps"
+	ResourceLabelHosts Resource = "label hosts"
+)
+
+// GlobalResources are the resources a default.yml diff reads, in display
+// order.
+var GlobalResources = []Resource{ResourceConfig, ResourcePolicies, ResourceQueries, ResourceLabelHosts}
+
+// TeamResources are the resources a team diff reads, in display order.
+var TeamResources = []Resource{ResourcePolicies, ResourceQueries, ResourceSoftware, ResourceProfiles, ResourceScripts, ResourceAppStore}
+
+// roleDenies lists the resources each Fleet role cannot read, from Fleet's
+// role permissions. Only clear-cut denials are listed: anything else is
+// fetched, and a 403 there still marks the resource unavailable.
+var roleDenies = map[string][]Resource{
+	"maintainer":    {ResourceAppStore},
+	"observer":      {ResourceAppStore},
+	"observer_plus": {ResourceAppStore},
+	"technician":    {ResourceAppStore},
+	"gitops":        {ResourceSoftware, ResourceProfiles, ResourceLabelHosts},
+}
+
+// User mirrors GET /api/v1/fleet/me: the user an API token belongs to.
+type User struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	GlobalRole *string    `json:"global_role"` // nil for team-only users
+	APIOnly    bool       `json:"api_only"`
+	Teams      []UserTeam `json:"teams"`
+}
+
+// UserTeam is a team the user has a role on.
+type UserTeam struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type meResponse struct {
+	User User `json:"user"`
+}
+
+// GetMe fetches the token's user. Every Fleet server serves /me to any valid
+// token, so a 404 means the URL doesn't point at a Fleet server; it is
+// reported as such rather than as missing permissions.
+func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	var resp meResponse
+	if err := c.get(ctx, "/api/v1/fleet/me", nil, &resp); err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			switch httpErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("no Fleet API at %s (GET /api/v1/fleet/me returned 404); check the Fleet URL: %w", c.baseURL, err)
+			case http.StatusUnauthorized:
+				return nil, fmt.Errorf("API token rejected by %s; check the token: %w", c.baseURL, err)
+			}
+		}
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// Capabilities is what an API token may read, derived from its user's
+// global and team roles. A nil *Capabilities permits everything, so callers
+// that skip discovery keep the reactive 403 handling.
+type Capabilities struct {
+	User       string          // email, or name for users without one
+	GlobalRole string          // "" for team-only users
+	TeamRoles  map[uint]string // team ID -> role, for team-only users
+}
+
+// GetCapabilities fetches the token's user and derives its capabilities.
+func (c *Client) GetCapabilities(ctx context.Context) (*Capabilities, error) {
+	user, err := c.GetMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewCapabilities(user), nil
+}
+
+// NewCapabilities derives capabilities from a user's roles.
+func NewCapabilities(u *User) *Capabilities {
+	caps := &Capabilities{User: u.Email, TeamRoles: make(map[uint]string, len(u.Teams))}
+	if caps.User == "" {
+		caps.User = u.Name
+	}
+	if u.GlobalRole != nil {
+		caps.GlobalRole = *u.GlobalRole
+	}
+	for _, t := range u.Teams {
+		caps.TeamRoles[t.ID] = t.Role
+	}
+	return caps
+}
+
+// Role returns the token's role in a team (0 is "No team"): its global role
+// if it has one, else its role on that team, or "" if it has none. "No team"
+// is only visible to users with a global role.
+func (c *Capabilities) Role(teamID uint) string {
+	if c.GlobalRole != "" || teamID == 0 {
+		return c.GlobalRole
+	}
+	return c.TeamRoles[teamID]
+}
+
+// CanRead reports whether the token may read a resource of a team.
+func (c *Capabilities) CanRead(teamID uint, r Resource) bool {
+	if c == nil {
+		return true
+	}
+	role := c.Role(teamID)
+	return role != "" && !denies(role, r)
+}
+
+// CanReadGlobal reports whether the token may read a global resource. Fleet
+// shows team-only users the global config, policies and queries and the
+// hosts on their teams, but VPP tokens are global-role only.
+func (c *Capabilities) CanReadGlobal(r Resource) bool {
+	if c == nil {
+		return true
+	}
+	if c.GlobalRole == "" {
+		return r != ResourceAppStore
+	}
+	return !denies(c.GlobalRole, r)
+}
+
+func denies(role string, r Resource) bool {
+	for _, d := range roleDenies[role] {
+		if d == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGetMe(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantRole string
+		wantErr  string
+	}{
+		{
+			name:     "global role",
+			status:   http.StatusOK,
+			body:     `{"user": {"id": 1, "email": "ci@example.com", "global_role": "gitops", "api_only": true, "teams": []}}`,
+			wantRole: "gitops",
+		},
+		{
+			name:   "team-only user",
+			status: http.StatusOK,
+			body:   `{"user": {"id": 2, "name": "ci", "global_role": null, "teams": [{"id": 3, "name": "Servers", "role": "maintainer"}]}}`,
+		},
+		{name: "wrong URL", status: http.StatusNotFound, body: `404 page not found`, wantErr: "no Fleet API at"},
+		{name: "bad token", status: http.StatusUnauthorized, body: `{"message": "Authentication required"}`, wantErr: "API token rejected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/fleet/me" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			user, err := testClient(t, ts, "tok").GetMe(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetMe: %v", err)
+			}
+			if got := NewCapabilities(user).GlobalRole; got != tt.wantRole {
+				t.Errorf("global role = %q, want %q", got, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	role := func(r string) *string { return &r }
+	tests := []struct {
+		name       string
+		user       User
+		teamID     uint
+		resource   Resource
+		wantTeam   bool
+		wantGlobal bool
+	}{
+		{name: "admin reads everything", user: User{GlobalRole: role("admin")}, teamID: 1, resource: ResourceAppStore, wantTeam: true, wantGlobal: true},
+		{name: "maintainer can't read VPP tokens", user: User{GlobalRole: role("maintainer")}, teamID: 1, resource: ResourceAppStore},
+		{name: "gitops can't read software", user: User{GlobalRole: role("gitops")}, teamID: 1, resource: ResourceSoftware},
+		{name: "gitops can't read label hosts", user: User{GlobalRole: role("gitops")}, teamID: 1, resource: ResourceLabelHosts},
+		{name: "gitops reads scripts", user: User{GlobalRole: role("gitops")}, teamID: 1, resource: ResourceScripts, wantTeam: true, wantGlobal: true},
+		{name: "unknown role tries everything", user: User{GlobalRole: role("auditor")}, teamID: 1, resource: ResourceScripts, wantTeam: true, wantGlobal: true},
+		{
+			name:       "team role on its team",
+			user:       User{Teams: []UserTeam{{ID: 1, Role: "maintainer"}}},
+			teamID:     1,
+			resource:   ResourceScripts,
+			wantTeam:   true,
+			wantGlobal: true,
+		},
+		{
+			name:       "team role on another team",
+			user:       User{Teams: []UserTeam{{ID: 1, Role: "admin"}}},
+			teamID:     2,
+			resource:   ResourcePolicies,
+			wantGlobal: true,
+		},
+		{
+			name:     "team role can't read No team",
+			user:     User{Teams: []UserTeam{{ID: 1, Role: "admin"}}},
+			teamID:   0,
+			resource: ResourcePolicies,
+			// global policies are still visible to team users
+			wantGlobal: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := NewCapabilities(&tt.user)
+			if got := caps.CanRead(tt.teamID, tt.resource); got != tt.wantTeam {
+				t.Errorf("CanRead(%d, %s) = %v, want %v", tt.teamID, tt.resource, got, tt.wantTeam)
+			}
+			if got := caps.CanReadGlobal(tt.resource); got != tt.wantGlobal {
+				t.Errorf("CanReadGlobal(%s) = %v, want %v", tt.resource, got, tt.wantGlobal)
+			}
+		})
+	}
+
+	var nilCaps *Capabilities
+	if !nilCaps.CanRead(5, ResourceSoftware) || !nilCaps.CanReadGlobal(ResourceAppStore) {
+		t.Error("nil capabilities should permit everything")
+	}
+}
+
+func TestFetchAllCapabilities(t *testing.T) {
+	role := func(r string) *string { return &r }
+	tests := []struct {
+		name       string
+		user       User
+		wantTeams  []string
+		wantNoTeam bool
+		notWant    []string // request paths that must not be made
+		check      func(t *testing.T, state *FleetState)
+	}{
+		{
+			name:       "gitops skips software, profiles and label hosts",
+			user:       User{GlobalRole: role("gitops")},
+			wantTeams:  []string{"Workstations", "Servers"},
+			wantNoTeam: true,
+			notWant:    []string{"/api/v1/fleet/software/titles", "/api/v1/fleet/configuration_profiles", "/api/v1/fleet/labels/7/hosts"},
+			check: func(t *testing.T, state *FleetState) {
+				if ws := state.Teams[0]; !ws.SoftwareUnavailable || !ws.ProfilesUnavailable || ws.ScriptsUnavailable {
+					t.Errorf("Workstations software/profiles/scripts unavailable = %v/%v/%v, want true/true/false",
+						ws.SoftwareUnavailable, ws.ProfilesUnavailable, ws.ScriptsUnavailable)
+				}
+				if !state.Labels[0].HostsUnavailable {
+					t.Error("label hosts should be unavailable")
+				}
+			},
+		},
+		{
+			name:      "team-only user skips other teams, No team and VPP tokens",
+			user:      User{Teams: []UserTeam{{ID: 2, Name: "Servers", Role: "maintainer"}}},
+			wantTeams: []string{"Servers"},
+			notWant:   []string{"/api/v1/fleet/teams/1/policies", "/api/v1/fleet/teams/0/policies", "/api/v1/fleet/vpp_tokens"},
+			check: func(t *testing.T, state *FleetState) {
+				if !state.VPPTokensUnavailable {
+					t.Error("VPP tokens should be unavailable")
+				}
+				if state.Capabilities == nil {
+					t.Error("state.Capabilities not recorded")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			paths := make(map[string]bool)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths[r.URL.Path] = true
+				mu.Unlock()
+				switch r.URL.Path {
+				case "/api/v1/fleet/teams":
+					json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}, {ID: 2, Name: "Servers"}}})
+				case "/api/v1/fleet/labels":
+					json.NewEncoder(w).Encode(labelsResponse{Labels: []Label{{ID: 7, Name: "Canaries", LabelMembershipType: "manual"}}})
+				default:
+					w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			scope := FullFetch
+			scope.Capabilities = NewCapabilities(&tt.user)
+			state, err := testClient(t, ts, "tok").FetchAll(context.Background(), scope)
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+			var gotTeams []string
+			for _, team := range state.Teams {
+				gotTeams = append(gotTeams, team.Name)
+			}
+			if !slices.Equal(gotTeams, tt.wantTeams) {
+				t.Errorf("teams = %v, want %v", gotTeams, tt.wantTeams)
+			}
+			if (state.NoTeam != nil) != tt.wantNoTeam {
+				t.Errorf("NoTeam fetched = %v, want %v", state.NoTeam != nil, tt.wantNoTeam)
+			}
+			for _, p := range tt.notWant {
+				if paths[p] {
+					t.Errorf("unexpected request to %s", p)
+				}
+			}
+			tt.check(t, state)
+		})
+	}
+}
@@ -17,18 +17,29 @@ import (
 	"time"
 
 	"golang.org/x/sync/errgroup"
+
+	"github.com/TsekNet/fleet-plan/internal/parser"
 )
 
 // Client is a read-only Fleet REST API client.
 type Client struct {
-	baseURL    string
-	token      string
-	httpClient *http.Client
+	baseURL     string
+	token       string
+	httpClient  *http.Client
+	concurrency int           // parallel requests in FetchAll and Enrich*
+	retries     int           // retry budget per GET
+	retryBase   time.Duration // first backoff delay, doubled per retry
+	stats       retryCounter
+	tlsFiles    TLSFiles
+	trace       *traceRecorder   // set by WithTrace
+	replay      *replayTransport // set by WithReplay
+	cache       *responseCache   // set by WithCache
+	version     ServerVersion    // from DetectVersion; unknown means latest
 }
 
 // NewClient creates a new read-only Fleet API client.
 // Returns an error if the URL uses plain HTTP (token would be sent in cleartext).
-func NewClient(baseURL, token string) (*Client, error) {
+func NewClient(baseURL, token string, opts ...Option) (*Client, error) {
 	baseURL = strings.TrimRight(baseURL, "/")
 
 	parsed, err := url.Parse(baseURL)
@@ -36,6 +47,26 @@ func NewClient(baseURL, token string) (*Client, error) {
 		return nil, fmt.Errorf("invalid Fleet server URL %q: must include scheme and host", baseURL)
 	}
 
+	c := &Client{
+		baseURL: baseURL,
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: DefaultTimeout,
+		},
+		concurrency: DefaultConcurrency,
+		retries:     DefaultRetries,
+		retryBase:   500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// A replayed client never touches the network, so plain HTTP is fine.
+	if c.replay != nil {
+		c.httpClient.Transport = c.replay
+		return c, nil
+	}
+
 	insecure := os.Getenv("FLEET_PLAN_INSECURE") == "1"
 	if strings.ToLower(parsed.Scheme) == "http" {
 		if !insecure {
@@ -44,13 +75,23 @@ func NewClient(baseURL, token string) (*Client, error) {
 		fmt.Fprintf(os.Stderr, "warning: FLEET_PLAN_INSECURE=1, sending API token over plain HTTP\n")
 	}
 
-	return &Client{
-		baseURL: baseURL,
-		token:   token,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+	transport, err := newTransport(c.tlsFiles)
+	if err != nil {
+		return nil, err
+	}
+	c.httpClient.Transport = transport
+	if c.cache != nil {
+		if err := c.cache.open(c.token); err != nil {
+			return nil, err
+		}
+		c.httpClient.Transport = &cachingTransport{base: transport, cache: c.cache}
+	}
+	// Traces record what the client saw, cache hits included, so they replay
+	// the same run.
+	if c.trace != nil {
+		c.httpClient.Transport = &recordingTransport{base: c.httpClient.Transport, rec: c.trace, token: c.token}
+	}
+	return c, nil
 }
 
 // get performs a GET request and decodes JSON into dest.
@@ -67,7 +108,7 @@ func (c *Client) get(ctx context.Context, path string, query url.Values, dest an
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("request to %s: %w", path, err)
 	}
@@ -122,23 +163,40 @@ type FleetState struct {
 	Config                 map[string]any // from GET /api/v1/fleet/config
 	GlobalPolicies         []Policy       // from GET /api/v1/fleet/global/policies (teamID=0)
 	GlobalQueries          []Query        // from GET /api/v1/fleet/queries (teamID=0)
+	NoTeam                 *Team          // "No team" (team_id 0) resources; not returned by /teams
+	VPPTokens              []VPPToken     // from GET /api/v1/fleet/vpp_tokens
+	VPPTokensUnavailable   bool           // true when GetVPPTokens returned 403/404 (token lacks permission)
+	Capabilities           *Capabilities  // the token's read access, from FetchScope; nil if not discovered
+	ServerVersion          ServerVersion  // from DetectVersion; unknown if not detected
+
+	// Interrupted is the context error that cut FetchAll short, nil when it
+	// completed. NotFetched then lists the global resources it didn't get
+	// (default.yml's config, policies, queries and label hosts) and the
+	// shared ones (teams, labels, the catalog, VPP tokens as app store apps);
+	// Team.NotFetched lists each team's.
+	Interrupted error
+	NotFetched  []Resource
 }
 
 // Team represents a Fleet team with its associated resources.
 // Policies/queries/profiles are fetched via separate endpoints.
 // Managed software definitions come directly from /teams[].software.
 type Team struct {
-	ID                    uint         `json:"id"`
-	Name                  string       `json:"name"`
-	Software              TeamSoftware `json:"software"`
-	Policies              []Policy
-	Queries               []Query
-	Profiles              []Profile // populated by GetProfiles
-	Scripts               []Script  `json:"-"` // populated by GetScripts
-	SoftwareTitles        []SoftwareTitle
-	SoftwareUnavailable   bool // true when GetSoftware returned 403/404 (token lacks permission)
-	ProfilesUnavailable   bool // true when GetProfiles returned 403/404 (token lacks permission)
-	ScriptsUnavailable    bool // true when GetScripts returned 403/404 (token lacks permission)
+	ID                  uint         `json:"id"`
+	Name                string       `json:"name"`
+	Software            TeamSoftware `json:"software"`
+	Policies            []Policy
+	Queries             []Query
+	Profiles            []Profile // populated by GetProfiles
+	Scripts             []Script  `json:"-"` // populated by GetScripts
+	SoftwareTitles      []SoftwareTitle
+	VPPApps             []VPPApp   // apps licensed to the team's VPP token; populated by GetVPPApps
+	PoliciesUnavailable bool       // true when "No team" policies returned 403/404 (older Fleet servers)
+	SoftwareUnavailable bool       // true when GetSoftware returned 403/404 (token lacks permission)
+	VPPAppsUnavailable  bool       // true when the team has no VPP token or GetVPPApps returned 403/404
+	ProfilesUnavailable bool       // true when GetProfiles returned 403/404 (token lacks permission)
+	ScriptsUnavailable  bool       // true when GetScripts returned 403/404 (token lacks permission)
+	NotFetched          []Resource `json:"-"` // skipped when FetchAll was interrupted
 }
 
 // TeamSoftware mirrors /api/v1/fleet/teams[].software for managed software
@@ -168,8 +226,12 @@ type TeamFleetApp struct {
 }
 
 type TeamAppStoreApp struct {
-	AppStoreID  string `json:"app_store_id"`
-	SelfService bool   `json:"self_service"`
+	AppStoreID       string   `json:"app_store_id"`
+	SelfService      bool     `json:"self_service"`
+	Platform         string   `json:"platform"`
+	LabelsIncludeAny []string `json:"labels_include_any"`
+	LabelsExcludeAny []string `json:"labels_exclude_any"`
+	Categories       []string `json:"categories"`
 }
 
 // FleetMaintainedApp is an entry from Fleet's maintained-app catalog.
@@ -217,10 +279,61 @@ type SoftwareTitle struct {
 }
 
 // SoftwareTitleAppStore contains app-store-specific metadata for a title.
+// Labels and categories are only returned by the title detail endpoint.
 type SoftwareTitleAppStore struct {
-	AppStoreID  string `json:"app_store_id"`
-	SelfService bool   `json:"self_service"`
-	Platform    string `json:"platform"`
+	AppStoreID       string               `json:"app_store_id"`
+	SelfService      bool                 `json:"self_service"`
+	Platform         string               `json:"platform"`
+	LabelsIncludeAny []SoftwareScopeLabel `json:"labels_include_any"`
+	LabelsExcludeAny []SoftwareScopeLabel `json:"labels_exclude_any"`
+	Categories       []string             `json:"categories"`
+}
+
+// SoftwareScopeLabel is a label reference in a software title's install scope.
+type SoftwareScopeLabel struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// VPPToken is an Apple Volume Purchasing Program token and its team
+// assignment. A nil Teams list means the token is unassigned; an empty list
+// means it is available to all teams.
+type VPPToken struct {
+	ID        uint           `json:"id"`
+	OrgName   string         `json:"org_name"`
+	Location  string         `json:"location"`
+	RenewDate string         `json:"renew_date"`
+	Teams     []VPPTokenTeam `json:"teams"`
+}
+
+// VPPTokenTeam is a team a VPP token is assigned to.
+type VPPTokenTeam struct {
+	TeamID uint   `json:"team_id"`
+	Name   string `json:"name"`
+}
+
+// AssignedTo reports whether the token can be used by the given team.
+func (t VPPToken) AssignedTo(teamID uint) bool {
+	if t.Teams == nil {
+		return false
+	}
+	if len(t.Teams) == 0 {
+		return true
+	}
+	for _, team := range t.Teams {
+		if team.TeamID == teamID {
+			return true
+		}
+	}
+	return false
+}
+
+// VPPApp is an App Store app licensed to a team's VPP token.
+type VPPApp struct {
+	AppStoreID    string `json:"app_store_id"`
+	Name          string `json:"name"`
+	LatestVersion string `json:"latest_version"`
+	Platform      string `json:"platform"`
 }
 
 // SoftwareTitlePackageMeta contains package metadata for a title.
@@ -238,6 +351,7 @@ type SoftwareTitleDetail struct {
 	ID              uint                        `json:"id"`
 	Name            string                      `json:"name"`
 	SoftwarePackage *SoftwareTitleDetailPackage  `json:"software_package"`
+	AppStoreApp     *SoftwareTitleAppStore       `json:"app_store_app"`
 }
 
 // SoftwareTitleDetailPackage contains the full package metadata including scripts.
@@ -258,6 +372,60 @@ type Label struct {
 	Query     string `json:"query"`
 	Platform  string `json:"platform"`
 	HostCount uint   `json:"host_count"`
+	LabelType string `json:"label_type"` // "builtin" or "regular"
+
+	LabelMembershipType string `json:"label_membership_type"` // "dynamic" or "manual"
+	Hosts               []Host `json:"-"`                     // manual labels only; populated by GetLabelHosts
+	HostsUnavailable    bool   `json:"-"`                     // true when GetLabelHosts returned 403/404
+}
+
+// Host is a Fleet host as returned by the host and label hosts endpoints.
+type Host struct {
+	ID             uint   `json:"id"`
+	Hostname       string `json:"hostname"`
+	DisplayName    string `json:"display_name"`
+	UUID           string `json:"uuid"`
+	HardwareSerial string `json:"hardware_serial"`
+	Platform       string `json:"platform"`
+	TeamID         *uint  `json:"team_id"`
+	TeamName       string `json:"team_name"`
+
+	Labels []HostLabel `json:"labels"` // host detail endpoints only
+}
+
+// HostLabel is a label a host is a member of.
+type HostLabel struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// Matches reports whether identifier names the host by serial, hostname or
+// UUID, the identifiers fleet-gitops accepts in manual labels.
+func (h Host) Matches(identifier string) bool {
+	for _, v := range []string{h.HardwareSerial, h.Hostname, h.UUID} {
+		if v != "" && strings.EqualFold(v, identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// String names the host for display: display name (or hostname) and serial.
+func (h Host) String() string {
+	name := h.DisplayName
+	if name == "" {
+		name = h.Hostname
+	}
+	switch {
+	case name == "":
+		name = h.HardwareSerial
+	case h.HardwareSerial != "" && h.HardwareSerial != name:
+		name += " (" + h.HardwareSerial + ")"
+	}
+	if name == "" {
+		name = h.UUID
+	}
+	return name
 }
 
 // Profile represents an MDM configuration profile.
@@ -265,6 +433,7 @@ type Profile struct {
 	ProfileUUID string `json:"profile_uuid"`
 	Name        string `json:"name"`
 	Platform    string `json:"platform"`
+	Content     string `json:"-"` // populated by EnrichProfileContents (Windows only)
 }
 
 // Script represents a Fleet script assigned to a team.
@@ -300,6 +469,14 @@ type labelsResponse struct {
 	Labels []Label `json:"labels"`
 }
 
+type hostsResponse struct {
+	Hosts []Host `json:"hosts"`
+}
+
+type hostResponse struct {
+	Host Host `json:"host"`
+}
+
 type profilesResponse struct {
 	Profiles []Profile `json:"profiles"`
 	Meta     struct {
@@ -314,6 +491,14 @@ type scriptsResponse struct {
 	} `json:"meta"`
 }
 
+type vppTokensResponse struct {
+	VPPTokens []VPPToken `json:"vpp_tokens"`
+}
+
+type vppAppsResponse struct {
+	AppStoreApps []VPPApp `json:"app_store_apps"`
+}
+
 type fleetMaintainedAppsResponse struct {
 	FleetMaintainedApps []FleetMaintainedApp `json:"fleet_maintained_apps"`
 	Meta                struct {
@@ -359,10 +544,21 @@ func (c *Client) GetTeams(ctx context.Context) ([]Team, error) {
 
 // GetPolicies fetches policies for a team (0 = global) with pagination.
 func (c *Client) GetPolicies(ctx context.Context, teamID uint) ([]Policy, error) {
-	apiPath := "/api/v1/fleet/global/policies"
 	if teamID > 0 {
-		apiPath = fmt.Sprintf("/api/v1/fleet/teams/%d/policies", teamID)
+		return c.getPolicies(ctx, fmt.Sprintf("/api/v1/fleet/teams/%d/policies", teamID), fmt.Sprintf("team %d", teamID))
 	}
+	return c.getPolicies(ctx, "/api/v1/fleet/global/policies", "team 0")
+}
+
+// GetNoTeamPolicies fetches policies for hosts not assigned to any team.
+// GetPolicies(ctx, 0) returns global policies instead.
+func (c *Client) GetNoTeamPolicies(ctx context.Context) ([]Policy, error) {
+	return c.getPolicies(ctx, "/api/v1/fleet/teams/0/policies", "no team")
+}
+
+// getPolicies fetches all pages of a policies endpoint; scope names it in
+// errors.
+func (c *Client) getPolicies(ctx context.Context, apiPath, scope string) ([]Policy, error) {
 	var all []Policy
 	page := 0
 	for {
@@ -372,7 +568,7 @@ func (c *Client) GetPolicies(ctx context.Context, teamID uint) ([]Policy, error)
 		}
 		var resp policiesResponse
 		if err := c.get(ctx, apiPath, q, &resp); err != nil {
-			return nil, fmt.Errorf("fetching policies (team %d): %w", teamID, err)
+			return nil, fmt.Errorf("fetching policies (%s): %w", scope, err)
 		}
 		all = append(all, resp.Policies...)
 		if len(resp.Policies) < 250 {
@@ -414,7 +610,8 @@ func (c *Client) GetQueries(ctx context.Context, teamID uint) ([]Query, error) {
 	return all, nil
 }
 
-// GetSoftware fetches managed (available_for_install) software titles for a team.
+// GetSoftware fetches managed (available_for_install) software titles for a team
+// (0 = "No team").
 // Uses available_for_install=true to exclude detected-only titles (OS packages,
 // browser extensions, etc.) and only return software deployed via Fleet/GitOps.
 // Paginates to collect all results.
@@ -426,9 +623,7 @@ func (c *Client) GetSoftware(ctx context.Context, teamID uint) ([]SoftwareTitle,
 			"per_page":              {"250"},
 			"page":                  {strconv.Itoa(page)},
 			"available_for_install": {"true"},
-		}
-		if teamID > 0 {
-			q.Set("team_id", strconv.FormatUint(uint64(teamID), 10))
+			"team_id":               {strconv.FormatUint(uint64(teamID), 10)},
 		}
 		var resp softwareResponse
 		if err := c.get(ctx, "/api/v1/fleet/software/titles", q, &resp); err != nil {
@@ -447,13 +642,11 @@ func (c *Client) GetSoftware(ctx context.Context, teamID uint) ([]SoftwareTitle,
 }
 
 // GetSoftwareTitleDetail fetches the full detail for a single software title,
-// including script content not available in the list endpoint.
+// including script content not available in the list endpoint. teamID 0
+// returns the "No team" installer.
 func (c *Client) GetSoftwareTitleDetail(ctx context.Context, titleID, teamID uint) (*SoftwareTitleDetail, error) {
 	apiPath := fmt.Sprintf("/api/v1/fleet/software/titles/%d", titleID)
-	q := url.Values{}
-	if teamID > 0 {
-		q.Set("team_id", strconv.FormatUint(uint64(teamID), 10))
-	}
+	q := url.Values{"team_id": {strconv.FormatUint(uint64(teamID), 10)}}
 	var resp struct {
 		SoftwareTitle SoftwareTitleDetail `json:"software_title"`
 	}
@@ -467,7 +660,7 @@ func (c *Client) GetSoftwareTitleDetail(ctx context.Context, titleID, teamID uin
 // and populates their script content. Errors are non-fatal (scripts stay empty).
 func (c *Client) EnrichFleetAppScripts(ctx context.Context, apps []TeamFleetApp) {
 	g, gctx := errgroup.WithContext(ctx)
-	g.SetLimit(5)
+	g.SetLimit(c.concurrency)
 	for i := range apps {
 		if apps[i].TitleID == 0 {
 			continue
@@ -488,6 +681,49 @@ func (c *Client) EnrichFleetAppScripts(ctx context.Context, apps []TeamFleetApp)
 	g.Wait()
 }
 
+// EnrichAppStoreTitles fetches title details for App Store titles and replaces
+// their list metadata with the detail version, which includes label scopes
+// and categories. Errors are non-fatal (list metadata is kept).
+func (c *Client) EnrichAppStoreTitles(ctx context.Context, titles []SoftwareTitle, teamID uint) {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+	for i := range titles {
+		if titles[i].AppStoreApp == nil || titles[i].ID == 0 {
+			continue
+		}
+		idx := i
+		g.Go(func() error {
+			detail, err := c.GetSoftwareTitleDetail(gctx, titles[idx].ID, teamID)
+			if err != nil || detail.AppStoreApp == nil {
+				return nil
+			}
+			titles[idx].AppStoreApp = detail.AppStoreApp
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// GetVPPTokens fetches the VPP tokens and their team assignments.
+func (c *Client) GetVPPTokens(ctx context.Context) ([]VPPToken, error) {
+	var resp vppTokensResponse
+	if err := c.get(ctx, "/api/v1/fleet/vpp_tokens", nil, &resp); err != nil {
+		return nil, fmt.Errorf("fetching VPP tokens: %w", err)
+	}
+	return resp.VPPTokens, nil
+}
+
+// GetVPPApps fetches the App Store apps licensed to the VPP token assigned to
+// a team (0 = "No team").
+func (c *Client) GetVPPApps(ctx context.Context, teamID uint) ([]VPPApp, error) {
+	q := url.Values{"team_id": {strconv.FormatUint(uint64(teamID), 10)}}
+	var resp vppAppsResponse
+	if err := c.get(ctx, "/api/v1/fleet/software/app_store_apps", q, &resp); err != nil {
+		return nil, fmt.Errorf("fetching VPP apps (team %d): %w", teamID, err)
+	}
+	return resp.AppStoreApps, nil
+}
+
 // GetFleetMaintainedApps fetches Fleet's maintained-app catalog.
 func (c *Client) GetFleetMaintainedApps(ctx context.Context) ([]FleetMaintainedApp, error) {
 	var all []FleetMaintainedApp
@@ -539,8 +775,89 @@ func (c *Client) GetLabels(ctx context.Context) ([]Label, error) {
 	return all, nil
 }
 
-// GetProfiles fetches MDM profiles for a team with pagination.
+// GetLabelHosts fetches the hosts that are members of a label, with
+// pagination.
+func (c *Client) GetLabelHosts(ctx context.Context, labelID uint) ([]Host, error) {
+	var all []Host
+	page := 0
+	for {
+		q := url.Values{
+			"per_page": {"250"},
+			"page":     {strconv.Itoa(page)},
+		}
+		var resp hostsResponse
+		path := fmt.Sprintf("/api/v1/fleet/labels/%d/hosts", labelID)
+		if err := c.get(ctx, path, q, &resp); err != nil {
+			return nil, fmt.Errorf("fetching label %d hosts: %w", labelID, err)
+		}
+		all = append(all, resp.Hosts...)
+		if len(resp.Hosts) < 250 {
+			break
+		}
+		page++
+		if page > 400 { // safety: max 100k hosts
+			break
+		}
+	}
+	return all, nil
+}
+
+// GetHostByIdentifier looks up a host by serial, hostname, UUID or osquery
+// host ID. Returns nil, nil when no host matches (HTTP 404).
+func (c *Client) GetHostByIdentifier(ctx context.Context, identifier string) (*Host, error) {
+	var resp hostResponse
+	path := "/api/v1/fleet/hosts/identifier/" + url.PathEscape(identifier)
+	if err := c.get(ctx, path, nil, &resp); err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fetching host %q: %w", identifier, err)
+	}
+	return &resp.Host, nil
+}
+
+// HostLookup is the result of looking up one host identifier. Host is nil
+// when no host matches.
+type HostLookup struct {
+	Host *Host
+	Err  error
+}
+
+// GetHostsByIdentifier looks up each identifier with GetHostByIdentifier in
+// parallel, keyed by identifier. Lookup failures are reported per
+// identifier; the only error returned is ctx's, if it ends first.
+func (c *Client) GetHostsByIdentifier(ctx context.Context, identifiers []string) (map[string]HostLookup, error) {
+	lookups := make([]HostLookup, len(identifiers))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+	for i := range identifiers {
+		idx := i
+		g.Go(func() error {
+			host, err := c.GetHostByIdentifier(gctx, identifiers[idx])
+			lookups[idx] = HostLookup{Host: host, Err: err}
+			return nil
+		})
+	}
+	g.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]HostLookup, len(identifiers))
+	for i, id := range identifiers {
+		byID[id] = lookups[i]
+	}
+	return byID, nil
+}
+
+// GetProfiles fetches MDM profiles for a team (0 = "No team") with pagination.
+// Servers older than FeatureConfigurationProfiles only have macOS profiles,
+// at a legacy endpoint.
 func (c *Client) GetProfiles(ctx context.Context, teamID uint) ([]Profile, error) {
+	if !c.version.Supports(FeatureConfigurationProfiles) {
+		return c.getAppleProfiles(ctx, teamID)
+	}
 	var all []Profile
 	page := 0
 	for {
@@ -567,7 +884,38 @@ func (c *Client) GetProfiles(ctx context.Context, teamID uint) ([]Profile, error
 	return all, nil
 }
 
-// GetScripts fetches scripts for a team with pagination.
+// legacyProfile is a profile from /api/v1/fleet/mdm/apple/profiles, which
+// identifies profiles by a numeric profile_id instead of profile_uuid.
+type legacyProfile struct {
+	ProfileID uint   `json:"profile_id"`
+	Name      string `json:"name"`
+}
+
+// getAppleProfiles fetches a team's macOS profiles from the pre-4.41
+// endpoint, which isn't paginated, and maps them to Profile.
+func (c *Client) getAppleProfiles(ctx context.Context, teamID uint) ([]Profile, error) {
+	q := url.Values{}
+	if teamID > 0 {
+		q.Set("team_id", strconv.FormatUint(uint64(teamID), 10))
+	}
+	var resp struct {
+		Profiles []legacyProfile `json:"profiles"`
+	}
+	if err := c.get(ctx, "/api/v1/fleet/mdm/apple/profiles", q, &resp); err != nil {
+		return nil, fmt.Errorf("fetching profiles (team %d): %w", teamID, err)
+	}
+	profiles := make([]Profile, 0, len(resp.Profiles))
+	for _, p := range resp.Profiles {
+		profiles = append(profiles, Profile{
+			ProfileUUID: strconv.FormatUint(uint64(p.ProfileID), 10),
+			Name:        p.Name,
+			Platform:    "darwin",
+		})
+	}
+	return profiles, nil
+}
+
+// GetScripts fetches scripts for a team (0 = "No team") with pagination.
 func (c *Client) GetScripts(ctx context.Context, teamID uint) ([]Script, error) {
 	var all []Script
 	page := 0
@@ -600,7 +948,7 @@ func (c *Client) GetScripts(ctx context.Context, teamID uint) ([]Script, error)
 // Errors are non-fatal (content stays empty).
 func (c *Client) EnrichScriptContents(ctx context.Context, scripts []Script) {
 	g, gctx := errgroup.WithContext(ctx)
-	g.SetLimit(5)
+	g.SetLimit(c.concurrency)
 	for i := range scripts {
 		if scripts[i].ID == 0 {
 			continue
@@ -620,21 +968,56 @@ func (c *Client) EnrichScriptContents(ctx context.Context, scripts []Script) {
 
 // getScriptContent downloads script content via GET /api/v1/fleet/scripts/:id?alt=media.
 func (c *Client) getScriptContent(ctx context.Context, scriptID uint) (string, error) {
-	u := fmt.Sprintf("%s/api/v1/fleet/scripts/%d?alt=media", c.baseURL, scriptID)
+	return c.download(ctx, fmt.Sprintf("/api/v1/fleet/scripts/%d", scriptID))
+}
+
+// EnrichProfileContents downloads the raw content of each Windows profile and
+// populates the Content field, so the diff engine can compare CSP items.
+// Apple profiles are skipped (Fleet may return them signed). Errors are
+// non-fatal (content stays empty).
+func (c *Client) EnrichProfileContents(ctx context.Context, profiles []Profile) {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+	for i := range profiles {
+		if profiles[i].ProfileUUID == "" || profiles[i].Platform != "windows" {
+			continue
+		}
+		idx := i
+		g.Go(func() error {
+			content, err := c.getProfileContent(gctx, profiles[idx].ProfileUUID)
+			if err != nil {
+				return nil // non-fatal
+			}
+			profiles[idx].Content = content
+			return nil
+		})
+	}
+	g.Wait()
+}
+
+// getProfileContent downloads profile content via
+// GET /api/v1/fleet/configuration_profiles/:uuid?alt=media.
+func (c *Client) getProfileContent(ctx context.Context, profileUUID string) (string, error) {
+	return c.download(ctx, "/api/v1/fleet/configuration_profiles/"+url.PathEscape(profileUUID))
+}
+
+// download fetches a raw file body via GET <path>?alt=media (1MB limit).
+func (c *Client) download(ctx context.Context, path string) (string, error) {
+	u := c.baseURL + path + "?alt=media"
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d fetching script %d", resp.StatusCode, scriptID)
+		return "", fmt.Errorf("HTTP %d downloading %s", resp.StatusCode, path)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1MB limit
@@ -644,36 +1027,103 @@ func (c *Client) getScriptContent(ctx context.Context, scriptID uint) (string, e
 	return string(body), nil
 }
 
-// FetchAll concurrently fetches the complete Fleet state. Uses errgroup for
-// parallel requests. If fetchGlobal is true, also fetches global config,
-// policies, and queries (for default.yml diffing).
-func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState, error) {
+// FetchScope limits what FetchAll downloads, so a plan scoped to one team
+// doesn't pay for the whole fleet. Teams (IDs), labels and VPP tokens are
+// always fetched.
+type FetchScope struct {
+	AllTeams   bool     // every team and "No team"; overrides Teams
+	Teams      []string // names of teams to fetch, case-insensitive; "No team" by name
+	Global     bool     // global config, policies and queries (default.yml)
+	Catalog    bool     // fleet-maintained app catalog
+	LabelHosts bool     // current members of manual labels
+
+	// Capabilities, when set, skips fetches the token's roles can't perform
+	// and marks those resources unavailable up front.
+	Capabilities *Capabilities
+}
+
+// FullFetch fetches every team, the catalog and manual label members, but
+// no global state.
+var FullFetch = FetchScope{AllTeams: true, Catalog: true, LabelHosts: true}
+
+// includesTeam reports whether the scope covers the named team.
+func (s FetchScope) includesTeam(name string) bool {
+	if s.AllTeams {
+		return true
+	}
+	for _, t := range s.Teams {
+		if strings.EqualFold(t, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchAll concurrently fetches Fleet state. Uses errgroup for parallel
+// requests. Without a scope it fetches FullFetch; with one, only the teams,
+// global config, policies and queries (for default.yml diffing), catalog and
+// label members it asks for. "No team" resources are fetched with teamID 0
+// into state.NoTeam; permission errors there are non-fatal. Teams outside
+// the scope are absent from state.Teams, and state.NoTeam is nil when "No
+// team" is out of scope or unreadable with the scope's capabilities.
+//
+// When ctx ends mid-fetch, FetchAll returns what it has instead of an error,
+// with state.Interrupted set and the rest listed in the NotFetched fields.
+func (c *Client) FetchAll(ctx context.Context, scope ...FetchScope) (*FleetState, error) {
 	state := &FleetState{}
-	wantGlobal := len(fetchGlobal) > 0 && fetchGlobal[0]
+	want := FullFetch
+	if len(scope) > 0 {
+		want = scope[0]
+	}
+	gaps := newFetchGaps(ctx)
 
-	teams, err := c.GetTeams(ctx)
-	if err != nil {
+	allTeams, err := c.GetTeams(ctx)
+	if err != nil && !gaps.global(err, ResourceTeams) {
 		return nil, err
 	}
+	caps := want.Capabilities
+	state.Capabilities = caps
+	state.ServerVersion = c.version
+	var teams []Team
+	for _, t := range allTeams {
+		if want.includesTeam(t.Name) && (caps == nil || caps.Role(t.ID) != "") {
+			teams = append(teams, t)
+		}
+	}
 
 	labels, err := c.GetLabels(ctx)
-	if err != nil {
+	if err != nil && !gaps.global(err, ResourceLabels) {
 		return nil, err
 	}
 	state.Labels = labels
 
-	fleetMaintainedCatalog, err := c.GetFleetMaintainedApps(ctx)
-	if err != nil {
-		if !isPermissionError(err) {
-			return nil, err
+	if want.Catalog && c.version.Supports(FeatureFleetMaintainedCatalog) {
+		fleetMaintainedCatalog, err := c.GetFleetMaintainedApps(ctx)
+		if err != nil && !gaps.global(err, ResourceCatalog) {
+			if !isPermissionError(err) {
+				return nil, err
+			}
+			fleetMaintainedCatalog = nil
 		}
-		fleetMaintainedCatalog = nil
+		state.FleetMaintainedCatalog = fleetMaintainedCatalog
+	}
+
+	if caps.CanReadGlobal(ResourceAppStore) && c.version.Supports(FeatureAppStoreApps) {
+		vppTokens, err := c.GetVPPTokens(ctx)
+		if err != nil {
+			if !isPermissionError(err) && !gaps.global(err, ResourceAppStore) {
+				return nil, err
+			}
+			state.VPPTokensUnavailable = true
+		}
+		state.VPPTokens = vppTokens
+	} else {
+		state.VPPTokensUnavailable = true
 	}
-	state.FleetMaintainedCatalog = fleetMaintainedCatalog
 
 	// Fetch per-team resources concurrently
 	g, gctx := errgroup.WithContext(ctx)
-	g.SetLimit(5)
+	g.SetLimit(c.concurrency)
 
 	// Local variables for global results; assigned to state after g.Wait().
 	var (
@@ -683,17 +1133,25 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 	)
 
 	// Fetch global config/policies/queries in parallel with team resources
-	if wantGlobal {
+	if want.Global && caps.CanReadGlobal(ResourceConfig) {
 		g.Go(func() error {
 			cfg, err := c.GetConfig(gctx)
+			if gaps.global(err, ResourceConfig) {
+				return nil
+			}
 			if err != nil {
 				return err
 			}
 			globalConfig = cfg
 			return nil
 		})
+	}
+	if want.Global {
 		g.Go(func() error {
 			policies, err := c.GetPolicies(gctx, 0)
+			if gaps.global(err, ResourcePolicies) {
+				return nil
+			}
 			if err != nil {
 				return err
 			}
@@ -702,6 +1160,9 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 		})
 		g.Go(func() error {
 			queries, err := c.GetQueries(gctx, 0)
+			if gaps.global(err, ResourceQueries) {
+				return nil
+			}
 			if err != nil {
 				return err
 			}
@@ -710,6 +1171,35 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 		})
 	}
 
+	// Current members of manual labels, for host membership diffs. Each
+	// goroutine writes only its own label.
+	for i := range state.Labels {
+		label := &state.Labels[i]
+		if !want.LabelHosts || label.LabelMembershipType != "manual" {
+			continue
+		}
+		if !caps.CanReadGlobal(ResourceLabelHosts) {
+			label.HostsUnavailable = true
+			continue
+		}
+		g.Go(func() error {
+			hosts, err := c.GetLabelHosts(gctx, label.ID)
+			if gaps.global(err, ResourceLabelHosts) {
+				label.HostsUnavailable = true
+				return nil
+			}
+			if err != nil {
+				if !isPermissionError(err) {
+					return err
+				}
+				label.HostsUnavailable = true
+				return nil
+			}
+			label.Hosts = hosts
+			return nil
+		})
+	}
+
 	// teamPartials holds per-goroutine results indexed by team slot.
 	// Each field is written by exactly one goroutine, so there is no data race.
 	type teamPartial struct {
@@ -721,69 +1211,147 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 		softwareUnavailable bool
 		scripts             []Script
 		scriptsUnavailable  bool
+		vppApps             []VPPApp
+		vppAppsUnavailable  bool
+		policiesUnavailable bool
 	}
-	teamPartials := make([]teamPartial, len(teams))
 
-	teamResults := make([]Team, len(teams))
-	for i, t := range teams {
+	// "No team" is fetched like any other team in the last slot. It has no
+	// queries (team_id 0 queries are global) and its policies live at a
+	// separate endpoint.
+	targets := teams
+	fetchNoTeam := want.includesTeam(parser.NoTeamName) && (caps == nil || caps.Role(0) != "")
+	if fetchNoTeam {
+		targets = append(targets, Team{ID: 0, Name: parser.NoTeamName})
+	}
+	teamPartials := make([]teamPartial, len(targets))
+
+	teamResults := make([]Team, len(targets))
+	for i, t := range targets {
 		teamResults[i] = t
 		idx := i
 		teamID := t.ID
+		noTeam := i == len(teams)
+
+		if noTeam && !c.version.Supports(FeatureNoTeamPolicies) {
+			teamPartials[idx].policiesUnavailable = true
+		} else if noTeam {
+			g.Go(func() error {
+				policies, err := c.GetNoTeamPolicies(gctx)
+				if gaps.team(err, 0, ResourcePolicies) {
+					return nil
+				}
+				if err != nil {
+					if !isPermissionError(err) {
+						return err
+					}
+					teamPartials[idx].policiesUnavailable = true
+					policies = nil
+				}
+				teamPartials[idx].policies = policies
+				return nil
+			})
+		} else {
+			g.Go(func() error {
+				policies, err := c.GetPolicies(gctx, teamID)
+				if gaps.team(err, teamID, ResourcePolicies) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				teamPartials[idx].policies = policies
+				return nil
+			})
 
-		g.Go(func() error {
-			policies, err := c.GetPolicies(gctx, teamID)
-			if err != nil {
-				return err
-			}
-			teamPartials[idx].policies = policies
-			return nil
-		})
+			g.Go(func() error {
+				queries, err := c.GetQueries(gctx, teamID)
+				if gaps.team(err, teamID, ResourceQueries) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				teamPartials[idx].queries = queries
+				return nil
+			})
+		}
 
-		g.Go(func() error {
-			queries, err := c.GetQueries(gctx, teamID)
-			if err != nil {
-				return err
-			}
-			teamPartials[idx].queries = queries
-			return nil
-		})
+		teamPartials[idx].profilesUnavailable = !caps.CanRead(teamID, ResourceProfiles)
+		teamPartials[idx].softwareUnavailable = !caps.CanRead(teamID, ResourceSoftware)
+		teamPartials[idx].scriptsUnavailable = !caps.CanRead(teamID, ResourceScripts)
 
-		g.Go(func() error {
-			profiles, err := c.GetProfiles(gctx, teamID)
-			if err != nil {
-				if !isPermissionError(err) {
-					return err
+		if !teamPartials[idx].profilesUnavailable {
+			g.Go(func() error {
+				profiles, err := c.GetProfiles(gctx, teamID)
+				if gaps.team(err, teamID, ResourceProfiles) {
+					return nil
 				}
-				teamPartials[idx].profilesUnavailable = true
-				profiles = nil
-			}
-			teamPartials[idx].profiles = profiles
-			return nil
-		})
+				if err != nil {
+					if !isPermissionError(err) {
+						return err
+					}
+					teamPartials[idx].profilesUnavailable = true
+					profiles = nil
+				}
+				teamPartials[idx].profiles = profiles
+				return nil
+			})
+		}
 
-		g.Go(func() error {
-			softwareTitles, err := c.GetSoftware(gctx, teamID)
-			if err != nil {
-				if !isPermissionError(err) {
-					return err
+		if !teamPartials[idx].softwareUnavailable {
+			g.Go(func() error {
+				softwareTitles, err := c.GetSoftware(gctx, teamID)
+				if gaps.team(err, teamID, ResourceSoftware) {
+					return nil
 				}
-				teamPartials[idx].softwareUnavailable = true
-				softwareTitles = nil
-			}
-			teamPartials[idx].softwareTitles = softwareTitles
-			return nil
-		})
+				if err != nil {
+					if !isPermissionError(err) {
+						return err
+					}
+					teamPartials[idx].softwareUnavailable = true
+					softwareTitles = nil
+				}
+				teamPartials[idx].softwareTitles = softwareTitles
+				return nil
+			})
+		}
 
+		if !teamPartials[idx].scriptsUnavailable {
+			g.Go(func() error {
+				scripts, err := c.GetScripts(gctx, teamID)
+				if gaps.team(err, teamID, ResourceScripts) {
+					return nil
+				}
+				if err != nil {
+					if !isPermissionError(err) {
+						return err
+					}
+					teamPartials[idx].scriptsUnavailable = true
+					scripts = nil
+				}
+				teamPartials[idx].scripts = scripts
+				return nil
+			})
+		}
+
+		if !teamHasVPPToken(state.VPPTokens, teamID) || !caps.CanRead(teamID, ResourceAppStore) {
+			teamPartials[idx].vppAppsUnavailable = true
+			continue
+		}
 		g.Go(func() error {
-			scripts, err := c.GetScripts(gctx, teamID)
+			vppApps, err := c.GetVPPApps(gctx, teamID)
+			if gaps.team(err, teamID, ResourceAppStore) {
+				return nil
+			}
 			if err != nil {
 				if !isPermissionError(err) {
 					return err
 				}
-				teamPartials[idx].scriptsUnavailable = true
-				scripts = nil
+				teamPartials[idx].vppAppsUnavailable = true
+				vppApps = nil
 			}
-			teamPartials[idx].scripts = scripts
+			teamPartials[idx].vppApps = vppApps
 			return nil
 		})
 	}
@@ -793,7 +1361,7 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 	}
 
 	// Assign global results after all goroutines have completed.
-	if wantGlobal {
+	if want.Global {
 		state.Config = globalConfig
 		state.GlobalPolicies = globalPolicies
 		state.GlobalQueries = globalQueries
@@ -810,15 +1378,44 @@ func (c *Client) FetchAll(ctx context.Context, fetchGlobal ...bool) (*FleetState
 		teamResults[i].SoftwareUnavailable = p.softwareUnavailable
 		teamResults[i].Scripts = p.scripts
 		teamResults[i].ScriptsUnavailable = p.scriptsUnavailable
+		teamResults[i].VPPApps = p.vppApps
+		teamResults[i].VPPAppsUnavailable = p.vppAppsUnavailable
+		teamResults[i].PoliciesUnavailable = p.policiesUnavailable
 	}
 
-	// Enrich script contents (second pass, needs script IDs from first pass)
+	// Enrich script and profile contents and App Store title scopes (second
+	// pass, needs IDs from first pass). Enrichment errors are non-fatal, so
+	// a context that ended during it leaves contents missing.
 	for i := range teamResults {
+		teamID := teamResults[i].ID
+		if !teamResults[i].SoftwareUnavailable && len(teamResults[i].SoftwareTitles) > 0 {
+			c.EnrichAppStoreTitles(ctx, teamResults[i].SoftwareTitles, teamID)
+			gaps.team(ctx.Err(), teamID, ResourceSoftware)
+		}
 		if !teamResults[i].ScriptsUnavailable && len(teamResults[i].Scripts) > 0 {
 			c.EnrichScriptContents(ctx, teamResults[i].Scripts)
+			gaps.team(ctx.Err(), teamID, ResourceScripts)
+		}
+		if !teamResults[i].ProfilesUnavailable && len(teamResults[i].Profiles) > 0 {
+			c.EnrichProfileContents(ctx, teamResults[i].Profiles)
+			gaps.team(ctx.Err(), teamID, ResourceProfiles)
 		}
 	}
+	gaps.apply(state, teamResults)
 
-	state.Teams = teamResults
+	state.Teams = teamResults[:len(teams)]
+	if fetchNoTeam {
+		state.NoTeam = &teamResults[len(teams)]
+	}
 	return state, nil
 }
+
+// teamHasVPPToken reports whether any token is assigned to the team.
+func teamHasVPPToken(tokens []VPPToken, teamID uint) bool {
+	for _, t := range tokens {
+		if t.AssignedTo(teamID) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/TsekNet/fleet-plan/internal/parser"
 )
 
 // testClient creates a Client pointing at the test server.
@@ -18,6 +24,7 @@ func testClient(t *testing.T, ts *httptest.Server, token string) *Client {
 	if err != nil {
 		t.Fatalf("NewClient: %v", err)
 	}
+	c.retryBase = time.Millisecond // keep 5xx test cases fast
 	return c
 }
 
@@ -229,6 +236,33 @@ func TestGetPoliciesPageParams(t *testing.T) {
 	}
 }
 
+func TestGetNoTeamPoliciesPagination(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/fleet/teams/0/policies" {
+			t.Errorf("path: got %q", r.URL.Path)
+		}
+		n := 1
+		if r.URL.Query().Get("page") == "0" {
+			n = 250
+		}
+		policies := make([]Policy, n)
+		for i := range policies {
+			policies[i] = Policy{ID: uint(i + 1), Name: fmt.Sprintf("Policy %d", i)}
+		}
+		json.NewEncoder(w).Encode(policiesResponse{Policies: policies})
+	}))
+	defer ts.Close()
+
+	c := testClient(t, ts, "tok")
+	policies, err := c.GetNoTeamPolicies(context.Background())
+	if err != nil {
+		t.Fatalf("GetNoTeamPolicies: %v", err)
+	}
+	if len(policies) != 251 {
+		t.Errorf("expected 251 policies across two pages, got %d", len(policies))
+	}
+}
+
 func TestGetPoliciesFields(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(policiesResponse{
@@ -281,6 +315,218 @@ func TestGetLabels(t *testing.T) {
 	}
 }
 
+// ---------- Hosts ----------
+
+func TestGetLabelHosts(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/fleet/labels/7/hosts" {
+			http.NotFound(w, r)
+			return
+		}
+		pages = append(pages, r.URL.Query().Get("page"))
+		if r.URL.Query().Get("page") == "0" {
+			hosts := make([]Host, 250)
+			for i := range hosts {
+				hosts[i] = Host{ID: uint(i + 1)}
+			}
+			json.NewEncoder(w).Encode(hostsResponse{Hosts: hosts})
+			return
+		}
+		json.NewEncoder(w).Encode(hostsResponse{Hosts: []Host{{ID: 251, Hostname: "kiosk-01", HardwareSerial: "C02ABC"}}})
+	}))
+	defer ts.Close()
+
+	hosts, err := testClient(t, ts, "tok").GetLabelHosts(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetLabelHosts: %v", err)
+	}
+	if len(hosts) != 251 || hosts[250].HardwareSerial != "C02ABC" {
+		t.Errorf("got %d hosts, last %+v", len(hosts), hosts[len(hosts)-1])
+	}
+	if !slices.Equal(pages, []string{"0", "1"}) {
+		t.Errorf("pages: got %v", pages)
+	}
+}
+
+func TestGetHostByIdentifier(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v1/fleet/hosts/identifier/C02ABC":
+			fmt.Fprint(w, `{"host":{"id":3,"hostname":"kiosk-01","hardware_serial":"C02ABC","team_id":2,"team_name":"Kiosks",
+				"labels":[{"id":1,"name":"macOS"},{"id":9,"name":"Front desk"}]}}`)
+		case "/api/v1/fleet/hosts/identifier/broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case "/api/v1/fleet/hosts/identifier/my%20mac":
+			fmt.Fprint(w, `{"host":{"id":4,"hostname":"my mac"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	c := testClient(t, ts, "tok")
+
+	tests := []struct {
+		identifier string
+		wantID     uint
+		wantErr    bool
+	}{
+		{identifier: "C02ABC", wantID: 3},
+		{identifier: "my mac", wantID: 4},
+		{identifier: "unknown"},
+		{identifier: "broken", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			host, err := c.GetHostByIdentifier(context.Background(), tt.identifier)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			switch {
+			case tt.wantID == 0 && host != nil:
+				t.Errorf("expected no host, got %+v", host)
+			case tt.wantID != 0 && (host == nil || host.ID != tt.wantID):
+				t.Errorf("host = %+v, want ID %d", host, tt.wantID)
+			case tt.wantID == 3 && (len(host.Labels) != 2 || host.Labels[1].Name != "Front desk" || *host.TeamID != 2):
+				t.Errorf("host detail = %+v", host)
+			}
+		})
+	}
+}
+
+func TestGetHostsByIdentifier(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, peak int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/fleet/hosts/identifier/")
+		switch {
+		case id == "broken":
+			http.Error(w, "boom", http.StatusBadRequest)
+		case strings.HasPrefix(id, "kiosk-"):
+			fmt.Fprintf(w, `{"host":{"id":1,"hostname":%q}}`, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	c := testClient(t, ts, "tok")
+	c.concurrency = 3
+
+	ids := []string{"kiosk-01", "kiosk-02", "kiosk-03", "kiosk-04", "kiosk-05", "unknown", "broken"}
+	got, err := c.GetHostsByIdentifier(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("GetHostsByIdentifier: %v", err)
+	}
+	for _, id := range ids[:5] {
+		if h := got[id].Host; h == nil || h.Hostname != id {
+			t.Errorf("%s = %+v", id, got[id])
+		}
+	}
+	if l := got["unknown"]; l.Host != nil || l.Err != nil {
+		t.Errorf("unknown = %+v, want no host and no error", l)
+	}
+	if got["broken"].Err == nil {
+		t.Error("broken: expected a lookup error")
+	}
+	if peak > 3 {
+		t.Errorf("%d lookups in flight, want at most 3", peak)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetHostsByIdentifier(ctx, ids); err == nil {
+		t.Error("expected the context error")
+	}
+}
+
+func TestHostMatchesAndString(t *testing.T) {
+	h := Host{Hostname: "kiosk-01.local", HardwareSerial: "C02ABC", UUID: "1234-ABCD"}
+	for _, id := range []string{"C02ABC", "c02abc", "kiosk-01.local", "1234-abcd"} {
+		if !h.Matches(id) {
+			t.Errorf("Matches(%q) = false", id)
+		}
+	}
+	if (Host{}).Matches("") || h.Matches("kiosk-01") {
+		t.Error("empty or partial identifiers should not match")
+	}
+
+	tests := []struct {
+		host Host
+		want string
+	}{
+		{h, "kiosk-01.local (C02ABC)"},
+		{Host{DisplayName: "Front desk", Hostname: "kiosk-01"}, "Front desk"},
+		{Host{HardwareSerial: "C02ABC"}, "C02ABC"},
+		{Host{Hostname: "C02ABC", HardwareSerial: "C02ABC"}, "C02ABC"},
+		{Host{UUID: "1234-ABCD"}, "1234-ABCD"},
+	}
+	for _, tt := range tests {
+		if got := tt.host.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFetchAllManualLabelHosts(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		hostFetches []string
+	)
+	fetched := func(id string) {
+		mu.Lock()
+		defer mu.Unlock()
+		hostFetches = append(hostFetches, id)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fleet/teams", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(teamsResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/labels", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"labels":[
+			{"id":1,"name":"macOS","label_membership_type":"dynamic"},
+			{"id":2,"name":"Kiosks","label_membership_type":"manual"},
+			{"id":3,"name":"Hidden","label_membership_type":"manual"}]}`)
+	})
+	mux.HandleFunc("/api/v1/fleet/labels/2/hosts", func(w http.ResponseWriter, r *http.Request) {
+		fetched("2")
+		fmt.Fprint(w, `{"hosts":[{"id":9,"hostname":"kiosk-01"}]}`)
+	})
+	mux.HandleFunc("/api/v1/fleet/labels/3/hosts", func(w http.ResponseWriter, r *http.Request) {
+		fetched("3")
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	mux.HandleFunc("/api/v1/fleet/labels/1/hosts", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("hosts of dynamic labels should not be fetched")
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	state, err := testClient(t, ts, "tok").FetchAll(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	slices.Sort(hostFetches)
+	if !slices.Equal(hostFetches, []string{"2", "3"}) {
+		t.Errorf("label host fetches: got %v", hostFetches)
+	}
+	if l := state.Labels[1]; len(l.Hosts) != 1 || l.Hosts[0].Hostname != "kiosk-01" || l.HostsUnavailable {
+		t.Errorf("Kiosks: %+v", l)
+	}
+	if l := state.Labels[2]; !l.HostsUnavailable {
+		t.Errorf("Hidden should be unavailable: %+v", l)
+	}
+}
+
 // ---------- GetQueries ----------
 
 func TestGetQueries(t *testing.T) {
@@ -506,7 +752,7 @@ func TestFetchAll(t *testing.T) {
 	}
 }
 
-// ---------- FetchAll with fetchGlobal ----------
+// ---------- FetchAll with Global scope ----------
 
 func TestFetchAllWithGlobal(t *testing.T) {
 	mux := http.NewServeMux()
@@ -545,7 +791,7 @@ func TestFetchAllWithGlobal(t *testing.T) {
 	defer ts.Close()
 
 	c := testClient(t, ts, "tok")
-	state, err := c.FetchAll(context.Background(), true)
+	state, err := c.FetchAll(context.Background(), FetchScope{AllTeams: true, Global: true})
 	if err != nil {
 		t.Fatalf("FetchAll: %v", err)
 	}
@@ -560,6 +806,99 @@ func TestFetchAllWithGlobal(t *testing.T) {
 	}
 }
 
+// ---------- FetchAll team scoping ----------
+
+func TestFetchAllScoped(t *testing.T) {
+	tests := []struct {
+		name       string
+		scope      FetchScope
+		wantTeams  []string
+		wantNoTeam bool
+		want       []string // request paths that must be made
+		notWant    []string // request paths that must not be made
+	}{
+		{
+			name:       "full fetch",
+			scope:      FullFetch,
+			wantTeams:  []string{"Workstations", "Servers"},
+			wantNoTeam: true,
+			want: []string{"/api/v1/fleet/teams/1/policies", "/api/v1/fleet/teams/2/policies",
+				"/api/v1/fleet/teams/0/policies", "/api/v1/fleet/software/fleet_maintained_apps", "/api/v1/fleet/labels/7/hosts"},
+			notWant: []string{"/api/v1/fleet/config", "/api/v1/fleet/global/policies"},
+		},
+		{
+			name:      "one team, case-insensitive",
+			scope:     FetchScope{Teams: []string{"workstations"}},
+			wantTeams: []string{"Workstations"},
+			want:      []string{"/api/v1/fleet/teams/1/policies"},
+			notWant: []string{"/api/v1/fleet/teams/2/policies", "/api/v1/fleet/teams/0/policies",
+				"/api/v1/fleet/software/fleet_maintained_apps", "/api/v1/fleet/labels/7/hosts",
+				"/api/v1/fleet/config", "/api/v1/fleet/global/policies"},
+		},
+		{
+			name:       "no team with catalog and label hosts",
+			scope:      FetchScope{Teams: []string{parser.NoTeamName}, Catalog: true, LabelHosts: true},
+			wantNoTeam: true,
+			want:       []string{"/api/v1/fleet/teams/0/policies", "/api/v1/fleet/software/fleet_maintained_apps", "/api/v1/fleet/labels/7/hosts"},
+			notWant:    []string{"/api/v1/fleet/teams/1/policies", "/api/v1/fleet/teams/2/policies"},
+		},
+		{
+			name:    "global only",
+			scope:   FetchScope{Global: true},
+			want:    []string{"/api/v1/fleet/config", "/api/v1/fleet/global/policies"},
+			notWant: []string{"/api/v1/fleet/teams/1/policies", "/api/v1/fleet/teams/0/policies", "/api/v1/fleet/scripts"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			paths := make(map[string]bool)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths[r.URL.Path] = true
+				mu.Unlock()
+				switch r.URL.Path {
+				case "/api/v1/fleet/teams":
+					json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}, {ID: 2, Name: "Servers"}}})
+				case "/api/v1/fleet/labels":
+					json.NewEncoder(w).Encode(labelsResponse{Labels: []Label{{ID: 7, Name: "Canaries", LabelMembershipType: "manual"}}})
+				case "/api/v1/fleet/config":
+					json.NewEncoder(w).Encode(map[string]any{})
+				default:
+					w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			state, err := testClient(t, ts, "tok").FetchAll(context.Background(), tt.scope)
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+			var gotTeams []string
+			for _, team := range state.Teams {
+				gotTeams = append(gotTeams, team.Name)
+			}
+			if !slices.Equal(gotTeams, tt.wantTeams) {
+				t.Errorf("teams = %v, want %v", gotTeams, tt.wantTeams)
+			}
+			if (state.NoTeam != nil) != tt.wantNoTeam {
+				t.Errorf("NoTeam fetched = %v, want %v", state.NoTeam != nil, tt.wantNoTeam)
+			}
+			for _, p := range tt.want {
+				if !paths[p] {
+					t.Errorf("expected request to %s", p)
+				}
+			}
+			for _, p := range tt.notWant {
+				if paths[p] {
+					t.Errorf("unexpected request to %s", p)
+				}
+			}
+		})
+	}
+}
+
 // ---------- GetSoftwareTitleDetail ----------
 
 func TestGetSoftwareTitleDetail(t *testing.T) {
@@ -901,6 +1240,44 @@ func TestGetScriptsPagination(t *testing.T) {
 	}
 }
 
+// ---------- EnrichProfileContents ----------
+
+func TestEnrichProfileContents(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "media" {
+			t.Errorf("expected alt=media, got %q", r.URL.RawQuery)
+		}
+		switch r.URL.Path {
+		case "/api/v1/fleet/configuration_profiles/win-1":
+			w.Write([]byte("<Replace><Item><Target><LocURI>./Device/x</LocURI></Target></Item></Replace>"))
+		case "/api/v1/fleet/configuration_profiles/win-broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			t.Errorf("unexpected download: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	profiles := []Profile{
+		{ProfileUUID: "win-1", Name: "lock", Platform: "windows"},
+		{ProfileUUID: "win-broken", Name: "broken", Platform: "windows"},
+		{ProfileUUID: "mac-1", Name: "wifi", Platform: "darwin"}, // skipped
+	}
+	c := testClient(t, ts, "tok")
+	c.EnrichProfileContents(context.Background(), profiles)
+
+	if !strings.Contains(profiles[0].Content, "./Device/x") {
+		t.Errorf("windows profile content not populated: %q", profiles[0].Content)
+	}
+	if profiles[1].Content != "" {
+		t.Errorf("failed download should leave content empty, got %q", profiles[1].Content)
+	}
+	if profiles[2].Content != "" {
+		t.Errorf("darwin profile should not be downloaded, got %q", profiles[2].Content)
+	}
+}
+
 // ---------- FetchAll scripts fallback ----------
 
 func TestFetchAllScripts403(t *testing.T) {
@@ -1041,3 +1418,163 @@ func TestFetchAllFleetMaintainedFallback(t *testing.T) {
 		})
 	}
 }
+
+// ---------- VPP ----------
+
+func TestVPPTokenAssignedTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		teams []VPPTokenTeam
+		team  uint
+		want  bool
+	}{
+		{name: "unassigned", teams: nil, team: 1, want: false},
+		{name: "all teams", teams: []VPPTokenTeam{}, team: 7, want: true},
+		{name: "assigned", teams: []VPPTokenTeam{{TeamID: 1}, {TeamID: 2}}, team: 2, want: true},
+		{name: "other team", teams: []VPPTokenTeam{{TeamID: 1}}, team: 2, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (VPPToken{Teams: tt.teams}).AssignedTo(tt.team); got != tt.want {
+				t.Errorf("AssignedTo(%d) = %v, want %v", tt.team, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAllVPP(t *testing.T) {
+	var vppAppsTeams []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fleet/teams", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Mobile"}, {ID: 2, Name: "Servers"}}})
+	})
+	mux.HandleFunc("/api/v1/fleet/labels", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(labelsResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/vpp_tokens", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"vpp_tokens":[{"id":1,"org_name":"Acme","teams":[{"team_id":1,"name":"Mobile"}]}]}`)
+	})
+	mux.HandleFunc("/api/v1/fleet/software/app_store_apps", func(w http.ResponseWriter, r *http.Request) {
+		vppAppsTeams = append(vppAppsTeams, r.URL.Query().Get("team_id"))
+		fmt.Fprint(w, `{"app_store_apps":[{"app_store_id":"111","name":"Slack","platform":"ios"}]}`)
+	})
+	mux.HandleFunc("/api/v1/fleet/software/titles", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("team_id") == "1" {
+			fmt.Fprint(w, `{"software_titles":[{"id":9,"name":"Slack","app_store_app":{"app_store_id":"111","platform":"ios"}}]}`)
+			return
+		}
+		json.NewEncoder(w).Encode(softwareResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/software/titles/9", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"software_title":{"id":9,"app_store_app":{"app_store_id":"111","platform":"ios",
+			"labels_include_any":[{"id":3,"name":"Field Staff"}],"categories":["Communication"]}}}`)
+	})
+	for _, p := range []string{"/api/v1/fleet/queries", "/api/v1/fleet/teams/1/policies", "/api/v1/fleet/teams/2/policies"} {
+		mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, `{}`) })
+	}
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	state, err := testClient(t, ts, "tok").FetchAll(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	if len(state.VPPTokens) != 1 || state.VPPTokensUnavailable {
+		t.Fatalf("VPP tokens: got %+v (unavailable=%v)", state.VPPTokens, state.VPPTokensUnavailable)
+	}
+	if len(vppAppsTeams) != 1 || vppAppsTeams[0] != "1" {
+		t.Errorf("VPP apps should only be fetched for team 1, got %v", vppAppsTeams)
+	}
+	mobile, servers := state.Teams[0], state.Teams[1]
+	if len(mobile.VPPApps) != 1 || mobile.VPPAppsUnavailable {
+		t.Errorf("Mobile VPP apps: got %+v (unavailable=%v)", mobile.VPPApps, mobile.VPPAppsUnavailable)
+	}
+	if !servers.VPPAppsUnavailable {
+		t.Error("Servers has no VPP token, VPPAppsUnavailable should be true")
+	}
+	meta := mobile.SoftwareTitles[0].AppStoreApp
+	if len(meta.LabelsIncludeAny) != 1 || meta.LabelsIncludeAny[0].Name != "Field Staff" || len(meta.Categories) != 1 {
+		t.Errorf("App Store title not enriched from detail: %+v", meta)
+	}
+}
+
+// ---------- No team ----------
+
+func TestFetchAllNoTeam(t *testing.T) {
+	var softwareTeams, scriptTeams []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fleet/teams", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}}})
+	})
+	mux.HandleFunc("/api/v1/fleet/labels", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(labelsResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/teams/1/policies", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(policiesResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/teams/0/policies", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(policiesResponse{Policies: []Policy{{ID: 9, Name: "Onboarding complete"}}})
+	})
+	mux.HandleFunc("/api/v1/fleet/queries", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("team_id") == "" {
+			t.Error("No team must not fetch queries (team_id 0 queries are global)")
+		}
+		json.NewEncoder(w).Encode(queriesResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/software/titles", func(w http.ResponseWriter, r *http.Request) {
+		softwareTeams = append(softwareTeams, r.URL.Query().Get("team_id"))
+		json.NewEncoder(w).Encode(softwareResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/scripts", func(w http.ResponseWriter, r *http.Request) {
+		scriptTeams = append(scriptTeams, r.URL.Query().Get("team_id"))
+		json.NewEncoder(w).Encode(scriptsResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/configuration_profiles", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(profilesResponse{})
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	state, err := testClient(t, ts, "tok").FetchAll(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	if len(state.Teams) != 1 {
+		t.Errorf("No team must not be added to Teams, got %d teams", len(state.Teams))
+	}
+	if state.NoTeam == nil || state.NoTeam.Name != parser.NoTeamName || state.NoTeam.ID != 0 {
+		t.Fatalf("NoTeam: got %+v", state.NoTeam)
+	}
+	if len(state.NoTeam.Policies) != 1 || state.NoTeam.PoliciesUnavailable {
+		t.Errorf("No team policies: got %+v", state.NoTeam.Policies)
+	}
+	if !slices.Contains(softwareTeams, "0") {
+		t.Errorf("software titles should be fetched with team_id=0, got %v", softwareTeams)
+	}
+	if !slices.Contains(scriptTeams, "") {
+		t.Errorf("scripts should be fetched without team_id for No team, got %v", scriptTeams)
+	}
+}
+
+func TestFetchAllNoTeamPoliciesUnsupported(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/fleet/teams", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(teamsResponse{})
+	})
+	mux.HandleFunc("/api/v1/fleet/labels", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(labelsResponse{})
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	state, err := testClient(t, ts, "tok").FetchAll(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	if !state.NoTeam.PoliciesUnavailable {
+		t.Error("404 on /teams/0/policies should mark No team policies unavailable")
+	}
+}
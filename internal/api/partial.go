@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Resources shared by every scope of a plan. Only an interrupted FetchAll
+// reports them, in FleetState.NotFetched.
+const (
+	ResourceTeams   Resource = "teams"
+	ResourceLabels  Resource = "labels"
+	ResourceCatalog Resource = "fleet-maintained app catalog"
+)
+
+// resourceOrder is the order NotFetched lists are reported in.
+var resourceOrder = []Resource{
+	ResourceTeams, ResourceLabels, ResourceCatalog, ResourceConfig, ResourcePolicies, ResourceQueries,
+	ResourceSoftware, ResourceProfiles, ResourceScripts, ResourceAppStore, ResourceLabelHosts,
+}
+
+// fetchGaps records what FetchAll skipped because its context ended, so an
+// interrupted fetch still returns the state it has instead of an error.
+type fetchGaps struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	globals []Resource
+	teams   map[uint][]Resource
+}
+
+func newFetchGaps(ctx context.Context) *fetchGaps {
+	return &fetchGaps{ctx: ctx, teams: make(map[uint][]Resource)}
+}
+
+// cut reports whether err is the fetch context ending rather than a failed
+// request. Once the context is done, every error is.
+func (g *fetchGaps) cut(err error) bool {
+	return err != nil && g.ctx.Err() != nil
+}
+
+// global records r as not fetched if err is from the context ending.
+func (g *fetchGaps) global(err error, r Resource) bool {
+	if !g.cut(err) {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !slices.Contains(g.globals, r) {
+		g.globals = append(g.globals, r)
+	}
+	return true
+}
+
+// team records r as not fetched for the team if err is from the context
+// ending.
+func (g *fetchGaps) team(err error, teamID uint, r Resource) bool {
+	if !g.cut(err) {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !slices.Contains(g.teams[teamID], r) {
+		g.teams[teamID] = append(g.teams[teamID], r)
+	}
+	return true
+}
+
+// apply marks state and its teams with what wasn't fetched. Teams must be
+// the state's teams, "No team" included.
+func (g *fetchGaps) apply(state *FleetState, teams []Team) {
+	if len(g.globals) == 0 && len(g.teams) == 0 {
+		return
+	}
+	state.Interrupted = g.ctx.Err()
+	state.NotFetched = sortResources(g.globals)
+	for i := range teams {
+		teams[i].NotFetched = sortResources(g.teams[teams[i].ID])
+	}
+}
+
+// sortResources orders rs by resourceOrder; concurrent fetches record them
+// in any order.
+func sortResources(rs []Resource) []Resource {
+	slices.SortFunc(rs, func(a, b Resource) int {
+		return slices.Index(resourceOrder, a) - slices.Index(resourceOrder, b)
+	})
+	return rs
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFetchAllInterrupted(t *testing.T) {
+	tests := []struct {
+		name           string
+		slow           []string // paths that never answer before the deadline
+		wantGlobal     []Resource
+		wantTeam       []Resource
+		wantTeams      int
+		wantNoDeadline bool
+	}{
+		{
+			name:           "complete",
+			wantTeams:      1,
+			wantNoDeadline: true,
+		},
+		{
+			name:      "team resources",
+			slow:      []string{"/api/v1/fleet/scripts", "/api/v1/fleet/software/titles"},
+			wantTeam:  []Resource{ResourceSoftware, ResourceScripts},
+			wantTeams: 1,
+		},
+		{
+			name:       "global queries",
+			slow:       []string{"/api/v1/fleet/queries"},
+			wantGlobal: []Resource{ResourceQueries},
+			wantTeam:   []Resource{ResourceQueries},
+			wantTeams:  1,
+		},
+		{
+			name: "teams",
+			slow: []string{"/api/v1/fleet/teams"},
+			wantGlobal: []Resource{ResourceTeams, ResourceLabels, ResourceCatalog, ResourceConfig,
+				ResourcePolicies, ResourceQueries, ResourceAppStore},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if slices.Contains(tt.slow, r.URL.Path) {
+					<-r.Context().Done()
+					return
+				}
+				switch r.URL.Path {
+				case "/api/v1/fleet/teams":
+					json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}}})
+				case "/api/v1/fleet/global/policies", "/api/v1/fleet/teams/1/policies":
+					w.Write([]byte(`{"policies": [{"id": 1, "name": "Disk encrypted"}]}`))
+				default:
+					w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			c := testClient(t, ts, "tok")
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			scope := FullFetch
+			scope.Global = true
+			state, err := c.FetchAll(ctx, scope)
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+
+			if tt.wantNoDeadline {
+				if state.Interrupted != nil || state.NotFetched != nil {
+					t.Errorf("complete fetch marked interrupted: %v, %v", state.Interrupted, state.NotFetched)
+				}
+			} else if !errors.Is(state.Interrupted, context.DeadlineExceeded) {
+				t.Errorf("Interrupted = %v, want context.DeadlineExceeded", state.Interrupted)
+			}
+			if !slices.Equal(state.NotFetched, tt.wantGlobal) {
+				t.Errorf("state.NotFetched = %v, want %v", state.NotFetched, tt.wantGlobal)
+			}
+			if len(state.Teams) != tt.wantTeams {
+				t.Fatalf("got %d teams, want %d", len(state.Teams), tt.wantTeams)
+			}
+			if tt.wantTeams > 0 {
+				team := state.Teams[0]
+				if !slices.Equal(team.NotFetched, tt.wantTeam) {
+					t.Errorf("team NotFetched = %v, want %v", team.NotFetched, tt.wantTeam)
+				}
+				if len(team.Policies) != 1 {
+					t.Errorf("team policies = %+v, want the one fetched before the deadline", team.Policies)
+				}
+			}
+		})
+	}
+}
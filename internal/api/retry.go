@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultConcurrency is the number of API requests FetchAll and the
+	// Enrich* helpers run in parallel.
+	DefaultConcurrency = 5
+	// DefaultTimeout is the per-request HTTP timeout.
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetries is how many times a failed GET is retried.
+	DefaultRetries = 3
+
+	// maxBackoff caps the exponential backoff between retries.
+	maxBackoff = 10 * time.Second
+	// maxRetryAfter caps how long a Retry-After header can stall a request.
+	maxRetryAfter = time.Minute
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithConcurrency sets how many requests run in parallel. Values below 1
+// keep DefaultConcurrency.
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithTimeout sets the per-request HTTP timeout. Zero keeps DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.httpClient.Timeout = d
+		}
+	}
+}
+
+// WithRetries sets how many times a GET is retried on 429, 5xx or a dropped
+// connection. Zero disables retries; negative values keep DefaultRetries.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		if n >= 0 {
+			c.retries = n
+		}
+	}
+}
+
+// do sends a GET, retrying 429s, 5xx responses and dropped connections with
+// jittered exponential backoff. A Retry-After header overrides the backoff.
+// GETs are idempotent, so retrying is always safe. The last response or error
+// is returned to the caller; TLS failures are wrapped in a TLSError.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		resp, err := c.httpClient.Do(req)
+		reason := retryReason(ctx, resp, err)
+		if reason == "" || attempt >= c.retries {
+			c.stats.finish(attempt, reason != "")
+			if err != nil {
+				if hint := tlsHint(err); hint != "" {
+					err = &TLSError{URL: c.baseURL, Err: err, Hint: hint}
+				}
+			}
+			return resp, err
+		}
+
+		wait := c.backoff(attempt)
+		if resp != nil {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = min(d, maxRetryAfter)
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		c.stats.retry(reason)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.stats.finish(attempt+1, true)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the wait before retry number attempt+1: exponential from
+// retryBase, capped at maxBackoff, with jitter over the upper half so
+// parallel requests that failed together don't retry together.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retryBase << attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryReason returns why a request should be retried ("HTTP 502",
+// "connection reset", ...), or "" if it shouldn't. Cancellation of the
+// caller's context is never retried.
+func retryReason(ctx context.Context, resp *http.Response, err error) string {
+	if err != nil {
+		if ctx.Err() != nil {
+			return ""
+		}
+		var netErr net.Error
+		switch {
+		case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return "connection reset"
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return "timeout"
+		}
+		return ""
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "HTTP 429"
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return ""
+}
+
+// parseRetryAfter parses a Retry-After header, either delay-seconds or an
+// HTTP date. Dates in the past mean no wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// RetryStats summarizes the retries a Client made.
+type RetryStats struct {
+	Retries   int            // retry attempts made
+	Reasons   map[string]int // retry attempts by cause, e.g. "HTTP 502"
+	Recovered int            // requests that succeeded after retrying
+	GaveUp    int            // requests that still failed with the retry budget spent
+}
+
+// String renders the stats for a one-line stderr summary, e.g.
+// "7 retries (HTTP 502 x5, HTTP 429 x2): 4 requests recovered, 1 gave up".
+// It returns "" when nothing was retried.
+func (s RetryStats) String() string {
+	if s.Retries == 0 {
+		return ""
+	}
+	reasons := make([]string, 0, len(s.Reasons))
+	for r := range s.Reasons {
+		reasons = append(reasons, r)
+	}
+	slices.SortFunc(reasons, func(a, b string) int {
+		if n := s.Reasons[b] - s.Reasons[a]; n != 0 {
+			return n
+		}
+		return strings.Compare(a, b)
+	})
+	for i, r := range reasons {
+		reasons[i] = fmt.Sprintf("%s x%d", r, s.Reasons[r])
+	}
+
+	noun := "retries"
+	if s.Retries == 1 {
+		noun = "retry"
+	}
+	out := fmt.Sprintf("%d %s (%s): %d requests recovered", s.Retries, noun, strings.Join(reasons, ", "), s.Recovered)
+	if s.GaveUp > 0 {
+		out += fmt.Sprintf(", %d gave up", s.GaveUp)
+	}
+	return out
+}
+
+// retryCounter accumulates RetryStats across concurrent requests.
+type retryCounter struct {
+	mu    sync.Mutex
+	stats RetryStats
+}
+
+func (rc *retryCounter) retry(reason string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.stats.Retries++
+	if rc.stats.Reasons == nil {
+		rc.stats.Reasons = make(map[string]int)
+	}
+	rc.stats.Reasons[reason]++
+}
+
+// finish records the outcome of a request that was retried at least once.
+func (rc *retryCounter) finish(retries int, failed bool) {
+	if retries == 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if failed {
+		rc.stats.GaveUp++
+	} else {
+		rc.stats.Recovered++
+	}
+}
+
+// RetryStats returns the retries made so far.
+func (c *Client) RetryStats() RetryStats {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	s := c.stats.stats
+	s.Reasons = make(map[string]int, len(s.Reasons))
+	for r, n := range c.stats.stats.Reasons {
+		s.Reasons[r] = n
+	}
+	return s
+}
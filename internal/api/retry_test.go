@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryServer fails the first `failures` requests with status, then serves
+// an empty label list.
+func retryServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			if status == 0 {
+				// Drop the connection without a response.
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+				return
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"message":"upstream error"}`))
+			return
+		}
+		w.Write([]byte(`{"labels":[]}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int32
+		status        int // 0 drops the connection
+		header        http.Header
+		retries       int
+		wantErr       bool
+		wantCalls     int32
+		wantRecovered int
+		wantGaveUp    int
+		wantReason    string
+	}{
+		{name: "502 then success", failures: 2, status: http.StatusBadGateway, retries: 3, wantCalls: 3, wantRecovered: 1, wantReason: "HTTP 502"},
+		{name: "429 with Retry-After", failures: 1, status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"0"}}, retries: 3, wantCalls: 2, wantRecovered: 1, wantReason: "HTTP 429"},
+		{name: "dropped connection", failures: 1, status: 0, retries: 3, wantCalls: 2, wantRecovered: 1, wantReason: "connection reset"},
+		{name: "budget exhausted", failures: 10, status: http.StatusServiceUnavailable, retries: 2, wantErr: true, wantCalls: 3, wantGaveUp: 1, wantReason: "HTTP 503"},
+		{name: "retries disabled", failures: 1, status: http.StatusBadGateway, retries: 0, wantErr: true, wantCalls: 1},
+		{name: "403 not retried", failures: 1, status: http.StatusForbidden, retries: 3, wantErr: true, wantCalls: 1},
+		{name: "501 not retried", failures: 1, status: http.StatusNotImplemented, retries: 3, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, calls := retryServer(t, tt.failures, tt.status, tt.header)
+			c := testClient(t, ts, "tok")
+			WithRetries(tt.retries)(c)
+			c.retryBase = time.Millisecond
+
+			_, err := c.GetLabels(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			stats := c.RetryStats()
+			if stats.Retries != int(tt.wantCalls)-1 {
+				t.Errorf("Retries = %d, want %d", stats.Retries, tt.wantCalls-1)
+			}
+			if stats.Recovered != tt.wantRecovered || stats.GaveUp != tt.wantGaveUp {
+				t.Errorf("Recovered/GaveUp = %d/%d, want %d/%d", stats.Recovered, stats.GaveUp, tt.wantRecovered, tt.wantGaveUp)
+			}
+			if tt.wantReason != "" && stats.Reasons[tt.wantReason] != stats.Retries {
+				t.Errorf("Reasons = %v, want all %q", stats.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestClientRetryHonorsContext(t *testing.T) {
+	ts, calls := retryServer(t, 10, http.StatusBadGateway, http.Header{"Retry-After": {"30"}})
+	c := testClient(t, ts, "tok")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetLabels(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %s, want cancellation to cut the Retry-After wait short", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "empty", value: ""},
+		{name: "seconds", value: "7", want: 7 * time.Second, wantOK: true},
+		{name: "zero seconds", value: "0", want: 0, wantOK: true},
+		{name: "negative seconds", value: "-3"},
+		{name: "http date", value: "Sun, 01 Jun 2025 12:00:20 GMT", want: 20 * time.Second, wantOK: true},
+		{name: "past http date", value: "Sun, 01 Jun 2025 11:00:00 GMT", want: 0, wantOK: true},
+		{name: "garbage", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{retryBase: 100 * time.Millisecond}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{attempt: 0, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{attempt: 2, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{attempt: 10, min: maxBackoff / 2, max: maxBackoff},
+		{attempt: 70, min: maxBackoff / 2, max: maxBackoff}, // shift overflow
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := c.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %s, want in [%s, %s]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryStatsString(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats RetryStats
+		want  string
+	}{
+		{name: "no retries", stats: RetryStats{}, want: ""},
+		{
+			name:  "one retry",
+			stats: RetryStats{Retries: 1, Reasons: map[string]int{"HTTP 429": 1}, Recovered: 1},
+			want:  "1 retry (HTTP 429 x1): 1 requests recovered",
+		},
+		{
+			name:  "sorted by count, with failures",
+			stats: RetryStats{Retries: 7, Reasons: map[string]int{"HTTP 429": 2, "HTTP 502": 5}, Recovered: 4, GaveUp: 1},
+			want:  "7 retries (HTTP 502 x5, HTTP 429 x2): 4 requests recovered, 1 gave up",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	c, err := NewClient("https://fleet.example.com", "tok",
+		WithConcurrency(12), WithTimeout(time.Minute), WithRetries(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.concurrency != 12 || c.httpClient.Timeout != time.Minute || c.retries != 0 {
+		t.Errorf("got concurrency=%d timeout=%s retries=%d", c.concurrency, c.httpClient.Timeout, c.retries)
+	}
+
+	c, err = NewClient("https://fleet.example.com", "tok",
+		WithConcurrency(0), WithTimeout(0), WithRetries(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.concurrency != DefaultConcurrency || c.httpClient.Timeout != DefaultTimeout || c.retries != DefaultRetries {
+		t.Errorf("zero options should keep defaults, got concurrency=%d timeout=%s retries=%d", c.concurrency, c.httpClient.Timeout, c.retries)
+	}
+}
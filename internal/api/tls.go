@@ -0,0 +1,110 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TLSFiles points at PEM files for reaching Fleet servers behind a private
+// PKI or an mTLS-terminating proxy.
+type TLSFiles struct {
+	CACert     string // CA bundle trusted in addition to the system roots
+	ClientCert string // client certificate presented for mTLS
+	ClientKey  string // private key for ClientCert
+}
+
+// WithTLS trusts an extra CA bundle and/or presents a client certificate.
+// The files are read by NewClient, which reports any errors.
+func WithTLS(files TLSFiles) Option {
+	return func(c *Client) {
+		c.tlsFiles = files
+	}
+}
+
+// newTransport clones http.DefaultTransport, so HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY are honored, and applies the client's TLS files.
+func newTransport(files TLSFiles) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if files == (TLSFiles{}) {
+		return transport, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if files.CACert != "" {
+		pem, err := os.ReadFile(files.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA certificate %s: no PEM certificates found", files.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (files.ClientCert == "") != (files.ClientKey == "") {
+		return nil, errors.New("client certificate and client key must be set together")
+	}
+	if files.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(files.ClientCert, files.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate %s: %w", files.ClientCert, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = cfg
+	return transport, nil
+}
+
+// tlsHint returns advice for a TLS failure, or "" if err isn't one. The
+// generic "x509: ..." messages don't say which flag fixes them.
+func tlsHint(err error) string {
+	var (
+		unknownCA   x509.UnknownAuthorityError
+		hostname    x509.HostnameError
+		invalid     x509.CertificateInvalidError
+		verifyError *tls.CertificateVerificationError
+	)
+	switch {
+	case errors.As(err, &unknownCA):
+		return "the server's certificate is signed by an unknown CA; pass the CA bundle with --ca-cert (or ca_cert in the config file)"
+	case errors.As(err, &hostname):
+		return "the server's certificate does not match the host in the Fleet URL"
+	case errors.As(err, &invalid):
+		return "the server's certificate is invalid (expired or not valid for serving)"
+	case errors.As(err, &verifyError):
+		return "the server's certificate failed verification; check --ca-cert"
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "tls: certificate required"), strings.Contains(msg, "tls: bad certificate"):
+		return "the server requires a client certificate it trusts; pass --client-cert and --client-key (or client_cert and client_key in the config file)"
+	case strings.Contains(msg, "tls: unknown certificate authority"):
+		return "the server does not trust the client certificate's CA"
+	}
+	return ""
+}
+
+// TLSError is a request that failed TLS verification, with a hint at the fix.
+type TLSError struct {
+	URL  string
+	Err  error
+	Hint string
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS error connecting to %s: %v\n%s", e.URL, e.Err, e.Hint)
+}
+
+func (e *TLSError) Unwrap() error { return e.Err }
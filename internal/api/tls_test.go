@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePEM writes a PEM block to dir/name and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCertFiles generates a self-signed client certificate, writes it and
+// its key to dir, and returns the paths and the parsed certificate.
+func clientCertFiles(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fleet-plan test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der),
+		writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER), cert
+}
+
+// tlsServer starts an HTTPS stand-in for Fleet that serves an empty label
+// list. With clientCA set it requires client certificates signed by it.
+func tlsServer(t *testing.T, clientCA *x509.Certificate) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(labelsResponse{})
+	}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // expected handshake failures
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA)
+		ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := clientCertFiles(t, dir)
+
+	tests := []struct {
+		name     string
+		mTLS     bool
+		files    func(serverCA string) TLSFiles
+		wantHint string // "" means the request succeeds
+	}{
+		{
+			name:     "unknown CA",
+			files:    func(string) TLSFiles { return TLSFiles{} },
+			wantHint: "--ca-cert",
+		},
+		{
+			name:  "custom CA bundle",
+			files: func(ca string) TLSFiles { return TLSFiles{CACert: ca} },
+		},
+		{
+			name:     "mTLS without client certificate",
+			mTLS:     true,
+			files:    func(ca string) TLSFiles { return TLSFiles{CACert: ca} },
+			wantHint: "--client-cert",
+		},
+		{
+			name: "mTLS with client certificate",
+			mTLS: true,
+			files: func(ca string) TLSFiles {
+				return TLSFiles{CACert: ca, ClientCert: certPath, ClientKey: keyPath}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clientCA *x509.Certificate
+			if tt.mTLS {
+				clientCA = clientCert
+			}
+			ts := tlsServer(t, clientCA)
+			serverCA := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+			c, err := NewClient(ts.URL, "tok", WithTLS(tt.files(serverCA)))
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			c.retryBase = time.Millisecond
+			_, err = c.GetLabels(context.Background())
+			if tt.wantHint == "" {
+				if err != nil {
+					t.Fatalf("GetLabels: %v", err)
+				}
+				return
+			}
+			var tlsErr *TLSError
+			if !errors.As(err, &tlsErr) {
+				t.Fatalf("err = %v, want a *TLSError", err)
+			}
+			if s := c.RetryStats(); s.Retries > 0 {
+				t.Errorf("TLS failure retried %d times, want none", s.Retries)
+			}
+			if !strings.Contains(tlsErr.Hint, tt.wantHint) {
+				t.Errorf("hint = %q, want it to mention %s", tlsErr.Hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := clientCertFiles(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name    string
+		files   TLSFiles
+		wantErr string
+	}{
+		{name: "missing CA file", files: TLSFiles{CACert: filepath.Join(dir, "missing.pem")}, wantErr: "reading CA certificate"},
+		{name: "CA file without certificates", files: TLSFiles{CACert: notPEM}, wantErr: "no PEM certificates"},
+		{name: "certificate without key", files: TLSFiles{ClientCert: certPath}, wantErr: "must be set together"},
+		{name: "key without certificate", files: TLSFiles{ClientKey: keyPath}, wantErr: "must be set together"},
+		{name: "key does not match", files: TLSFiles{ClientCert: certPath, ClientKey: notPEM}, wantErr: "loading client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient("https://fleet.example.com", "tok", WithTLS(tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTransportHonorsProxyEnvironment(t *testing.T) {
+	transport, err := newTransport(TLSFiles{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.Proxy == nil {
+		t.Error("transport ignores HTTPS_PROXY/NO_PROXY")
+	}
+}
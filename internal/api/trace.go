@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// redacted replaces the API token and other credentials in traces.
+const redacted = "REDACTED"
+
+// Trace is a recording of the requests a Client made, in a subset of the
+// HAR 1.2 format so browser devtools and HAR viewers can open it. Request
+// and response bodies are kept in full; the API token is redacted.
+type Trace struct {
+	Log TraceLog `json:"log"`
+}
+
+// TraceLog is the HAR "log" object.
+type TraceLog struct {
+	Version string       `json:"version"`
+	Creator TraceCreator `json:"creator"`
+	Entries []TraceEntry `json:"entries"`
+}
+
+// TraceCreator names the program that wrote the trace.
+type TraceCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// TraceEntry is one HTTP round trip. Retried requests appear once per
+// attempt. Error is set, and Response empty, when no response arrived.
+type TraceEntry struct {
+	StartedDateTime time.Time     `json:"startedDateTime"`
+	Time            float64       `json:"time"` // milliseconds
+	Request         TraceRequest  `json:"request"`
+	Response        TraceResponse `json:"response"`
+	Error           string        `json:"_error,omitempty"`
+}
+
+// TraceRequest is the HAR "request" object.
+type TraceRequest struct {
+	Method  string        `json:"method"`
+	URL     string        `json:"url"`
+	Headers []TraceHeader `json:"headers"`
+}
+
+// TraceResponse is the HAR "response" object.
+type TraceResponse struct {
+	Status     int           `json:"status"`
+	StatusText string        `json:"statusText"`
+	Headers    []TraceHeader `json:"headers"`
+	Content    TraceContent  `json:"content"`
+}
+
+// TraceHeader is a single HTTP header.
+type TraceHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TraceContent is a response body. Bodies that aren't valid UTF-8 are
+// base64-encoded.
+type TraceContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// WithTrace records every request and response the client makes, for
+// SaveTrace.
+func WithTrace() Option {
+	return func(c *Client) {
+		c.trace = &traceRecorder{}
+	}
+}
+
+// WithReplay answers every request from a recorded trace instead of the
+// network. Requests are matched by method, path and query, so the trace
+// replays against any base URL.
+func WithReplay(t *Trace) Option {
+	return func(c *Client) {
+		c.replay = newReplayTransport(t)
+	}
+}
+
+// SaveTrace writes the requests recorded since NewClient to path. It
+// returns the number of entries written, and errors if WithTrace wasn't set.
+func (c *Client) SaveTrace(path, creatorVersion string) (int, error) {
+	if c.trace == nil {
+		return 0, fmt.Errorf("HTTP tracing is not enabled")
+	}
+	t := c.trace.snapshot(creatorVersion)
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	// Bodies can hold org settings and host details; keep the file private.
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return 0, fmt.Errorf("writing HTTP trace: %w", err)
+	}
+	return len(t.Log.Entries), nil
+}
+
+// LoadTrace reads a trace written by SaveTrace (or any HAR file).
+func LoadTrace(path string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading HTTP trace: %w", err)
+	}
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing HTTP trace %s: %w", path, err)
+	}
+	if len(t.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HTTP trace %s has no entries", path)
+	}
+	return &t, nil
+}
+
+// BaseURL returns the scheme and host of the trace's first request, the
+// Fleet server it was recorded against.
+func (t *Trace) BaseURL() string {
+	for _, e := range t.Log.Entries {
+		if u, err := url.Parse(e.Request.URL); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+	return ""
+}
+
+// traceRecorder collects entries from concurrent requests.
+type traceRecorder struct {
+	mu      sync.Mutex
+	entries []TraceEntry
+}
+
+func (r *traceRecorder) add(e TraceEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+}
+
+func (r *traceRecorder) snapshot(creatorVersion string) Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Trace{Log: TraceLog{
+		Version: "1.2",
+		Creator: TraceCreator{Name: "fleet-plan", Version: creatorVersion},
+		Entries: append([]TraceEntry{}, r.entries...),
+	}}
+}
+
+// recordingTransport buffers each response body so it can be both recorded
+// and returned to the caller.
+type recordingTransport struct {
+	base  http.RoundTripper
+	rec   *traceRecorder
+	token string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	entry := TraceEntry{
+		StartedDateTime: start,
+		Request: TraceRequest{
+			Method:  req.Method,
+			URL:     t.redact(req.URL.String()),
+			Headers: t.headers(req.Header),
+		},
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		var body []byte
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		entry.Response = TraceResponse{
+			Status:     resp.StatusCode,
+			StatusText: http.StatusText(resp.StatusCode),
+			Headers:    t.headers(resp.Header),
+			Content:    t.content(body, resp.Header.Get("Content-Type")),
+		}
+	}
+	entry.Time = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		entry.Error = t.redact(err.Error())
+		resp = nil
+	}
+	t.rec.add(entry)
+	return resp, err
+}
+
+// redact removes the API token from s.
+func (t *recordingTransport) redact(s string) string {
+	if t.token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, t.token, redacted)
+}
+
+// headers flattens h in a stable order, redacting credentials.
+func (t *recordingTransport) headers(h http.Header) []TraceHeader {
+	out := []TraceHeader{}
+	for _, name := range sortedHeaderNames(h) {
+		for _, v := range h[name] {
+			switch http.CanonicalHeaderKey(name) {
+			case "Authorization":
+				scheme, _, _ := strings.Cut(v, " ")
+				v = scheme + " " + redacted
+			case "Cookie", "Set-Cookie":
+				v = redacted
+			default:
+				v = t.redact(v)
+			}
+			out = append(out, TraceHeader{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func (t *recordingTransport) content(body []byte, mimeType string) TraceContent {
+	c := TraceContent{Size: len(body), MimeType: mimeType}
+	if utf8.Valid(body) {
+		c.Text = t.redact(string(body))
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+func sortedHeaderNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// replayTransport serves recorded responses. Entries for the same request
+// are served in recorded order, so retries replay as they happened; the
+// last one repeats once they run out. Recorded transport errors replay as
+// plain errors, which are not retried.
+type replayTransport struct {
+	mu      sync.Mutex
+	entries map[string][]TraceEntry
+	served  map[string]int
+}
+
+func newReplayTransport(t *Trace) *replayTransport {
+	rt := &replayTransport{entries: make(map[string][]TraceEntry), served: make(map[string]int)}
+	for _, e := range t.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			continue
+		}
+		key := replayKey(e.Request.Method, u)
+		rt.entries[key] = append(rt.entries[key], e)
+	}
+	return rt
+}
+
+// replayKey identifies a request by method, path and normalized query.
+func replayKey(method string, u *url.URL) string {
+	return method + " " + u.Path + "?" + u.Query().Encode()
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := replayKey(req.Method, req.URL)
+	rt.mu.Lock()
+	recorded := rt.entries[key]
+	i := min(rt.served[key], len(recorded)-1)
+	rt.served[key]++
+	rt.mu.Unlock()
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("replay: no recorded response for %s %s", req.Method, req.URL.RequestURI())
+	}
+	e := recorded[i]
+	if e.Error != "" {
+		return nil, fmt.Errorf("replay: recorded error: %s", e.Error)
+	}
+
+	body := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Response.Content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("replay: decoding body for %s: %w", key, err)
+		}
+		body = decoded
+	}
+	header := make(http.Header)
+	for _, h := range e.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText),
+		StatusCode:    e.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
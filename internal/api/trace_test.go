@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// replayClient returns a client that answers from the trace at path.
+func replayClient(t *testing.T, path string) *Client {
+	t.Helper()
+	trace, err := LoadTrace(path)
+	if err != nil {
+		t.Fatalf("LoadTrace: %v", err)
+	}
+	c, err := NewClient(trace.BaseURL(), "replay", WithReplay(trace))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.retryBase = time.Millisecond
+	return c
+}
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	const token = "s3cret-t0ken"
+	var labelCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/fleet/teams":
+			json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}}})
+		case "/api/v1/fleet/labels":
+			if labelCalls.Add(1) == 1 {
+				http.Error(w, "upstream down", http.StatusBadGateway)
+				return
+			}
+			json.NewEncoder(w).Encode(labelsResponse{Labels: []Label{{ID: 7, Name: "Canaries"}}})
+		case "/api/v1/fleet/scripts":
+			w.Write([]byte(`{"scripts": [{"id": 3, "name": "setup.sh"}]}`))
+		case "/api/v1/fleet/scripts/3":
+			w.Write([]byte("#!/bin/sh\n\xff\xfe binary-ish\n")) // not UTF-8: stored as base64
+		case "/api/v1/fleet/config":
+			w.Write([]byte(`{"org_info": {"org_name": "Example"}, "echo": "` + token + `"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Setenv("FLEET_PLAN_INSECURE", "1")
+	recorder, err := NewClient(ts.URL, token, WithTrace())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	recorder.retryBase = time.Millisecond
+
+	scope := FullFetch
+	scope.Global = true
+	want, err := recorder.FetchAll(context.Background(), scope)
+	if err != nil {
+		t.Fatalf("FetchAll: %v", err)
+	}
+	ts.Close()
+
+	path := filepath.Join(t.TempDir(), "trace.har")
+	n, err := recorder.SaveTrace(path, "test")
+	if err != nil {
+		t.Fatalf("SaveTrace: %v", err)
+	}
+	if n == 0 {
+		t.Fatal("no requests recorded")
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), token) {
+		t.Error("trace contains the API token")
+	}
+	if !strings.Contains(string(data), `"Bearer REDACTED"`) {
+		t.Error("trace is missing the redacted Authorization header")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("trace mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	replayer := replayClient(t, path)
+	got, err := replayer.FetchAll(context.Background(), scope)
+	if err != nil {
+		t.Fatalf("replayed FetchAll: %v", err)
+	}
+	want.Config["echo"] = redacted // the token in response bodies is redacted too
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed state differs:\n got %+v\nwant %+v", got, want)
+	}
+	if s := replayer.RetryStats(); s.Retries != 1 || s.Reasons["HTTP 502"] != 1 {
+		t.Errorf("replayed retries = %+v, want the recorded 502 retried once", s)
+	}
+}
+
+func TestReplayFixture(t *testing.T) {
+	c := replayClient(t, filepath.Join("testdata", "fleet.har"))
+	ctx := context.Background()
+
+	caps, err := c.GetCapabilities(ctx)
+	if err != nil {
+		t.Fatalf("GetCapabilities: %v", err)
+	}
+	if caps.GlobalRole != "gitops" {
+		t.Errorf("global role = %q, want gitops", caps.GlobalRole)
+	}
+	if v, err := c.DetectVersion(ctx); err != nil || v.String() != "4.58.0" {
+		t.Errorf("DetectVersion = %s, %v; want 4.58.0", v, err)
+	}
+	teams, err := c.GetTeams(ctx)
+	if err != nil {
+		t.Fatalf("GetTeams: %v", err)
+	}
+	if len(teams) != 2 || teams[1].Name != "Servers" {
+		t.Errorf("teams = %+v, want Workstations and Servers", teams)
+	}
+
+	_, err = c.GetLabels(ctx)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response for GET /api/v1/fleet/labels") {
+		t.Errorf("unrecorded request: err = %v, want a replay miss", err)
+	}
+}
+
+func TestLoadTraceErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.har")
+	os.WriteFile(empty, []byte(`{"log": {"version": "1.2", "entries": []}}`), 0o600)
+	garbage := filepath.Join(dir, "garbage.har")
+	os.WriteFile(garbage, []byte(`not json`), 0o600)
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "missing", path: filepath.Join(dir, "missing.har"), wantErr: "reading HTTP trace"},
+		{name: "not JSON", path: garbage, wantErr: "parsing HTTP trace"},
+		{name: "no entries", path: empty, wantErr: "has no entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTrace(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
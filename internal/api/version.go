@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ServerVersion is a Fleet server release. The zero value is an unknown
+// version (detection failed, or a development build), which is assumed to
+// support every feature.
+type ServerVersion struct {
+	Major, Minor, Patch int
+	Raw                 string // as reported by the server, e.g. "4.58.0-1-g5e4e3f2"
+}
+
+var versionRE = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion parses a Fleet version string. Anything after major.minor.patch
+// (pre-release or git describe suffixes) is ignored; 0.0.0 development
+// builds and unparsable strings yield an unknown version that keeps Raw.
+func ParseVersion(s string) ServerVersion {
+	m := versionRE.FindStringSubmatch(s)
+	if m == nil {
+		return ServerVersion{Raw: s}
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	if major == 0 && minor == 0 && patch == 0 {
+		return ServerVersion{Raw: s}
+	}
+	return ServerVersion{Major: major, Minor: minor, Patch: patch, Raw: s}
+}
+
+// Known reports whether the version was detected and parsed.
+func (v ServerVersion) Known() bool {
+	return v.Major != 0 || v.Minor != 0 || v.Patch != 0
+}
+
+// AtLeast reports whether v is min or later. Unknown versions are treated as
+// the latest release.
+func (v ServerVersion) AtLeast(min ServerVersion) bool {
+	if !v.Known() {
+		return true
+	}
+	if v.Major != min.Major {
+		return v.Major > min.Major
+	}
+	if v.Minor != min.Minor {
+		return v.Minor > min.Minor
+	}
+	return v.Patch >= min.Patch
+}
+
+// Supports reports whether the server has a feature.
+func (v ServerVersion) Supports(f Feature) bool {
+	return v.AtLeast(f.Since())
+}
+
+func (v ServerVersion) String() string {
+	if v.Known() {
+		return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	}
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return "unknown"
+}
+
+// Feature is a Fleet API or GitOps capability that first shipped in a known
+// server release.
+type Feature string
+
+const (
+	FeatureConfigurationProfiles  Feature = "configuration_profiles"
+	FeatureAppStoreApps           Feature = "app_store_apps"
+	FeatureFleetMaintainedCatalog Feature = "fleet_maintained_catalog"
+	FeatureFleetMaintainedApps    Feature = "fleet_maintained_apps"
+	FeatureNoTeamPolicies         Feature = "no_team_policies"
+	FeatureManualLabels           Feature = "manual_labels"
+	FeaturePolicyInstallSoftware  Feature = "policy_install_software"
+	FeaturePolicyRunScript        Feature = "policy_run_script"
+)
+
+// featureInfo is one row of the compatibility table.
+type featureInfo struct {
+	since string
+	key   string // GitOps YAML key that needs the feature; "" for API-only changes
+}
+
+// compatibility maps each feature to the first Fleet release with it. The
+// client uses it to pick endpoint variants and field mappings, and the diff
+// to warn about GitOps keys the server doesn't understand.
+var compatibility = map[Feature]featureInfo{
+	// /configuration_profiles (macOS and Windows, profile_uuid) replaced
+	// /mdm/apple/profiles (macOS only, numeric profile_id).
+	FeatureConfigurationProfiles: {since: "4.41.0"},
+	// /vpp_tokens, /software/app_store_apps and software.app_store_apps.
+	FeatureAppStoreApps:           {since: "4.56.0", key: "software.app_store_apps"},
+	FeatureFleetMaintainedCatalog: {since: "4.57.0"},
+	FeatureFleetMaintainedApps:    {since: "4.67.0", key: "software.fleet_maintained_apps"},
+	// /teams/0/policies and policies in teams/no-team.yml.
+	FeatureNoTeamPolicies:        {since: "4.63.0", key: "policies (teams/no-team.yml)"},
+	FeatureManualLabels:          {since: "4.62.0", key: "label_membership_type: manual"},
+	FeaturePolicyInstallSoftware: {since: "4.57.0", key: "policies[].install_software"},
+	FeaturePolicyRunScript:       {since: "4.58.0", key: "policies[].run_script"},
+}
+
+// Since returns the first Fleet release with the feature.
+func (f Feature) Since() ServerVersion {
+	return ParseVersion(compatibility[f].since)
+}
+
+// Key returns the GitOps YAML key that needs the feature, or "" if the
+// feature only changes the API.
+func (f Feature) Key() string {
+	return compatibility[f].key
+}
+
+type versionResponse struct {
+	Version string `json:"version"`
+}
+
+// DetectVersion fetches the server version from GET /api/v1/fleet/version
+// and remembers it, so later requests pick the endpoints and fields that
+// release has. Call it before FetchAll; it is not safe to call concurrently
+// with other requests. On error the version stays unknown.
+func (c *Client) DetectVersion(ctx context.Context) (ServerVersion, error) {
+	var resp versionResponse
+	if err := c.get(ctx, "/api/v1/fleet/version", nil, &resp); err != nil {
+		return ServerVersion{}, fmt.Errorf("detecting Fleet version: %w", err)
+	}
+	c.version = ParseVersion(resp.Version)
+	return c.version, nil
+}
+
+// Version returns the version found by DetectVersion.
+func (c *Client) Version() ServerVersion {
+	return c.version
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      string
+		wantKnown bool
+	}{
+		{in: "4.58.0", want: "4.58.0", wantKnown: true},
+		{in: "v4.41.2", want: "4.41.2", wantKnown: true},
+		{in: "4.63.0-rc.1", want: "4.63.0", wantKnown: true},
+		{in: "4.60.1-12-g5e4e3f2", want: "4.60.1", wantKnown: true},
+		{in: "0.0.0-SNAPSHOT-5e4e3f2", want: "0.0.0-SNAPSHOT-5e4e3f2"},
+		{in: "dev", want: "dev"},
+		{in: "", want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			v := ParseVersion(tt.in)
+			if v.String() != tt.want || v.Known() != tt.wantKnown {
+				t.Errorf("ParseVersion(%q) = %s (known %v), want %s (known %v)", tt.in, v, v.Known(), tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestServerVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		v, min string
+		want   bool
+	}{
+		{v: "4.58.0", min: "4.58.0", want: true},
+		{v: "4.58.1", min: "4.58.0", want: true},
+		{v: "4.57.9", min: "4.58.0", want: false},
+		{v: "4.100.0", min: "4.58.0", want: true},
+		{v: "5.0.0", min: "4.99.0", want: true},
+		{v: "3.99.0", min: "4.0.0", want: false},
+		{v: "dev", min: "9.0.0", want: true}, // unknown: assume latest
+	}
+	for _, tt := range tests {
+		if got := ParseVersion(tt.v).AtLeast(ParseVersion(tt.min)); got != tt.want {
+			t.Errorf("%s.AtLeast(%s) = %v, want %v", tt.v, tt.min, got, tt.want)
+		}
+	}
+
+	for f, info := range compatibility {
+		if !ParseVersion(info.since).Known() {
+			t.Errorf("feature %s: invalid since version %q", f, info.since)
+		}
+	}
+}
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "release", status: http.StatusOK, body: `{"version": "4.58.0", "branch": "HEAD", "go_version": "go1.23"}`, want: "4.58.0"},
+		{name: "not found", status: http.StatusNotFound, body: `{}`, want: "unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/fleet/version" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			c := testClient(t, ts, "tok")
+			_, err := c.DetectVersion(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := c.Version().String(); got != tt.want {
+				t.Errorf("version = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAllVersionVariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    []string // request paths that must be made
+		notWant []string // request paths that must not be made
+	}{
+		{
+			name:    "current server",
+			version: "4.70.0",
+			want: []string{"/api/v1/fleet/configuration_profiles", "/api/v1/fleet/vpp_tokens",
+				"/api/v1/fleet/software/fleet_maintained_apps", "/api/v1/fleet/teams/0/policies"},
+			notWant: []string{"/api/v1/fleet/mdm/apple/profiles"},
+		},
+		{
+			name:    "no version endpoint",
+			want:    []string{"/api/v1/fleet/configuration_profiles", "/api/v1/fleet/teams/0/policies"},
+			notWant: []string{"/api/v1/fleet/mdm/apple/profiles"},
+		},
+		{
+			name:    "old server",
+			version: "4.40.0",
+			want:    []string{"/api/v1/fleet/mdm/apple/profiles"},
+			notWant: []string{"/api/v1/fleet/configuration_profiles", "/api/v1/fleet/vpp_tokens",
+				"/api/v1/fleet/software/fleet_maintained_apps", "/api/v1/fleet/teams/0/policies"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			paths := make(map[string]bool)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				paths[r.URL.Path] = true
+				mu.Unlock()
+				switch r.URL.Path {
+				case "/api/v1/fleet/version":
+					if tt.version == "" {
+						http.NotFound(w, r)
+						return
+					}
+					json.NewEncoder(w).Encode(versionResponse{Version: tt.version})
+				case "/api/v1/fleet/teams":
+					json.NewEncoder(w).Encode(teamsResponse{Teams: []Team{{ID: 1, Name: "Workstations"}}})
+				case "/api/v1/fleet/mdm/apple/profiles":
+					w.Write([]byte(`{"profiles": [{"profile_id": 12, "team_id": 1, "name": "Wi-Fi", "identifier": "com.example.wifi"}]}`))
+				default:
+					w.Write([]byte(`{}`))
+				}
+			}))
+			defer ts.Close()
+
+			c := testClient(t, ts, "tok")
+			c.DetectVersion(context.Background())
+			state, err := c.FetchAll(context.Background())
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+			for _, p := range tt.want {
+				if !paths[p] {
+					t.Errorf("expected request to %s", p)
+				}
+			}
+			for _, p := range tt.notWant {
+				if paths[p] {
+					t.Errorf("unexpected request to %s", p)
+				}
+			}
+			if state.ServerVersion != c.Version() {
+				t.Errorf("state.ServerVersion = %s, want %s", state.ServerVersion, c.Version())
+			}
+			if tt.version == "4.40.0" {
+				if !state.VPPTokensUnavailable || !state.NoTeam.PoliciesUnavailable {
+					t.Error("VPP tokens and No team policies should be unavailable on an old server")
+				}
+				want := Profile{ProfileUUID: "12", Name: "Wi-Fi", Platform: "darwin"}
+				if got := state.Teams[0].Profiles; len(got) != 1 || got[0] != want {
+					t.Errorf("legacy profiles = %+v, want [%+v]", got, want)
+				}
+			}
+		})
+	}
+}
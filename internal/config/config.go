@@ -1,5 +1,6 @@
 // Package config resolves fleet-plan authentication.
-// Priority: flags > env vars > config file (~/.config/fleet-plan.json or <repo>/.config/fleet-plan.json).
+// Priority: flags > env vars > config file (<repo>/.config/fleet-plan.json,
+// ~/.config/fleet-plan.json, then fleetctl's ~/.fleet/config).
 package config
 
 import (
@@ -7,6 +8,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 const (
@@ -23,6 +26,8 @@ const (
 type ResolvedAuth struct {
 	URL   string
 	Token string
+
+	source contextSource // config file context that filled gaps, if any
 }
 
 // configFile supports both flat and contexts-based JSON formats:
@@ -32,70 +37,172 @@ type ResolvedAuth struct {
 type configFile struct {
 	URL            string                       `json:"url"`
 	Token          string                       `json:"token"`
-	Contexts       map[string]configFileContext  `json:"contexts"`
+	TokenCommand   string                       `json:"token_command"`
+	Contexts       map[string]configFileContext `json:"contexts"`
 	DefaultContext string                       `json:"default_context"`
+
+	// API client tuning; see ClientSettings.
+	Concurrency int    `json:"concurrency"`
+	Timeout     string `json:"timeout"` // Go duration, e.g. "45s"
+	Retries     *int   `json:"retries"`
+	Cache       bool   `json:"cache"`
+
+	// TLS files for the flat format, and defaults for contexts; see TLSSettings.
+	CACert     string `json:"ca_cert"`
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
 }
 
 type configFileContext struct {
-	URL   string `json:"url"`
-	Token string `json:"token"`
+	URL          string `json:"url"`
+	Token        string `json:"token"`
+	TokenCommand string `json:"token_command"` // credential helper that prints the token
+	CACert       string `json:"ca_cert"`
+	ClientCert   string `json:"client_cert"`
+	ClientKey    string `json:"client_key"`
+}
+
+// hasAuth reports whether the context can supply a URL or token.
+func (c configFileContext) hasAuth() bool {
+	return c.URL != "" || c.Token != "" || c.TokenCommand != ""
+}
+
+// context returns the named context. With no name it returns the flat format
+// if it sets a URL or token, else default_context. Top-level TLS keys fill
+// gaps in a context.
+func (c configFile) context(name string) (configFileContext, bool) {
+	flat := configFileContext{
+		URL: c.URL, Token: c.Token, TokenCommand: c.TokenCommand,
+		CACert: c.CACert, ClientCert: c.ClientCert, ClientKey: c.ClientKey,
+	}
+	if name == "" {
+		if flat.hasAuth() {
+			return flat, true
+		}
+		name = c.DefaultContext
+	}
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return flat, false
+	}
+	if ctx.CACert == "" {
+		ctx.CACert = c.CACert
+	}
+	if ctx.ClientCert == "" && ctx.ClientKey == "" {
+		ctx.ClientCert, ctx.ClientKey = c.ClientCert, c.ClientKey
+	}
+	return ctx, true
+}
+
+// hasPlaintextToken reports whether the file stores a token rather than a
+// token_command.
+func (c configFile) hasPlaintextToken() bool {
+	if c.Token != "" {
+		return true
+	}
+	for _, ctx := range c.Contexts {
+		if ctx.Token != "" {
+			return true
+		}
+	}
+	return false
 }
 
-// resolve returns the effective URL and token, handling both flat and contexts formats.
-func (c configFile) resolve() (string, string) {
-	if c.URL != "" || c.Token != "" {
-		return c.URL, c.Token
+// hasTokenCommand reports whether the file sets a token_command anywhere.
+func (c configFile) hasTokenCommand() bool {
+	if c.TokenCommand != "" {
+		return true
 	}
-	if c.DefaultContext != "" {
-		if ctx, ok := c.Contexts[c.DefaultContext]; ok {
-			return ctx.URL, ctx.Token
+	for _, ctx := range c.Contexts {
+		if ctx.TokenCommand != "" {
+			return true
 		}
 	}
-	return "", ""
+	return false
 }
 
+// warnedPaths tracks config files already warned about, since auth and client
+// settings each load the file.
+var warnedPaths = make(map[string]bool)
+
 // loadConfigFile reads .config/fleet-plan.json from the given directory.
-// Returns zero values if the file doesn't exist or can't be parsed.
+// Returns zero values if the file doesn't exist or can't be parsed. Files
+// holding a plaintext token get a warning if others can read them.
 func loadConfigFile(root string) configFile {
 	path := filepath.Join(root, ConfigRelPath)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return configFile{}
 	}
-	if info, err := os.Stat(path); err == nil {
-		if mode := info.Mode().Perm(); mode&0o077 != 0 {
-			fmt.Fprintf(os.Stderr, "warning: %s is readable by others (mode %04o), consider chmod 600\n", path, mode)
-		}
-	}
 	var cfg configFile
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return configFile{}
 	}
+	if cfg.hasPlaintextToken() {
+		warnIfReadable(path)
+	}
 	return cfg
 }
 
+// warnIfReadable warns once per path when a file holding a token is
+// readable by group or others.
+func warnIfReadable(path string) {
+	info, err := os.Stat(path)
+	if err != nil || warnedPaths[path] {
+		return
+	}
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		warnedPaths[path] = true
+		fmt.Fprintf(os.Stderr, "warning: %s is readable by others (mode %04o), consider chmod 600\n", path, mode)
+	}
+}
+
 // ResolveAuth resolves auth with priority: flags > env vars > config file.
 // Config file is searched in: 1) repoRoot/.config/ 2) $HOME/.config/
+// 3) fleetctl's $HOME/.fleet/config ("default" context)
 func ResolveAuth(flagURL, flagToken string, repoRoot ...string) (*ResolvedAuth, error) {
+	return ResolveAuthContext(flagURL, flagToken, "", repoRoot...)
+}
+
+// ResolveAuthContext is ResolveAuth with an explicit context name (--context).
+// A named context replaces the env vars and default_context: the first
+// config file defining it supplies whatever the flags don't, and it is an
+// error if no file defines it. A context's token_command runs only when no
+// token is otherwise set, and only from $HOME/.config/fleet-plan.json: anyone
+// who can change the repo could otherwise run commands on the machine
+// planning it.
+func ResolveAuthContext(flagURL, flagToken, contextName string, repoRoot ...string) (*ResolvedAuth, error) {
 	url := flagURL
 	token := flagToken
 
-	// Env vars fill in gaps
-	if url == "" {
-		url = os.Getenv(EnvURL)
-	}
-	if token == "" {
-		token = os.Getenv(EnvToken)
+	// Env vars fill in gaps, unless a context was picked explicitly
+	if contextName == "" {
+		if url == "" {
+			url = os.Getenv(EnvURL)
+		}
+		if token == "" {
+			token = os.Getenv(EnvToken)
+		}
 	}
 
 	// Config file fills remaining gaps (repo root first, then home dir)
+	var src contextSource
 	if url == "" || token == "" {
-		cfgURL, cfgToken := findConfigAuth(repoRoot...)
+		var err error
+		src, err = findContext(contextName, configFileContext.hasAuth, repoRoot...)
+		if err != nil {
+			return nil, err
+		}
 		if url == "" {
-			url = cfgURL
+			url = src.ctx.URL
 		}
 		if token == "" {
-			token = cfgToken
+			token = src.ctx.Token
+		}
+		if token == "" && src.ctx.TokenCommand != "" {
+			if token, err = runTokenCommand(src.ctx.TokenCommand); err != nil {
+				return nil, fmt.Errorf("%s: %w", src.path, err)
+			}
 		}
 	}
 
@@ -106,22 +213,163 @@ func ResolveAuth(flagURL, flagToken string, repoRoot ...string) (*ResolvedAuth,
 		return nil, fmt.Errorf("API token required (--token or $%s)", EnvToken)
 	}
 
-	return &ResolvedAuth{URL: url, Token: token}, nil
+	return &ResolvedAuth{URL: url, Token: token, source: src}, nil
+}
+
+// contextSource is a context and the config file it came from.
+type contextSource struct {
+	ctx  configFileContext
+	path string // config file
+	root string // directory relative paths resolve against
 }
 
-// findConfigAuth checks repo root then $HOME for a config file.
-func findConfigAuth(repoRoot ...string) (string, string) {
-	// Try repo root first
+// findContext searches repo root, then $HOME, then fleetctl's config for a
+// context. With no name it returns the first file's default context for
+// which has returns true (fleetctl's "default" context last), and a zero
+// source if there is none. A name that no file defines is an error, and so
+// is a token_command in the repo config.
+func findContext(name string, has func(configFileContext) bool, repoRoot ...string) (contextSource, error) {
+	var sources []func() (contextSource, bool)
+	fromJSON := func(root string) func() (contextSource, bool) {
+		return func() (contextSource, bool) {
+			ctx, ok := loadConfigFile(root).context(name)
+			return contextSource{ctx: ctx, path: filepath.Join(root, ConfigRelPath), root: root}, ok
+		}
+	}
 	if len(repoRoot) > 0 && repoRoot[0] != "" {
-		cfg := loadConfigFile(repoRoot[0])
-		if u, t := cfg.resolve(); u != "" || t != "" {
-			return u, t
+		if loadConfigFile(repoRoot[0]).hasTokenCommand() {
+			return contextSource{}, fmt.Errorf("%s: token_command is only read from $HOME/%s; move it there",
+				filepath.Join(repoRoot[0], ConfigRelPath), ConfigRelPath)
+		}
+		sources = append(sources, fromJSON(repoRoot[0]))
+	}
+	home, homeErr := os.UserHomeDir()
+	if homeErr == nil {
+		sources = append(sources, fromJSON(home), func() (contextSource, bool) {
+			ctx, ok := loadFleetctlConfig(home).context(name)
+			return contextSource{ctx: ctx, path: filepath.Join(home, FleetctlConfigRelPath), root: home}, ok
+		})
+	}
+
+	var searched []string
+	for _, load := range sources {
+		src, ok := load()
+		searched = append(searched, src.path)
+		if name == "" {
+			if has(src.ctx) {
+				return src, nil
+			}
+			continue
+		}
+		if ok {
+			return src, nil
 		}
 	}
-	// Fall back to $HOME
+	if name != "" {
+		return contextSource{}, fmt.Errorf("context %q not found in %s", name, strings.Join(searched, ", "))
+	}
+	return contextSource{}, nil
+}
+
+// ClientSettings tunes the Fleet API client. Zero values (nil Retries) leave
+// the client defaults in place.
+type ClientSettings struct {
+	Concurrency int           // parallel API requests
+	Timeout     time.Duration // per-request timeout
+	Retries     *int          // retries per GET on 429, 5xx or dropped connections
+	Cache       bool          // cache slow-changing responses on disk
+}
+
+// ResolveClientSettings fills unset fields of flags from the config file
+// ("concurrency", "timeout", "retries" and "cache" keys), searching the repo root
+// first and then $HOME, like ResolveAuth.
+func ResolveClientSettings(flags ClientSettings, repoRoot ...string) (ClientSettings, error) {
+	s := flags
+
+	var roots []string
+	if len(repoRoot) > 0 && repoRoot[0] != "" {
+		roots = append(roots, repoRoot[0])
+	}
 	if home, err := os.UserHomeDir(); err == nil {
-		cfg := loadConfigFile(home)
-		return cfg.resolve()
+		roots = append(roots, home)
+	}
+
+	for _, root := range roots {
+		cfg := loadConfigFile(root)
+		if s.Concurrency == 0 {
+			s.Concurrency = cfg.Concurrency
+		}
+		if s.Timeout == 0 && cfg.Timeout != "" {
+			d, err := time.ParseDuration(cfg.Timeout)
+			if err != nil || d <= 0 {
+				return ClientSettings{}, fmt.Errorf("%s: invalid timeout %q (want a duration like \"45s\")", filepath.Join(root, ConfigRelPath), cfg.Timeout)
+			}
+			s.Timeout = d
+		}
+		if s.Retries == nil {
+			s.Retries = cfg.Retries
+		}
+		s.Cache = s.Cache || cfg.Cache
+	}
+
+	if s.Timeout < 0 {
+		return ClientSettings{}, fmt.Errorf("timeout must be positive, got %s", s.Timeout)
+	}
+	if s.Concurrency < 0 {
+		return ClientSettings{}, fmt.Errorf("concurrency must be at least 1, got %d", s.Concurrency)
+	}
+	if s.Retries != nil && *s.Retries < 0 {
+		return ClientSettings{}, fmt.Errorf("retries must be 0 or more, got %d", *s.Retries)
+	}
+	return s, nil
+}
+
+// TLSSettings holds PEM file paths for Fleet servers behind a private PKI or
+// an mTLS-terminating proxy.
+type TLSSettings struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// ResolveTLS fills unset fields of flags from the config file context auth
+// was resolved from ("ca_cert", "client_cert" and "client_key", or
+// fleetctl's "rootca"), so TLS files always belong to the server the token
+// is for. When flags or env vars supplied both URL and token, only flags
+// apply. Relative paths in a config file are resolved against the
+// directory holding .config/, and "~/" against $HOME. A client certificate
+// and key always come from the same place.
+func ResolveTLS(flags TLSSettings, auth *ResolvedAuth) (TLSSettings, error) {
+	s := flags
+
+	var src contextSource
+	if auth != nil {
+		src = auth.source
+	}
+	if s.CACert == "" {
+		s.CACert = configPath(src.root, src.ctx.CACert)
+	}
+	if s.ClientCert == "" && s.ClientKey == "" {
+		s.ClientCert = configPath(src.root, src.ctx.ClientCert)
+		s.ClientKey = configPath(src.root, src.ctx.ClientKey)
+	}
+
+	if (s.ClientCert == "") != (s.ClientKey == "") {
+		return TLSSettings{}, fmt.Errorf("client certificate and key must be set together (--client-cert and --client-key)")
+	}
+	return s, nil
+}
+
+// configPath resolves a path from a config file under root.
+func configPath(root, path string) string {
+	switch {
+	case path == "" || filepath.IsAbs(path):
+		return path
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		return path
 	}
-	return "", ""
+	return filepath.Join(root, path)
 }
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolveAuth(t *testing.T) {
@@ -181,87 +185,359 @@ func TestResolveAuthConfigFile(t *testing.T) {
 	}
 }
 
-func TestLoadConfigFileMissing(t *testing.T) {
-	cfg := loadConfigFile(t.TempDir())
-	u, tok := cfg.resolve()
-	if u != "" || tok != "" {
-		t.Errorf("expected empty config for missing file, got url=%q token=%q", u, tok)
-	}
-}
-
-func TestConfigFileResolve(t *testing.T) {
+// TestResolveAuthContextConfigFormats covers how the flat and contexts
+// formats pick a URL and token when no context is named.
+func TestResolveAuthContextConfigFormats(t *testing.T) {
 	tests := []struct {
 		name      string
-		cfg       configFile
+		json      string // empty: no config file
 		wantURL   string
 		wantToken string
+		wantErr   bool
 	}{
+		{
+			name:    "missing file",
+			wantErr: true,
+		},
 		{
 			name:      "flat format",
-			cfg:       configFile{URL: "https://fleet.example.com", Token: "tok"},
+			json:      `{"url":"https://fleet.example.com","token":"tok"}`,
 			wantURL:   "https://fleet.example.com",
 			wantToken: "tok",
 		},
 		{
-			name: "contexts format uses default_context",
-			cfg: configFile{
-				Contexts: map[string]configFileContext{
-					"dev":  {URL: "https://dev.example.com", Token: "devtok"},
-					"prod": {URL: "https://prod.example.com", Token: "prodtok"},
-				},
-				DefaultContext: "dev",
-			},
+			name:      "contexts format uses default_context",
+			json:      `{"contexts":{"dev":{"url":"https://dev.example.com","token":"devtok"},"prod":{"url":"https://prod.example.com","token":"prodtok"}},"default_context":"dev"}`,
 			wantURL:   "https://dev.example.com",
 			wantToken: "devtok",
 		},
 		{
-			name: "contexts format selects prod",
-			cfg: configFile{
-				Contexts: map[string]configFileContext{
-					"dev":  {URL: "https://dev.example.com", Token: "devtok"},
-					"prod": {URL: "https://prod.example.com", Token: "prodtok"},
-				},
-				DefaultContext: "prod",
-			},
+			name:      "contexts format selects prod",
+			json:      `{"contexts":{"dev":{"url":"https://dev.example.com","token":"devtok"},"prod":{"url":"https://prod.example.com","token":"prodtok"}},"default_context":"prod"}`,
 			wantURL:   "https://prod.example.com",
 			wantToken: "prodtok",
 		},
 		{
-			name: "flat takes priority over contexts",
-			cfg: configFile{
-				URL:   "https://fleet.example.com",
-				Token: "flattok",
-				Contexts: map[string]configFileContext{
-					"dev": {URL: "https://dev.example.com", Token: "devtok"},
-				},
-				DefaultContext: "dev",
-			},
+			name:      "flat takes priority over contexts",
+			json:      `{"url":"https://fleet.example.com","token":"flattok","contexts":{"dev":{"url":"https://dev.example.com","token":"devtok"}},"default_context":"dev"}`,
 			wantURL:   "https://fleet.example.com",
 			wantToken: "flattok",
 		},
 		{
-			name: "missing default context returns empty",
-			cfg: configFile{
-				Contexts: map[string]configFileContext{
-					"dev": {URL: "https://dev.example.com", Token: "devtok"},
-				},
-				DefaultContext: "nonexistent",
+			name:    "missing default context",
+			json:    `{"contexts":{"dev":{"url":"https://dev.example.com","token":"devtok"}},"default_context":"nonexistent"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvURL, "")
+			t.Setenv(EnvToken, "")
+			t.Setenv("HOME", t.TempDir())
+
+			root := t.TempDir()
+			if tt.json != "" {
+				os.MkdirAll(filepath.Join(root, ".config"), 0o755)
+				os.WriteFile(filepath.Join(root, ConfigRelPath), []byte(tt.json), 0o600)
+			}
+
+			auth, err := ResolveAuthContext("", "", "", root)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", auth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if auth.URL != tt.wantURL {
+				t.Errorf("URL: got %q, want %q", auth.URL, tt.wantURL)
+			}
+			if auth.Token != tt.wantToken {
+				t.Errorf("Token: got %q, want %q", auth.Token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestResolveClientSettings(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		flags   ClientSettings
+		json    string
+		want    ClientSettings
+		wantErr bool
+	}{
+		{name: "nothing set keeps client defaults", json: `{}`},
+		{
+			name: "config file fills settings",
+			json: `{"concurrency":10,"timeout":"45s","retries":5,"cache":true}`,
+			want: ClientSettings{Concurrency: 10, Timeout: 45 * time.Second, Retries: intPtr(5), Cache: true},
+		},
+		{
+			name:  "flags override config file",
+			flags: ClientSettings{Concurrency: 2, Timeout: time.Minute, Retries: intPtr(0)},
+			json:  `{"concurrency":10,"timeout":"45s","retries":5}`,
+			want:  ClientSettings{Concurrency: 2, Timeout: time.Minute, Retries: intPtr(0)},
+		},
+		{
+			name: "settings alongside contexts",
+			json: `{"contexts":{"dev":{"url":"https://dev.example.com","token":"t"}},"default_context":"dev","retries":0}`,
+			want: ClientSettings{Retries: intPtr(0)},
+		},
+		{name: "invalid timeout", json: `{"timeout":"soon"}`, wantErr: true},
+		{name: "negative retries", json: `{"retries":-1}`, wantErr: true},
+		{name: "negative concurrency flag", flags: ClientSettings{Concurrency: -1}, json: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			root := t.TempDir()
+			os.MkdirAll(filepath.Join(root, ".config"), 0o755)
+			os.WriteFile(filepath.Join(root, ConfigRelPath), []byte(tt.json), 0o600)
+
+			got, err := ResolveClientSettings(tt.flags, root)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Concurrency != tt.want.Concurrency || got.Timeout != tt.want.Timeout || got.Cache != tt.want.Cache {
+				t.Errorf("got concurrency=%d timeout=%s cache=%v, want %d %s %v", got.Concurrency, got.Timeout, got.Cache, tt.want.Concurrency, tt.want.Timeout, tt.want.Cache)
+			}
+			if (got.Retries == nil) != (tt.want.Retries == nil) || (got.Retries != nil && *got.Retries != *tt.want.Retries) {
+				t.Errorf("Retries = %v, want %v", got.Retries, tt.want.Retries)
+			}
+		})
+	}
+}
+
+func TestResolveTLS(t *testing.T) {
+	home := t.TempDir()
+	tests := []struct {
+		name     string
+		flags    TLSSettings
+		envAuth  bool // FLEET_URL and FLEET_TOKEN set
+		repoJSON string
+		homeJSON string
+		want     func(root string) TLSSettings
+		wantErr  bool
+	}{
+		{
+			name:     "nothing set",
+			repoJSON: `{"url":"https://fleet.example.com","token":"t"}`,
+			want:     func(string) TLSSettings { return TLSSettings{} },
+		},
+		{
+			name:     "flat format, relative to repo root",
+			repoJSON: `{"url":"https://fleet.example.com","token":"t","ca_cert":"certs/ca.pem","client_cert":"certs/me.pem","client_key":"certs/me.key"}`,
+			want: func(root string) TLSSettings {
+				return TLSSettings{
+					CACert:     filepath.Join(root, "certs/ca.pem"),
+					ClientCert: filepath.Join(root, "certs/me.pem"),
+					ClientKey:  filepath.Join(root, "certs/me.key"),
+				}
+			},
+		},
+		{
+			name:     "default context, absolute and home paths",
+			repoJSON: `{"contexts":{"prod":{"url":"https://prod.example.com","token":"t","ca_cert":"/etc/pki/fleet-ca.pem","client_cert":"~/certs/me.pem","client_key":"~/certs/me.key"},"dev":{"ca_cert":"/dev-ca.pem"}},"default_context":"prod"}`,
+			want: func(string) TLSSettings {
+				return TLSSettings{
+					CACert:     "/etc/pki/fleet-ca.pem",
+					ClientCert: filepath.Join(home, "certs/me.pem"),
+					ClientKey:  filepath.Join(home, "certs/me.key"),
+				}
+			},
+		},
+		{
+			name:     "top-level CA shared by contexts",
+			repoJSON: `{"contexts":{"prod":{"url":"https://prod.example.com","token":"t"}},"default_context":"prod","ca_cert":"/etc/pki/fleet-ca.pem"}`,
+			want:     func(string) TLSSettings { return TLSSettings{CACert: "/etc/pki/fleet-ca.pem"} },
+		},
+		{
+			name:     "flags override config file, cert and key together",
+			flags:    TLSSettings{ClientCert: "/flag.pem", ClientKey: "/flag.key"},
+			repoJSON: `{"url":"https://fleet.example.com","token":"t","ca_cert":"/file-ca.pem","client_cert":"/file.pem","client_key":"/file.key"}`,
+			want: func(string) TLSSettings {
+				return TLSSettings{CACert: "/file-ca.pem", ClientCert: "/flag.pem", ClientKey: "/flag.key"}
 			},
 		},
 		{
-			name: "empty config",
-			cfg:  configFile{},
+			name:     "repo auth never borrows home certs",
+			repoJSON: `{"url":"https://staging.example.com","token":"t"}`,
+			homeJSON: `{"url":"https://prod.example.com","token":"p","ca_cert":"/prod-ca.pem","client_cert":"/prod.pem","client_key":"/prod.key"}`,
+			want:     func(string) TLSSettings { return TLSSettings{} },
+		},
+		{
+			name:     "env auth never borrows file certs",
+			envAuth:  true,
+			repoJSON: `{"ca_cert":"/repo-ca.pem"}`,
+			homeJSON: `{"url":"https://prod.example.com","token":"p","ca_cert":"/prod-ca.pem"}`,
+			want:     func(string) TLSSettings { return TLSSettings{} },
 		},
+		{
+			name:     "certificate without key",
+			flags:    TLSSettings{ClientCert: "/flag.pem"},
+			repoJSON: `{"url":"https://fleet.example.com","token":"t"}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", home)
+			t.Setenv(EnvURL, "")
+			t.Setenv(EnvToken, "")
+			if tt.envAuth {
+				t.Setenv(EnvURL, "https://env.example.com")
+				t.Setenv(EnvToken, "env-token")
+			}
+			os.Remove(filepath.Join(home, ConfigRelPath))
+			if tt.homeJSON != "" {
+				os.MkdirAll(filepath.Join(home, ".config"), 0o700)
+				os.WriteFile(filepath.Join(home, ConfigRelPath), []byte(tt.homeJSON), 0o600)
+			}
+			root := t.TempDir()
+			os.MkdirAll(filepath.Join(root, ".config"), 0o755)
+			os.WriteFile(filepath.Join(root, ConfigRelPath), []byte(tt.repoJSON), 0o600)
+
+			auth, err := ResolveAuth("", "", root)
+			if err != nil {
+				t.Fatalf("ResolveAuth: %v", err)
+			}
+			got, err := ResolveTLS(tt.flags, auth)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := tt.want(root); got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveAuthContext(t *testing.T) {
+	repoJSON := `{"contexts":{"dev":{"url":"https://dev.example.com","token":"devtoken"}},"default_context":"dev"}`
+	homeJSON := `{"contexts":{"vault":{"url":"https://vault.example.com","token_command":"echo helper-token"}}}`
+	fleetctlYAML := "contexts:\n  default:\n    address: https://fleetctl.example.com\n    token: fleetctl-token\n  prod:\n    address: https://prod.example.com\n    token: prod-token\n"
+
+	tests := []struct {
+		name      string
+		flagToken string
+		context   string
+		repoJSON  string
+		homeJSON  string
+		fleetctl  string
+		envURL    string
+		wantURL   string
+		wantToken string
+		wantErr   string
+	}{
+		{name: "named context", context: "dev", repoJSON: repoJSON, wantURL: "https://dev.example.com", wantToken: "devtoken"},
+		{name: "named context beats env vars", context: "dev", repoJSON: repoJSON, envURL: "https://env.example.com", wantURL: "https://dev.example.com", wantToken: "devtoken"},
+		{name: "token_command", context: "vault", homeJSON: homeJSON, wantURL: "https://vault.example.com", wantToken: "helper-token"},
+		{name: "flag token skips token_command", context: "vault", flagToken: "flagtoken", homeJSON: `{"contexts":{"vault":{"url":"https://vault.example.com","token_command":"exit 1"}}}`, wantURL: "https://vault.example.com", wantToken: "flagtoken"},
+		{name: "failing token_command", context: "vault", homeJSON: `{"contexts":{"vault":{"url":"https://vault.example.com","token_command":"exit 1"}}}`, wantErr: "token_command"},
+		{name: "token_command in repo config", context: "dev", repoJSON: `{"contexts":{"dev":{"url":"https://dev.example.com","token_command":"echo helper-token"}}}`, wantErr: "only read from $HOME"},
+		{name: "fleetctl default context as fallback", fleetctl: fleetctlYAML, wantURL: "https://fleetctl.example.com", wantToken: "fleetctl-token"},
+		{name: "repo config before fleetctl", repoJSON: repoJSON, fleetctl: fleetctlYAML, wantURL: "https://dev.example.com", wantToken: "devtoken"},
+		{name: "named fleetctl context", context: "prod", repoJSON: repoJSON, fleetctl: fleetctlYAML, wantURL: "https://prod.example.com", wantToken: "prod-token"},
+		{name: "unknown context", context: "qa", repoJSON: repoJSON, fleetctl: fleetctlYAML, wantErr: `context "qa" not found`},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u, tok := tt.cfg.resolve()
-			if u != tt.wantURL {
-				t.Errorf("URL: got %q, want %q", u, tt.wantURL)
+			if runtime.GOOS == "windows" && strings.Contains(tt.homeJSON, "token_command") {
+				t.Skip("token_command tests use sh")
 			}
-			if tok != tt.wantToken {
-				t.Errorf("Token: got %q, want %q", tok, tt.wantToken)
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv(EnvURL, tt.envURL)
+			t.Setenv(EnvToken, "")
+
+			root := t.TempDir()
+			if tt.repoJSON != "" {
+				os.MkdirAll(filepath.Join(root, ".config"), 0o755)
+				os.WriteFile(filepath.Join(root, ConfigRelPath), []byte(tt.repoJSON), 0o600)
+			}
+			if tt.homeJSON != "" {
+				os.MkdirAll(filepath.Join(home, ".config"), 0o700)
+				os.WriteFile(filepath.Join(home, ConfigRelPath), []byte(tt.homeJSON), 0o600)
+			}
+			if tt.fleetctl != "" {
+				os.MkdirAll(filepath.Join(home, ".fleet"), 0o700)
+				os.WriteFile(filepath.Join(home, FleetctlConfigRelPath), []byte(tt.fleetctl), 0o600)
+			}
+
+			auth, err := ResolveAuthContext("", tt.flagToken, tt.context, root)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if auth.URL != tt.wantURL || auth.Token != tt.wantToken {
+				t.Errorf("got %q/%q, want %q/%q", auth.URL, auth.Token, tt.wantURL, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestRepoTokenCommandNeverRuns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(EnvURL, "https://fleet.example.com")
+	t.Setenv(EnvToken, "")
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".config"), 0o755)
+	repoJSON := fmt.Sprintf(`{"url":"https://fleet.example.com","token_command":%q}`, "touch "+marker)
+	os.WriteFile(filepath.Join(root, ConfigRelPath), []byte(repoJSON), 0o600)
+
+	for _, contextName := range []string{"", "default"} {
+		if _, err := ResolveAuthContext("", "", contextName, root); err == nil {
+			t.Errorf("context %q: expected an error for a repo token_command", contextName)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("repo token_command was executed")
+	}
+}
+
+func TestHasPlaintextToken(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  configFile
+		want bool
+	}{
+		{name: "flat token", cfg: configFile{Token: "tok"}, want: true},
+		{name: "context token", cfg: configFile{Contexts: map[string]configFileContext{"dev": {Token: "tok"}}}, want: true},
+		{name: "token_command only", cfg: configFile{Contexts: map[string]configFileContext{"dev": {TokenCommand: "vault read"}}}},
+		{name: "empty", cfg: configFile{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.hasPlaintextToken(); got != tt.want {
+				t.Errorf("hasPlaintextToken() = %v, want %v", got, tt.want)
 			}
 		})
 	}
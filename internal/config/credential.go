@@ -0,0 +1,47 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tokenCommandTimeout bounds a credential helper, long enough for an
+// interactive unlock prompt.
+const tokenCommandTimeout = 2 * time.Minute
+
+// runTokenCommand runs a context's token_command through the shell (a vault
+// or password-manager CLI, e.g. "op read op://fleet/api/token") and returns
+// its trimmed stdout. The helper shares the terminal's stdin and stderr so
+// it can prompt.
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("token_command %q timed out after %s", command, tokenCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("token_command %q failed: %w", command, err)
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("token_command %q printed no token", command)
+	}
+	return token, nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FleetctlConfigRelPath is fleetctl's config file relative to $HOME, written
+// by `fleetctl login` and `fleetctl config set`.
+const FleetctlConfigRelPath = ".fleet/config"
+
+// fleetctlDefaultContext is the context fleetctl uses without --context.
+const fleetctlDefaultContext = "default"
+
+// fleetctlConfig is the subset of fleetctl's YAML config fleet-plan reads:
+//
+//	contexts:
+//	  default:
+//	    address: https://fleet.example.com
+//	    token: ...
+//	    rootca: /path/to/ca.pem
+type fleetctlConfig struct {
+	Contexts map[string]fleetctlContext `yaml:"contexts"`
+}
+
+type fleetctlContext struct {
+	Address   string `yaml:"address"`
+	Token     string `yaml:"token"`
+	RootCA    string `yaml:"rootca"`
+	URLPrefix string `yaml:"url-prefix"`
+}
+
+// loadFleetctlConfig reads ~/.fleet/config under home. Returns zero values
+// if the file doesn't exist or can't be parsed.
+func loadFleetctlConfig(home string) fleetctlConfig {
+	path := filepath.Join(home, FleetctlConfigRelPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fleetctlConfig{}
+	}
+	var cfg fleetctlConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fleetctlConfig{}
+	}
+	for _, ctx := range cfg.Contexts {
+		if ctx.Token != "" {
+			warnIfReadable(path)
+			break
+		}
+	}
+	return cfg
+}
+
+// context returns the named fleetctl context ("default" when name is empty)
+// as a fleet-plan context. fleetctl's url-prefix is appended to the address.
+func (c fleetctlConfig) context(name string) (configFileContext, bool) {
+	if name == "" {
+		name = fleetctlDefaultContext
+	}
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return configFileContext{}, false
+	}
+	url := ctx.Address
+	if ctx.URLPrefix != "" {
+		url = strings.TrimRight(url, "/") + "/" + strings.Trim(ctx.URLPrefix, "/")
+	}
+	return configFileContext{URL: url, Token: ctx.Token, CACert: ctx.RootCA}, true
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFleetctlConfigContext(t *testing.T) {
+	home := t.TempDir()
+	os.MkdirAll(filepath.Join(home, ".fleet"), 0o700)
+	os.WriteFile(filepath.Join(home, FleetctlConfigRelPath), []byte(`contexts:
+  default:
+    address: https://fleet.example.com
+    email: admin@example.com
+    token: default-token
+    tls-skip-verify: false
+  staging:
+    address: https://staging.example.com/
+    url-prefix: /fleet
+    token: staging-token
+    rootca: /etc/pki/staging-ca.pem
+`), 0o600)
+	cfg := loadFleetctlConfig(home)
+
+	tests := []struct {
+		name   string
+		ctx    string
+		want   configFileContext
+		wantOK bool
+	}{
+		{name: "default context", want: configFileContext{URL: "https://fleet.example.com", Token: "default-token"}, wantOK: true},
+		{
+			name:   "named context with url-prefix and rootca",
+			ctx:    "staging",
+			want:   configFileContext{URL: "https://staging.example.com/fleet", Token: "staging-token", CACert: "/etc/pki/staging-ca.pem"},
+			wantOK: true,
+		},
+		{name: "missing context", ctx: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cfg.context(tt.ctx)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("context(%q) = %+v, %v; want %+v, %v", tt.ctx, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLoadFleetctlConfigInvalid(t *testing.T) {
+	home := t.TempDir()
+	os.MkdirAll(filepath.Join(home, ".fleet"), 0o700)
+	os.WriteFile(filepath.Join(home, FleetctlConfigRelPath), []byte("contexts: [not, a, map"), 0o600)
+	if _, ok := loadFleetctlConfig(home).context(""); ok {
+		t.Error("expected no context from an unparseable fleetctl config")
+	}
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// Access is what the API token could read for one result, so skipped
+// diffs are explained up front instead of as "diff skipped" errors.
+type Access struct {
+	Role      string           // the token's role in this scope; "" if it has none
+	Resources []ResourceAccess // in api.GlobalResources or api.TeamResources order
+}
+
+// ResourceAccess reports whether one resource type was diffable.
+type ResourceAccess struct {
+	Resource api.Resource
+	Readable bool
+}
+
+// globalAccess returns the token's access to default.yml resources, or nil
+// when capabilities weren't discovered.
+func globalAccess(current *api.FleetState) *Access {
+	caps := current.Capabilities
+	if caps == nil {
+		return nil
+	}
+	access := &Access{Role: caps.GlobalRole}
+	for _, r := range api.GlobalResources {
+		readable := caps.CanReadGlobal(r)
+		if r == api.ResourceConfig {
+			readable = readable && current.Config != nil
+		}
+		access.Resources = append(access.Resources, ResourceAccess{Resource: r, Readable: readable})
+	}
+	return access
+}
+
+// teamAccess returns the token's access to a team's resources, or nil when
+// capabilities weren't discovered. Resources the role allows but the server
+// refused (403) are not readable either. A team missing from Fleet is
+// readable wherever the global role allows, since it will be created empty.
+func teamAccess(current *api.FleetState, team api.Team, exists bool) *Access {
+	caps := current.Capabilities
+	if caps == nil {
+		return nil
+	}
+	if !exists && team.Name != parser.NoTeamName {
+		team.ID = 0 // not a team ID; only the global role applies
+	}
+	access := &Access{Role: caps.Role(team.ID)}
+	for _, r := range api.TeamResources {
+		readable := caps.CanRead(team.ID, r)
+		if exists {
+			switch r {
+			case api.ResourcePolicies:
+				readable = readable && !team.PoliciesUnavailable
+			case api.ResourceSoftware:
+				readable = readable && !team.SoftwareUnavailable
+			case api.ResourceProfiles:
+				readable = readable && !team.ProfilesUnavailable
+			case api.ResourceScripts:
+				readable = readable && !team.ScriptsUnavailable
+			case api.ResourceAppStore:
+				readable = readable && !current.VPPTokensUnavailable
+			}
+		}
+		access.Resources = append(access.Resources, ResourceAccess{Resource: r, Readable: readable})
+	}
+	return access
+}
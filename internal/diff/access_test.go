@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestDiffAccess(t *testing.T) {
+	globalRole := func(r string) *api.Capabilities {
+		return api.NewCapabilities(&api.User{GlobalRole: &r})
+	}
+	teamRole := api.NewCapabilities(&api.User{Teams: []api.UserTeam{{ID: 1, Name: "Workstations", Role: "maintainer"}}})
+
+	proposed := &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{},
+		Teams: []parser.ParsedTeam{
+			{Name: "Workstations"},
+			{Name: "Servers", Policies: []parser.ParsedPolicy{{Name: "Disk encryption"}}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		caps         *api.Capabilities
+		teams        []api.Team
+		wantRoles    map[string]string
+		wantReadable map[string][]api.Resource
+		wantError    map[string]string // team -> substring of one of its errors
+	}{
+		{
+			name:      "not discovered",
+			teams:     []api.Team{{ID: 1, Name: "Workstations"}},
+			wantRoles: map[string]string{},
+		},
+		{
+			name:      "gitops",
+			caps:      globalRole("gitops"),
+			teams:     []api.Team{{ID: 1, Name: "Workstations", SoftwareUnavailable: true}},
+			wantRoles: map[string]string{"(global)": "gitops", "Workstations": "gitops", "Servers": "gitops"},
+			wantReadable: map[string][]api.Resource{
+				"(global)":     {api.ResourceConfig, api.ResourcePolicies, api.ResourceQueries},
+				"Workstations": {api.ResourcePolicies, api.ResourceQueries, api.ResourceScripts, api.ResourceAppStore},
+			},
+			wantError: map[string]string{"Servers": "will be created"},
+		},
+		{
+			name:      "server refused profiles",
+			caps:      globalRole("admin"),
+			teams:     []api.Team{{ID: 1, Name: "Workstations", ProfilesUnavailable: true}},
+			wantRoles: map[string]string{"(global)": "admin", "Workstations": "admin", "Servers": "admin"},
+			wantReadable: map[string][]api.Resource{
+				"Workstations": {api.ResourcePolicies, api.ResourceQueries, api.ResourceSoftware, api.ResourceScripts, api.ResourceAppStore},
+			},
+		},
+		{
+			name:      "team-only user",
+			caps:      teamRole,
+			teams:     []api.Team{{ID: 1, Name: "Workstations"}},
+			wantRoles: map[string]string{"(global)": "", "Workstations": "maintainer", "Servers": ""},
+			wantReadable: map[string][]api.Resource{
+				"(global)": {api.ResourceConfig, api.ResourcePolicies, api.ResourceQueries, api.ResourceLabelHosts},
+				"Servers":  nil,
+			},
+			wantError: map[string]string{"Servers": `no role on team "Servers"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := &api.FleetState{Teams: tt.teams, Config: map[string]any{}, Capabilities: tt.caps}
+			results := Diff(current, proposed, nil, nil)
+			for _, r := range results {
+				role, ok := tt.wantRoles[r.Team]
+				if !ok {
+					if r.Access != nil {
+						t.Errorf("%s: access = %+v, want nil", r.Team, r.Access)
+					}
+					continue
+				}
+				if r.Access == nil {
+					t.Fatalf("%s: access is nil", r.Team)
+				}
+				if r.Access.Role != role {
+					t.Errorf("%s: role = %q, want %q", r.Team, r.Access.Role, role)
+				}
+				if want, ok := tt.wantReadable[r.Team]; ok {
+					var got []api.Resource
+					for _, ra := range r.Access.Resources {
+						if ra.Readable {
+							got = append(got, ra.Resource)
+						}
+					}
+					if !slices.Equal(got, want) {
+						t.Errorf("%s: readable = %v, want %v", r.Team, got, want)
+					}
+				}
+				if want, ok := tt.wantError[r.Team]; ok {
+					if !slices.ContainsFunc(r.Errors, func(e string) bool { return strings.Contains(e, want) }) {
+						t.Errorf("%s: errors = %v, want one containing %q", r.Team, r.Errors, want)
+					}
+				}
+				if r.Team == "Servers" && r.Access.Role == "" && !r.Policies.IsEmpty() {
+					t.Errorf("Servers: policies reported as added for a team the token can't see: %+v", r.Policies)
+				}
+			}
+		})
+	}
+}
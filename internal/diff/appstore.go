@@ -0,0 +1,238 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// appStorePlatform normalizes an App Store app platform. Fleet treats an
+// app_store_apps entry without a platform as the macOS app.
+func appStorePlatform(platform string) string {
+	p := strings.ToLower(strings.TrimSpace(platform))
+	if p == "" || p == "macos" {
+		return "darwin"
+	}
+	return p
+}
+
+// appStoreKey identifies an App Store app on a team. The same app_store_id
+// can be added once per platform.
+func appStoreKey(id, platform string) string {
+	return strings.TrimSpace(id) + "/" + appStorePlatform(platform)
+}
+
+// appStoreName is the ResourceChange name for an App Store app. The platform
+// suffix is omitted for macOS so existing plans keep their names.
+func appStoreName(id, platform string) string {
+	name := "app store app " + strings.TrimSpace(id)
+	if p := appStorePlatform(platform); p != "darwin" {
+		name += " (" + p + ")"
+	}
+	return name
+}
+
+// mergeAppStoreTitles fills platform, label scopes and categories for the
+// API's app_store_apps entries from software title metadata, and adds
+// entries for App Store titles the /teams response omitted. API entries
+// without a platform adopt it from the only title with the same app_store_id.
+func mergeAppStoreTitles(apps []api.TeamAppStoreApp, titles []api.SoftwareTitle) []api.TeamAppStoreApp {
+	byID := make(map[string][]*api.SoftwareTitleAppStore)
+	for _, t := range titles {
+		if t.AppStoreApp == nil {
+			continue
+		}
+		id := strings.TrimSpace(t.AppStoreApp.AppStoreID)
+		if id != "" {
+			byID[id] = append(byID[id], t.AppStoreApp)
+		}
+	}
+	if len(byID) == 0 {
+		return apps
+	}
+
+	merged := make([]api.TeamAppStoreApp, 0, len(apps))
+	seen := make(map[string]bool, len(apps))
+	for _, a := range apps {
+		id := strings.TrimSpace(a.AppStoreID)
+		metas := byID[id]
+		if a.Platform == "" && len(metas) == 1 {
+			a.Platform = metas[0].Platform
+		}
+		for _, m := range metas {
+			if appStorePlatform(m.Platform) == appStorePlatform(a.Platform) {
+				fillAppStoreFromTitle(&a, m)
+				break
+			}
+		}
+		seen[appStoreKey(id, a.Platform)] = true
+		merged = append(merged, a)
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, m := range byID[id] {
+			if seen[appStoreKey(id, m.Platform)] {
+				continue
+			}
+			a := api.TeamAppStoreApp{AppStoreID: id, SelfService: m.SelfService, Platform: m.Platform}
+			fillAppStoreFromTitle(&a, m)
+			seen[appStoreKey(id, m.Platform)] = true
+			merged = append(merged, a)
+		}
+	}
+	return merged
+}
+
+// fillAppStoreFromTitle copies label scopes and categories from title
+// metadata into a where the /teams response left them empty.
+func fillAppStoreFromTitle(a *api.TeamAppStoreApp, m *api.SoftwareTitleAppStore) {
+	labelNames := func(labels []api.SoftwareScopeLabel) []string {
+		var names []string
+		for _, l := range labels {
+			names = append(names, l.Name)
+		}
+		return names
+	}
+	if len(a.LabelsIncludeAny) == 0 {
+		a.LabelsIncludeAny = labelNames(m.LabelsIncludeAny)
+	}
+	if len(a.LabelsExcludeAny) == 0 {
+		a.LabelsExcludeAny = labelNames(m.LabelsExcludeAny)
+	}
+	if len(a.Categories) == 0 {
+		a.Categories = m.Categories
+	}
+}
+
+// diffAppStoreApps diffs App Store apps keyed by app_store_id and platform,
+// comparing self_service, label scopes and categories.
+func diffAppStoreApps(current []api.TeamAppStoreApp, proposed []parser.ParsedAppStoreApp, rd *ResourceDiff) {
+	currentApps := make(map[string]api.TeamAppStoreApp)
+	for _, a := range current {
+		if strings.TrimSpace(a.AppStoreID) != "" {
+			currentApps[appStoreKey(a.AppStoreID, a.Platform)] = a
+		}
+	}
+	proposedApps := make(map[string]parser.ParsedAppStoreApp)
+	for _, a := range proposed {
+		if strings.TrimSpace(a.AppStoreID) != "" {
+			proposedApps[appStoreKey(a.AppStoreID, a.Platform)] = a
+		}
+	}
+
+	for key, a := range proposedApps {
+		name := appStoreName(a.AppStoreID, a.Platform)
+		cur, exists := currentApps[key]
+		if !exists {
+			fields := map[string]FieldDiff{
+				"app_store_id": {New: a.AppStoreID},
+				"platform":     {New: appStorePlatform(a.Platform)},
+				"self_service": {New: fmt.Sprint(a.SelfService)},
+			}
+			for field, vals := range map[string][]string{
+				"labels_include_any": a.LabelsIncludeAny,
+				"labels_exclude_any": a.LabelsExcludeAny,
+				"categories":         a.Categories,
+			} {
+				if len(vals) > 0 {
+					fields[field] = FieldDiff{New: joinSorted(vals)}
+				}
+			}
+			rd.Added = append(rd.Added, ResourceChange{Name: name, Fields: fields})
+			continue
+		}
+		fields := make(map[string]FieldDiff)
+		if cur.SelfService != a.SelfService {
+			fields["self_service"] = FieldDiff{Old: fmt.Sprint(cur.SelfService), New: fmt.Sprint(a.SelfService)}
+		}
+		for _, f := range []struct {
+			name          string
+			cur, proposed []string
+		}{
+			{"labels_include_any", cur.LabelsIncludeAny, a.LabelsIncludeAny},
+			{"labels_exclude_any", cur.LabelsExcludeAny, a.LabelsExcludeAny},
+			{"categories", cur.Categories, a.Categories},
+		} {
+			if old, want := joinSorted(f.cur), joinSorted(f.proposed); old != want {
+				fields[f.name] = FieldDiff{Old: old, New: want}
+			}
+		}
+		if len(fields) > 0 {
+			rd.Modified = append(rd.Modified, ResourceChange{Name: name, Fields: fields})
+		}
+	}
+	for key, a := range currentApps {
+		if _, exists := proposedApps[key]; !exists {
+			rd.Deleted = append(rd.Deleted, ResourceChange{Name: appStoreName(a.AppStoreID, a.Platform)})
+		}
+	}
+}
+
+// validateAppStoreApps warns about App Store apps being added to a team that
+// cannot install them: the team has no VPP token assigned, or the app isn't
+// licensed to the team's token. Fleet rejects both at apply time. Returns nil
+// when VPP tokens could not be read.
+func validateAppStoreApps(proposed []parser.ParsedAppStoreApp, current []api.TeamAppStoreApp, team api.Team, state *api.FleetState) []string {
+	if state.VPPTokensUnavailable || len(proposed) == 0 {
+		return nil
+	}
+	installed := make(map[string]bool, len(current))
+	for _, a := range current {
+		installed[appStoreKey(a.AppStoreID, a.Platform)] = true
+	}
+	var added []parser.ParsedAppStoreApp
+	for _, a := range proposed {
+		if strings.TrimSpace(a.AppStoreID) != "" && !installed[appStoreKey(a.AppStoreID, a.Platform)] {
+			added = append(added, a)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	hasToken := false
+	for _, t := range state.VPPTokens {
+		if t.AssignedTo(team.ID) {
+			hasToken = true
+			break
+		}
+	}
+	if !hasToken {
+		names := make([]string, len(added))
+		for i, a := range added {
+			names[i] = appStoreName(a.AppStoreID, a.Platform)
+		}
+		return []string{fmt.Sprintf("no VPP token is assigned to this team; cannot add %s", strings.Join(names, ", "))}
+	}
+	if team.VPPAppsUnavailable {
+		return nil
+	}
+
+	licensed := make(map[string]bool, len(team.VPPApps))
+	for _, a := range team.VPPApps {
+		licensed[appStoreKey(a.AppStoreID, a.Platform)] = true
+	}
+	var errs []string
+	for _, a := range added {
+		if !licensed[appStoreKey(a.AppStoreID, a.Platform)] {
+			errs = append(errs, fmt.Sprintf("%s is not in the VPP license list for this team's token", appStoreName(a.AppStoreID, a.Platform)))
+		}
+	}
+	return errs
+}
+
+// joinSorted renders a string list order-insensitively for comparison.
+func joinSorted(vals []string) string {
+	sorted := slices.Clone(vals)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
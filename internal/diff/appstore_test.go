@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestMergeAppStoreTitles(t *testing.T) {
+	apps := []api.TeamAppStoreApp{{AppStoreID: "111", SelfService: true}}
+	titles := []api.SoftwareTitle{
+		{ID: 1, AppStoreApp: &api.SoftwareTitleAppStore{
+			AppStoreID:       "111",
+			Platform:         "ios",
+			LabelsIncludeAny: []api.SoftwareScopeLabel{{Name: "Field Staff"}},
+			Categories:       []string{"Communication"},
+		}},
+		{ID: 2, AppStoreApp: &api.SoftwareTitleAppStore{AppStoreID: "222", Platform: "ipados"}},
+		{ID: 3, SoftwarePackage: &api.SoftwareTitlePackageMeta{Name: "pkg"}},
+	}
+
+	got := mergeAppStoreTitles(apps, titles)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 apps, got %+v", got)
+	}
+	if got[0].Platform != "ios" || !got[0].SelfService {
+		t.Errorf("API entry should adopt title platform and keep self_service: %+v", got[0])
+	}
+	if !slices.Equal(got[0].LabelsIncludeAny, []string{"Field Staff"}) || !slices.Equal(got[0].Categories, []string{"Communication"}) {
+		t.Errorf("labels/categories not filled from title: %+v", got[0])
+	}
+	if got[1].AppStoreID != "222" || got[1].Platform != "ipados" {
+		t.Errorf("title-only app not added: %+v", got[1])
+	}
+}
+
+func TestDiffAppStoreApps(t *testing.T) {
+	tests := []struct {
+		name         string
+		current      []api.TeamAppStoreApp
+		proposed     []parser.ParsedAppStoreApp
+		wantAdded    []string
+		wantModified map[string][]string // name -> changed fields
+		wantDeleted  []string
+	}{
+		{
+			name:     "unchanged, empty platform is darwin",
+			current:  []api.TeamAppStoreApp{{AppStoreID: "111", Platform: "darwin"}},
+			proposed: []parser.ParsedAppStoreApp{{AppStoreID: "111"}},
+		},
+		{
+			name:        "same id on another platform is a separate app",
+			current:     []api.TeamAppStoreApp{{AppStoreID: "111", Platform: "darwin"}},
+			proposed:    []parser.ParsedAppStoreApp{{AppStoreID: "111", Platform: "ios"}},
+			wantAdded:   []string{"app store app 111 (ios)"},
+			wantDeleted: []string{"app store app 111"},
+		},
+		{
+			name: "labels and categories compared order-insensitively",
+			current: []api.TeamAppStoreApp{{
+				AppStoreID: "111", Platform: "ipados",
+				LabelsIncludeAny: []string{"B", "A"}, Categories: []string{"Productivity"},
+			}},
+			proposed: []parser.ParsedAppStoreApp{{
+				AppStoreID: "111", Platform: "ipados", SelfService: true,
+				LabelsIncludeAny: []string{"A", "B"}, LabelsExcludeAny: []string{"Kiosks"},
+			}},
+			wantModified: map[string][]string{
+				"app store app 111 (ipados)": {"categories", "labels_exclude_any", "self_service"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rd ResourceDiff
+			diffAppStoreApps(tt.current, tt.proposed, &rd)
+			sortResourceChanges(&rd)
+
+			if got := changeNames(rd.Added); !slices.Equal(got, tt.wantAdded) {
+				t.Errorf("added: got %v, want %v", got, tt.wantAdded)
+			}
+			if got := changeNames(rd.Deleted); !slices.Equal(got, tt.wantDeleted) {
+				t.Errorf("deleted: got %v, want %v", got, tt.wantDeleted)
+			}
+			if len(rd.Modified) != len(tt.wantModified) {
+				t.Fatalf("modified: got %+v, want %v", rd.Modified, tt.wantModified)
+			}
+			for _, rc := range rd.Modified {
+				var fields []string
+				for f := range rc.Fields {
+					fields = append(fields, f)
+				}
+				slices.Sort(fields)
+				if !slices.Equal(fields, tt.wantModified[rc.Name]) {
+					t.Errorf("%s fields: got %v, want %v", rc.Name, fields, tt.wantModified[rc.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAppStoreApps(t *testing.T) {
+	assigned := []api.VPPToken{{ID: 1, Teams: []api.VPPTokenTeam{{TeamID: 1}}}}
+	licensed := []api.VPPApp{{AppStoreID: "111", Platform: "ios"}, {AppStoreID: "222", Platform: "darwin"}}
+
+	tests := []struct {
+		name         string
+		proposed     []parser.ParsedAppStoreApp
+		current      []api.TeamAppStoreApp
+		team         api.Team
+		state        api.FleetState
+		wantContains []string
+	}{
+		{
+			name:     "licensed apps",
+			proposed: []parser.ParsedAppStoreApp{{AppStoreID: "111", Platform: "ios"}, {AppStoreID: "222"}},
+			team:     api.Team{ID: 1, VPPApps: licensed},
+			state:    api.FleetState{VPPTokens: assigned},
+		},
+		{
+			name:         "no token assigned",
+			proposed:     []parser.ParsedAppStoreApp{{AppStoreID: "111", Platform: "ios"}},
+			team:         api.Team{ID: 2, VPPAppsUnavailable: true},
+			state:        api.FleetState{VPPTokens: assigned},
+			wantContains: []string{"no VPP token is assigned to this team; cannot add app store app 111 (ios)"},
+		},
+		{
+			name:         "not licensed for platform",
+			proposed:     []parser.ParsedAppStoreApp{{AppStoreID: "111"}},
+			team:         api.Team{ID: 1, VPPApps: licensed},
+			state:        api.FleetState{VPPTokens: assigned},
+			wantContains: []string{"app store app 111 is not in the VPP license list"},
+		},
+		{
+			name:     "already installed apps are not re-checked",
+			proposed: []parser.ParsedAppStoreApp{{AppStoreID: "333"}},
+			current:  []api.TeamAppStoreApp{{AppStoreID: "333"}},
+			team:     api.Team{ID: 2},
+			state:    api.FleetState{VPPTokens: assigned},
+		},
+		{
+			name:     "tokens unreadable skips validation",
+			proposed: []parser.ParsedAppStoreApp{{AppStoreID: "111"}},
+			team:     api.Team{ID: 2},
+			state:    api.FleetState{VPPTokensUnavailable: true},
+		},
+		{
+			name:     "license list unreadable skips license check",
+			proposed: []parser.ParsedAppStoreApp{{AppStoreID: "999"}},
+			team:     api.Team{ID: 1, VPPAppsUnavailable: true},
+			state:    api.FleetState{VPPTokens: assigned},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateAppStoreApps(tt.proposed, tt.current, tt.team, &tt.state)
+			if len(got) != len(tt.wantContains) {
+				t.Fatalf("errors: got %v, want %d", got, len(tt.wantContains))
+			}
+			for i, want := range tt.wantContains {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("error[%d] = %q, want substring %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffAppStoreAppsFromTitles(t *testing.T) {
+	current := &api.FleetState{
+		VPPTokens: []api.VPPToken{{ID: 1, Teams: []api.VPPTokenTeam{}}}, // all teams
+		Labels:    []api.Label{{Name: "Field Staff"}},
+		Teams: []api.Team{{
+			ID:       1,
+			Name:     "Mobile",
+			Software: api.TeamSoftware{AppStoreApps: []api.TeamAppStoreApp{{AppStoreID: "111"}}},
+			SoftwareTitles: []api.SoftwareTitle{{ID: 5, AppStoreApp: &api.SoftwareTitleAppStore{
+				AppStoreID: "111", Platform: "ios",
+				LabelsIncludeAny: []api.SoftwareScopeLabel{{Name: "Field Staff"}},
+			}}},
+			VPPApps: []api.VPPApp{{AppStoreID: "111", Platform: "ios"}},
+		}},
+	}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name: "Mobile",
+		Software: parser.ParsedSoftware{AppStoreApps: []parser.ParsedAppStoreApp{
+			{AppStoreID: "111", Platform: "ios", LabelsIncludeAny: []string{"Field Staff"}},
+			{AppStoreID: "444", Platform: "ios"},
+		}},
+	}}}
+
+	r := Diff(current, proposed, nil, nil)[0]
+	if len(r.Software.Modified) != 0 || len(r.Software.Deleted) != 0 {
+		t.Errorf("expected 111 unchanged, got modified=%+v deleted=%+v", r.Software.Modified, r.Software.Deleted)
+	}
+	if names := changeNames(r.Software.Added); !slices.Equal(names, []string{"app store app 444 (ios)"}) {
+		t.Errorf("added: got %v", names)
+	}
+	if len(r.Errors) != 1 || !strings.Contains(r.Errors[0], "app store app 444 (ios) is not in the VPP license list") {
+		t.Errorf("expected license warning, got %v", r.Errors)
+	}
+}
+
+func changeNames(changes []ResourceChange) []string {
+	var names []string
+	for _, c := range changes {
+		names = append(names, c.Name)
+	}
+	return names
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// maxSuggestions caps "did you mean" lists for unknown slugs and keys.
+const maxSuggestions = 3
+
+// validateFleetApps checks every proposed fleet_maintained_apps[].slug against
+// Fleet's maintained-app catalog. Typos and platform mismatches are otherwise
+// accepted silently until fleetctl gitops fails at apply time. FMAs that are
+// installed on the team but whose catalog entry has since been removed are
+// reported separately. Returns nil when the catalog is unavailable.
+func validateFleetApps(proposed []parser.ParsedFleetApp, current []api.TeamFleetApp, catalog []api.FleetMaintainedApp) []string {
+	if len(catalog) == 0 || len(proposed) == 0 {
+		return nil
+	}
+
+	bySlug := make(map[string]api.FleetMaintainedApp, len(catalog))
+	byName := make(map[string][]string)
+	for _, app := range catalog {
+		slug := strings.ToLower(parser.NormalizeSoftwarePath(app.Slug))
+		if slug == "" {
+			continue
+		}
+		bySlug[slug] = app
+		name, _ := splitSlug(slug)
+		byName[name] = append(byName[name], app.Slug)
+	}
+
+	installed := make(map[string]bool, len(current))
+	for _, a := range current {
+		installed[strings.ToLower(parser.NormalizeSoftwarePath(a.Slug))] = true
+	}
+
+	var errs []string
+	for _, fma := range proposed {
+		slug := strings.ToLower(parser.NormalizeSoftwarePath(fma.Slug))
+		if slug == "" {
+			errs = append(errs, "fleet-maintained app entry is missing a slug")
+			continue
+		}
+		name, platform := splitSlug(slug)
+
+		if app, ok := bySlug[slug]; ok {
+			catPlatform := normalizeFleetPlatform(app.Platform)
+			if platform != "" && catPlatform != "" && normalizeFleetPlatform(platform) != catPlatform {
+				errs = append(errs, fmt.Sprintf("fleet-maintained app %q targets platform %q but the catalog entry is for %q",
+					fma.Slug, platform, catPlatform))
+			}
+			continue
+		}
+
+		if installed[slug] {
+			errs = append(errs, fmt.Sprintf("fleet-maintained app %q is no longer in Fleet's catalog (entry removed upstream)", fma.Slug))
+			continue
+		}
+
+		if others := byName[name]; len(others) > 0 {
+			sorted := append([]string(nil), others...)
+			sort.Strings(sorted)
+			errs = append(errs, fmt.Sprintf("fleet-maintained app %q is not available for platform %q (available: %s)",
+				fma.Slug, platform, strings.Join(sorted, ", ")))
+			continue
+		}
+
+		msg := fmt.Sprintf("unknown fleet-maintained app slug %q", fma.Slug)
+		if suggestions := closestMatches(slug, slices.Collect(maps.Keys(bySlug))); len(suggestions) > 0 {
+			msg += fmt.Sprintf(" (did you mean %s?)", quoteJoin(suggestions))
+		}
+		errs = append(errs, msg)
+	}
+	return errs
+}
+
+// splitSlug splits an FMA slug ("name/platform") into its parts.
+func splitSlug(slug string) (name, platform string) {
+	if i := strings.LastIndex(slug, "/"); i >= 0 {
+		return slug[:i], slug[i+1:]
+	}
+	return slug, ""
+}
+
+// closestMatches returns candidates within a small edit distance of target,
+// nearest first. The threshold scales with target length so short names
+// don't match everything.
+func closestMatches(target string, candidates []string) []string {
+	maxDist := len(target) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	type match struct {
+		name string
+		dist int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if d := levenshtein(target, c); d <= maxDist {
+			matches = append(matches, match{c, d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		return matches[i].name < matches[j].name
+	})
+	var out []string
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		out = append(out, matches[i].name)
+	}
+	return out
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// quoteJoin renders names as a quoted, " or "-separated list.
+func quoteJoin(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return strings.Join(quoted, " or ")
+}
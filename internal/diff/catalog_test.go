@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestValidateFleetApps(t *testing.T) {
+	catalog := []api.FleetMaintainedApp{
+		{ID: 1, Slug: "cursor/windows", Name: "Cursor", Platform: "windows"},
+		{ID: 2, Slug: "zoom/darwin", Name: "Zoom", Platform: "darwin"},
+		{ID: 3, Slug: "zoom/windows", Name: "Zoom", Platform: "windows"},
+		{ID: 4, Slug: "1password/darwin", Name: "1Password", Platform: "darwin"},
+		{ID: 5, Slug: "odd/windows", Name: "Odd", Platform: "darwin"},
+	}
+
+	tests := []struct {
+		name         string
+		slugs        []string
+		current      []api.TeamFleetApp
+		catalog      []api.FleetMaintainedApp
+		wantContains []string // one substring per expected error, in order
+	}{
+		{name: "valid slugs", slugs: []string{"cursor/windows", "Zoom/darwin"}},
+		{name: "catalog unavailable skips validation", slugs: []string{"nope/windows"}, catalog: []api.FleetMaintainedApp{}},
+		{
+			name:         "typo suggests closest",
+			slugs:        []string{"curser/windows"},
+			wantContains: []string{`unknown fleet-maintained app slug "curser/windows" (did you mean "cursor/windows"?)`},
+		},
+		{
+			name:         "unknown slug without close match",
+			slugs:        []string{"totally-unrelated-app/linux"},
+			wantContains: []string{`unknown fleet-maintained app slug "totally-unrelated-app/linux"`},
+		},
+		{
+			name:         "wrong platform lists available",
+			slugs:        []string{"1password/windows"},
+			wantContains: []string{`not available for platform "windows" (available: 1password/darwin)`},
+		},
+		{
+			name:         "catalog platform disagrees with slug",
+			slugs:        []string{"odd/windows"},
+			wantContains: []string{`targets platform "windows" but the catalog entry is for "darwin"`},
+		},
+		{
+			name:         "installed app removed from catalog",
+			slugs:        []string{"retired/darwin"},
+			current:      []api.TeamFleetApp{{Slug: "retired/darwin"}},
+			wantContains: []string{`"retired/darwin" is no longer in Fleet's catalog`},
+		},
+		{
+			name:         "missing slug",
+			slugs:        []string{""},
+			wantContains: []string{"missing a slug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proposed []parser.ParsedFleetApp
+			for _, s := range tt.slugs {
+				proposed = append(proposed, parser.ParsedFleetApp{Slug: s})
+			}
+			cat := catalog
+			if tt.catalog != nil {
+				cat = tt.catalog
+			}
+			got := validateFleetApps(proposed, tt.current, cat)
+			if len(got) != len(tt.wantContains) {
+				t.Fatalf("errors: got %v, want %d", got, len(tt.wantContains))
+			}
+			for i, want := range tt.wantContains {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("error[%d] = %q, want substring %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"cursor", "curser", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffReportsUnknownFleetApp(t *testing.T) {
+	current := &api.FleetState{
+		Teams:                  []api.Team{{ID: 1, Name: "T"}},
+		FleetMaintainedCatalog: []api.FleetMaintainedApp{{Slug: "slack/darwin", Platform: "darwin"}},
+	}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name:     "T",
+		Software: parser.ParsedSoftware{FleetMaintained: []parser.ParsedFleetApp{{Slug: "slak/darwin"}}},
+	}}}
+
+	results := Diff(current, proposed, nil, nil)
+	if len(results[0].Errors) != 1 || !strings.Contains(results[0].Errors[0], `did you mean "slack/darwin"`) {
+		t.Errorf("expected unknown slug error with suggestion, got %v", results[0].Errors)
+	}
+}
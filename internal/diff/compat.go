@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"fmt"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// gitopsUses is what a scope declares that needs a minimum Fleet release.
+type gitopsUses struct {
+	policies []parser.ParsedPolicy
+	labels   []parser.ParsedLabel
+	software parser.ParsedSoftware
+	noTeam   bool
+}
+
+// features returns the features the scope's YAML uses, in compatibility
+// table order.
+func (u gitopsUses) features() []api.Feature {
+	var fs []api.Feature
+	if len(u.software.AppStoreApps) > 0 {
+		fs = append(fs, api.FeatureAppStoreApps)
+	}
+	if len(u.software.FleetMaintained) > 0 {
+		fs = append(fs, api.FeatureFleetMaintainedApps)
+	}
+	if u.noTeam && len(u.policies) > 0 {
+		fs = append(fs, api.FeatureNoTeamPolicies)
+	}
+	if hasManualLabels(u.labels) {
+		fs = append(fs, api.FeatureManualLabels)
+	}
+	var install, run bool
+	for _, p := range u.policies {
+		install = install || p.InstallSoftware != nil
+		run = run || p.RunScript != nil
+	}
+	if install {
+		fs = append(fs, api.FeaturePolicyInstallSoftware)
+	}
+	if run {
+		fs = append(fs, api.FeaturePolicyRunScript)
+	}
+	return fs
+}
+
+// versionWarnings reports GitOps keys the server's Fleet release doesn't
+// support yet, which fleetctl gitops would reject. An unknown version
+// produces no warnings.
+func versionWarnings(v api.ServerVersion, uses gitopsUses) []string {
+	if !v.Known() {
+		return nil
+	}
+	var warnings []string
+	for _, f := range uses.features() {
+		if !v.Supports(f) {
+			warnings = append(warnings, fmt.Sprintf("%s requires Fleet %s or later; server runs %s", f.Key(), f.Since(), v))
+		}
+	}
+	return warnings
+}
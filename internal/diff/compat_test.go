@@ -0,0 +1,63 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestVersionWarnings(t *testing.T) {
+	uses := gitopsUses{
+		policies: []parser.ParsedPolicy{
+			{Name: "Firefox installed", InstallSoftware: &parser.PolicySoftwareRef{PackagePath: "firefox.yml"}},
+			{Name: "Disk encrypted", RunScript: &parser.PolicyScriptRef{Path: "encrypt.sh"}},
+		},
+		labels:   []parser.ParsedLabel{{Name: "Canaries", LabelMembershipType: "manual"}},
+		software: parser.ParsedSoftware{AppStoreApps: []parser.ParsedAppStoreApp{{AppStoreID: "1"}}},
+		noTeam:   true,
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		uses    gitopsUses
+		want    []string
+	}{
+		{
+			name:    "old server",
+			version: "4.55.0",
+			uses:    uses,
+			want: []string{
+				"software.app_store_apps requires Fleet 4.56.0 or later; server runs 4.55.0",
+				"policies (teams/no-team.yml) requires Fleet 4.63.0 or later; server runs 4.55.0",
+				"label_membership_type: manual requires Fleet 4.62.0 or later; server runs 4.55.0",
+				"policies[].install_software requires Fleet 4.57.0 or later; server runs 4.55.0",
+				"policies[].run_script requires Fleet 4.58.0 or later; server runs 4.55.0",
+			},
+		},
+		{
+			name:    "partially supported",
+			version: "4.57.3",
+			uses:    gitopsUses{policies: uses.policies, software: uses.software},
+			want:    []string{"policies[].run_script requires Fleet 4.58.0 or later; server runs 4.57.3"},
+		},
+		{
+			name:    "fleet-maintained apps",
+			version: "4.66.0",
+			uses:    gitopsUses{software: parser.ParsedSoftware{FleetMaintained: []parser.ParsedFleetApp{{Slug: "slack/darwin"}}}},
+			want:    []string{"software.fleet_maintained_apps requires Fleet 4.67.0 or later; server runs 4.66.0"},
+		},
+		{name: "current server", version: "4.70.0", uses: uses},
+		{name: "unknown version", version: "", uses: uses},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := versionWarnings(api.ParseVersion(tt.version), tt.uses)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("warnings =\n  %q\nwant\n  %q", got, tt.want)
+			}
+		})
+	}
+}
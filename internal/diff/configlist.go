@@ -0,0 +1,254 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// listKeyFields are element fields that identify a config list element
+// across versions, most specific first (jira entries share a url but not a
+// project_key).
+var listKeyFields = []string{"project_key", "name", "url", "domain"}
+
+// decodeConfigList parses a flattened config value back into a list.
+// Returns false for anything that isn't a JSON array.
+func decodeConfigList(s string) ([]any, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") {
+		return nil, false
+	}
+	var list []any
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, false
+	}
+	return list, true
+}
+
+// diffConfigList diffs two config lists element by element. Map elements
+// are matched on the first of listKeyFields that identifies every element
+// on both sides (path "key[project_key=ENG]"), scalars by value, and
+// anything else by position (path "key[2]").
+func diffConfigList(section, path string, old, proposed []any) []ConfigChange {
+	if field, ok := listElementKey(old, proposed); ok {
+		oldByKey := make(map[string]any, len(old))
+		for _, e := range old {
+			oldByKey[formatConfigValue(e.(map[string]any)[field])] = e
+		}
+		var changes []ConfigChange
+		seen := make(map[string]bool, len(proposed))
+		for _, e := range proposed {
+			k := formatConfigValue(e.(map[string]any)[field])
+			seen[k] = true
+			elemPath := fmt.Sprintf("%s[%s=%s]", path, field, k)
+			if prev, ok := oldByKey[k]; ok {
+				changes = append(changes, diffConfigElement(section, elemPath, prev, e)...)
+			} else {
+				changes = append(changes, ConfigChange{Section: section, Key: elemPath, New: formatConfigValue(e)})
+			}
+		}
+		for _, e := range old {
+			k := formatConfigValue(e.(map[string]any)[field])
+			if !seen[k] {
+				changes = append(changes, ConfigChange{
+					Section: section, Key: fmt.Sprintf("%s[%s=%s]", path, field, k),
+					Old: formatConfigValue(e), Removed: true,
+				})
+			}
+		}
+		return changes
+	}
+
+	if allScalars(old) && allScalars(proposed) {
+		return diffScalarList(section, path, old, proposed)
+	}
+
+	var changes []ConfigChange
+	for i := 0; i < len(old) || i < len(proposed); i++ {
+		elemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(old):
+			changes = append(changes, ConfigChange{Section: section, Key: elemPath, New: formatConfigValue(proposed[i])})
+		case i >= len(proposed):
+			changes = append(changes, ConfigChange{Section: section, Key: elemPath, Old: formatConfigValue(old[i]), Removed: true})
+		default:
+			changes = append(changes, diffConfigElement(section, elemPath, old[i], proposed[i])...)
+		}
+	}
+	return changes
+}
+
+// diffScalarList reports values added to or removed from a list of scalars,
+// indexed by their position on the side they appear. Reordering alone is
+// not a change.
+func diffScalarList(section, path string, old, proposed []any) []ConfigChange {
+	count := func(list []any) map[string]int {
+		m := make(map[string]int, len(list))
+		for _, v := range list {
+			m[formatConfigValue(v)]++
+		}
+		return m
+	}
+	oldCount, proposedCount := count(old), count(proposed)
+
+	var changes []ConfigChange
+	for i, v := range proposed {
+		s := formatConfigValue(v)
+		if oldCount[s] > 0 {
+			oldCount[s]--
+			continue
+		}
+		if !containsEnvVar(s) {
+			changes = append(changes, ConfigChange{Section: section, Key: fmt.Sprintf("%s[%d]", path, i), New: s})
+		}
+	}
+	for i, v := range old {
+		s := formatConfigValue(v)
+		if proposedCount[s] > 0 {
+			proposedCount[s]--
+			continue
+		}
+		changes = append(changes, ConfigChange{Section: section, Key: fmt.Sprintf("%s[%d]", path, i), Old: s, Removed: true})
+	}
+	return changes
+}
+
+// diffConfigElement compares two list elements at path, recursing into
+// maps and nested lists. The API fills in keys YAML omits, so an omitted
+// key means "leave as is" and is only removed below a "<replace>" subtree;
+// a key YAML sets to an empty value ("", null, []) is removed. Proposed
+// values containing "$" are env var placeholders and are skipped.
+func diffConfigElement(section, path string, old, proposed any) []ConfigChange {
+	oldMap, oldIsMap := old.(map[string]any)
+	proposedMap, proposedIsMap := proposed.(map[string]any)
+	if oldIsMap && proposedIsMap {
+		keys := make(map[string]bool)
+		for k := range oldMap {
+			keys[k] = true
+		}
+		for k := range proposedMap {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		replace := lookupDefault(section, stripListIndexes(path)).kind == defaultReplace
+		var changes []ConfigChange
+		for _, k := range sorted {
+			if _, ok := proposedMap[k]; !ok && !replace {
+				continue
+			}
+			changes = append(changes, diffConfigElement(section, path+"."+k, oldMap[k], proposedMap[k])...)
+		}
+		return changes
+	}
+
+	oldList, oldIsList := old.([]any)
+	proposedList, proposedIsList := proposed.([]any)
+	if oldIsList && proposedIsList {
+		return diffConfigList(section, path, oldList, proposedList)
+	}
+
+	oldVal, proposedVal := formatConfigValue(old), formatConfigValue(proposed)
+	switch {
+	case containsEnvVar(proposedVal), configValuesEqual(oldVal, proposedVal):
+		return nil
+	case isEmptyConfigValue(proposedVal):
+		return []ConfigChange{{Section: section, Key: path, Old: oldVal, Removed: true}}
+	case isEmptyConfigValue(oldVal):
+		return []ConfigChange{{Section: section, Key: path, New: proposedVal}}
+	}
+	return []ConfigChange{{Section: section, Key: path, Old: oldVal, New: proposedVal}}
+}
+
+// listIndex matches the element selectors diffConfigList adds to paths,
+// e.g. "[2]" or "[url=https://acme.atlassian.net]".
+var listIndex = regexp.MustCompile(`\[[^\]]*\]`)
+
+// stripListIndexes turns an element path back into the config key of its
+// list, for looking it up in the defaults model.
+func stripListIndexes(path string) string {
+	return listIndex.ReplaceAllString(path, "")
+}
+
+// listElementKey picks the natural key for a list of maps: the first of
+// listKeyFields that is set and unique on every element of both lists.
+func listElementKey(old, proposed []any) (string, bool) {
+	if len(old) == 0 && len(proposed) == 0 {
+		return "", false
+	}
+	for _, field := range listKeyFields {
+		if uniqueField(old, field) && uniqueField(proposed, field) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// uniqueField reports whether every element of list is a map with a
+// distinct, non-empty scalar value for field.
+func uniqueField(list []any, field string) bool {
+	seen := make(map[string]bool, len(list))
+	for _, e := range list {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return false
+		}
+		v, ok := m[field]
+		if !ok || !isScalar(v) {
+			return false
+		}
+		s := formatConfigValue(v)
+		if s == "" || seen[s] {
+			return false
+		}
+		seen[s] = true
+	}
+	return true
+}
+
+func allScalars(list []any) bool {
+	for _, v := range list {
+		if !isScalar(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return true
+}
+
+// formatConfigValue renders a decoded config value: scalars as text, maps
+// and lists as JSON (with sorted keys), null as "".
+func formatConfigValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]any, []any:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isEmptyConfigValue reports whether a formatted value means "unset".
+func isEmptyConfigValue(s string) bool {
+	return s == "" || s == "[]" || s == "{}"
+}
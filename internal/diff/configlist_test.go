@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestDiffConfigList(t *testing.T) {
+	jira := func(project, user string) map[string]any {
+		return map[string]any{"url": "https://acme.atlassian.net", "project_key": project, "username": user, "api_token": ""}
+	}
+
+	tests := []struct {
+		name     string
+		section  string // default org_settings
+		path     string // default "load"
+		old      []any
+		proposed []any
+		want     []string // "key old→new", "+key new", "-key old"
+	}{
+		{
+			name:     "scalars added and removed by value",
+			old:      []any{"SELECT 1", "SELECT 2", "SELECT 3"},
+			proposed: []any{"SELECT 1", "SELECT 3", "SELECT 4"},
+			want:     []string{"+load[2] SELECT 4", "-load[1] SELECT 2"},
+		},
+		{
+			name:     "scalar reorder is not a change",
+			old:      []any{"a", "b"},
+			proposed: []any{"b", "a"},
+		},
+		{
+			name:     "maps matched by natural key",
+			old:      []any{jira("ENG", "bot"), jira("OPS", "bot")},
+			proposed: []any{map[string]any{"url": "https://acme.atlassian.net", "project_key": "OPS", "username": "ops-bot"}, jira("SEC", "bot")},
+			want: []string{
+				"load[project_key=OPS].username bot→ops-bot",
+				`+load[project_key=SEC] {"api_token":"","project_key":"SEC","url":"https://acme.atlassian.net","username":"bot"}`,
+				`-load[project_key=ENG] {"api_token":"","project_key":"ENG","url":"https://acme.atlassian.net","username":"bot"}`,
+			},
+		},
+		{
+			name:     "maps without a natural key matched by position",
+			old:      []any{map[string]any{"a": "1"}},
+			proposed: []any{map[string]any{"a": "2"}, map[string]any{"a": "3"}},
+			want:     []string{"load[0].a 1→2", `+load[1] {"a":"3"}`},
+		},
+		{
+			name:     "field omitted from element is left as is",
+			old:      []any{map[string]any{"name": "x", "extra": "y"}},
+			proposed: []any{map[string]any{"name": "x"}},
+		},
+		{
+			name:     "field set empty is removed",
+			old:      []any{map[string]any{"name": "x", "extra": "y"}},
+			proposed: []any{map[string]any{"name": "x", "extra": ""}},
+			want:     []string{"-load[name=x].extra y"},
+		},
+		{
+			name:     "field omitted below a replace subtree is removed",
+			section:  "agent_options",
+			path:     "config.decorators.load",
+			old:      []any{map[string]any{"name": "x", "extra": "y"}},
+			proposed: []any{map[string]any{"name": "x"}},
+			want:     []string{"-config.decorators.load[name=x].extra y"},
+		},
+		{
+			name: "only the changed field of a server-filled element",
+			path: "integrations.jira",
+			old: []any{map[string]any{
+				"url": "https://acme.atlassian.net", "project_key": "ENG", "username": "bot",
+				"api_token": "********", "enable_failing_policies": false, "enable_software_vulnerabilities": false,
+			}},
+			proposed: []any{map[string]any{"url": "https://acme.atlassian.net", "project_key": "ENG", "username": "eng-bot"}},
+			want:     []string{"integrations.jira[project_key=ENG].username bot→eng-bot"},
+		},
+		{
+			name:     "env var placeholder inside element skipped",
+			old:      []any{map[string]any{"project_key": "ENG", "api_token": "secret"}},
+			proposed: []any{map[string]any{"project_key": "ENG", "api_token": "$JIRA_TOKEN"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			section, path := tt.section, tt.path
+			if section == "" {
+				section = "org_settings"
+			}
+			if path == "" {
+				path = "load"
+			}
+			for _, c := range diffConfigList(section, path, tt.old, tt.proposed) {
+				switch {
+				case c.Removed:
+					got = append(got, fmt.Sprintf("-%s %s", c.Key, c.Old))
+				case c.Old == "":
+					got = append(got, fmt.Sprintf("+%s %s", c.Key, c.New))
+				default:
+					got = append(got, fmt.Sprintf("%s %s→%s", c.Key, c.Old, c.New))
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListElementKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		old, proposed []any
+		want          string
+	}{
+		{"project_key beats shared url", []any{map[string]any{"url": "u", "project_key": "A"}}, []any{map[string]any{"url": "u", "project_key": "B"}}, "project_key"},
+		{"duplicate url", []any{map[string]any{"url": "u"}, map[string]any{"url": "u"}}, nil, ""},
+		{"scalars", []any{"a"}, []any{"b"}, ""},
+		{"name", []any{map[string]any{"name": "n"}}, []any{}, "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := listElementKey(tt.old, tt.proposed)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// maxConflictItems caps how many overlapping LocURIs/payloads are listed per
+// finding before collapsing the rest into "and N more".
+const maxConflictItems = 3
+
+// detectProfileConflicts reports pairs of profiles on the same team that
+// configure the same Apple payload type (with overlapping setting keys) or the
+// same Windows OMA-URI. Fleet delivers both profiles, so hosts in both label
+// scopes end up with whichever one the OS applied last. Pairs whose label
+// scopes provably cannot intersect are skipped.
+//
+// When changedFiles is non-empty, only conflicts involving at least one
+// changed profile or team file are reported (MR-scoped filtering).
+func detectProfileConflicts(profiles []parser.ParsedProfile, changedFiles []string) []string {
+	var findings []string
+	for i := 0; i < len(profiles); i++ {
+		for j := i + 1; j < len(profiles); j++ {
+			a, b := profiles[i], profiles[j]
+			if len(changedFiles) > 0 && !profileChanged(a, changedFiles) && !profileChanged(b, changedFiles) {
+				continue
+			}
+			overlaps := payloadOverlaps(a.Payloads, b.Payloads)
+			overlaps = append(overlaps, locURIOverlaps(a.CSPItems, b.CSPItems)...)
+			if len(overlaps) == 0 || scopesDisjoint(a, b) || scopesDisjoint(b, a) {
+				continue
+			}
+			findings = append(findings, fmt.Sprintf(
+				"profile conflict: %q and %q both configure %s (scopes: %s vs %s)",
+				a.Path, b.Path, summarizeOverlaps(overlaps), describeScope(a), describeScope(b)))
+		}
+	}
+	return findings
+}
+
+// payloadOverlaps returns the payload types configured by both profiles. A
+// shared type counts when the payloads share at least one setting key, or
+// when either payload has no setting keys (the type itself is the setting).
+func payloadOverlaps(a, b []parser.ProfilePayload) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, pa := range a {
+		for _, pb := range b {
+			if pa.Type != pb.Type || seen[pa.Type] {
+				continue
+			}
+			var shared []string
+			for _, k := range pa.Keys {
+				if slices.Contains(pb.Keys, k) {
+					shared = append(shared, k)
+				}
+			}
+			switch {
+			case len(shared) > 0:
+				out = append(out, fmt.Sprintf("%s [%s]", pa.Type, strings.Join(shared, ", ")))
+			case len(pa.Keys) == 0 || len(pb.Keys) == 0:
+				out = append(out, pa.Type)
+			default:
+				continue
+			}
+			seen[pa.Type] = true
+		}
+	}
+	return out
+}
+
+// locURIOverlaps returns the OMA-URI paths targeted by both profiles.
+// LocURIs are compared case-insensitively, matching the Windows CSP.
+func locURIOverlaps(a, b []parser.CSPItem) []string {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	inB := make(map[string]bool, len(b))
+	for _, item := range b {
+		inB[strings.ToLower(item.LocURI)] = true
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, item := range a {
+		key := strings.ToLower(item.LocURI)
+		if inB[key] && !seen[key] {
+			seen[key] = true
+			out = append(out, item.LocURI)
+		}
+	}
+	return out
+}
+
+// scopesDisjoint reports whether no host can be in both profiles' scopes
+// because a excludes every label b requires.
+func scopesDisjoint(a, b parser.ParsedProfile) bool {
+	if len(a.LabelsExcludeAny) == 0 {
+		return false
+	}
+	excluded := func(name string) bool { return slices.Contains(a.LabelsExcludeAny, name) }
+	for _, l := range b.LabelsIncludeAll {
+		if excluded(l) {
+			return true
+		}
+	}
+	if len(b.LabelsIncludeAny) == 0 {
+		return false
+	}
+	for _, l := range b.LabelsIncludeAny {
+		if !excluded(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeScope renders a profile's label targeting for conflict messages.
+func describeScope(p parser.ParsedProfile) string {
+	var parts []string
+	if len(p.LabelsIncludeAll) > 0 {
+		parts = append(parts, "include_all ["+strings.Join(p.LabelsIncludeAll, ", ")+"]")
+	}
+	if len(p.LabelsIncludeAny) > 0 {
+		parts = append(parts, "include_any ["+strings.Join(p.LabelsIncludeAny, ", ")+"]")
+	}
+	if len(p.LabelsExcludeAny) > 0 {
+		parts = append(parts, "exclude_any ["+strings.Join(p.LabelsExcludeAny, ", ")+"]")
+	}
+	if len(parts) == 0 {
+		return "all hosts"
+	}
+	return strings.Join(parts, " ")
+}
+
+// summarizeOverlaps joins overlap descriptions, collapsing long lists.
+func summarizeOverlaps(items []string) string {
+	if len(items) <= maxConflictItems {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:maxConflictItems], ", "), len(items)-maxConflictItems)
+}
+
+// profileChanged reports whether the profile file or the team YAML that
+// references it appears in changedFiles.
+func profileChanged(p parser.ParsedProfile, changedFiles []string) bool {
+	return isChangedFile(p.Path, changedFiles) || isChangedFile(p.SourceFile, changedFiles)
+}
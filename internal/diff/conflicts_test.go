@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestDetectProfileConflicts(t *testing.T) {
+	screensaver := func(path string, keys ...string) parser.ParsedProfile {
+		return parser.ParsedProfile{
+			Path:     path,
+			Platform: "darwin",
+			Payloads: []parser.ProfilePayload{{Type: "com.apple.screensaver", Keys: keys}},
+		}
+	}
+	lockURI := "./Device/Vendor/MSFT/Policy/Config/DeviceLock/MinDevicePasswordLength"
+
+	tests := []struct {
+		name         string
+		profiles     []parser.ParsedProfile
+		changedFiles []string
+		wantCount    int
+		wantContains []string
+	}{
+		{
+			name: "same payload type with shared key",
+			profiles: []parser.ParsedProfile{
+				screensaver("/repo/profiles/a.mobileconfig", "idleTime", "askForPassword"),
+				screensaver("/repo/profiles/b.mobileconfig", "idleTime"),
+			},
+			wantCount:    1,
+			wantContains: []string{"a.mobileconfig", "b.mobileconfig", "com.apple.screensaver [idleTime]", "all hosts vs all hosts"},
+		},
+		{
+			name: "same payload type with disjoint keys",
+			profiles: []parser.ParsedProfile{
+				screensaver("/repo/profiles/a.mobileconfig", "idleTime"),
+				screensaver("/repo/profiles/b.mobileconfig", "moduleName"),
+			},
+		},
+		{
+			name: "different payload types",
+			profiles: []parser.ParsedProfile{
+				screensaver("/repo/profiles/a.mobileconfig", "idleTime"),
+				{Path: "/repo/profiles/b.mobileconfig", Payloads: []parser.ProfilePayload{{Type: "com.apple.wifi.managed", Keys: []string{"idleTime"}}}},
+			},
+		},
+		{
+			name: "same LocURI, case-insensitive",
+			profiles: []parser.ParsedProfile{
+				{Path: "/repo/profiles/a.xml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}},
+				{Path: "/repo/profiles/b.xml", CSPItems: []parser.CSPItem{{LocURI: strings.ToLower(lockURI)}}, LabelsIncludeAny: []string{"Laptops"}},
+			},
+			wantCount:    1,
+			wantContains: []string{"MinDevicePasswordLength", "all hosts vs include_any [Laptops]"},
+		},
+		{
+			name: "scopes provably disjoint",
+			profiles: []parser.ParsedProfile{
+				{Path: "/repo/profiles/a.xml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}, LabelsExcludeAny: []string{"Kiosks"}},
+				{Path: "/repo/profiles/b.xml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}, LabelsIncludeAll: []string{"Kiosks"}},
+			},
+		},
+		{
+			name: "include_any only partly excluded still overlaps",
+			profiles: []parser.ParsedProfile{
+				{Path: "/repo/profiles/a.xml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}, LabelsExcludeAny: []string{"Kiosks"}},
+				{Path: "/repo/profiles/b.xml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}, LabelsIncludeAny: []string{"Kiosks", "Laptops"}},
+			},
+			wantCount: 1,
+		},
+		{
+			name: "changed files exclude untouched pair",
+			profiles: []parser.ParsedProfile{
+				{Path: "/repo/profiles/a.xml", SourceFile: "/repo/teams/t.yml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}},
+				{Path: "/repo/profiles/b.xml", SourceFile: "/repo/teams/t.yml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}},
+			},
+			changedFiles: []string{"profiles/c.xml"},
+		},
+		{
+			name: "changed files include pair member",
+			profiles: []parser.ParsedProfile{
+				{Path: "/repo/profiles/a.xml", SourceFile: "/repo/teams/t.yml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}},
+				{Path: "/repo/profiles/b.xml", SourceFile: "/repo/teams/t.yml", CSPItems: []parser.CSPItem{{LocURI: lockURI}}},
+			},
+			changedFiles: []string{"profiles/b.xml"},
+			wantCount:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectProfileConflicts(tt.profiles, tt.changedFiles)
+			if len(got) != tt.wantCount {
+				t.Fatalf("findings: got %d, want %d: %v", len(got), tt.wantCount, got)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got[0], want) {
+					t.Errorf("finding %q should contain %q", got[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarizeOverlaps(t *testing.T) {
+	got := summarizeOverlaps([]string{"a", "b", "c", "d", "e"})
+	if got != "a, b, c and 2 more" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestDiffReportsProfileConflicts(t *testing.T) {
+	uri := "./Device/Vendor/MSFT/Policy/Config/Defender/AllowRealtimeMonitoring"
+	current := &api.FleetState{Teams: []api.Team{{ID: 1, Name: "T"}}}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name: "T",
+		Profiles: []parser.ParsedProfile{
+			{Path: "/repo/profiles/defender.xml", Name: "defender", Platform: "windows", CSPItems: []parser.CSPItem{{LocURI: uri}}},
+			{Path: "/repo/profiles/baseline.xml", Name: "baseline", Platform: "windows", CSPItems: []parser.CSPItem{{LocURI: uri}}},
+		},
+	}}}
+
+	results := Diff(current, proposed, nil, nil)
+	found := false
+	for _, e := range results[0].Errors {
+		if strings.HasPrefix(e, "profile conflict:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected profile conflict in errors, got %v", results[0].Errors)
+	}
+}
@@ -0,0 +1,189 @@
+package diff
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// fleetDefaultsJSON is Fleet's default value for every config key fleet-plan
+// knows about, per default.yml section (org_settings, agent_options,
+// controls). Two markers stand in for values:
+//
+//   - {"*": "<open>"} or {"*": "<replace>"}: a subtree with free-form keys
+//     (integrations, agent_options.config, ...). Keys below it are not
+//     typo-checked. "<replace>" subtrees are replaced wholesale on apply,
+//     so keys Fleet has that YAML omits are removed.
+//   - "<skip>": a key gitops manages outside the config endpoint (profiles,
+//     scripts, secrets), or one the server computes or masks
+//     (smtp_settings.configured, smtp_settings.password). No additions or
+//     resets are reported for it, nor changes against a masked value.
+//
+//go:embed fleet_defaults.json
+var fleetDefaultsJSON []byte
+
+// fleetDefaults is fleetDefaultsJSON decoded, keyed by section.
+var fleetDefaults = func() map[string]map[string]any {
+	var d map[string]map[string]any
+	if err := json.Unmarshal(fleetDefaultsJSON, &d); err != nil {
+		panic("diff: invalid fleet_defaults.json: " + err.Error())
+	}
+	return d
+}()
+
+const (
+	markerOpen    = "<open>"
+	markerReplace = "<replace>"
+	markerSkip    = "<skip>"
+)
+
+// maskedConfigValue is what Fleet returns in place of secrets such as the
+// SMTP password.
+const maskedConfigValue = "********"
+
+// defaultKind classifies a config key against the defaults model.
+type defaultKind int
+
+const (
+	defaultUnknown defaultKind = iota // not a Fleet setting
+	defaultLeaf                       // known key with a default value
+	defaultOpen                       // below a free-form subtree
+	defaultReplace                    // below a subtree replaced on apply
+	defaultSkip                       // managed outside the config endpoint
+)
+
+// defaultEntry is the result of looking up a key in the defaults model.
+type defaultEntry struct {
+	kind  defaultKind
+	value string // defaultLeaf: the default, formatted like flattenMap
+
+	// defaultUnknown: the dotted path of the deepest known map and its keys,
+	// for "did you mean" suggestions.
+	parent string
+	known  []string
+}
+
+// lookupDefault resolves a dot-separated key within a section.
+func lookupDefault(section, key string) defaultEntry {
+	node, ok := fleetDefaults[section]
+	if !ok {
+		return defaultEntry{kind: defaultOpen}
+	}
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if kind, ok := subtreeMarker(node); ok {
+			return defaultEntry{kind: kind}
+		}
+		v, ok := node[part]
+		if !ok {
+			known := make([]string, 0, len(node))
+			for k := range node {
+				known = append(known, k)
+			}
+			slices.Sort(known)
+			return defaultEntry{kind: defaultUnknown, parent: strings.Join(parts[:i], "."), known: known}
+		}
+		if s, ok := v.(string); ok && s == markerSkip {
+			return defaultEntry{kind: defaultSkip}
+		}
+		next, ok := v.(map[string]any)
+		if !ok {
+			if i < len(parts)-1 {
+				// YAML nests below a key the model treats as a scalar.
+				return defaultEntry{kind: defaultSkip}
+			}
+			return defaultEntry{kind: defaultLeaf, value: formatDefault(v)}
+		}
+		node = next
+	}
+	if kind, ok := subtreeMarker(node); ok {
+		return defaultEntry{kind: kind}
+	}
+	return defaultEntry{kind: defaultSkip}
+}
+
+// subtreeMarker reports whether node is an open or replace subtree.
+func subtreeMarker(node map[string]any) (defaultKind, bool) {
+	switch node["*"] {
+	case markerOpen:
+		return defaultOpen, true
+	case markerReplace:
+		return defaultReplace, true
+	}
+	return defaultUnknown, false
+}
+
+// defaultLeaves returns every known key in a section with its default,
+// excluding skipped keys and free-form subtrees.
+func defaultLeaves(section string) map[string]string {
+	leaves := make(map[string]string)
+	var walk func(node map[string]any, prefix string)
+	walk = func(node map[string]any, prefix string) {
+		if _, ok := subtreeMarker(node); ok {
+			return
+		}
+		for k, v := range node {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			switch val := v.(type) {
+			case map[string]any:
+				walk(val, key)
+			case string:
+				if val != markerSkip {
+					leaves[key] = val
+				}
+			default:
+				leaves[key] = formatDefault(v)
+			}
+		}
+	}
+	walk(fleetDefaults[section], "")
+	return leaves
+}
+
+// replaceRoots returns the dotted paths of a section's "<replace>" subtrees.
+func replaceRoots(section string) []string {
+	var roots []string
+	var walk func(node map[string]any, prefix string)
+	walk = func(node map[string]any, prefix string) {
+		if kind, ok := subtreeMarker(node); ok {
+			if kind == defaultReplace {
+				roots = append(roots, prefix)
+			}
+			return
+		}
+		for k, v := range node {
+			if m, ok := v.(map[string]any); ok {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				walk(m, key)
+			}
+		}
+	}
+	walk(fleetDefaults[section], "")
+	slices.Sort(roots)
+	return roots
+}
+
+// formatDefault renders a default the way flattenMap renders YAML values so
+// the two compare directly. null becomes "".
+func formatDefault(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLookupDefault(t *testing.T) {
+	tests := []struct {
+		section, key string
+		wantKind     defaultKind
+		wantValue    string
+		wantParent   string
+	}{
+		{"org_settings", "server_settings.query_report_cap", defaultLeaf, "1000", ""},
+		{"org_settings", "server_settings.debug_host_ids", defaultLeaf, "[]", ""},
+		{"org_settings", "features.enable_host_users", defaultLeaf, "true", ""},
+		{"org_settings", "features.additional_queries.users", defaultOpen, "", ""},
+		{"org_settings", "integrations.jira", defaultOpen, "", ""},
+		{"org_settings", "secrets", defaultSkip, "", ""},
+		{"org_settings", "server_setings.server_url", defaultUnknown, "", ""},
+		{"org_settings", "webhook_settings.host_status_webhook.days", defaultUnknown, "", "webhook_settings.host_status_webhook"},
+		{"agent_options", "config.options.distributed_interval", defaultReplace, "", ""},
+		{"agent_options", "script_execution_timeout", defaultLeaf, "300", ""},
+		{"controls", "windows_updates.deadline_days", defaultLeaf, "", ""},
+		{"controls", "macos_settings.custom_settings", defaultSkip, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.section+"."+tt.key, func(t *testing.T) {
+			got := lookupDefault(tt.section, tt.key)
+			if got.kind != tt.wantKind || got.value != tt.wantValue || got.parent != tt.wantParent {
+				t.Errorf("got kind=%d value=%q parent=%q, want kind=%d value=%q parent=%q",
+					got.kind, got.value, got.parent, tt.wantKind, tt.wantValue, tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestDefaultLeavesExcludeMarkers(t *testing.T) {
+	leaves := defaultLeaves("org_settings")
+	if leaves["smtp_settings.port"] != "587" {
+		t.Errorf("smtp_settings.port = %q, want 587", leaves["smtp_settings.port"])
+	}
+	for _, k := range []string{"secrets", "integrations.*", "features.additional_queries.*"} {
+		if _, ok := leaves[k]; ok {
+			t.Errorf("%s should not be a default leaf", k)
+		}
+	}
+	if got := replaceRoots("agent_options"); !slices.Equal(got, []string{"command_line_flags", "config", "extensions", "overrides"}) {
+		t.Errorf("replaceRoots(agent_options) = %v", got)
+	}
+}
+
+func TestConfigResetsReplaceSubtree(t *testing.T) {
+	apiSection := map[string]any{
+		"config": map[string]any{
+			"options":    map[string]any{"distributed_interval": float64(10)},
+			"decorators": map[string]any{"load": []any{"SELECT 1"}},
+		},
+		"overrides":       map[string]any{"platforms": map[string]any{"darwin": "x"}},
+		"update_channels": map[string]any{"osqueryd": "stable", "orbit": "edge"},
+	}
+	proposed := map[string]any{
+		"config": map[string]any{"options": map[string]any{"distributed_interval": 10}},
+	}
+
+	got := configResets("agent_options", apiSection, proposed)
+	keys := make([]string, 0, len(got))
+	for _, c := range got {
+		if !c.Reset {
+			t.Errorf("%s: Reset not set", c.Key)
+		}
+		keys = append(keys, c.Key)
+	}
+	slices.Sort(keys)
+	// overrides isn't in YAML at all, so it's left alone.
+	want := []string{"config.decorators.load", "update_channels.orbit"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("resets: got %v, want %v", keys, want)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
@@ -30,9 +31,12 @@ type DiffResult struct {
 	Profiles              ResourceDiff
 	Scripts               ResourceDiff
 	Labels                LabelValidation
+	LabelMembership       ResourceDiff   // manual label hosts added and removed
 	Config                []ConfigChange // org_settings, agent_options, controls diffs
 	Errors                []string
-	SkippedConfigSections []string // config sections absent from API (e.g. "agent_options")
+	SkippedConfigSections []string       // config sections absent from API (e.g. "agent_options")
+	Access                *Access        // what the API token could read; nil if not discovered
+	NotFetched            []api.Resource // skipped because the run was interrupted before fetching them
 }
 
 // ConfigChange represents a change in a top-level config section.
@@ -41,6 +45,8 @@ type ConfigChange struct {
 	Key     string // dot-separated path, e.g. "server_settings.server_url"
 	Old     string
 	New     string
+	Reset   bool // key omitted from YAML; New is Fleet's default
+	Removed bool // list element or element field dropped from YAML
 }
 
 // ResourceDiff categorizes changes for one resource type.
@@ -66,6 +72,7 @@ type ResourceChange struct {
 	Fields    map[string]FieldDiff // field name -> old/new values
 	HostCount uint                 // from API: affected hosts
 	Warning   string               // e.g., "will delete compliance data"
+	Cost      int                  // scheduled queries: estimated cost score
 }
 
 // FieldDiff shows old vs new value for a single field.
@@ -83,8 +90,10 @@ type LabelValidation struct {
 // LabelRef is a label name with metadata.
 type LabelRef struct {
 	Name         string
-	HostCount    uint   // only for valid labels
-	ReferencedBy string // which policy/query references it
+	HostCount    uint   // only for valid labels that exist in Fleet
+	ReferencedBy string // resources referencing it, e.g. "policy: A, query: B"
+	Pending      bool   // declared in YAML, created by this plan
+	Deleted      bool   // in Fleet, deleted by this plan
 }
 
 // ---------- Diff engine ----------
@@ -100,10 +109,13 @@ type ScriptEnricher interface {
 type DiffOption func(*diffOptions)
 
 type diffOptions struct {
+	ctx           context.Context
 	enricher      ScriptEnricher
+	hosts         HostResolver
 	baseline      *parser.ParsedRepo
 	verbose       bool
 	includeGlobal bool
+	queryCost     int
 }
 
 // WithScriptEnricher enables script-level diffing for fleet-maintained apps.
@@ -111,6 +123,12 @@ func WithScriptEnricher(e ScriptEnricher) DiffOption {
 	return func(o *diffOptions) { o.enricher = e }
 }
 
+// WithHostResolver resolves manual label host identifiers that are not yet
+// label members, so typos are reported instead of silently dropped.
+func WithHostResolver(r HostResolver) DiffOption {
+	return func(o *diffOptions) { o.hosts = r }
+}
+
 // WithVerbose enables detailed stderr logging of baseline subtraction.
 func WithVerbose(v bool) DiffOption {
 	return func(o *diffOptions) { o.verbose = v }
@@ -130,6 +148,12 @@ func WithBaseline(b *parser.ParsedRepo) DiffOption {
 	return func(o *diffOptions) { o.baseline = b }
 }
 
+// WithQueryCostLimit sets the cost above which scheduled queries are flagged.
+// Zero keeps DefaultQueryCostLimit.
+func WithQueryCostLimit(limit int) DiffOption {
+	return func(o *diffOptions) { o.queryCost = limit }
+}
+
 // vlog writes to stderr when verbose mode is enabled.
 func vlog(verbose bool, format string, args ...any) {
 	if verbose {
@@ -170,6 +194,12 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 	for _, o := range opts {
 		o(&cfg)
 	}
+	if cfg.queryCost <= 0 {
+		cfg.queryCost = DefaultQueryCostLimit
+	}
+	if cfg.ctx == nil {
+		cfg.ctx = context.Background()
+	}
 	var results []DiffResult
 
 	// Build label lookup from API
@@ -177,6 +207,7 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 	for _, l := range current.Labels {
 		labelMap[l.Name] = l
 	}
+	refs := buildRefIndex(current, proposed)
 
 	vlog(cfg.verbose, "baseline=%v, baseline.Global=%v, teamFilters=%v, changedFiles=%v",
 		cfg.baseline != nil, cfg.baseline != nil && cfg.baseline.Global != nil, teamFilters, changedFiles)
@@ -185,17 +216,30 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 	if proposed.Global != nil && (len(teamFilters) == 0 || cfg.includeGlobal) {
 		vlog(cfg.verbose, "(global) proposed: %d policies, %d queries", len(proposed.Global.Policies), len(proposed.Global.Queries))
 		vlog(cfg.verbose, "(global) fleet: %d policies, %d queries", len(current.GlobalPolicies), len(current.GlobalQueries))
-		globalResult := DiffResult{Team: "(global)"}
+		globalResult := DiffResult{Team: "(global)", Access: globalAccess(current),
+			NotFetched: globalNotFetched(current, proposed.Labels)}
 
 		if current.Config != nil {
-			globalResult.Config, globalResult.SkippedConfigSections = diffConfig(current.Config, proposed.Global)
+			var warnings []string
+			globalResult.Config, globalResult.SkippedConfigSections, warnings = diffConfig(current.Config, proposed.Global)
+			globalResult.Errors = append(globalResult.Errors, warnings...)
+		} else if globalResult.notFetched(api.ResourceConfig) {
+			globalResult.Errors = append(globalResult.Errors, notFetchedError("config diff"))
 		}
 
 		// Diff global policies
-		globalResult.Policies = diffPolicies(current.GlobalPolicies, proposed.Global.Policies)
+		if globalResult.notFetched(api.ResourcePolicies) {
+			globalResult.Errors = append(globalResult.Errors, notFetchedError("policies diff"))
+		} else {
+			globalResult.Policies = diffPolicies(current.GlobalPolicies, proposed.Global.Policies)
+		}
 
 		// Diff global queries
-		globalResult.Queries = diffQueries(current.GlobalQueries, proposed.Global.Queries)
+		if globalResult.notFetched(api.ResourceQueries) {
+			globalResult.Errors = append(globalResult.Errors, notFetchedError("queries diff"))
+		} else {
+			globalResult.Queries = diffQueries(current.GlobalQueries, proposed.Global.Queries)
+		}
 
 		vlog(cfg.verbose, "(global) MR diff: policies=%s queries=%s config=%d",
 			rdSummary(globalResult.Policies), rdSummary(globalResult.Queries), len(globalResult.Config))
@@ -206,7 +250,7 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 				len(cfg.baseline.Global.Policies), len(cfg.baseline.Global.Queries))
 			var baseConfig []ConfigChange
 			if current.Config != nil {
-				baseConfig, _ = diffConfig(current.Config, cfg.baseline.Global)
+				baseConfig, _, _ = diffConfig(current.Config, cfg.baseline.Global)
 			}
 			basePolicies := diffPolicies(current.GlobalPolicies, cfg.baseline.Global.Policies)
 			baseQueries := diffQueries(current.GlobalQueries, cfg.baseline.Global.Queries)
@@ -222,6 +266,20 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 				rdSummary(globalResult.Policies), rdSummary(globalResult.Queries), len(globalResult.Config))
 		}
 
+		sqlSources := teamSQLSources(proposed.Global.Policies, proposed.Global.Queries)
+		sqlSources = append(sqlSources, labelSQLSources(proposed.Labels)...)
+		globalResult.Errors = append(globalResult.Errors,
+			validateSQL(sqlSources, changedFiles, &globalResult.Policies, &globalResult.Queries)...)
+		scoreQueries(&globalResult.Queries, proposed.Global.Queries, cfg.queryCost)
+		if globalResult.notFetched(api.ResourceLabels) {
+			globalResult.Errors = append(globalResult.Errors, notFetchedError("label checks"))
+		} else {
+			globalResult.Labels = validateLabels(globalLabelUses(proposed.Global), refs, labelMap, nil)
+			addLabelMembership(cfg.ctx, &globalResult, proposed.Labels, labelMap, cfg.hosts, changedFiles)
+		}
+		globalResult.Errors = append(globalResult.Errors, versionWarnings(current.ServerVersion,
+			gitopsUses{policies: proposed.Global.Policies, labels: proposed.Labels})...)
+
 		results = append(results, globalResult)
 	}
 
@@ -231,6 +289,10 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 	for _, t := range current.Teams {
 		currentTeams[t.Name] = t
 	}
+	// "No team" isn't returned by /teams; FetchAll loads it separately.
+	if current.NoTeam != nil {
+		currentTeams[parser.NoTeamName] = *current.NoTeam
+	}
 
 	for _, proposedTeam := range proposed.Teams {
 		if len(teamFilters) > 0 && !parser.MatchesAnyTeam(proposedTeam.Name, teamFilters) {
@@ -239,14 +301,36 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 
 		result := DiffResult{Team: proposedTeam.Name}
 
-		currentTeam, exists := currentTeams[proposedTeam.Name]
+		teamName := proposedTeam.Name
+		if strings.EqualFold(teamName, parser.NoTeamName) {
+			teamName = parser.NoTeamName
+		}
+		currentTeam, exists := currentTeams[teamName]
 		if !exists {
+			currentTeam.Name = teamName
+		}
+		result.Access = teamAccess(current, currentTeam, exists)
+		result.NotFetched = teamNotFetched(current, currentTeam, proposedTeam)
+		if slices.Contains(current.NotFetched, api.ResourceTeams) {
+			result.Errors = append(result.Errors, notFetchedError("team diff"))
+			results = append(results, result)
+			continue
+		}
+		if !exists && result.Access != nil && result.Access.Role == "" {
+			// Team-only tokens only see their own teams, so a missing team
+			// may exist; don't report its resources as new.
+			if teamName == parser.NoTeamName {
+				result.Errors = append(result.Errors, "\"No team\" diff skipped: API token has no global role")
+			} else {
+				result.Errors = append(result.Errors, fmt.Sprintf("team diff skipped: API token has no role on team %q", proposedTeam.Name))
+			}
+		} else if !exists {
 			// "No team" is a special Fleet concept -- it always exists but isn't
 			// returned by the /teams API endpoint. It holds hosts not assigned to
 			// any team. Skip the "will be created" warning for it.
-			if strings.EqualFold(proposedTeam.Name, "No team") {
-				// Can't deep-diff against API state for "No team" since it's not
-				// in the teams list. Just show it exists with its resource counts.
+			if teamName == parser.NoTeamName {
+				// Without fetched team-0 state we can't deep-diff "No team".
+				// Just show it exists with its resource counts.
 				pCount := len(proposedTeam.Policies)
 				qCount := len(proposedTeam.Queries)
 				if pCount > 0 || qCount > 0 {
@@ -269,17 +353,31 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 			vlog(cfg.verbose, "[%s] fleet: %d policies, %d queries", proposedTeam.Name,
 				len(currentTeam.Policies), len(currentTeam.Queries))
 
-			result.Policies = diffPolicies(currentTeam.Policies, proposedTeam.Policies)
-			result.Queries = diffQueries(currentTeam.Queries, proposedTeam.Queries)
+			switch {
+			case result.notFetched(api.ResourcePolicies):
+				result.Errors = append(result.Errors, notFetchedError("policies diff"))
+			case currentTeam.PoliciesUnavailable:
+				result.Errors = append(result.Errors, "policies diff skipped: Fleet server does not expose \"No team\" policies")
+			default:
+				result.Policies = diffPolicies(currentTeam.Policies, proposedTeam.Policies)
+			}
+			if result.notFetched(api.ResourceQueries) {
+				result.Errors = append(result.Errors, notFetchedError("queries diff"))
+			} else {
+				result.Queries = diffQueries(currentTeam.Queries, proposedTeam.Queries)
+			}
 
 			// enrichedSoftware holds the API software state with fleet-maintained
 			// app scripts populated. Hoisted here so the baseline subtraction
 			// can reuse the same enriched state.
 			enrichedSoftware := currentTeam.Software
 
-			if currentTeam.SoftwareUnavailable {
+			switch {
+			case result.notFetched(api.ResourceSoftware):
+				result.Errors = append(result.Errors, notFetchedError("software diff"))
+			case currentTeam.SoftwareUnavailable:
 				result.Errors = append(result.Errors, "software diff skipped: API token lacks permission to read software titles")
-			} else {
+			default:
 				// Fleet's /teams API may return fleet_maintained_apps: null, or
 				// return a partial list (e.g., only macOS FMAs while Windows FMAs
 				// are merged into packages). Infer from software titles + catalog
@@ -287,25 +385,44 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 				if len(proposedTeam.Software.FleetMaintained) > 0 {
 					inferred := inferFleetMaintainedApps(currentTeam, current.FleetMaintainedCatalog, proposedTeam.Software.Packages)
 					if cfg.enricher != nil && len(inferred) > 0 {
-						cfg.enricher.EnrichFleetAppScripts(context.Background(), inferred)
+						cfg.enricher.EnrichFleetAppScripts(cfg.ctx, inferred)
+						if cfg.ctx.Err() != nil {
+							// Scripts it didn't fetch would diff as removed.
+							result.skipNotFetched(api.ResourceSoftware, "software diff")
+							break
+						}
 					}
 					enrichedSoftware.FleetMaintained = mergeFleetApps(currentTeam.Software.FleetMaintained, inferred)
 				}
+				enrichedSoftware.AppStoreApps = mergeAppStoreTitles(currentTeam.Software.AppStoreApps, currentTeam.SoftwareTitles)
+
+				if result.notFetched(api.ResourceAppStore) && len(proposedTeam.Software.AppStoreApps) > 0 {
+					result.Errors = append(result.Errors, notFetchedError("App Store license checks"))
+				}
+				result.Errors = append(result.Errors, validateAppStoreApps(proposedTeam.Software.AppStoreApps,
+					enrichedSoftware.AppStoreApps, currentTeam, current)...)
 
 				result.Software = diffSoftware(enrichedSoftware, proposedTeam.Software)
+				annotateSoftwareImpact(&result.Software, enrichedSoftware, currentTeam.SoftwareTitles)
 			}
 
-			if currentTeam.ProfilesUnavailable {
+			switch {
+			case result.notFetched(api.ResourceProfiles):
+				result.Errors = append(result.Errors, notFetchedError("profiles diff"))
+			case currentTeam.ProfilesUnavailable:
 				result.Errors = append(result.Errors, "profiles diff skipped: API token lacks permission to read profiles")
-			} else {
+			default:
 				var profileWarnings []string
 				result.Profiles, profileWarnings = diffProfiles(currentTeam.Profiles, proposedTeam.Profiles, changedFiles)
 				result.Errors = append(result.Errors, profileWarnings...)
 			}
 
-			if currentTeam.ScriptsUnavailable {
+			switch {
+			case result.notFetched(api.ResourceScripts):
+				result.Errors = append(result.Errors, notFetchedError("scripts diff"))
+			case currentTeam.ScriptsUnavailable:
 				result.Errors = append(result.Errors, "scripts diff skipped: API token lacks permission to read scripts")
-			} else {
+			default:
 				result.Scripts = diffScripts(currentTeam.Scripts, proposedTeam.Scripts)
 			}
 
@@ -323,7 +440,9 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 					vlog(cfg.verbose, "[%s] baseline team found: %d policies, %d queries",
 						proposedTeam.Name, len(baseTeam.Policies), len(baseTeam.Queries))
 					baseDiff := DiffResult{}
-					baseDiff.Policies = diffPolicies(currentTeam.Policies, baseTeam.Policies)
+					if !currentTeam.PoliciesUnavailable {
+						baseDiff.Policies = diffPolicies(currentTeam.Policies, baseTeam.Policies)
+					}
 					baseDiff.Queries = diffQueries(currentTeam.Queries, baseTeam.Queries)
 					if !currentTeam.SoftwareUnavailable {
 						baseDiff.Software = diffSoftware(enrichedSoftware, baseTeam.Software)
@@ -354,6 +473,16 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 			}
 		}
 
+		result.Errors = append(result.Errors, validateFleetApps(proposedTeam.Software.FleetMaintained,
+			currentTeam.Software.FleetMaintained, current.FleetMaintainedCatalog)...)
+		result.Errors = append(result.Errors, detectProfileConflicts(proposedTeam.Profiles, changedFiles)...)
+		result.Errors = append(result.Errors, versionWarnings(current.ServerVersion, gitopsUses{
+			policies: proposedTeam.Policies,
+			labels:   proposedTeam.Labels,
+			software: proposedTeam.Software,
+			noTeam:   teamName == parser.NoTeamName,
+		})...)
+
 		if len(changedFiles) > 0 {
 			vlog(cfg.verbose, "[%s] before changedFiles filter: policies=%s queries=%s software=%s",
 				proposedTeam.Name, rdSummary(result.Policies), rdSummary(result.Queries),
@@ -369,7 +498,17 @@ func Diff(current *api.FleetState, proposed *parser.ParsedRepo, teamFilters []st
 				rdSummary(result.Software))
 		}
 
-		result.Labels = validateLabels(proposedTeam, labelMap, changedNames(result.Policies))
+		result.Errors = append(result.Errors, refs.checkTeam(proposedTeam)...)
+		teamSources := teamSQLSources(proposedTeam.Policies, proposedTeam.Queries)
+		teamSources = append(teamSources, labelSQLSources(proposedTeam.Labels)...)
+		result.Errors = append(result.Errors, validateSQL(teamSources, changedFiles, &result.Policies, &result.Queries)...)
+		scoreQueries(&result.Queries, proposedTeam.Queries, cfg.queryCost)
+		if result.notFetched(api.ResourceLabels) {
+			result.Errors = append(result.Errors, notFetchedError("label checks"))
+		} else {
+			result.Labels = validateLabels(teamLabelUses(proposedTeam), refs, labelMap, proposedTeam.Labels)
+			addLabelMembership(cfg.ctx, &result, proposedTeam.Labels, labelMap, cfg.hosts, changedFiles)
+		}
 		results = append(results, result)
 	}
 
@@ -418,6 +557,10 @@ func buildSourceMap(team parser.ParsedTeam) map[string][]string {
 			add(name, sf)
 		}
 	}
+	for _, a := range team.Software.AppStoreApps {
+		add(appStoreName(a.AppStoreID, a.Platform), a.SourceFile)
+		add(appStoreName(a.AppStoreID, a.Platform), team.SourceFile)
+	}
 	for _, p := range team.Profiles {
 		add(p.Name, p.SourceFile)
 		add(p.Name, team.SourceFile)
@@ -437,10 +580,8 @@ func filterResourceDiff(rd ResourceDiff, sourceNames map[string][]string, change
 			return true
 		}
 		for _, src := range srcs {
-			for _, cf := range changedFiles {
-				if src == cf || strings.HasSuffix(src, "/"+cf) {
-					return true
-				}
+			if isChangedFile(src, changedFiles) {
+				return true
 			}
 		}
 		return false
@@ -452,6 +593,20 @@ func filterResourceDiff(rd ResourceDiff, sourceNames map[string][]string, change
 	}
 }
 
+// isChangedFile reports whether src (an absolute or repo-relative path)
+// matches any repo-relative path in changedFiles.
+func isChangedFile(src string, changedFiles []string) bool {
+	if src == "" {
+		return false
+	}
+	for _, cf := range changedFiles {
+		if src == cf || strings.HasSuffix(src, "/"+cf) {
+			return true
+		}
+	}
+	return false
+}
+
 func filterChanges(changes []ResourceChange, keep func(string) bool) []ResourceChange {
 	var out []ResourceChange
 	for _, c := range changes {
@@ -714,14 +869,7 @@ func diffSoftware(current api.TeamSoftware, proposed parser.ParsedSoftware) Reso
 
 	proposedPkgs := make(map[string]parser.ParsedSoftwarePackage)
 	for _, p := range proposed.Packages {
-		key := parser.NormalizeSoftwarePath(p.RefPath)
-		if key == "" {
-			key = inferSoftwarePathFromSource(p.SourceFile)
-		}
-		if key == "" {
-			key = parser.NormalizeSoftwarePath(p.URL)
-		}
-		if key != "" {
+		if key := packageKey(p); key != "" {
 			proposedPkgs[key] = p
 		}
 	}
@@ -827,56 +975,26 @@ func diffSoftware(current api.TeamSoftware, proposed parser.ParsedSoftware) Reso
 		}
 	}
 
-	// -------- App Store apps (keyed by app_store_id) --------
-	currentApps := make(map[string]api.TeamAppStoreApp)
-	for _, a := range current.AppStoreApps {
-		id := strings.TrimSpace(a.AppStoreID)
-		if id != "" {
-			currentApps[id] = a
-		}
-	}
-	proposedApps := make(map[string]parser.ParsedAppStoreApp)
-	for _, a := range proposed.AppStoreApps {
-		id := strings.TrimSpace(a.AppStoreID)
-		if id != "" {
-			proposedApps[id] = a
-		}
-	}
-
-	for id, a := range proposedApps {
-		cur, exists := currentApps[id]
-		if !exists {
-			rd.Added = append(rd.Added, ResourceChange{
-				Name: "app store app " + id,
-				Fields: map[string]FieldDiff{
-					"app_store_id": {New: a.AppStoreID},
-					"self_service": {New: fmt.Sprint(a.SelfService)},
-				},
-			})
-			continue
-		}
-		if cur.SelfService != a.SelfService {
-			rd.Modified = append(rd.Modified, ResourceChange{
-				Name: "app store app " + id,
-				Fields: map[string]FieldDiff{
-					"self_service": {
-						Old: fmt.Sprint(cur.SelfService),
-						New: fmt.Sprint(a.SelfService),
-					},
-				},
-			})
-		}
-	}
-	for id := range currentApps {
-		if _, exists := proposedApps[id]; !exists {
-			rd.Deleted = append(rd.Deleted, ResourceChange{Name: "app store app " + id})
-		}
-	}
+	// -------- App Store apps (keyed by app_store_id + platform) --------
+	diffAppStoreApps(current.AppStoreApps, proposed.AppStoreApps, &rd)
 
 	sortResourceChanges(&rd)
 	return rd
 }
 
+// packageKey is the name a proposed software package is diffed under: its
+// referenced path, else its path under software/, else its URL.
+func packageKey(p parser.ParsedSoftwarePackage) string {
+	key := parser.NormalizeSoftwarePath(p.RefPath)
+	if key == "" {
+		key = inferSoftwarePathFromSource(p.SourceFile)
+	}
+	if key == "" {
+		key = parser.NormalizeSoftwarePath(p.URL)
+	}
+	return key
+}
+
 func inferSoftwarePathFromSource(source string) string {
 	source = strings.ReplaceAll(source, "\\", "/")
 	if idx := strings.Index(source, "/software/"); idx >= 0 {
@@ -1106,7 +1224,8 @@ func diffProfiles(current []api.Profile, proposed []parser.ParsedProfile, change
 			warnings = append(warnings, fmt.Sprintf("duplicate profile name %q derived from %q (conflicts with another profile)", name, p.Path))
 		}
 		proposedNames[name] = true
-		if _, exists := currentMap[name]; !exists {
+		cur, exists := currentMap[name]
+		if !exists {
 			fields := map[string]FieldDiff{
 				"platform": {New: p.Platform},
 			}
@@ -1117,10 +1236,19 @@ func diffProfiles(current []api.Profile, proposed []parser.ParsedProfile, change
 				Name:   name,
 				Fields: fields,
 			})
+		} else if fields, ok := diffCSPItems(cur, p); ok {
+			// Windows profile content is downloaded from Fleet, so compare
+			// at the CSP node level instead of guessing from changed files.
+			if len(fields) > 0 {
+				diff.Modified = append(diff.Modified, ResourceChange{
+					Name:   name,
+					Fields: fields,
+				})
+			}
 		} else if changedSet[p.Path] {
-			// The Fleet API does not return profile content, so we cannot
-			// compare payloads. When the profile XML file appears in the
-			// git changed-files list, treat it as modified.
+			// Content is unavailable (Apple profiles, or the download
+			// failed), so we cannot compare payloads. When the profile file
+			// appears in the git changed-files list, treat it as modified.
 			diff.Modified = append(diff.Modified, ResourceChange{
 				Name: name,
 				Fields: map[string]FieldDiff{
@@ -1141,6 +1269,61 @@ func diffProfiles(current []api.Profile, proposed []parser.ParsedProfile, change
 	return diff, warnings
 }
 
+// diffCSPItems compares a Windows profile's CSP items (from Fleet content)
+// against the proposed file, keyed by LocURI. Each added, removed, or changed
+// node becomes a field named after its LocURI; a changed verb (Add, Replace or
+// Delete) is its own "<LocURI> verb" field, since it changes what the device
+// does even when the data is the same. Returns ok=false when either
+// side's content is unavailable, so the caller can fall back to changed-file
+// detection.
+func diffCSPItems(cur api.Profile, proposed parser.ParsedProfile) (map[string]FieldDiff, bool) {
+	if cur.Content == "" || len(proposed.CSPItems) == 0 {
+		return nil, false
+	}
+	curItems, err := parser.ParseSyncML([]byte(cur.Content))
+	if err != nil || len(curItems) == 0 {
+		return nil, false
+	}
+
+	index := func(items []parser.CSPItem) map[string]parser.CSPItem {
+		m := make(map[string]parser.CSPItem, len(items))
+		for _, item := range items {
+			m[strings.ToLower(item.LocURI)] = item
+		}
+		return m
+	}
+	curIdx, newIdx := index(curItems), index(proposed.CSPItems)
+
+	fields := make(map[string]FieldDiff)
+	for key, item := range newIdx {
+		old, exists := curIdx[key]
+		if !exists {
+			val := item.Data
+			switch {
+			case item.Verb == "Delete":
+				val = "(deleted)"
+			case val == "":
+				val = "(added)"
+			}
+			fields[item.LocURI] = FieldDiff{New: val}
+			continue
+		}
+		if old.Verb != item.Verb {
+			fields[item.LocURI+" verb"] = FieldDiff{Old: old.Verb, New: item.Verb}
+		}
+		// A Delete carries no data, so only its verb is worth reporting.
+		if item.Verb != "Delete" && normalizeWS(old.Data) != normalizeWS(item.Data) {
+			fields[item.LocURI] = FieldDiff{Old: old.Data, New: item.Data}
+		}
+	}
+	for key, old := range curIdx {
+		if _, exists := newIdx[key]; !exists {
+			fields[old.LocURI] = FieldDiff{Old: old.Data, New: "(removed)"}
+		}
+	}
+	return fields, true
+}
+
 // diffScripts compares current scripts (from API) against proposed scripts
 // (from YAML). Scripts are matched by filename. Content is compared when both
 // sides have non-empty content.
@@ -1183,71 +1366,21 @@ func diffScripts(current []api.Script, proposed []parser.ParsedScript) ResourceD
 	return diff
 }
 
-// ---------- Label validation ----------
-
-// changedNames collects all resource names from a ResourceDiff (added + modified + deleted).
-func changedNames(d ResourceDiff) map[string]bool {
-	names := make(map[string]bool, d.Total())
-	for _, c := range d.Added {
-		names[c.Name] = true
-	}
-	for _, c := range d.Modified {
-		names[c.Name] = true
-	}
-	for _, c := range d.Deleted {
-		names[c.Name] = true
-	}
-	return names
-}
-
-// validateLabels checks labels referenced by changed policies only.
-// If no policies changed, the label section is empty.
-func validateLabels(team parser.ParsedTeam, labelMap map[string]api.Label, changed map[string]bool) LabelValidation {
-	var validation LabelValidation
-	seen := make(map[string]bool)
-
-	checkLabel := func(name, referencedBy string) {
-		if seen[name] {
-			return
-		}
-		seen[name] = true
-		if l, ok := labelMap[name]; ok {
-			validation.Valid = append(validation.Valid, LabelRef{
-				Name:         name,
-				HostCount:    l.HostCount,
-				ReferencedBy: referencedBy,
-			})
-		} else {
-			validation.Missing = append(validation.Missing, LabelRef{
-				Name:         name,
-				ReferencedBy: referencedBy,
-			})
-		}
-	}
-
-	for _, p := range team.Policies {
-		if !changed[p.Name] {
-			continue
-		}
-		for _, name := range p.LabelsIncludeAny {
-			checkLabel(name, p.Name)
-		}
-		for _, name := range p.LabelsExcludeAny {
-			checkLabel(name, p.Name)
-		}
-	}
-
-	return validation
-}
-
 // ---------- Global config diffing ----------
 
 // diffConfig compares the current Fleet config (from API) against proposed
-// global config sections from default.yml. Returns a list of config changes.
+// global config sections from default.yml, using the Fleet defaults model
+// (fleet_defaults.json) for keys one side doesn't set:
+//   - YAML key absent from the API: an addition, unless YAML restates the default.
+//   - Known key set in Fleet but absent from YAML: a reset to the default.
+//   - YAML key unknown to the model and absent from the API: a typo warning.
+//
+// Lists set on both sides are diffed per element (see diffConfigList).
 // Skips values containing "$" (env var placeholders that Fleet substitutes).
-func diffConfig(apiConfig map[string]any, proposed *parser.ParsedGlobal) ([]ConfigChange, []string) {
+// Returns the changes, sections absent from the API, and warnings.
+func diffConfig(apiConfig map[string]any, proposed *parser.ParsedGlobal) ([]ConfigChange, []string, []string) {
 	var changes []ConfigChange
-	var skipped []string
+	var skipped, warnings []string
 
 	sections := map[string]map[string]any{
 		"org_settings":  proposed.OrgSettings,
@@ -1255,7 +1388,8 @@ func diffConfig(apiConfig map[string]any, proposed *parser.ParsedGlobal) ([]Conf
 		"controls":      proposed.Controls,
 	}
 
-	for section, proposedMap := range sections {
+	for _, section := range []string{"org_settings", "agent_options", "controls"} {
+		proposedMap := sections[section]
 		if proposedMap == nil {
 			continue
 		}
@@ -1283,39 +1417,133 @@ func diffConfig(apiConfig map[string]any, proposed *parser.ParsedGlobal) ([]Conf
 			continue
 		}
 
-		// Recursive key-by-key comparison.
-		// Only report a diff when the API actually has a value for this key.
-		// If getNestedValue returns "" the API doesn't expose the field (e.g.
-		// agent_options sub-keys, sso_settings) and we can't determine whether
-		// the proposed value differs from what Fleet already has.
+		unknown := make(map[string]bool)
 		flattenMap(proposedMap, "", func(key, proposedVal string) {
-			if containsEnvVar(proposedVal) {
-				return
-			}
 			if proposedVal == "<nil>" || proposedVal == "" {
 				return
 			}
 			apiVal := getNestedValue(apiSection, key)
-			if apiVal == "<nil>" || apiVal == "" {
+			if apiVal != "<nil>" && apiVal != "" {
+				if configValuesEqual(apiVal, proposedVal) {
+					return
+				}
+				if apiVal == maskedConfigValue && lookupDefault(section, key).kind == defaultSkip {
+					return // Fleet never returns the real secret
+				}
+				// Lists are diffed per element; env var placeholders are
+				// skipped inside the elements.
+				apiList, apiIsList := decodeConfigList(apiVal)
+				proposedList, proposedIsList := decodeConfigList(proposedVal)
+				if apiIsList && proposedIsList {
+					changes = append(changes, diffConfigList(section, key, apiList, proposedList)...)
+					return
+				}
+				if !containsEnvVar(proposedVal) {
+					changes = append(changes, ConfigChange{
+						Section: section,
+						Key:     key,
+						Old:     apiVal,
+						New:     proposedVal,
+					})
+				}
 				return
 			}
-			compareAPI, compareProposed := apiVal, proposedVal
-			if looksLikeJSON(apiVal) && looksLikeJSON(proposedVal) {
-				compareAPI = normalizeJSON(apiVal)
-				compareProposed = normalizeJSON(proposedVal)
+			if containsEnvVar(proposedVal) {
+				return
 			}
-			if compareAPI != compareProposed {
-				changes = append(changes, ConfigChange{
-					Section: section,
-					Key:     key,
-					Old:     apiVal,
-					New:     proposedVal,
-				})
+
+			def := lookupDefault(section, key)
+			switch def.kind {
+			case defaultSkip:
+				return
+			case defaultLeaf:
+				if configValuesEqual(def.value, proposedVal) {
+					return
+				}
+			case defaultUnknown:
+				if msg, path := unknownKeyWarning(section, key, def); !unknown[path] {
+					unknown[path] = true
+					warnings = append(warnings, msg)
+				}
+				return
 			}
+			changes = append(changes, ConfigChange{Section: section, Key: key, New: proposedVal})
 		})
+
+		changes = append(changes, configResets(section, apiSection, proposedMap)...)
 	}
 
-	return changes, skipped
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	sort.Strings(warnings)
+	return changes, skipped, warnings
+}
+
+// configResets reports keys Fleet has set that the YAML section omits.
+// Known keys reset to their default; keys below a "<replace>" subtree that
+// YAML defines are removed.
+func configResets(section string, apiSection, proposedMap map[string]any) []ConfigChange {
+	var resets []ConfigChange
+	for key, def := range defaultLeaves(section) {
+		if hasNestedKey(proposedMap, key) {
+			continue
+		}
+		apiVal := getNestedValue(apiSection, key)
+		if apiVal == "<nil>" || apiVal == "" || configValuesEqual(apiVal, def) {
+			continue
+		}
+		resets = append(resets, ConfigChange{Section: section, Key: key, Old: apiVal, New: def, Reset: true})
+	}
+
+	for _, root := range replaceRoots(section) {
+		if !hasNestedKey(proposedMap, root) {
+			continue
+		}
+		apiRoot, ok := getNestedMap(apiSection, root)
+		if !ok {
+			continue
+		}
+		flattenMap(apiRoot, root, func(key, apiVal string) {
+			if apiVal == "<nil>" || apiVal == "" || hasNestedKey(proposedMap, key) {
+				return
+			}
+			resets = append(resets, ConfigChange{Section: section, Key: key, Old: apiVal, Reset: true})
+		})
+	}
+	return resets
+}
+
+// unknownKeyWarning formats a probable-typo warning for a key the defaults
+// model doesn't know, suggesting siblings with a similar name. Also returns
+// the unknown path so keys nested under the same typo are reported once.
+func unknownKeyWarning(section, key string, def defaultEntry) (msg, path string) {
+	rest := key
+	if def.parent != "" {
+		rest = strings.TrimPrefix(key, def.parent+".")
+	}
+	name, _, _ := strings.Cut(rest, ".")
+	path = name
+	if def.parent != "" {
+		path = def.parent + "." + name
+	}
+	msg = fmt.Sprintf("%s.%s: unknown key (probable typo", section, path)
+	if suggestions := closestMatches(name, def.known); len(suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %s?", quoteJoin(suggestions))
+	}
+	return msg + ")", path
+}
+
+// configValuesEqual compares two flattened config values, ignoring JSON key
+// order and empty JSON members.
+func configValuesEqual(a, b string) bool {
+	if looksLikeJSON(a) && looksLikeJSON(b) {
+		return normalizeJSON(a) == normalizeJSON(b)
+	}
+	return a == b
 }
 
 // containsEnvVar returns true if the string contains a $ (env var placeholder).
@@ -1441,6 +1669,37 @@ func getNestedValue(m map[string]any, key string) string {
 	return ""
 }
 
+// hasNestedKey reports whether a dot-separated key is set in m. A scalar or
+// null at an intermediate path counts as set: YAML replaced the subtree.
+func hasNestedKey(m map[string]any, key string) bool {
+	current := m
+	for _, part := range strings.Split(key, ".") {
+		v, ok := current[part]
+		if !ok {
+			return false
+		}
+		next, ok := v.(map[string]any)
+		if !ok {
+			return true
+		}
+		current = next
+	}
+	return true
+}
+
+// getNestedMap retrieves a nested map from m using a dot-separated key.
+func getNestedMap(m map[string]any, key string) (map[string]any, bool) {
+	current := m
+	for _, part := range strings.Split(key, ".") {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
 // ---------- Helpers ----------
 
 // scriptDiffSummary returns a human-readable summary of what changed between
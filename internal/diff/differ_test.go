@@ -170,15 +170,15 @@ func TestDiffTestdataAgainstMockAPI(t *testing.T) {
 		t.Errorf("Workstations: expected 0 modified scripts, got %d", len(ws.Scripts.Modified))
 	}
 
-	// Labels: macOS 14+ valid, Ubuntu 24.04 missing
+	// Labels: macOS 14+ valid, Ubuntu 24.04 declared in labels/ but not in
+	// the mock API, so pending
 	if len(ws.Labels.Valid) == 0 {
 		t.Error("expected at least 1 valid label reference")
 	}
-	if len(ws.Labels.Missing) != 1 {
-		t.Errorf("expected 1 missing label, got %d", len(ws.Labels.Missing))
-	} else if ws.Labels.Missing[0].Name != "Ubuntu 24.04" {
-		t.Errorf("missing label: got %q", ws.Labels.Missing[0].Name)
+	if len(ws.Labels.Missing) != 0 {
+		t.Errorf("expected no missing labels, got %v", ws.Labels.Missing)
 	}
+	assertPendingLabel(t, ws.Labels, "Ubuntu 24.04")
 
 	// --- Servers ---
 	srv := findTeam(t, allResults, "Servers")
@@ -187,12 +187,11 @@ func TestDiffTestdataAgainstMockAPI(t *testing.T) {
 	if len(srv.Policies.Added) != 1 {
 		t.Errorf("Servers: expected 1 added policy, got %d", len(srv.Policies.Added))
 	}
-	// SSH Root Login references Ubuntu 24.04 which is not in mock API → missing
-	if len(srv.Labels.Missing) != 1 {
-		t.Errorf("Servers: expected 1 missing label, got %d", len(srv.Labels.Missing))
-	} else if srv.Labels.Missing[0].Name != "Ubuntu 24.04" {
-		t.Errorf("Servers: missing label: got %q", srv.Labels.Missing[0].Name)
+	// SSH Root Login references Ubuntu 24.04, created by this plan → pending
+	if len(srv.Labels.Missing) != 0 {
+		t.Errorf("Servers: expected no missing labels, got %v", srv.Labels.Missing)
 	}
+	assertPendingLabel(t, srv.Labels, "Ubuntu 24.04")
 	// OS Version is new → added, Uptime modified (interval 3600→86400)
 	if len(srv.Queries.Added) != 1 {
 		t.Errorf("Servers: expected 1 added query, got %d", len(srv.Queries.Added))
@@ -431,9 +430,8 @@ func TestDiffLabelValidation(t *testing.T) {
 	}
 }
 
-func TestDiffLabelValidationSkipsUnchangedPolicies(t *testing.T) {
-	// Policy exists in both current and proposed with identical config.
-	// Its labels should NOT appear in the label validation output.
+func TestDiffLabelValidationCoversUnchangedResources(t *testing.T) {
+	// Nothing changes, but every label reference is still validated.
 	current := &api.FleetState{
 		Teams: []api.Team{{
 			ID:       1,
@@ -449,7 +447,7 @@ func TestDiffLabelValidationSkipsUnchangedPolicies(t *testing.T) {
 				Name:             "Unchanged",
 				Query:            "SELECT 1;",
 				Platform:         "darwin",
-				LabelsIncludeAny: []string{"Some Label"},
+				LabelsIncludeAny: []string{"Some Label", "Ghost"},
 			}},
 		}},
 	}
@@ -457,11 +455,11 @@ func TestDiffLabelValidationSkipsUnchangedPolicies(t *testing.T) {
 	results := Diff(current, proposed, nil, nil)
 	r := results[0]
 
-	if len(r.Labels.Valid) != 0 {
-		t.Errorf("unchanged policy labels should not appear: got %d valid", len(r.Labels.Valid))
+	if len(r.Labels.Valid) != 1 || r.Labels.Valid[0].HostCount != 10 {
+		t.Errorf("valid labels = %+v", r.Labels.Valid)
 	}
-	if len(r.Labels.Missing) != 0 {
-		t.Errorf("unchanged policy labels should not appear: got %d missing", len(r.Labels.Missing))
+	if len(r.Labels.Missing) != 1 || r.Labels.Missing[0].ReferencedBy != "policy: Unchanged" {
+		t.Errorf("missing labels = %+v", r.Labels.Missing)
 	}
 }
 
@@ -1245,6 +1243,134 @@ func TestDiffProfilesDetectsRealChanges(t *testing.T) {
 	}
 }
 
+// TestDiffProfilesCSPItems verifies LocURI-level diffs for Windows profiles
+// when Fleet returned the current profile content.
+func TestDiffProfilesCSPItems(t *testing.T) {
+	const (
+		minLen   = "./Device/Vendor/MSFT/Policy/Config/DeviceLock/MinDevicePasswordLength"
+		timeout  = "./Device/Vendor/MSFT/Policy/Config/DeviceLock/MaxInactivityTimeDeviceLock"
+		realtime = "./Device/Vendor/MSFT/Policy/Config/Defender/AllowRealtimeMonitoring"
+	)
+	current := []api.Profile{{
+		Name:     "lock",
+		Platform: "windows",
+		Content: `<Replace><Item><Target><LocURI>` + minLen + `</LocURI></Target><Data>8</Data></Item></Replace>
+<Replace><Item><Target><LocURI>` + timeout + `</LocURI></Target><Data>5</Data></Item></Replace>`,
+	}}
+
+	tests := []struct {
+		name       string
+		items      []parser.CSPItem
+		changed    []string
+		wantFields map[string]FieldDiff
+	}{
+		{
+			name:  "node changed, added and removed",
+			items: []parser.CSPItem{{Verb: "Replace", LocURI: minLen, Data: "12"}, {Verb: "Replace", LocURI: realtime, Data: "1"}},
+			wantFields: map[string]FieldDiff{
+				minLen:   {Old: "8", New: "12"},
+				realtime: {New: "1"},
+				timeout:  {Old: "5", New: "(removed)"},
+			},
+		},
+		{
+			name:    "identical content is unchanged even when file changed",
+			items:   []parser.CSPItem{{Verb: "Replace", LocURI: minLen, Data: "8"}, {Verb: "Replace", LocURI: timeout, Data: " 5 "}},
+			changed: []string{"/repo/profiles/lock.xml"},
+		},
+		{
+			name:  "verb change with the same data",
+			items: []parser.CSPItem{{Verb: "Add", LocURI: minLen, Data: "8"}, {Verb: "Replace", LocURI: timeout, Data: "5"}},
+			wantFields: map[string]FieldDiff{
+				minLen + " verb": {Old: "Replace", New: "Add"},
+			},
+		},
+		{
+			name:  "node switched to delete",
+			items: []parser.CSPItem{{Verb: "Replace", LocURI: minLen, Data: "8"}, {Verb: "Delete", LocURI: timeout}},
+			wantFields: map[string]FieldDiff{
+				timeout + " verb": {Old: "Replace", New: "Delete"},
+			},
+		},
+		{
+			name:  "new delete node",
+			items: []parser.CSPItem{{Verb: "Replace", LocURI: minLen, Data: "8"}, {Verb: "Replace", LocURI: timeout, Data: "5"}, {Verb: "Delete", LocURI: realtime}},
+			wantFields: map[string]FieldDiff{
+				realtime: {New: "(deleted)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed := []parser.ParsedProfile{{Path: "/repo/profiles/lock.xml", Name: "lock", Platform: "windows", CSPItems: tt.items}}
+			rd, _ := diffProfiles(current, proposed, tt.changed)
+			if len(tt.wantFields) == 0 {
+				if !rd.IsEmpty() {
+					t.Fatalf("expected no changes, got %+v", rd)
+				}
+				return
+			}
+			if len(rd.Modified) != 1 {
+				t.Fatalf("expected 1 modified profile, got %+v", rd)
+			}
+			got := rd.Modified[0].Fields
+			if len(got) != len(tt.wantFields) {
+				t.Errorf("fields: got %v, want %v", got, tt.wantFields)
+			}
+			for k, want := range tt.wantFields {
+				if got[k] != want {
+					t.Errorf("field %s: got %+v, want %+v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffNoTeam(t *testing.T) {
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name:     parser.NoTeamName,
+		Policies: []parser.ParsedPolicy{{Name: "Onboarding complete", Query: "SELECT 1;"}},
+		Scripts:  []parser.ParsedScript{{Name: "enroll.sh", Content: "echo hi"}},
+	}}}
+
+	t.Run("full diff against team 0 state", func(t *testing.T) {
+		current := &api.FleetState{NoTeam: &api.Team{
+			Name:     parser.NoTeamName,
+			Policies: []api.Policy{{Name: "Legacy check", Query: "SELECT 0;", FailingHostCount: 12}},
+		}}
+		r := Diff(current, proposed, nil, nil)[0]
+		if len(r.Policies.Added) != 1 || len(r.Policies.Deleted) != 1 {
+			t.Errorf("policies: got %s", rdNames(r.Policies))
+		}
+		if len(r.Scripts.Added) != 1 {
+			t.Errorf("scripts: got %s", rdNames(r.Scripts))
+		}
+		for _, e := range r.Errors {
+			if strings.Contains(e, "no API diff available") || strings.Contains(e, "will be created") {
+				t.Errorf("unexpected error: %s", e)
+			}
+		}
+	})
+
+	t.Run("policies unsupported by server", func(t *testing.T) {
+		current := &api.FleetState{NoTeam: &api.Team{Name: parser.NoTeamName, PoliciesUnavailable: true}}
+		r := Diff(current, proposed, nil, nil)[0]
+		if !r.Policies.IsEmpty() {
+			t.Errorf("policies should be skipped, got %s", rdNames(r.Policies))
+		}
+		if len(r.Errors) != 1 || !strings.Contains(r.Errors[0], "policies diff skipped") {
+			t.Errorf("errors: got %v", r.Errors)
+		}
+	})
+
+	t.Run("no team-0 state falls back to counts", func(t *testing.T) {
+		r := Diff(&api.FleetState{}, proposed, nil, nil)[0]
+		if len(r.Errors) != 1 || !strings.Contains(r.Errors[0], "no API diff available") {
+			t.Errorf("errors: got %v", r.Errors)
+		}
+	})
+}
+
 // --- Global config diff tests ---
 
 func TestDiffGlobalConfig(t *testing.T) {
@@ -1256,14 +1382,67 @@ func TestDiffGlobalConfig(t *testing.T) {
 		wantKey          string // key to find in changes (empty = skip check)
 		wantKeyAbsent    string // key that must NOT appear in changes
 		wantOld, wantNew string
+		wantReset        bool
+		wantError        string // substring of the only expected error
 	}{
 		{
-			name:      "key absent from API is skipped",
+			name:      "key absent from API is an addition",
 			apiConfig: map[string]any{"org_info": map[string]any{"org_name": "Acme Corp"}},
 			proposedOrg: map[string]any{"org_info": map[string]any{
 				"org_name": "Acme Corp", "org_logo_url": "https://example.com/logo.png",
 			}},
-			wantChanges: 0, wantKeyAbsent: "org_info.org_logo_url",
+			wantChanges: 1, wantKey: "org_info.org_logo_url", wantNew: "https://example.com/logo.png",
+		},
+		{
+			name:        "key absent from API restating the default is not an addition",
+			apiConfig:   map[string]any{"features": map[string]any{}},
+			proposedOrg: map[string]any{"features": map[string]any{"enable_host_users": true}},
+			wantChanges: 0,
+		},
+		{
+			name: "key set in Fleet but absent from YAML resets to default",
+			apiConfig: map[string]any{
+				"org_info":        map[string]any{"org_name": "Acme Corp"},
+				"server_settings": map[string]any{"server_url": "https://fleet.example.com", "query_report_cap": float64(500)},
+			},
+			proposedOrg: map[string]any{
+				"org_info":        map[string]any{"org_name": "Acme Corp"},
+				"server_settings": map[string]any{"server_url": "https://fleet.example.com"},
+			},
+			wantChanges: 1, wantKey: "server_settings.query_report_cap", wantOld: "500", wantNew: "1000", wantReset: true,
+		},
+		{
+			name: "read-only and masked SMTP keys are never reset or modified",
+			apiConfig: map[string]any{"smtp_settings": map[string]any{
+				"enable_smtp": true, "configured": true, "server": "smtp.example.com", "password": "********",
+			}},
+			proposedOrg: map[string]any{"smtp_settings": map[string]any{
+				"enable_smtp": true, "server": "smtp.example.com", "password": "hunter2",
+			}},
+			wantChanges: 0, wantKeyAbsent: "smtp_settings.configured",
+		},
+		{
+			name:        "unknown key is a probable typo",
+			apiConfig:   map[string]any{"server_settings": map[string]any{"live_query_disabled": false}},
+			proposedOrg: map[string]any{"server_settings": map[string]any{"live_query_disable": true}},
+			wantChanges: 0,
+			wantError:   `org_settings.server_settings.live_query_disable: unknown key (probable typo, did you mean "live_query_disabled"?)`,
+		},
+		{
+			name: "list diffed per element",
+			apiConfig: map[string]any{"webhook_settings": map[string]any{
+				"failing_policies_webhook": map[string]any{"policy_ids": []any{float64(1), float64(2)}},
+			}},
+			proposedOrg: map[string]any{"webhook_settings": map[string]any{
+				"failing_policies_webhook": map[string]any{"policy_ids": []any{2, 3}},
+			}},
+			wantChanges: 2, wantKey: "webhook_settings.failing_policies_webhook.policy_ids[1]", wantNew: "3",
+		},
+		{
+			name:        "free-form subtree key is an addition, not a typo",
+			apiConfig:   map[string]any{"integrations": map[string]any{}},
+			proposedOrg: map[string]any{"integrations": map[string]any{"google_calendar": []any{map[string]any{"domain": "example.com"}}}},
+			wantChanges: 1, wantKey: "integrations.google_calendar",
 		},
 		{
 			name:      "value modified",
@@ -1314,12 +1493,21 @@ func TestDiffGlobalConfig(t *testing.T) {
 						if tt.wantNew != "" && c.New != tt.wantNew {
 							t.Errorf("new: got %q, want %q", c.New, tt.wantNew)
 						}
+						if c.Reset != tt.wantReset {
+							t.Errorf("reset: got %v, want %v", c.Reset, tt.wantReset)
+						}
 					}
 				}
 				if !found {
 					t.Errorf("expected key %q in config changes", tt.wantKey)
 				}
 			}
+			if tt.wantError == "" && len(global.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", global.Errors)
+			}
+			if tt.wantError != "" && (len(global.Errors) != 1 || !strings.Contains(global.Errors[0], tt.wantError)) {
+				t.Errorf("errors: got %v, want %q", global.Errors, tt.wantError)
+			}
 			if tt.wantKeyAbsent != "" {
 				for _, c := range global.Config {
 					if c.Key == tt.wantKeyAbsent {
@@ -1957,3 +2145,16 @@ func findTeam(t *testing.T, results []DiffResult, name string) *DiffResult {
 	t.Fatalf("team %q not found in results", name)
 	return nil
 }
+
+func assertPendingLabel(t *testing.T, lv LabelValidation, name string) {
+	t.Helper()
+	for _, l := range lv.Valid {
+		if l.Name == name {
+			if !l.Pending {
+				t.Errorf("label %q should be pending", name)
+			}
+			return
+		}
+	}
+	t.Errorf("label %q not in valid labels: %+v", name, lv.Valid)
+}
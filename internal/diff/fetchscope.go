@@ -0,0 +1,44 @@
+package diff
+
+import (
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// FetchScope returns the Fleet state Diff needs for proposed with these
+// team filters: only the teams it will diff; global config, policies and
+// queries only when it produces a global result; the fleet-maintained app
+// catalog only when a diffed team declares fleet-maintained apps; and
+// manual label members only when a diffed scope declares manual labels.
+// teamFilters and includeGlobal must match the ones passed to Diff.
+func FetchScope(proposed *parser.ParsedRepo, teamFilters []string, includeGlobal bool) api.FetchScope {
+	var scope api.FetchScope
+
+	if proposed.Global != nil && (len(teamFilters) == 0 || includeGlobal) {
+		scope.Global = true
+		scope.LabelHosts = hasManualLabels(proposed.Labels)
+	}
+
+	for _, t := range proposed.Teams {
+		if len(teamFilters) > 0 && !parser.MatchesAnyTeam(t.Name, teamFilters) {
+			continue
+		}
+		scope.Teams = append(scope.Teams, t.Name)
+		if len(t.Software.FleetMaintained) > 0 {
+			scope.Catalog = true
+		}
+		if hasManualLabels(t.Labels) {
+			scope.LabelHosts = true
+		}
+	}
+	return scope
+}
+
+func hasManualLabels(labels []parser.ParsedLabel) bool {
+	for _, l := range labels {
+		if l.LabelMembershipType == "manual" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestFetchScope(t *testing.T) {
+	manual := []parser.ParsedLabel{{Name: "Canaries", LabelMembershipType: "manual"}}
+	dynamic := []parser.ParsedLabel{{Name: "Macs", Query: "SELECT 1;"}}
+	repo := func(global bool, globalLabels []parser.ParsedLabel) *parser.ParsedRepo {
+		r := &parser.ParsedRepo{
+			Labels: globalLabels,
+			Teams: []parser.ParsedTeam{
+				{Name: "Workstations", Software: parser.ParsedSoftware{FleetMaintained: []parser.ParsedFleetApp{{Slug: "slack/darwin"}}}},
+				{Name: "Servers", Labels: manual},
+				{Name: parser.NoTeamName},
+			},
+		}
+		if global {
+			r.Global = &parser.ParsedGlobal{}
+		}
+		return r
+	}
+
+	tests := []struct {
+		name          string
+		proposed      *parser.ParsedRepo
+		teamFilters   []string
+		includeGlobal bool
+		want          api.FetchScope
+	}{
+		{
+			name:     "all teams with global",
+			proposed: repo(true, dynamic),
+			want: api.FetchScope{
+				Teams:  []string{"Workstations", "Servers", parser.NoTeamName},
+				Global: true, Catalog: true, LabelHosts: true,
+			},
+		},
+		{
+			name:        "team filter skips global, catalog and label hosts",
+			proposed:    repo(true, manual),
+			teamFilters: []string{"no team"},
+			want:        api.FetchScope{Teams: []string{parser.NoTeamName}},
+		},
+		{
+			name:          "team filter with global and manual global labels",
+			proposed:      repo(true, manual),
+			teamFilters:   []string{"Workstations"},
+			includeGlobal: true,
+			want:          api.FetchScope{Teams: []string{"Workstations"}, Global: true, Catalog: true, LabelHosts: true},
+		},
+		{
+			name:        "team with manual labels",
+			proposed:    repo(false, nil),
+			teamFilters: []string{"servers"},
+			want:        api.FetchScope{Teams: []string{"Servers"}, LabelHosts: true},
+		},
+		{
+			name:        "filter matches no team",
+			proposed:    repo(false, nil),
+			teamFilters: []string{"Kiosks"},
+			want:        api.FetchScope{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FetchScope(tt.proposed, tt.teamFilters, tt.includeGlobal)
+			if !slices.Equal(got.Teams, tt.want.Teams) {
+				t.Errorf("Teams = %v, want %v", got.Teams, tt.want.Teams)
+			}
+			if got.AllTeams || got.Global != tt.want.Global || got.Catalog != tt.want.Catalog || got.LabelHosts != tt.want.LabelHosts {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// HostScope is what decides which resources apply to a single host.
+type HostScope struct {
+	Team     string          // team name, parser.NoTeamName for unassigned hosts
+	Platform string          // Fleet host platform: darwin, windows, ubuntu, ios, ...
+	Labels   map[string]bool // current labels plus manual labels this plan adds it to
+}
+
+// NewHostScope builds a host's scope from its Fleet record. Manual labels in
+// the repo that list the host count as memberships, so changes scoped to a
+// label the plan is about to add it to are not filtered out.
+func NewHostScope(h api.Host, proposed *parser.ParsedRepo) HostScope {
+	hs := HostScope{
+		Team:     h.TeamName,
+		Platform: strings.ToLower(h.Platform),
+		Labels:   make(map[string]bool),
+	}
+	if h.TeamID == nil || hs.Team == "" {
+		hs.Team = parser.NoTeamName
+	}
+	for _, l := range h.Labels {
+		hs.Labels[l.Name] = true
+	}
+
+	manual := append([]parser.ParsedLabel(nil), proposed.Labels...)
+	for _, t := range proposed.Teams {
+		if t.Name == hs.Team {
+			manual = append(manual, t.Labels...)
+		}
+	}
+	for _, l := range manual {
+		if l.LabelMembershipType != "manual" {
+			continue
+		}
+		for _, id := range l.Hosts {
+			if h.Matches(strings.TrimSpace(id)) {
+				hs.Labels[l.Name] = true
+			}
+		}
+	}
+	return hs
+}
+
+// resourceScope is the platform and label targeting of one resource.
+type resourceScope struct {
+	platform   string // comma-separated Fleet platforms; empty applies to all
+	includeAll []string
+	includeAny []string
+	excludeAny []string
+}
+
+// appliesTo reports whether a resource with this scope targets the host.
+func (s resourceScope) appliesTo(hs HostScope) bool {
+	if !platformMatches(s.platform, hs.Platform) {
+		return false
+	}
+	for _, l := range s.includeAll {
+		if !hs.Labels[l] {
+			return false
+		}
+	}
+	if len(s.includeAny) > 0 && !hasAnyLabel(hs, s.includeAny) {
+		return false
+	}
+	return !hasAnyLabel(hs, s.excludeAny)
+}
+
+func hasAnyLabel(hs HostScope, labels []string) bool {
+	for _, l := range labels {
+		if hs.Labels[l] {
+			return true
+		}
+	}
+	return false
+}
+
+// platformMatches reports whether a host platform is in a comma-separated
+// platform list. Fleet reports Linux hosts by distribution (ubuntu, rhel,
+// ...) while resources target "linux".
+func platformMatches(platforms, host string) bool {
+	if strings.TrimSpace(platforms) == "" || host == "" {
+		return true
+	}
+	family := host
+	switch host {
+	case "darwin", "windows", "chrome", "ios", "ipados":
+	default:
+		family = "linux"
+	}
+	for _, p := range strings.Split(platforms, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == host || p == family {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeIndex maps ResourceChange names to the scopes a resource has before
+// and after apply. A change touches the host if either scope targets it.
+type scopeIndex map[string][]resourceScope
+
+func (ix scopeIndex) add(name string, s resourceScope) {
+	ix[name] = append(ix[name], s)
+}
+
+// keeps reports whether a change applies to the host. Resources whose scope
+// is unknown are kept.
+func (ix scopeIndex) keeps(hs HostScope) func(string) bool {
+	return func(name string) bool {
+		scopes, ok := ix[name]
+		if !ok {
+			return true
+		}
+		for _, s := range scopes {
+			if s.appliesTo(hs) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterForHost narrows diff results to the changes that reach one host:
+// the global scope and the host's team, with policies and queries filtered
+// by platform and labels, profiles by platform and label scope, and software
+// by labels (and platform for fleet-maintained and App Store apps). Config,
+// scripts and errors are team-wide and kept; manual label membership
+// changes are kept for labels the host is or will be a member of.
+func FilterForHost(results []DiffResult, hs HostScope, current *api.FleetState, proposed *parser.ParsedRepo) []DiffResult {
+	var out []DiffResult
+	for _, r := range results {
+		var policies, queries, software, profiles scopeIndex
+		switch {
+		case r.Team == "(global)":
+			policies, queries = globalScopes(current, proposed)
+		case r.Team == hs.Team:
+			policies, queries, software, profiles = teamScopes(current, proposed, hs.Team)
+		default:
+			continue
+		}
+
+		r.Policies = filterChangesBy(r.Policies, policies.keeps(hs))
+		r.Queries = filterChangesBy(r.Queries, queries.keeps(hs))
+		if software != nil {
+			r.Software = filterChangesBy(r.Software, software.keeps(hs))
+			r.Profiles = filterChangesBy(r.Profiles, profiles.keeps(hs))
+		}
+		r.LabelMembership = filterChangesBy(r.LabelMembership, func(name string) bool { return hs.Labels[name] })
+		out = append(out, r)
+	}
+	return out
+}
+
+func filterChangesBy(rd ResourceDiff, keep func(string) bool) ResourceDiff {
+	return ResourceDiff{
+		Added:    filterChanges(rd.Added, keep),
+		Modified: filterChanges(rd.Modified, keep),
+		Deleted:  filterChanges(rd.Deleted, keep),
+	}
+}
+
+// globalScopes indexes global policies and queries from Fleet and default.yml.
+func globalScopes(current *api.FleetState, proposed *parser.ParsedRepo) (policies, queries scopeIndex) {
+	policies, queries = make(scopeIndex), make(scopeIndex)
+	addPolicyScopes(policies, current.GlobalPolicies)
+	addQueryScopes(queries, current.GlobalQueries)
+	if proposed.Global != nil {
+		addParsedScopes(policies, queries, proposed.Global.Policies, proposed.Global.Queries)
+	}
+	return policies, queries
+}
+
+// teamScopes indexes a team's resources from Fleet and its team file.
+func teamScopes(current *api.FleetState, proposed *parser.ParsedRepo, team string) (policies, queries, software, profiles scopeIndex) {
+	policies, queries = make(scopeIndex), make(scopeIndex)
+	software, profiles = make(scopeIndex), make(scopeIndex)
+
+	cur, ok := findCurrentTeam(current, team)
+	if ok {
+		addPolicyScopes(policies, cur.Policies)
+		addQueryScopes(queries, cur.Queries)
+		for _, p := range cur.Profiles {
+			profiles.add(p.Name, resourceScope{platform: profilePlatforms(p.Platform)})
+		}
+		for _, a := range cur.Software.FleetMaintained {
+			slug := parser.NormalizeSoftwarePath(a.Slug)
+			software.add("fleet app "+slug, resourceScope{platform: slugPlatform(slug)})
+		}
+		for _, a := range cur.Software.AppStoreApps {
+			software.add(appStoreName(a.AppStoreID, a.Platform), resourceScope{
+				platform:   appStorePlatform(a.Platform),
+				includeAny: a.LabelsIncludeAny,
+				excludeAny: a.LabelsExcludeAny,
+			})
+		}
+	}
+
+	for _, t := range proposed.Teams {
+		if t.Name != team {
+			continue
+		}
+		addParsedScopes(policies, queries, t.Policies, t.Queries)
+		for _, p := range t.Profiles {
+			profiles.add(p.Name, resourceScope{
+				platform:   profilePlatforms(p.Platform),
+				includeAll: p.LabelsIncludeAll,
+				includeAny: p.LabelsIncludeAny,
+				excludeAny: p.LabelsExcludeAny,
+			})
+		}
+		for _, p := range t.Software.Packages {
+			software.add(packageKey(p), resourceScope{includeAny: p.LabelsIncludeAny, excludeAny: p.LabelsExcludeAny})
+		}
+		for _, a := range t.Software.FleetMaintained {
+			slug := parser.NormalizeSoftwarePath(a.Slug)
+			software.add("fleet app "+slug, resourceScope{
+				platform:   slugPlatform(slug),
+				includeAny: a.LabelsIncludeAny,
+				excludeAny: a.LabelsExcludeAny,
+			})
+		}
+		for _, a := range t.Software.AppStoreApps {
+			software.add(appStoreName(a.AppStoreID, a.Platform), resourceScope{
+				platform:   appStorePlatform(a.Platform),
+				includeAny: a.LabelsIncludeAny,
+				excludeAny: a.LabelsExcludeAny,
+			})
+		}
+	}
+	return policies, queries, software, profiles
+}
+
+func findCurrentTeam(current *api.FleetState, name string) (api.Team, bool) {
+	if name == parser.NoTeamName && current.NoTeam != nil {
+		return *current.NoTeam, true
+	}
+	for _, t := range current.Teams {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return api.Team{}, false
+}
+
+func addPolicyScopes(ix scopeIndex, policies []api.Policy) {
+	for _, p := range policies {
+		ix.add(p.Name, resourceScope{platform: p.Platform, includeAny: p.LabelsIncludeAny, excludeAny: p.LabelsExcludeAny})
+	}
+}
+
+func addQueryScopes(ix scopeIndex, queries []api.Query) {
+	for _, q := range queries {
+		ix.add(q.Name, resourceScope{platform: q.Platform})
+	}
+}
+
+func addParsedScopes(policies, queries scopeIndex, pp []parser.ParsedPolicy, qq []parser.ParsedQuery) {
+	for _, p := range pp {
+		policies.add(p.Name, resourceScope{platform: p.Platform, includeAny: p.LabelsIncludeAny, excludeAny: p.LabelsExcludeAny})
+	}
+	for _, q := range qq {
+		queries.add(q.Name, resourceScope{platform: q.Platform, includeAny: q.LabelsIncludeAny, excludeAny: q.LabelsExcludeAny})
+	}
+}
+
+// profilePlatforms widens Apple profiles to every Apple platform.
+func profilePlatforms(platform string) string {
+	if platform == "darwin" {
+		return "darwin,ios,ipados"
+	}
+	return platform
+}
+
+// slugPlatform returns the platform suffix of a fleet-maintained app slug
+// ("slack/darwin" -> "darwin").
+func slugPlatform(slug string) string {
+	if i := strings.LastIndex(slug, "/"); i >= 0 {
+		return slug[i+1:]
+	}
+	return ""
+}
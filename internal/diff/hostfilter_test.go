@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestPlatformMatches(t *testing.T) {
+	tests := []struct {
+		platforms, host string
+		want            bool
+	}{
+		{"", "darwin", true},
+		{"darwin", "darwin", true},
+		{"darwin,windows", "windows", true},
+		{"darwin", "windows", false},
+		{"linux", "ubuntu", true},
+		{"linux", "rhel", true},
+		{"darwin", "ios", false},
+		{"darwin,ios,ipados", "ipados", true},
+		{"chrome", "chrome", true},
+		{"windows", "", true},
+	}
+	for _, tt := range tests {
+		if got := platformMatches(tt.platforms, tt.host); got != tt.want {
+			t.Errorf("platformMatches(%q, %q) = %v, want %v", tt.platforms, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestResourceScopeAppliesTo(t *testing.T) {
+	hs := HostScope{Platform: "darwin", Labels: map[string]bool{"Engineering": true, "macOS 14+": true}}
+	tests := []struct {
+		name  string
+		scope resourceScope
+		want  bool
+	}{
+		{name: "unscoped", want: true},
+		{name: "platform mismatch", scope: resourceScope{platform: "windows"}, want: false},
+		{name: "include any hit", scope: resourceScope{includeAny: []string{"Sales", "Engineering"}}, want: true},
+		{name: "include any miss", scope: resourceScope{includeAny: []string{"Sales"}}, want: false},
+		{name: "include all", scope: resourceScope{includeAll: []string{"Engineering", "macOS 14+"}}, want: true},
+		{name: "include all partial", scope: resourceScope{includeAll: []string{"Engineering", "Sales"}}, want: false},
+		{name: "excluded", scope: resourceScope{excludeAny: []string{"Engineering"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.appliesTo(hs); got != tt.want {
+				t.Errorf("appliesTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHostScope(t *testing.T) {
+	teamID := uint(1)
+	host := api.Host{
+		Hostname: "kiosk-01", HardwareSerial: "C02ABC", Platform: "darwin",
+		TeamID: &teamID, TeamName: "Kiosks",
+		Labels: []api.HostLabel{{Name: "macOS"}},
+	}
+	proposed := &parser.ParsedRepo{
+		Labels: []parser.ParsedLabel{
+			{Name: "Front desk", LabelMembershipType: "manual", Hosts: []string{"c02abc"}},
+			{Name: "Back office", LabelMembershipType: "manual", Hosts: []string{"C02XYZ"}},
+		},
+		Teams: []parser.ParsedTeam{
+			{Name: "Kiosks", Labels: []parser.ParsedLabel{{Name: "Team kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-01"}}}},
+			{Name: "Other", Labels: []parser.ParsedLabel{{Name: "Other kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-01"}}}},
+		},
+	}
+
+	hs := NewHostScope(host, proposed)
+	if hs.Team != "Kiosks" || hs.Platform != "darwin" {
+		t.Errorf("scope = %+v", hs)
+	}
+	var labels []string
+	for l := range hs.Labels {
+		labels = append(labels, l)
+	}
+	slices.Sort(labels)
+	if want := []string{"Front desk", "Team kiosks", "macOS"}; !slices.Equal(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+
+	if hs := NewHostScope(api.Host{Platform: "Windows"}, &parser.ParsedRepo{}); hs.Team != parser.NoTeamName || hs.Platform != "windows" {
+		t.Errorf("unassigned host scope = %+v", hs)
+	}
+}
+
+func TestFilterForHost(t *testing.T) {
+	current := &api.FleetState{
+		GlobalPolicies: []api.Policy{{Name: "Old windows check", Platform: "windows"}},
+		Teams: []api.Team{{
+			Name:     "Workstations",
+			Policies: []api.Policy{{Name: "Rescoped", LabelsIncludeAny: []string{"Engineering"}}},
+			Profiles: []api.Profile{{Name: "Old Wi-Fi", Platform: "windows"}},
+		}},
+	}
+	proposed := &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{
+			Policies: []parser.ParsedPolicy{{Name: "Mac check", Platform: "darwin"}},
+			Queries:  []parser.ParsedQuery{{Name: "Linux query", Platform: "linux"}},
+		},
+		Teams: []parser.ParsedTeam{{
+			Name: "Workstations",
+			Policies: []parser.ParsedPolicy{
+				{Name: "Rescoped", LabelsIncludeAny: []string{"Sales"}},
+				{Name: "Not engineering", LabelsExcludeAny: []string{"Engineering"}},
+			},
+			Profiles: []parser.ParsedProfile{{Name: "Wi-Fi", Platform: "darwin", LabelsIncludeAll: []string{"Engineering"}}},
+			Software: parser.ParsedSoftware{
+				Packages:        []parser.ParsedSoftwarePackage{{RefPath: "software/zoom.yml", LabelsIncludeAny: []string{"Sales"}}},
+				FleetMaintained: []parser.ParsedFleetApp{{Slug: "slack/darwin"}, {Slug: "slack/windows"}},
+			},
+		}},
+	}
+	results := []DiffResult{
+		{
+			Team:     "(global)",
+			Config:   []ConfigChange{{Section: "org_settings", Key: "org_name", New: "Acme"}},
+			Policies: ResourceDiff{Added: []ResourceChange{{Name: "Mac check"}}, Deleted: []ResourceChange{{Name: "Old windows check"}}},
+			Queries:  ResourceDiff{Added: []ResourceChange{{Name: "Linux query"}}},
+		},
+		{
+			Team: "Workstations",
+			Policies: ResourceDiff{
+				Added:    []ResourceChange{{Name: "Not engineering"}},
+				Modified: []ResourceChange{{Name: "Rescoped"}},
+			},
+			Profiles: ResourceDiff{Added: []ResourceChange{{Name: "Wi-Fi"}}, Deleted: []ResourceChange{{Name: "Old Wi-Fi"}}},
+			Software: ResourceDiff{Added: []ResourceChange{
+				{Name: "software/zoom.yml"}, {Name: "fleet app slack/darwin"}, {Name: "fleet app slack/windows"},
+			}},
+			Scripts:         ResourceDiff{Added: []ResourceChange{{Name: "fix.sh"}}},
+			LabelMembership: ResourceDiff{Modified: []ResourceChange{{Name: "Kiosks"}, {Name: "Engineering"}}},
+		},
+		{Team: "Servers", Policies: ResourceDiff{Added: []ResourceChange{{Name: "Other"}}}},
+	}
+	hs := HostScope{Team: "Workstations", Platform: "darwin", Labels: map[string]bool{"Engineering": true}}
+
+	got := FilterForHost(results, hs, current, proposed)
+	if len(got) != 2 || got[0].Team != "(global)" || got[1].Team != "Workstations" {
+		t.Fatalf("results = %+v", got)
+	}
+	names := func(changes []ResourceChange) []string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+
+	global, team := got[0], got[1]
+	if len(global.Config) != 1 {
+		t.Errorf("config should be kept: %+v", global.Config)
+	}
+	checks := []struct {
+		field string
+		got   []string
+		want  []string
+	}{
+		{"global policies added", names(global.Policies.Added), []string{"Mac check"}},
+		{"global policies deleted", names(global.Policies.Deleted), nil},
+		{"global queries added", names(global.Queries.Added), nil},
+		{"team policies added", names(team.Policies.Added), nil},
+		{"team policies modified (scoped before apply)", names(team.Policies.Modified), []string{"Rescoped"}},
+		{"profiles added", names(team.Profiles.Added), []string{"Wi-Fi"}},
+		{"profiles deleted", names(team.Profiles.Deleted), nil},
+		{"software added", names(team.Software.Added), []string{"fleet app slack/darwin"}},
+		{"scripts added", names(team.Scripts.Added), []string{"fix.sh"}},
+		{"label membership", names(team.LabelMembership.Modified), []string{"Engineering"}},
+	}
+	for _, c := range checks {
+		if !slices.Equal(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// wideInstallHosts is the host count above which a script change on an
+// installed title is called out as a warning.
+const wideInstallHosts = 50
+
+// installFields are software fields whose change re-runs installation on
+// hosts that already have the title.
+var installFields = []string{"install_script", "post_install_script", "pre_install_query", "url", "hash_sha256"}
+
+// annotateSoftwareImpact sets HostCount on software changes from the team's
+// software titles, and warns about removals and install changes that affect
+// hosts with the title installed. Added software has no installs yet.
+func annotateSoftwareImpact(rd *ResourceDiff, current api.TeamSoftware, titles []api.SoftwareTitle) {
+	counts := softwareHostCounts(current, titles)
+	if len(counts) == 0 {
+		return
+	}
+
+	for i := range rd.Modified {
+		c := &rd.Modified[i]
+		c.HostCount = counts[c.Name]
+		if c.HostCount < wideInstallHosts || c.Warning != "" {
+			continue
+		}
+		for _, f := range installFields {
+			if _, ok := c.Fields[f]; ok {
+				c.Warning = fmt.Sprintf("%s changed for title installed on %d hosts", f, c.HostCount)
+				break
+			}
+		}
+	}
+	for i := range rd.Deleted {
+		c := &rd.Deleted[i]
+		c.HostCount = counts[c.Name]
+		if c.HostCount > 0 {
+			c.Warning = fmt.Sprintf("uninstall availability removed for title installed on %d hosts", c.HostCount)
+		}
+	}
+}
+
+// softwareHostCounts maps software change names (as produced by diffSoftware)
+// to the host count of the matching software title. Packages match on
+// installer URL, fleet-maintained apps on title ID, App Store apps on
+// app_store_id and platform.
+func softwareHostCounts(current api.TeamSoftware, titles []api.SoftwareTitle) map[string]uint {
+	byURL := make(map[string]uint)
+	byID := make(map[uint]uint)
+	byApp := make(map[string]uint)
+	for _, t := range titles {
+		byID[t.ID] = t.HostCount
+		if t.SoftwarePackage != nil {
+			if u := parser.NormalizeSoftwarePath(t.SoftwarePackage.PackageURL); u != "" {
+				byURL[u] = t.HostCount
+			}
+		}
+		if t.AppStoreApp != nil {
+			byApp[appStoreKey(t.AppStoreApp.AppStoreID, t.AppStoreApp.Platform)] = t.HostCount
+		}
+	}
+
+	counts := make(map[string]uint)
+	for _, p := range current.Packages {
+		key := parser.NormalizeSoftwarePath(p.ReferencedYAMLPath)
+		if key == "" {
+			key = parser.NormalizeSoftwarePath(p.URL)
+		}
+		if n, ok := byURL[parser.NormalizeSoftwarePath(p.URL)]; ok && key != "" {
+			counts[key] = n
+		}
+	}
+	for _, a := range current.FleetMaintained {
+		if n, ok := byID[a.TitleID]; ok && a.TitleID != 0 {
+			counts["fleet app "+parser.NormalizeSoftwarePath(a.Slug)] = n
+		}
+	}
+	for _, a := range current.AppStoreApps {
+		if n, ok := byApp[appStoreKey(a.AppStoreID, a.Platform)]; ok && strings.TrimSpace(a.AppStoreID) != "" {
+			counts[appStoreName(a.AppStoreID, a.Platform)] = n
+		}
+	}
+	return counts
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestAnnotateSoftwareImpact(t *testing.T) {
+	current := api.TeamSoftware{
+		Packages: []api.TeamSoftwarePackage{
+			{URL: "https://dl.example.com/tool.pkg", ReferencedYAMLPath: "software/mac/tool.yml"},
+		},
+		FleetMaintained: []api.TeamFleetApp{
+			{Slug: "zoom/darwin", TitleID: 7},
+			{Slug: "notion/darwin", TitleID: 8},
+		},
+		AppStoreApps: []api.TeamAppStoreApp{{AppStoreID: "111", Platform: "ios"}},
+	}
+	titles := []api.SoftwareTitle{
+		{ID: 5, HostCount: 40, SoftwarePackage: &api.SoftwareTitlePackageMeta{PackageURL: "https://dl.example.com/tool.pkg"}},
+		{ID: 7, HostCount: 900},
+		{ID: 8, HostCount: 10},
+		{ID: 9, HostCount: 75, AppStoreApp: &api.SoftwareTitleAppStore{AppStoreID: "111", Platform: "ios"}},
+	}
+
+	rd := ResourceDiff{
+		Added: []ResourceChange{{Name: "fleet app slack/darwin"}},
+		Modified: []ResourceChange{
+			{Name: "fleet app zoom/darwin", Fields: map[string]FieldDiff{"install_script": {New: "+2/-1"}}},
+			{Name: "fleet app notion/darwin", Fields: map[string]FieldDiff{"install_script": {New: "+1/-0"}}},
+			{Name: "app store app 111 (ios)", Fields: map[string]FieldDiff{"self_service": {Old: "false", New: "true"}}},
+		},
+		Deleted: []ResourceChange{{Name: "software/mac/tool.yml"}},
+	}
+	annotateSoftwareImpact(&rd, current, titles)
+
+	if rd.Added[0].HostCount != 0 {
+		t.Errorf("added software should have no host count, got %d", rd.Added[0].HostCount)
+	}
+
+	tests := []struct {
+		change      ResourceChange
+		wantHosts   uint
+		wantWarning string
+	}{
+		{rd.Modified[0], 900, "install_script changed for title installed on 900 hosts"},
+		{rd.Modified[1], 10, ""}, // below wideInstallHosts
+		{rd.Modified[2], 75, ""}, // no install field changed
+		{rd.Deleted[0], 40, "uninstall availability removed for title installed on 40 hosts"},
+	}
+	for _, tt := range tests {
+		if tt.change.HostCount != tt.wantHosts {
+			t.Errorf("%s: HostCount = %d, want %d", tt.change.Name, tt.change.HostCount, tt.wantHosts)
+		}
+		if tt.change.Warning != tt.wantWarning {
+			t.Errorf("%s: Warning = %q, want %q", tt.change.Name, tt.change.Warning, tt.wantWarning)
+		}
+	}
+}
+
+func TestDiffSoftwareRemovalWarnsWithHostCount(t *testing.T) {
+	current := &api.FleetState{Teams: []api.Team{{
+		ID:   1,
+		Name: "T",
+		Software: api.TeamSoftware{Packages: []api.TeamSoftwarePackage{
+			{URL: "https://dl.example.com/tool.pkg", ReferencedYAMLPath: "software/mac/tool.yml"},
+		}},
+		SoftwareTitles: []api.SoftwareTitle{
+			{ID: 5, HostCount: 312, SoftwarePackage: &api.SoftwareTitlePackageMeta{PackageURL: "https://dl.example.com/tool.pkg"}},
+		},
+	}}}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{Name: "T"}}}
+
+	r := Diff(current, proposed, nil, nil)[0]
+	if len(r.Software.Deleted) != 1 {
+		t.Fatalf("expected 1 deleted package, got %+v", r.Software.Deleted)
+	}
+	d := r.Software.Deleted[0]
+	if d.HostCount != 312 || !strings.Contains(d.Warning, "installed on 312 hosts") {
+		t.Errorf("deleted package: got HostCount=%d Warning=%q", d.HostCount, d.Warning)
+	}
+}
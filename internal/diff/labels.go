@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// labelUse is one resource's reference to a label, e.g. "policy: Disk
+// encryption" scoping to "macOS 14+".
+type labelUse struct {
+	label    string
+	resource string
+}
+
+// teamLabelUses lists every label reference in a team: policies, queries,
+// software packages, fleet-maintained and App Store apps, and profiles.
+func teamLabelUses(team parser.ParsedTeam) []labelUse {
+	var uses []labelUse
+	add := func(resource string, lists ...[]string) {
+		for _, l := range concatLabels(lists...) {
+			uses = append(uses, labelUse{label: l, resource: resource})
+		}
+	}
+
+	for _, p := range team.Policies {
+		add("policy: "+p.Name, p.LabelsIncludeAny, p.LabelsExcludeAny)
+	}
+	for _, q := range team.Queries {
+		add("query: "+q.Name, q.LabelsIncludeAny, q.LabelsExcludeAny)
+	}
+	for _, p := range team.Software.Packages {
+		add("software: "+p.RefPath, p.LabelsIncludeAny, p.LabelsExcludeAny)
+	}
+	for _, a := range team.Software.FleetMaintained {
+		add("software: "+a.Slug, a.LabelsIncludeAny, a.LabelsExcludeAny)
+	}
+	for _, a := range team.Software.AppStoreApps {
+		add("software: "+appStoreName(a.AppStoreID, a.Platform), a.LabelsIncludeAny, a.LabelsExcludeAny)
+	}
+	for _, p := range team.Profiles {
+		add("profile: "+p.Name, p.LabelsIncludeAll, p.LabelsIncludeAny, p.LabelsExcludeAny)
+	}
+	return uses
+}
+
+// globalLabelUses lists label references on global policies and queries.
+func globalLabelUses(global *parser.ParsedGlobal) []labelUse {
+	return teamLabelUses(parser.ParsedTeam{Policies: global.Policies, Queries: global.Queries})
+}
+
+// validateLabels resolves each referenced label against Fleet and the repo.
+// Labels in Fleet are valid with their host count; labels only declared in
+// YAML (default.yml or the team's labels key) are valid but pending until
+// apply. Labels in neither, or deleted by this plan, are missing, and
+// ReferencedBy lists every resource they break.
+func validateLabels(uses []labelUse, ix refIndex, labelMap map[string]api.Label, teamLabels []parser.ParsedLabel) LabelValidation {
+	var order []string
+	resources := make(map[string][]string)
+	for _, u := range uses {
+		if _, ok := resources[u.label]; !ok {
+			order = append(order, u.label)
+		}
+		resources[u.label] = append(resources[u.label], u.resource)
+	}
+
+	declared := make(map[string]bool, len(teamLabels))
+	for _, l := range teamLabels {
+		declared[l.Name] = true
+	}
+
+	var validation LabelValidation
+	for _, name := range order {
+		ref := LabelRef{Name: name, ReferencedBy: strings.Join(resources[name], ", ")}
+		l, inFleet := labelMap[name]
+		switch {
+		case ix.deleted[name]:
+			ref.Deleted = true
+			validation.Missing = append(validation.Missing, ref)
+		case inFleet:
+			ref.HostCount = l.HostCount
+			validation.Valid = append(validation.Valid, ref)
+		case ix.labels[name] || declared[name]:
+			ref.Pending = true
+			validation.Valid = append(validation.Valid, ref)
+		default:
+			validation.Missing = append(validation.Missing, ref)
+		}
+	}
+	return validation
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestTeamLabelUses(t *testing.T) {
+	team := parser.ParsedTeam{
+		Policies: []parser.ParsedPolicy{{Name: "P", LabelsIncludeAny: []string{"A"}, LabelsExcludeAny: []string{"A", "B"}}},
+		Queries:  []parser.ParsedQuery{{Name: "Q", LabelsExcludeAny: []string{"B"}}},
+		Software: parser.ParsedSoftware{
+			Packages:        []parser.ParsedSoftwarePackage{{RefPath: "software/zoom.yml", LabelsIncludeAny: []string{"C"}}},
+			FleetMaintained: []parser.ParsedFleetApp{{Slug: "slack/darwin", LabelsExcludeAny: []string{"C"}}},
+			AppStoreApps:    []parser.ParsedAppStoreApp{{AppStoreID: "111", Platform: "ios", LabelsIncludeAny: []string{"C"}}},
+		},
+		Profiles: []parser.ParsedProfile{{Name: "Wi-Fi", LabelsIncludeAll: []string{"D"}}},
+	}
+
+	want := []labelUse{
+		{"A", "policy: P"},
+		{"B", "policy: P"},
+		{"B", "query: Q"},
+		{"C", "software: software/zoom.yml"},
+		{"C", "software: slack/darwin"},
+		{"C", "software: app store app 111 (ios)"},
+		{"D", "profile: Wi-Fi"},
+	}
+	if got := teamLabelUses(team); !slices.Equal(got, want) {
+		t.Errorf("teamLabelUses =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	current := &api.FleetState{Labels: []api.Label{
+		{Name: "Engineering", HostCount: 12, LabelType: "regular"},
+		{Name: "Retired", HostCount: 3, LabelType: "regular"},
+	}}
+	labelMap := make(map[string]api.Label)
+	for _, l := range current.Labels {
+		labelMap[l.Name] = l
+	}
+	ix := buildRefIndex(current, &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{LabelsDeclared: true},
+		Labels: []parser.ParsedLabel{{Name: "Engineering"}, {Name: "New global"}},
+	})
+	teamLabels := []parser.ParsedLabel{{Name: "New team"}}
+
+	tests := []struct {
+		name        string
+		uses        []labelUse
+		wantValid   []LabelRef
+		wantMissing []LabelRef
+	}{
+		{
+			name:      "in Fleet",
+			uses:      []labelUse{{"Engineering", "policy: P"}},
+			wantValid: []LabelRef{{Name: "Engineering", HostCount: 12, ReferencedBy: "policy: P"}},
+		},
+		{
+			name: "declared in YAML only is pending",
+			uses: []labelUse{{"New global", "query: Q"}, {"New team", "profile: Wi-Fi"}},
+			wantValid: []LabelRef{
+				{Name: "New global", ReferencedBy: "query: Q", Pending: true},
+				{Name: "New team", ReferencedBy: "profile: Wi-Fi", Pending: true},
+			},
+		},
+		{
+			name:        "missing label lists every resource it breaks",
+			uses:        []labelUse{{"Ghost", "policy: P"}, {"Ghost", "software: software/zoom.yml"}},
+			wantMissing: []LabelRef{{Name: "Ghost", ReferencedBy: "policy: P, software: software/zoom.yml"}},
+		},
+		{
+			name:        "deleted by this plan",
+			uses:        []labelUse{{"Retired", "query: Q"}},
+			wantMissing: []LabelRef{{Name: "Retired", ReferencedBy: "query: Q", Deleted: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateLabels(tt.uses, ix, labelMap, teamLabels)
+			if !slices.Equal(got.Valid, tt.wantValid) {
+				t.Errorf("valid = %+v, want %+v", got.Valid, tt.wantValid)
+			}
+			if !slices.Equal(got.Missing, tt.wantMissing) {
+				t.Errorf("missing = %+v, want %+v", got.Missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestDiffValidatesGlobalLabels(t *testing.T) {
+	current := &api.FleetState{Labels: []api.Label{{Name: "macOS", HostCount: 5, LabelType: "builtin"}}}
+	proposed := &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{
+			Policies: []parser.ParsedPolicy{{Name: "P", LabelsIncludeAny: []string{"macOS"}}},
+			Queries:  []parser.ParsedQuery{{Name: "Q", LabelsIncludeAny: []string{"Ghost"}}},
+		},
+	}
+
+	results := Diff(current, proposed, nil, nil)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	lv := results[0].Labels
+	if len(lv.Valid) != 1 || lv.Valid[0].Name != "macOS" {
+		t.Errorf("valid = %+v", lv.Valid)
+	}
+	if len(lv.Missing) != 1 || lv.Missing[0].ReferencedBy != "query: Q" {
+		t.Errorf("missing = %+v", lv.Missing)
+	}
+}
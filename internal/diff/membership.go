@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// HostResolver looks up Fleet hosts by serial, hostname or UUID, in
+// parallel. Identifiers no host answers to map to a nil Host. The error is
+// only set when ctx ends before every lookup finished.
+type HostResolver interface {
+	GetHostsByIdentifier(ctx context.Context, identifiers []string) (map[string]api.HostLookup, error)
+}
+
+// addLabelMembership sets r's label membership diff and errors. If ctx ends
+// while hosts are being resolved, label hosts are marked not fetched instead.
+func addLabelMembership(ctx context.Context, r *DiffResult, labels []parser.ParsedLabel, current map[string]api.Label, resolver HostResolver, changedFiles []string) {
+	if r.notFetched(api.ResourceLabelHosts) {
+		// FetchAll marked the labels it didn't get as HostsUnavailable.
+		r.Errors = append(r.Errors, notFetchedError("membership diff for some manual labels"))
+	}
+	rd, errs, err := diffLabelMembership(ctx, labels, current, resolver, changedFiles)
+	if err != nil {
+		if !r.notFetched(api.ResourceLabelHosts) {
+			r.skipNotFetched(api.ResourceLabelHosts, "label membership diff")
+		}
+		return
+	}
+	r.LabelMembership = rd
+	r.Errors = append(r.Errors, errs...)
+}
+
+// diffLabelMembership compares the hosts listed by manual labels against
+// their current members in Fleet. Each label with membership changes becomes
+// one change with "hosts added" and "hosts removed" fields: modified when the
+// label exists in Fleet, added otherwise. Identifiers that match no current
+// member are resolved through resolver in one batch; ones no Fleet host
+// answers to are errors, since the device would silently miss everything
+// scoped to the label. Without a resolver, unmatched identifiers are
+// reported as added as written. If ctx ends while resolving, the diff is
+// abandoned and the context error returned.
+func diffLabelMembership(ctx context.Context, labels []parser.ParsedLabel, current map[string]api.Label, resolver HostResolver, changedFiles []string) (ResourceDiff, []string, error) {
+	var manual []parser.ParsedLabel
+	var unmatched []string
+	pending := make(map[string]bool)
+	for _, l := range labels {
+		if l.LabelMembershipType != "manual" {
+			continue
+		}
+		if len(changedFiles) > 0 && !isChangedFile(l.SourceFile, changedFiles) {
+			continue
+		}
+		cur := current[l.Name]
+		if cur.HostsUnavailable {
+			continue
+		}
+		manual = append(manual, l)
+		for _, id := range labelHosts(l) {
+			if !pending[id] && !slices.ContainsFunc(cur.Hosts, func(h api.Host) bool { return h.Matches(id) }) {
+				pending[id] = true
+				unmatched = append(unmatched, id)
+			}
+		}
+	}
+
+	var resolved map[string]api.HostLookup
+	if resolver != nil && len(unmatched) > 0 {
+		var err error
+		if resolved, err = resolver.GetHostsByIdentifier(ctx, unmatched); err != nil {
+			return ResourceDiff{}, nil, err
+		}
+	}
+
+	var rd ResourceDiff
+	var errs []string
+	for _, l := range manual {
+		cur, inFleet := current[l.Name]
+		kept := make(map[uint]bool)
+		var added []string
+		for _, id := range labelHosts(l) {
+			if i := slices.IndexFunc(cur.Hosts, func(h api.Host) bool { return h.Matches(id) }); i >= 0 {
+				kept[cur.Hosts[i].ID] = true
+				continue
+			}
+			if resolver == nil {
+				added = append(added, id)
+				continue
+			}
+			lookup := resolved[id]
+			switch host := lookup.Host; {
+			case lookup.Err != nil:
+				errs = append(errs, fmt.Sprintf("%s: label %q: resolving host %q: %s", l.SourceFile, l.Name, id, lookup.Err))
+			case host == nil:
+				errs = append(errs, fmt.Sprintf("%s: label %q: host %q not found in Fleet (no host with that serial, hostname or UUID)", l.SourceFile, l.Name, id))
+			case slices.ContainsFunc(cur.Hosts, func(h api.Host) bool { return h.ID == host.ID }):
+				kept[host.ID] = true
+			default:
+				added = append(added, host.String())
+			}
+		}
+
+		var removed []string
+		for _, h := range cur.Hosts {
+			if !kept[h.ID] {
+				removed = append(removed, h.String())
+			}
+		}
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		slices.Sort(added)
+		slices.Sort(removed)
+
+		change := ResourceChange{
+			Name:      l.Name,
+			Fields:    make(map[string]FieldDiff),
+			HostCount: uint(len(added) + len(removed)),
+		}
+		if len(added) > 0 {
+			change.Fields["hosts added"] = FieldDiff{New: strings.Join(added, ", ")}
+		}
+		if len(removed) > 0 {
+			change.Fields["hosts removed"] = FieldDiff{New: strings.Join(removed, ", ")}
+		}
+		if inFleet {
+			rd.Modified = append(rd.Modified, change)
+		} else {
+			rd.Added = append(rd.Added, change)
+		}
+	}
+	return rd, errs, nil
+}
+
+// labelHosts returns l's host identifiers trimmed, without blanks and
+// case-insensitive duplicates.
+func labelHosts(l parser.ParsedLabel) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range l.Hosts {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[strings.ToLower(id)] {
+			continue
+		}
+		seen[strings.ToLower(id)] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// fakeHosts resolves identifiers from a fixed host list.
+type fakeHosts struct {
+	hosts   []api.Host
+	lookups []string
+}
+
+func (f *fakeHosts) GetHostsByIdentifier(_ context.Context, identifiers []string) (map[string]api.HostLookup, error) {
+	f.lookups = append(f.lookups, identifiers...)
+	resolved := make(map[string]api.HostLookup)
+	for _, id := range identifiers {
+		if id == "flaky" {
+			resolved[id] = api.HostLookup{Err: errors.New("HTTP 500")}
+			continue
+		}
+		for _, h := range f.hosts {
+			if h.Matches(id) {
+				resolved[id] = api.HostLookup{Host: &h}
+				break
+			}
+		}
+	}
+	return resolved, nil
+}
+
+func TestDiffLabelMembership(t *testing.T) {
+	kiosk1 := api.Host{ID: 1, Hostname: "kiosk-01", HardwareSerial: "C02AAA"}
+	kiosk2 := api.Host{ID: 2, Hostname: "kiosk-02", HardwareSerial: "C02BBB", UUID: "UUID-2"}
+	kiosk3 := api.Host{ID: 3, Hostname: "kiosk-03", HardwareSerial: "C02CCC"}
+	current := map[string]api.Label{
+		"Kiosks":  {Name: "Kiosks", LabelMembershipType: "manual", Hosts: []api.Host{kiosk1, kiosk2}},
+		"Private": {Name: "Private", LabelMembershipType: "manual", HostsUnavailable: true},
+	}
+
+	tests := []struct {
+		name         string
+		label        parser.ParsedLabel
+		changedFiles []string
+		wantAdded    []string // change names
+		wantModified map[string]map[string]string
+		wantErrs     []string
+		wantLookups  []string
+	}{
+		{
+			name:  "unchanged membership, any identifier",
+			label: parser.ParsedLabel{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []string{"c02aaa", "uuid-2"}},
+		},
+		{
+			name:        "host added and removed",
+			label:       parser.ParsedLabel{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-01", "C02CCC"}},
+			wantLookups: []string{"C02CCC"},
+			wantModified: map[string]map[string]string{"Kiosks": {
+				"hosts added":   "kiosk-03 (C02CCC)",
+				"hosts removed": "kiosk-02 (C02BBB)",
+			}},
+		},
+		{
+			name:        "identifier resolving to a current member",
+			label:       parser.ParsedLabel{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []string{"C02AAA", "kiosk-02.local"}},
+			wantLookups: []string{"kiosk-02.local"},
+		},
+		{
+			name:        "unknown and failing identifiers",
+			label:       parser.ParsedLabel{Name: "Kiosks", SourceFile: "labels/kiosks.yml", LabelMembershipType: "manual", Hosts: []string{"C02AAA", "C02BBB", "C02TYPO", "flaky"}},
+			wantLookups: []string{"C02TYPO", "flaky"},
+			wantErrs: []string{
+				`labels/kiosks.yml: label "Kiosks": host "C02TYPO" not found in Fleet`,
+				`labels/kiosks.yml: label "Kiosks": resolving host "flaky": HTTP 500`,
+			},
+		},
+		{
+			name:        "new label",
+			label:       parser.ParsedLabel{Name: "New", LabelMembershipType: "manual", Hosts: []string{"C02CCC", "C02CCC"}},
+			wantLookups: []string{"C02CCC"},
+			wantAdded:   []string{"New"},
+		},
+		{
+			name:  "dynamic labels are skipped",
+			label: parser.ParsedLabel{Name: "Kiosks", Query: "SELECT 1", Hosts: []string{"C02CCC"}},
+		},
+		{
+			name:  "members unreadable",
+			label: parser.ParsedLabel{Name: "Private", LabelMembershipType: "manual", Hosts: []string{"C02CCC"}},
+		},
+		{
+			name:         "label file not in changed files",
+			label:        parser.ParsedLabel{Name: "Kiosks", SourceFile: "/repo/labels/kiosks.yml", LabelMembershipType: "manual"},
+			changedFiles: []string{"labels/other.yml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &fakeHosts{hosts: []api.Host{kiosk1, {ID: 2, Hostname: "kiosk-02.local"}, kiosk3}}
+			rd, errs, err := diffLabelMembership(context.Background(), []parser.ParsedLabel{tt.label}, current, resolver, tt.changedFiles)
+			if err != nil {
+				t.Fatalf("diffLabelMembership: %v", err)
+			}
+
+			var added []string
+			for _, c := range rd.Added {
+				added = append(added, c.Name)
+			}
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+			if len(rd.Modified) != len(tt.wantModified) {
+				t.Fatalf("modified = %+v, want %v", rd.Modified, tt.wantModified)
+			}
+			for _, c := range rd.Modified {
+				want := tt.wantModified[c.Name]
+				if len(c.Fields) != len(want) {
+					t.Errorf("%s fields = %+v, want %v", c.Name, c.Fields, want)
+				}
+				for field, v := range want {
+					if c.Fields[field].New != v {
+						t.Errorf("%s %s = %q, want %q", c.Name, field, c.Fields[field].New, v)
+					}
+				}
+				if c.HostCount != uint(len(want)) {
+					t.Errorf("%s host count = %d", c.Name, c.HostCount)
+				}
+			}
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("errors = %v, want %v", errs, tt.wantErrs)
+			}
+			for i, want := range tt.wantErrs {
+				if !strings.HasPrefix(errs[i], want) {
+					t.Errorf("error[%d] = %q, want prefix %q", i, errs[i], want)
+				}
+			}
+			if !slices.Equal(resolver.lookups, tt.wantLookups) {
+				t.Errorf("lookups = %v, want %v", resolver.lookups, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestDiffReportsLabelMembership(t *testing.T) {
+	current := &api.FleetState{
+		Teams: []api.Team{{ID: 1, Name: "T"}},
+		Labels: []api.Label{{
+			Name: "Kiosks", LabelMembershipType: "manual",
+			Hosts: []api.Host{{ID: 1, Hostname: "kiosk-01"}},
+		}},
+	}
+	proposed := &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{},
+		Labels: []parser.ParsedLabel{{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-01", "kiosk-02"}}},
+		Teams: []parser.ParsedTeam{{
+			Name:   "T",
+			Labels: []parser.ParsedLabel{{Name: "Team kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-09"}}},
+		}},
+	}
+
+	results := Diff(current, proposed, nil, nil)
+	if len(results) != 2 {
+		t.Fatalf("expected global and team results, got %d", len(results))
+	}
+	if m := results[0].LabelMembership.Modified; len(m) != 1 || m[0].Fields["hosts added"].New != "kiosk-02" {
+		t.Errorf("global membership = %+v", results[0].LabelMembership)
+	}
+	if a := results[1].LabelMembership.Added; len(a) != 1 || a[0].Name != "Team kiosks" {
+		t.Errorf("team membership = %+v", results[1].LabelMembership)
+	}
+
+	results = Diff(current, proposed, nil, nil, WithHostResolver(&fakeHosts{}))
+	if errs := results[0].Errors; len(errs) != 1 || !strings.Contains(errs[0], `host "kiosk-02" not found in Fleet`) {
+		t.Errorf("global errors with resolver = %v", errs)
+	}
+}
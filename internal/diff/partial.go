@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// WithContext bounds the requests Diff makes itself (fleet-maintained app
+// scripts, manual label hosts). Diffs whose state the context cut short are
+// skipped and listed in DiffResult.NotFetched.
+func WithContext(ctx context.Context) DiffOption {
+	return func(o *diffOptions) { o.ctx = ctx }
+}
+
+// Partial reports whether any result skipped a diff because the run was
+// interrupted before its Fleet state was fetched.
+func Partial(results []DiffResult) bool {
+	for _, r := range results {
+		if len(r.NotFetched) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notFetchedError explains a check skipped for lack of Fleet state.
+func notFetchedError(what string) string {
+	return fmt.Sprintf("%s skipped: not fetched before the run was interrupted", what)
+}
+
+// notFetched reports whether res was left out of the result's diff.
+func (r DiffResult) notFetched(res api.Resource) bool {
+	return slices.Contains(r.NotFetched, res)
+}
+
+// skipNotFetched marks res as not fetched when a request the diff itself
+// makes is cut short, and explains the skipped check.
+func (r *DiffResult) skipNotFetched(res api.Resource, what string) {
+	if !r.notFetched(res) {
+		r.NotFetched = append(r.NotFetched, res)
+	}
+	r.Errors = append(r.Errors, notFetchedError(what))
+}
+
+// globalNotFetched returns what an interrupted fetch left out of the
+// default.yml diff.
+func globalNotFetched(current *api.FleetState, labels []parser.ParsedLabel) []api.Resource {
+	var nf []api.Resource
+	for _, r := range current.NotFetched {
+		switch {
+		case r == api.ResourceConfig, r == api.ResourcePolicies, r == api.ResourceQueries, r == api.ResourceLabels:
+			nf = append(nf, r)
+		case r == api.ResourceLabelHosts && hasManualLabels(labels):
+			nf = append(nf, r)
+		}
+	}
+	return nf
+}
+
+// teamNotFetched returns what an interrupted fetch left out of a team's
+// diff: the team's own resources, and those compared through shared state
+// that wasn't fetched. Without the team list, nothing about the team is
+// known.
+func teamNotFetched(current *api.FleetState, team api.Team, proposed parser.ParsedTeam) []api.Resource {
+	if slices.Contains(current.NotFetched, api.ResourceTeams) {
+		return slices.Clone(api.TeamResources)
+	}
+	nf := slices.Clone(team.NotFetched)
+	add := func(r api.Resource) {
+		if !slices.Contains(nf, r) {
+			nf = append(nf, r)
+		}
+	}
+	for _, r := range current.NotFetched {
+		switch {
+		case r == api.ResourceLabels, r == api.ResourceAppStore:
+			add(r)
+		case r == api.ResourceCatalog && len(proposed.Software.FleetMaintained) > 0:
+			add(api.ResourceSoftware)
+		case r == api.ResourceLabelHosts && hasManualLabels(proposed.Labels):
+			add(r)
+		}
+	}
+	return nf
+}
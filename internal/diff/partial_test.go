@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// cancelledHosts fails every lookup with the context's error.
+type cancelledHosts struct{}
+
+func (cancelledHosts) GetHostsByIdentifier(ctx context.Context, _ []string) (map[string]api.HostLookup, error) {
+	return nil, ctx.Err()
+}
+
+func TestDiffNotFetched(t *testing.T) {
+	proposed := &parser.ParsedRepo{
+		Global: &parser.ParsedGlobal{
+			Queries: []parser.ParsedQuery{{Name: "Uptime", Query: "SELECT * FROM uptime;"}},
+		},
+		Labels: []parser.ParsedLabel{{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []string{"kiosk-02"}}},
+		Teams: []parser.ParsedTeam{{
+			Name:     "Workstations",
+			Policies: []parser.ParsedPolicy{{Name: "Disk encrypted", Query: "SELECT 1;"}},
+			Queries:  []parser.ParsedQuery{{Name: "Users", Query: "SELECT * FROM users;"}},
+		}},
+	}
+	kiosks := api.Label{Name: "Kiosks", LabelMembershipType: "manual", Hosts: []api.Host{{ID: 1, Hostname: "kiosk-01"}}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name           string
+		current        *api.FleetState
+		opts           []DiffOption
+		wantGlobal     []api.Resource
+		wantTeam       []api.Resource
+		wantErrors     []string // on the team result
+		wantQueries    int      // team queries added
+		wantPolicies   int      // team policies added
+		wantMembership bool     // global label membership diffed
+	}{
+		{
+			name: "complete",
+			current: &api.FleetState{
+				Teams:  []api.Team{{ID: 1, Name: "Workstations"}},
+				Labels: []api.Label{kiosks},
+			},
+			wantQueries:    1,
+			wantPolicies:   1,
+			wantMembership: true,
+		},
+		{
+			name: "team resources",
+			current: &api.FleetState{
+				Teams:      []api.Team{{ID: 1, Name: "Workstations", NotFetched: []api.Resource{api.ResourcePolicies, api.ResourceScripts}}},
+				Labels:     []api.Label{kiosks},
+				NotFetched: []api.Resource{api.ResourceQueries},
+			},
+			wantGlobal: []api.Resource{api.ResourceQueries},
+			wantTeam:   []api.Resource{api.ResourcePolicies, api.ResourceScripts},
+			wantErrors: []string{
+				"policies diff skipped: not fetched before the run was interrupted",
+				"scripts diff skipped: not fetched before the run was interrupted",
+			},
+			wantQueries:    1,
+			wantMembership: true,
+		},
+		{
+			name: "teams and labels",
+			current: &api.FleetState{
+				NotFetched: []api.Resource{api.ResourceTeams, api.ResourceLabels},
+			},
+			wantGlobal: []api.Resource{api.ResourceLabels},
+			wantTeam:   api.TeamResources,
+			wantErrors: []string{"team diff skipped: not fetched before the run was interrupted"},
+		},
+		{
+			name: "host lookups cut short",
+			current: &api.FleetState{
+				Teams:  []api.Team{{ID: 1, Name: "Workstations"}},
+				Labels: []api.Label{kiosks},
+			},
+			opts:         []DiffOption{WithContext(cancelled), WithHostResolver(cancelledHosts{})},
+			wantGlobal:   []api.Resource{api.ResourceLabelHosts},
+			wantQueries:  1,
+			wantPolicies: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Diff(tt.current, proposed, nil, nil, tt.opts...)
+			if len(results) != 2 {
+				t.Fatalf("got %d results, want global and team", len(results))
+			}
+			global, team := results[0], results[1]
+
+			if !slices.Equal(global.NotFetched, tt.wantGlobal) {
+				t.Errorf("global NotFetched = %v, want %v", global.NotFetched, tt.wantGlobal)
+			}
+			if !slices.Equal(team.NotFetched, tt.wantTeam) {
+				t.Errorf("team NotFetched = %v, want %v", team.NotFetched, tt.wantTeam)
+			}
+			if !slices.Equal(team.Errors, tt.wantErrors) {
+				t.Errorf("team errors = %q, want %q", team.Errors, tt.wantErrors)
+			}
+			if got := len(team.Queries.Added); got != tt.wantQueries {
+				t.Errorf("team queries added = %d, want %d", got, tt.wantQueries)
+			}
+			if got := len(team.Policies.Added); got != tt.wantPolicies {
+				t.Errorf("team policies added = %d, want %d", got, tt.wantPolicies)
+			}
+			if got := !global.LabelMembership.IsEmpty(); got != tt.wantMembership {
+				t.Errorf("global membership diffed = %v, want %v", got, tt.wantMembership)
+			}
+			partial := tt.wantGlobal != nil || tt.wantTeam != nil
+			if Partial(results) != partial {
+				t.Errorf("Partial = %v, want %v", Partial(results), partial)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/osquery"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// DefaultQueryCostLimit is the cost above which a scheduled query is flagged
+// when no --max-query-cost is set.
+const DefaultQueryCostLimit = 100
+
+// tableCosts weights tables by the work osquery does to generate them: disk
+// and memory scans, hashing, per-process enumeration. Tables not listed are
+// cheap and weigh nothing.
+var tableCosts = map[string]int{
+	"authenticode":         3,
+	"certificates":         1,
+	"curl":                 3,
+	"curl_certificate":     3,
+	"file":                 2,
+	"hash":                 3,
+	"keychain_items":       2,
+	"magic":                3,
+	"mdfind":               4,
+	"npm_packages":         2,
+	"ntfs_acl_permissions": 3,
+	"process_envs":         2,
+	"process_memory_map":   4,
+	"process_open_files":   4,
+	"process_open_sockets": 4,
+	"processes":            1,
+	"signature":            3,
+	"system_profiler":      4,
+	"unified_log":          6,
+	"windows_eventlog":     4,
+	"yara":                 6,
+}
+
+// requiredConstraints lists tables that walk the filesystem or network
+// unless one of these columns is constrained in WHERE or JOIN ... ON.
+var requiredConstraints = map[string][]string{
+	"authenticode":         {"path"},
+	"augeas":               {"path", "node"},
+	"curl":                 {"url"},
+	"curl_certificate":     {"hostname"},
+	"file":                 {"path", "directory"},
+	"hash":                 {"path", "directory"},
+	"magic":                {"path"},
+	"ntfs_acl_permissions": {"path"},
+	"signature":            {"path"},
+	"yara":                 {"path"},
+}
+
+const (
+	unconstrainedCost = 100 // per table missing a required constraint
+	wildcardCost      = 20  // recursive %% pattern on a filesystem table
+	joinCost          = 2   // per extra table joined to an expensive one
+)
+
+// queryCost is the estimated cost of a scheduled query and what drives it.
+type queryCost struct {
+	score   int
+	reasons []string
+}
+
+// scoreQuery estimates how expensive a scheduled query is per hour on each
+// host: the weight of the tables it reads, plus penalties for missing
+// required constraints, recursive wildcards and joins, scaled by how often
+// it runs. Queries that do not parse or are not scheduled score zero.
+func scoreQuery(sql string, interval uint) queryCost {
+	if interval == 0 {
+		return queryCost{}
+	}
+	q, err := osquery.Parse(sql)
+	if err != nil {
+		return queryCost{}
+	}
+
+	var weight int
+	var reasons, expensive, tables []string
+	for _, sel := range q.Selects {
+		for _, tr := range sel.Tables {
+			name := strings.ToLower(tr.Name)
+			if tr.Function || q.CTEs[name] {
+				continue
+			}
+			tables = append(tables, name)
+			if c := tableCosts[name]; c > 0 {
+				weight += c
+				if !slices.Contains(expensive, name) {
+					expensive = append(expensive, name)
+				}
+			}
+			if cols, ok := requiredConstraints[name]; ok && !isConstrained(sel, tr, cols) {
+				weight += unconstrainedCost
+				reasons = append(reasons, fmt.Sprintf("%s without a %s constraint", name, strings.Join(cols, " or ")))
+			}
+		}
+	}
+	if weight == 0 {
+		return queryCost{}
+	}
+
+	if strings.Contains(sql, "%%") && slices.ContainsFunc(tables, func(t string) bool {
+		return slices.Contains(requiredConstraints[t], "path")
+	}) {
+		weight += wildcardCost
+		reasons = append(reasons, `recursive "%%" wildcard`)
+	}
+	if len(tables) > 1 {
+		weight += joinCost * (len(tables) - 1)
+		reasons = append(reasons, fmt.Sprintf("joins %d tables", len(tables)))
+	}
+	if len(expensive) > 0 {
+		reasons = append([]string{"reads " + strings.Join(expensive, ", ")}, reasons...)
+	}
+	reasons = append(reasons, fmt.Sprintf("runs every %ds", interval))
+
+	score := (weight*3600 + int(interval) - 1) / int(interval)
+	return queryCost{score: score, reasons: reasons}
+}
+
+// isConstrained reports whether a WHERE or ON column in sel constrains tr on
+// one of cols. Unqualified columns count for any table with that column.
+func isConstrained(sel *osquery.Select, tr osquery.TableRef, cols []string) bool {
+	for _, c := range sel.Columns {
+		if !c.Constraint || !slices.Contains(cols, strings.ToLower(c.Name)) {
+			continue
+		}
+		if c.Table == "" || strings.EqualFold(c.Table, tr.Alias) || (tr.Alias == "" && strings.EqualFold(c.Table, tr.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreQueries sets Cost on added and modified scheduled queries and warns
+// on those scoring above limit. A warning the query already carries (e.g.
+// an unknown table) is kept and the cost warning appended to it, so the
+// query still counts against --max-query-cost.
+func scoreQueries(rd *ResourceDiff, proposed []parser.ParsedQuery, limit int) {
+	byName := make(map[string]parser.ParsedQuery, len(proposed))
+	for _, q := range proposed {
+		byName[q.Name] = q
+	}
+	for _, list := range [][]ResourceChange{rd.Added, rd.Modified} {
+		for i := range list {
+			q, ok := byName[list[i].Name]
+			if !ok {
+				continue
+			}
+			cost := scoreQuery(q.Query, q.Interval)
+			list[i].Cost = cost.score
+			if cost.score <= limit {
+				continue
+			}
+			msg := fmt.Sprintf("query cost %d exceeds %d: %s", cost.score, limit, strings.Join(cost.reasons, ", "))
+			if list[i].Warning != "" {
+				msg = list[i].Warning + "; " + msg
+			}
+			list[i].Warning = msg
+		}
+	}
+}
+
+// QueriesOverCost lists added or modified queries whose cost exceeds limit,
+// for failing CI.
+func QueriesOverCost(results []DiffResult, limit int) []string {
+	var over []string
+	for _, r := range results {
+		for _, list := range [][]ResourceChange{r.Queries.Added, r.Queries.Modified} {
+			for _, c := range list {
+				if c.Cost > limit {
+					over = append(over, fmt.Sprintf("%s: query %q (cost %d)", r.Team, c.Name, c.Cost))
+				}
+			}
+		}
+	}
+	return over
+}
@@ -0,0 +1,147 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestScoreQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		interval    uint
+		wantScore   int
+		wantReasons []string
+	}{
+		{name: "cheap table", query: "SELECT * FROM os_version", interval: 60},
+		{name: "not scheduled", query: "SELECT * FROM hash", interval: 0},
+		{name: "invalid SQL", query: "SELECT FROM hash", interval: 60},
+		{
+			name:        "constrained hash hourly",
+			query:       "SELECT sha256 FROM hash WHERE path = '/etc/passwd'",
+			interval:    3600,
+			wantScore:   3,
+			wantReasons: []string{"reads hash", "runs every 3600s"},
+		},
+		{
+			name:        "interval scales cost",
+			query:       "SELECT * FROM process_open_sockets",
+			interval:    60,
+			wantScore:   240,
+			wantReasons: []string{"reads process_open_sockets", "runs every 60s"},
+		},
+		{
+			name:        "missing required constraint",
+			query:       "SELECT path FROM file WHERE size > 1000",
+			interval:    3600,
+			wantScore:   102,
+			wantReasons: []string{"reads file", "file without a path or directory constraint", "runs every 3600s"},
+		},
+		{
+			name:        "constraint through join",
+			query:       "SELECT h.sha256 FROM processes p JOIN hash h ON h.path = p.path",
+			interval:    3600,
+			wantScore:   6,
+			wantReasons: []string{"reads processes, hash", "joins 2 tables", "runs every 3600s"},
+		},
+		{
+			name:        "recursive wildcard",
+			query:       "SELECT * FROM yara WHERE path LIKE '/Users/%%' AND sigfile = '/etc/rules.yar'",
+			interval:    86400,
+			wantScore:   2,
+			wantReasons: []string{"reads yara", `recursive "%%" wildcard`, "runs every 86400s"},
+		},
+		{
+			name:        "subquery columns do not constrain the outer table",
+			query:       "SELECT * FROM hash WHERE sha256 IN (SELECT path FROM processes WHERE pid = 1)",
+			interval:    3600,
+			wantScore:   106,
+			wantReasons: []string{"reads hash, processes", "hash without a path or directory constraint", "joins 2 tables", "runs every 3600s"},
+		},
+		{
+			name:      "CTE names are not tables",
+			query:     "WITH hash AS (SELECT 1 AS path) SELECT * FROM hash",
+			interval:  60,
+			wantScore: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scoreQuery(tt.query, tt.interval)
+			if got.score != tt.wantScore {
+				t.Errorf("score = %d, want %d (reasons %v)", got.score, tt.wantScore, got.reasons)
+			}
+			if !slices.Equal(got.reasons, tt.wantReasons) {
+				t.Errorf("reasons = %q, want %q", got.reasons, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestScoreQueries(t *testing.T) {
+	rd := ResourceDiff{
+		Added: []ResourceChange{{Name: "Sockets"}, {Name: "OS"}, {Name: "Broken", Warning: "invalid SQL at query line 1"}, {Name: "Schema", Warning: "unknown table foo"}},
+		Modified: []ResourceChange{{Name: "Hash all", Fields: map[string]FieldDiff{
+			"interval": {Old: "86400", New: "300"},
+		}}},
+	}
+	proposed := []parser.ParsedQuery{
+		{Name: "Sockets", Query: "SELECT * FROM process_open_sockets", Interval: 60},
+		{Name: "OS", Query: "SELECT * FROM os_version", Interval: 60},
+		{Name: "Broken", Query: "SELECT * FROM", Interval: 60},
+		{Name: "Schema", Query: "SELECT * FROM process_open_sockets JOIN foo", Interval: 60},
+		{Name: "Hash all", Query: "SELECT * FROM hash", Interval: 300},
+	}
+
+	scoreQueries(&rd, proposed, DefaultQueryCostLimit)
+
+	if c := rd.Added[0]; c.Cost != 240 || !strings.HasPrefix(c.Warning, "query cost 240 exceeds 100: reads process_open_sockets") {
+		t.Errorf("Sockets = %+v", c)
+	}
+	if c := rd.Added[1]; c.Cost != 0 || c.Warning != "" {
+		t.Errorf("OS = %+v", c)
+	}
+	if c := rd.Added[2]; c.Cost != 0 || c.Warning != "invalid SQL at query line 1" {
+		t.Errorf("Broken should keep its SQL warning, got %+v", c)
+	}
+	if c := rd.Added[3]; c.Cost <= DefaultQueryCostLimit || !strings.HasPrefix(c.Warning, "unknown table foo; query cost ") {
+		t.Errorf("Schema should keep its warning and be scored, got %+v", c)
+	}
+	if got := QueriesOverCost([]DiffResult{{Team: "T", Queries: rd}}, DefaultQueryCostLimit); !slices.Contains(got, fmt.Sprintf(`T: query "Schema" (cost %d)`, rd.Added[3].Cost)) {
+		t.Errorf("QueriesOverCost = %v, want the warned query over the limit", got)
+	}
+	if c := rd.Modified[0]; c.Cost != 1236 || !strings.Contains(c.Warning, "hash without a path or directory constraint") {
+		t.Errorf("Hash all = %+v", c)
+	}
+}
+
+func TestQueriesOverCost(t *testing.T) {
+	current := &api.FleetState{Teams: []api.Team{{ID: 1, Name: "T"}}}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name: "T",
+		Queries: []parser.ParsedQuery{
+			{Name: "Sockets", Query: "SELECT * FROM process_open_sockets", Interval: 60},
+			{Name: "Hourly sockets", Query: "SELECT * FROM process_open_sockets", Interval: 3600},
+		},
+	}}}
+
+	results := Diff(current, proposed, nil, nil, WithQueryCostLimit(200))
+	for _, c := range results[0].Queries.Added {
+		if flagged := strings.Contains(c.Warning, "exceeds 200"); flagged != (c.Name == "Sockets") {
+			t.Errorf("%s: warning %q against limit 200", c.Name, c.Warning)
+		}
+	}
+	got := QueriesOverCost(results, 200)
+	if !slices.Equal(got, []string{`T: query "Sockets" (cost 240)`}) {
+		t.Errorf("QueriesOverCost = %v", got)
+	}
+	if got := QueriesOverCost(results, 300); len(got) != 0 {
+		t.Errorf("QueriesOverCost above all scores = %v", got)
+	}
+}
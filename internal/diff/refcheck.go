@@ -0,0 +1,142 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// refIndex records what will exist once the plan is applied, so references
+// between resources can be resolved: labels repo-wide, scripts and software
+// per team.
+type refIndex struct {
+	labels  map[string]bool // labels that survive apply (Fleet or default.yml)
+	deleted map[string]bool // Fleet labels this plan deletes
+	teams   map[string]teamRefs
+}
+
+// teamRefs indexes a team's scripts and software by the keys other
+// resources use to reference them.
+type teamRefs struct {
+	scripts     map[string]bool // script filename, Fleet's script identity
+	packages    map[string]bool // resolved package YAML path
+	hashes      map[string]bool // package hash_sha256
+	appStoreIDs map[string]bool
+}
+
+// buildRefIndex indexes every team's scripts and packages and the labels
+// that exist after apply. When default.yml declares labels, regular Fleet
+// labels that neither it nor a team file lists are deleted by the plan and
+// no longer resolve.
+func buildRefIndex(current *api.FleetState, proposed *parser.ParsedRepo) refIndex {
+	ix := refIndex{
+		labels:  make(map[string]bool),
+		deleted: make(map[string]bool),
+		teams:   make(map[string]teamRefs),
+	}
+
+	declared := make(map[string]bool)
+	for _, l := range proposed.Labels {
+		declared[l.Name] = true
+		ix.labels[l.Name] = true
+	}
+	// Team-scoped labels are managed by their team file, not default.yml.
+	for _, team := range proposed.Teams {
+		for _, l := range team.Labels {
+			declared[l.Name] = true
+		}
+	}
+	deletes := proposed.Global != nil && proposed.Global.LabelsDeclared
+	for _, l := range current.Labels {
+		if deletes && l.LabelType != "builtin" && !declared[l.Name] {
+			ix.deleted[l.Name] = true
+			continue
+		}
+		ix.labels[l.Name] = true
+	}
+
+	for _, team := range proposed.Teams {
+		refs := teamRefs{
+			scripts:     make(map[string]bool),
+			packages:    make(map[string]bool),
+			hashes:      make(map[string]bool),
+			appStoreIDs: make(map[string]bool),
+		}
+		for _, s := range team.Scripts {
+			refs.scripts[s.Name] = true
+		}
+		for _, p := range team.Software.Packages {
+			refs.packages[p.SourceFile] = true
+			if p.HashSHA256 != "" {
+				refs.hashes[p.HashSHA256] = true
+			}
+		}
+		for _, a := range team.Software.AppStoreApps {
+			refs.appStoreIDs[a.AppStoreID] = true
+		}
+		ix.teams[team.Name] = refs
+	}
+	return ix
+}
+
+// checkTeam reports a team's dangling references: policy automations and
+// setup-experience entries naming scripts or software the team does not
+// have. Label references are resolved by validateLabels.
+func (ix refIndex) checkTeam(team parser.ParsedTeam) []string {
+	var errs []string
+	refs := ix.teams[team.Name]
+	report := func(file, resource, format string, args ...any) {
+		errs = append(errs, fmt.Sprintf("%s: %s: ", file, resource)+fmt.Sprintf(format, args...))
+	}
+
+	for _, p := range team.Policies {
+		resource := fmt.Sprintf("policy %q", p.Name)
+		dir := filepath.Dir(p.SourceFile)
+		if p.RunScript != nil && p.RunScript.Path != "" && !refs.scripts[filepath.Base(p.RunScript.Path)] {
+			report(p.SourceFile, resource, "run_script %q is not in the team's controls.scripts", p.RunScript.Path)
+		}
+		if sw := p.InstallSoftware; sw != nil {
+			switch {
+			case sw.PackagePath != "" && !refs.packages[filepath.Join(dir, sw.PackagePath)]:
+				report(p.SourceFile, resource, "install_software package_path %q is not in the team's software.packages", sw.PackagePath)
+			case sw.AppStoreID != "" && !refs.appStoreIDs[sw.AppStoreID]:
+				report(p.SourceFile, resource, "install_software app_store_id %q is not in the team's software.app_store_apps", sw.AppStoreID)
+			case sw.HashSHA256 != "" && !refs.hashes[sw.HashSHA256]:
+				report(p.SourceFile, resource, "install_software hash_sha256 %q matches no package in the team's software.packages", sw.HashSHA256)
+			}
+		}
+	}
+
+	if script := team.Setup.Script; script != "" {
+		if _, err := os.Stat(script); err != nil {
+			report(team.SourceFile, "setup_experience", "script %q does not exist", filepath.Base(script))
+		}
+	}
+	for _, sw := range team.Setup.Software {
+		switch {
+		case sw.PackagePath != "" && !refs.packages[sw.PackagePath]:
+			report(team.SourceFile, "setup_experience", "software package_path %q is not in the team's software.packages", filepath.Base(sw.PackagePath))
+		case sw.AppStoreID != "" && !refs.appStoreIDs[sw.AppStoreID]:
+			report(team.SourceFile, "setup_experience", "software app_store_id %q is not in the team's software.app_store_apps", sw.AppStoreID)
+		}
+	}
+	return errs
+}
+
+// concatLabels joins label lists, dropping duplicates.
+func concatLabels(lists ...[]string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, l := range list {
+			if !seen[l] {
+				seen[l] = true
+				out = append(out, l)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestBuildRefIndexLabels(t *testing.T) {
+	current := &api.FleetState{Labels: []api.Label{
+		{Name: "macOS", LabelType: "builtin"},
+		{Name: "Engineering", LabelType: "regular"},
+		{Name: "Retired", LabelType: "regular"},
+	}}
+	proposedLabels := []parser.ParsedLabel{{Name: "Engineering"}, {Name: "New"}}
+
+	tests := []struct {
+		name        string
+		global      *parser.ParsedGlobal
+		teams       []parser.ParsedTeam
+		wantLabels  []string
+		wantDeleted []string
+	}{
+		{
+			name:        "declared labels delete unlisted regular labels",
+			global:      &parser.ParsedGlobal{LabelsDeclared: true},
+			wantLabels:  []string{"macOS", "Engineering", "New"},
+			wantDeleted: []string{"Retired"},
+		},
+		{
+			name:       "no labels key keeps Fleet labels",
+			global:     &parser.ParsedGlobal{},
+			wantLabels: []string{"macOS", "Engineering", "Retired", "New"},
+		},
+		{
+			name:       "team-declared labels are not deleted",
+			global:     &parser.ParsedGlobal{LabelsDeclared: true},
+			teams:      []parser.ParsedTeam{{Name: "T", Labels: []parser.ParsedLabel{{Name: "Retired"}}}},
+			wantLabels: []string{"macOS", "Engineering", "Retired", "New"},
+		},
+		{
+			name:       "team-scoped run keeps Fleet labels",
+			wantLabels: []string{"macOS", "Engineering", "Retired", "New"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := buildRefIndex(current, &parser.ParsedRepo{Global: tt.global, Teams: tt.teams, Labels: proposedLabels})
+			if len(ix.labels) != len(tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", ix.labels, tt.wantLabels)
+			}
+			for _, l := range tt.wantLabels {
+				if !ix.labels[l] {
+					t.Errorf("label %q should resolve", l)
+				}
+			}
+			if len(ix.deleted) != len(tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", ix.deleted, tt.wantDeleted)
+			}
+			for _, l := range tt.wantDeleted {
+				if !ix.deleted[l] {
+					t.Errorf("label %q should be deleted", l)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckTeamReferences(t *testing.T) {
+	root := t.TempDir()
+	policyFile := filepath.Join(root, "policies", "p.yml")
+	teamFile := filepath.Join(root, "teams", "t.yml")
+	zoom := filepath.Join(root, "software", "zoom.yml")
+	setupScript := filepath.Join(root, "scripts", "setup.sh")
+	os.MkdirAll(filepath.Dir(setupScript), 0o755)
+	os.WriteFile(setupScript, []byte("#!/bin/sh\n"), 0o644)
+
+	base := parser.ParsedTeam{
+		Name:       "T",
+		SourceFile: teamFile,
+		Scripts:    []parser.ParsedScript{{Name: "fix.sh"}},
+		Software: parser.ParsedSoftware{
+			Packages:     []parser.ParsedSoftwarePackage{{SourceFile: zoom, HashSHA256: "abc", RefPath: "software/zoom.yml", TeamFile: teamFile}},
+			AppStoreApps: []parser.ParsedAppStoreApp{{AppStoreID: "111", SourceFile: teamFile}},
+		},
+	}
+	policy := func(p parser.ParsedPolicy) parser.ParsedTeam {
+		team := base
+		p.Name, p.SourceFile = "P", policyFile
+		team.Policies = []parser.ParsedPolicy{p}
+		return team
+	}
+
+	tests := []struct {
+		name         string
+		team         parser.ParsedTeam
+		wantContains []string
+	}{
+		{
+			name: "resolved automations",
+			team: policy(parser.ParsedPolicy{
+				RunScript:       &parser.PolicyScriptRef{Path: "../scripts/fix.sh"},
+				InstallSoftware: &parser.PolicySoftwareRef{PackagePath: "../software/zoom.yml"},
+			}),
+		},
+		{
+			name:         "run_script not in controls.scripts",
+			team:         policy(parser.ParsedPolicy{RunScript: &parser.PolicyScriptRef{Path: "../scripts/gone.sh"}}),
+			wantContains: []string{policyFile + `: policy "P": run_script "../scripts/gone.sh" is not in the team's controls.scripts`},
+		},
+		{
+			name:         "install_software package not in team",
+			team:         policy(parser.ParsedPolicy{InstallSoftware: &parser.PolicySoftwareRef{PackagePath: "../software/slack.yml"}}),
+			wantContains: []string{`install_software package_path "../software/slack.yml" is not in the team's software.packages`},
+		},
+		{
+			name:         "install_software app store app not in team",
+			team:         policy(parser.ParsedPolicy{InstallSoftware: &parser.PolicySoftwareRef{AppStoreID: "222"}}),
+			wantContains: []string{`install_software app_store_id "222"`},
+		},
+		{
+			name: "install_software by hash",
+			team: policy(parser.ParsedPolicy{InstallSoftware: &parser.PolicySoftwareRef{HashSHA256: "abc"}}),
+		},
+		{
+			name: "setup experience resolves",
+			team: func() parser.ParsedTeam {
+				team := base
+				team.Setup = parser.ParsedSetupExperience{Script: setupScript, Software: []parser.SetupSoftwareRef{{PackagePath: zoom}, {AppStoreID: "111"}}}
+				return team
+			}(),
+		},
+		{
+			name: "setup experience dangling",
+			team: func() parser.ParsedTeam {
+				team := base
+				team.Setup = parser.ParsedSetupExperience{
+					Script:   filepath.Join(root, "scripts", "missing.sh"),
+					Software: []parser.SetupSoftwareRef{{PackagePath: filepath.Join(root, "software", "slack.yml")}, {AppStoreID: "999"}},
+				}
+				return team
+			}(),
+			wantContains: []string{
+				teamFile + `: setup_experience: script "missing.sh" does not exist`,
+				`setup_experience: software package_path "slack.yml" is not in the team's software.packages`,
+				`setup_experience: software app_store_id "999"`,
+			},
+		},
+	}
+
+	current := &api.FleetState{Labels: []api.Label{{Name: "Engineering"}, {Name: "Retired"}}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed := &parser.ParsedRepo{
+				Teams:  []parser.ParsedTeam{tt.team},
+				Labels: []parser.ParsedLabel{{Name: "Engineering"}, {Name: "New"}},
+				Global: &parser.ParsedGlobal{LabelsDeclared: true},
+			}
+			got := buildRefIndex(current, proposed).checkTeam(tt.team)
+			if len(got) != len(tt.wantContains) {
+				t.Fatalf("errors: got %v, want %d", got, len(tt.wantContains))
+			}
+			for i, want := range tt.wantContains {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("error[%d] = %q, want substring %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffReportsDanglingReferences(t *testing.T) {
+	current := &api.FleetState{
+		Teams: []api.Team{{ID: 1, Name: "T"}},
+	}
+	proposed := &parser.ParsedRepo{
+		Teams: []parser.ParsedTeam{{
+			Name:     "T",
+			Policies: []parser.ParsedPolicy{{Name: "P", SourceFile: "teams/t.yml", RunScript: &parser.PolicyScriptRef{Path: "fix.sh"}}},
+		}},
+	}
+
+	results := Diff(current, proposed, nil, nil)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if errs := results[0].Errors; len(errs) != 1 || !strings.Contains(errs[0], `teams/t.yml: policy "P": run_script "fix.sh"`) {
+		t.Errorf("team errors = %v", errs)
+	}
+}
@@ -0,0 +1,140 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/osquery"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// sqlSource is one SQL string to check and where it came from.
+type sqlSource struct {
+	kind       string // "policy", "query", "label"
+	name       string
+	query      string
+	sourceFile string
+	platform   string // comma-separated; empty means every platform
+}
+
+// validateSQL parses policy and query SQL and checks it against the osquery
+// schema, returning an error per problem that names the source file and the
+// line within the query. Tables must carry the referenced columns and be
+// available on every platform the resource targets. Added and modified
+// policies and queries with a problem get a Warning, so the plan marks them
+// invalid. Tables the schema doesn't know are only an "info: " note, since
+// the embedded schema can trail osquery and fleetd releases. When
+// changedFiles is non-empty, only resources from changed files are checked
+// (MR-scoped).
+func validateSQL(sources []sqlSource, changedFiles []string, policies, queries *ResourceDiff) []string {
+	var errs []string
+	invalid := make(map[string]string) // "kind/name" -> message
+	for _, s := range sources {
+		if strings.TrimSpace(s.query) == "" {
+			continue
+		}
+		if len(changedFiles) > 0 && !isChangedFile(s.sourceFile, changedFiles) {
+			continue
+		}
+		where := fmt.Sprintf("%s %q", s.kind, s.name)
+		if s.sourceFile != "" {
+			where = s.sourceFile + ": " + where
+		}
+		problems, notes := sqlProblems(s.query, s.platform)
+		for _, p := range problems {
+			errs = append(errs, where+": "+p)
+		}
+		for _, n := range notes {
+			errs = append(errs, "info: "+where+": "+n)
+		}
+		if len(problems) > 0 {
+			invalid[s.kind+"/"+s.name] = problems[0]
+		}
+	}
+	if len(invalid) == 0 {
+		return errs
+	}
+
+	mark := func(kind string, rd *ResourceDiff) {
+		if rd == nil {
+			return
+		}
+		for _, list := range [][]ResourceChange{rd.Added, rd.Modified} {
+			for i := range list {
+				if msg, ok := invalid[kind+"/"+list[i].Name]; ok && list[i].Warning == "" {
+					list[i].Warning = msg
+				}
+			}
+		}
+	}
+	mark("policy", policies)
+	mark("query", queries)
+	return errs
+}
+
+// sqlProblems parses query and checks it against the osquery schema for the
+// given platforms. Each problem locates itself within the query, e.g.
+// "invalid SQL at query line 2, column 7: near ...". Tables missing from the
+// schema are returned as notes instead, e.g. "table appz is not in the
+// osquery schema, so it was not checked (did you mean "apps"?) at query
+// line 1, column 15".
+func sqlProblems(query, platform string) (problems, notes []string) {
+	q, err := osquery.Parse(query)
+	if err != nil {
+		var se *osquery.SyntaxError
+		if errors.As(err, &se) {
+			return []string{fmt.Sprintf("invalid SQL at query line %d, column %d: %s", se.Line, se.Column, se.Message)}, nil
+		}
+		return []string{"invalid SQL: " + err.Error()}, nil
+	}
+
+	for _, se := range osquery.CheckSchema(q, targetPlatforms(platform)) {
+		if se.UnknownTable == "" {
+			problems = append(problems, fmt.Sprintf("%s at query line %d, column %d", se.Message, se.Line, se.Column))
+			continue
+		}
+		msg := fmt.Sprintf("table %s is not in the osquery schema, so it was not checked", se.UnknownTable)
+		if suggestions := closestMatches(strings.ToLower(se.UnknownTable), osquery.TableNames()); len(suggestions) > 0 {
+			msg += fmt.Sprintf(" (did you mean %q?)", suggestions[0])
+		}
+		notes = append(notes, fmt.Sprintf("%s at query line %d, column %d", msg, se.Line, se.Column))
+	}
+	return problems, notes
+}
+
+// targetPlatforms splits a resource's platform field into the valid
+// platforms it names. Unknown names are reported by ValidatePlatform, not
+// here.
+func targetPlatforms(platform string) []string {
+	var platforms []string
+	for _, p := range strings.Split(platform, ",") {
+		p = strings.TrimSpace(p)
+		if parser.ValidPlatforms[p] {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// teamSQLSources lists a team's policies and queries for validateSQL.
+func teamSQLSources(policies []parser.ParsedPolicy, queries []parser.ParsedQuery) []sqlSource {
+	var sources []sqlSource
+	for _, p := range policies {
+		sources = append(sources, sqlSource{"policy", p.Name, p.Query, p.SourceFile, p.Platform})
+	}
+	for _, q := range queries {
+		sources = append(sources, sqlSource{"query", q.Name, q.Query, q.SourceFile, q.Platform})
+	}
+	return sources
+}
+
+// labelSQLSources lists dynamic labels for validateSQL. Manual labels have
+// no query.
+func labelSQLSources(labels []parser.ParsedLabel) []sqlSource {
+	var sources []sqlSource
+	for _, l := range labels {
+		sources = append(sources, sqlSource{"label", l.Name, l.Query, l.SourceFile, l.Platform})
+	}
+	return sources
+}
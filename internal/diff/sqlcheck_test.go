@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func TestValidateSQL(t *testing.T) {
+	tests := []struct {
+		name         string
+		sources      []sqlSource
+		changedFiles []string
+		wantContains []string
+	}{
+		{
+			name:    "valid SQL",
+			sources: []sqlSource{{"policy", "P", "SELECT 1 FROM os_version;", "teams/a.yml", ""}},
+		},
+		{
+			name:    "empty query is skipped",
+			sources: []sqlSource{{"label", "Manual", "", "default.yml", ""}},
+		},
+		{
+			name:         "syntax error names file and query line",
+			sources:      []sqlSource{{"policy", "P", "SELECT 1\nFORM os_version", "teams/a.yml", ""}},
+			wantContains: []string{`teams/a.yml: policy "P": invalid SQL at query line 2, column 6: near "os_version": syntax error`},
+		},
+		{
+			name:         "label without source file",
+			sources:      []sqlSource{{"label", "L", "SELECT", "", ""}},
+			wantContains: []string{`label "L": invalid SQL at query line 1, column 7: incomplete input`},
+		},
+		{
+			name: "changed files scope the check",
+			sources: []sqlSource{
+				{"query", "Q1", "SELEC 1", "/repo/teams/a.yml", ""},
+				{"query", "Q2", "SELEC 2", "/repo/teams/b.yml", ""},
+			},
+			changedFiles: []string{"teams/b.yml"},
+			wantContains: []string{`query "Q2"`},
+		},
+		{
+			name:         "table not available on the policy platform",
+			sources:      []sqlSource{{"policy", "FileVault", "SELECT 1 FROM apps", "teams/a.yml", "windows"}},
+			wantContains: []string{`teams/a.yml: policy "FileVault": table "apps" is not available on windows (available on: darwin) at query line 1, column 15`},
+		},
+		{
+			name:         "unknown table is a note suggesting the closest",
+			sources:      []sqlSource{{"query", "Q", "SELECT * FROM os_verison", "teams/a.yml", ""}},
+			wantContains: []string{`info: teams/a.yml: query "Q": table os_verison is not in the osquery schema, so it was not checked (did you mean "os_version"?) at query line 1, column 15`},
+		},
+		{
+			name:         "unknown column",
+			sources:      []sqlSource{{"label", "L", "SELECT 1 FROM os_version WHERE verison = '14'", "default.yml", "darwin"}},
+			wantContains: []string{`label "L": no such column: verison at query line 1, column 32`},
+		},
+		{
+			name:    "invalid platform names are left to platform validation",
+			sources: []sqlSource{{"policy", "P", "SELECT 1 FROM apps", "teams/a.yml", "darwin,macos"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateSQL(tt.sources, tt.changedFiles, nil, nil)
+			if len(got) != len(tt.wantContains) {
+				t.Fatalf("errors: got %v, want %d", got, len(tt.wantContains))
+			}
+			for i, want := range tt.wantContains {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("error[%d] = %q, want substring %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSQLMarksChanges(t *testing.T) {
+	policies := ResourceDiff{
+		Added:    []ResourceChange{{Name: "Broken"}},
+		Modified: []ResourceChange{{Name: "Fine", Fields: map[string]FieldDiff{"query": {Old: "a", New: "b"}}}},
+	}
+	queries := ResourceDiff{Added: []ResourceChange{{Name: "Broken"}}}
+	sources := []sqlSource{
+		{"policy", "Broken", "SELECT FROM", "teams/a.yml", ""},
+		{"policy", "Fine", "SELECT 1 FROM santa_statuz", "teams/a.yml", ""},
+		{"query", "Broken", "SELECT 1", "teams/a.yml", ""},
+	}
+
+	validateSQL(sources, nil, &policies, &queries)
+	if w := policies.Added[0].Warning; !strings.HasPrefix(w, "invalid SQL at query line 1") {
+		t.Errorf("added policy warning = %q", w)
+	}
+	if w := policies.Modified[0].Warning; w != "" {
+		t.Errorf("policy on a table missing from the schema marked: %q", w)
+	}
+	if w := queries.Added[0].Warning; w != "" {
+		t.Errorf("query sharing a policy's name marked: %q", w)
+	}
+}
+
+func TestDiffReportsInvalidSQL(t *testing.T) {
+	current := &api.FleetState{Teams: []api.Team{{ID: 1, Name: "T"}}}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name:     "T",
+		Policies: []parser.ParsedPolicy{{Name: "P", Query: "SELECT * FORM users", SourceFile: "teams/t.yml"}},
+	}}}
+
+	r := Diff(current, proposed, nil, nil)[0]
+	if len(r.Policies.Added) != 1 || !strings.Contains(r.Policies.Added[0].Warning, `near "FORM"`) {
+		t.Errorf("expected added policy marked invalid, got %+v", r.Policies.Added)
+	}
+	if len(r.Errors) != 1 || !strings.Contains(r.Errors[0], `teams/t.yml: policy "P": invalid SQL at query line 1, column 10`) {
+		t.Errorf("expected SQL error, got %v", r.Errors)
+	}
+}
+
+func TestDiffReportsPlatformMismatch(t *testing.T) {
+	current := &api.FleetState{Teams: []api.Team{{ID: 1, Name: "T"}}}
+	proposed := &parser.ParsedRepo{Teams: []parser.ParsedTeam{{
+		Name:     "T",
+		Policies: []parser.ParsedPolicy{{Name: "P", Query: "SELECT 1 FROM apps WHERE name = 'Zoom.app'", Platform: "windows", SourceFile: "teams/t.yml"}},
+	}}}
+
+	r := Diff(current, proposed, nil, nil)[0]
+	if len(r.Policies.Added) != 1 || !strings.Contains(r.Policies.Added[0].Warning, `table "apps" is not available on windows`) {
+		t.Errorf("expected added policy marked, got %+v", r.Policies.Added)
+	}
+	if len(r.Errors) != 1 || !strings.HasPrefix(r.Errors[0], `teams/t.yml: policy "P": table "apps"`) {
+		t.Errorf("expected platform error, got %v", r.Errors)
+	}
+}
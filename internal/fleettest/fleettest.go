@@ -0,0 +1,415 @@
+// Package fleettest provides an in-process fake Fleet server for end-to-end
+// tests. It serves an api.FleetState over every GET endpoint api.Client
+// uses, with Fleet's pagination, per-endpoint errors and file downloads, so
+// FetchAll (and the whole CLI) can run against known state without a real
+// server.
+package fleettest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+// DefaultUser is the token's user when WithUser isn't set: a global admin,
+// who can read everything.
+var DefaultUser = api.User{ID: 1, Name: "fleettest", Email: "fleettest@example.com", GlobalRole: ptr("admin"), APIOnly: true}
+
+// Server is a fake Fleet server. It listens on plain HTTP, so clients need
+// FLEET_PLAN_INSECURE=1. The state must not be modified while the server
+// runs.
+type Server struct {
+	*httptest.Server
+
+	state    *api.FleetState
+	token    string
+	user     api.User
+	hosts    []api.Host
+	statuses []statusRule
+
+	mu       sync.Mutex
+	requests []string
+}
+
+type statusRule struct {
+	pattern string
+	status  int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithToken makes the server answer 401 to requests without this bearer
+// token. By default any token is accepted.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithUser sets the user /me returns, and with it the token's roles.
+func WithUser(u api.User) Option {
+	return func(s *Server) {
+		s.user = u
+	}
+}
+
+// WithHosts adds hosts for /hosts/identifier lookups, in addition to the
+// members of manual labels in the state.
+func WithHosts(hosts ...api.Host) Option {
+	return func(s *Server) {
+		s.hosts = append(s.hosts, hosts...)
+	}
+}
+
+// WithStatus answers requests whose path matches any of the path.Match
+// patterns with status and a Fleet error body, e.g. 403 for
+// "/api/v1/fleet/software/titles*" to simulate a token without access to
+// software. Rules are checked in the order they were added.
+func WithStatus(status int, patterns ...string) Option {
+	return func(s *Server) {
+		for _, p := range patterns {
+			s.statuses = append(s.statuses, statusRule{pattern: p, status: status})
+		}
+	}
+}
+
+// NewServer starts a server for state. Callers must Close it.
+func NewServer(state *api.FleetState, opts ...Option) *Server {
+	s := &Server{state: state, user: DefaultUser}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Requests returns the requests served so far, as "GET /path?query" in
+// arrival order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	s.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "fleettest only serves GET requests")
+		return
+	}
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	for _, rule := range s.statuses {
+		if ok, _ := path.Match(rule.pattern, r.URL.Path); ok {
+			writeError(w, rule.status, http.StatusText(rule.status))
+			return
+		}
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/api/v1/fleet/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "Resource Not Found")
+		return
+	}
+	seg := strings.Split(rest, "/")
+	q := r.URL.Query()
+
+	switch {
+	case rest == "me":
+		writeJSON(w, map[string]any{"user": s.user})
+	case rest == "version":
+		writeJSON(w, map[string]any{"version": s.version()})
+	case rest == "config":
+		cfg := s.state.Config
+		if cfg == nil {
+			cfg = map[string]any{}
+		}
+		writeJSON(w, cfg)
+	case rest == "teams":
+		teams := make([]teamJSON, len(s.state.Teams))
+		for i, t := range s.state.Teams {
+			teams[i] = teamJSON{ID: t.ID, Name: t.Name, Software: t.Software}
+		}
+		items, _ := paginate(teams, q)
+		writeJSON(w, map[string]any{"teams": items})
+	case rest == "global/policies":
+		items, _ := paginate(s.state.GlobalPolicies, q)
+		writeJSON(w, map[string]any{"policies": items})
+	case len(seg) == 3 && seg[0] == "teams" && seg[2] == "policies":
+		s.withTeam(w, seg[1], func(t *api.Team) {
+			items, _ := paginate(t.Policies, q)
+			writeJSON(w, map[string]any{"policies": items})
+		})
+	case rest == "queries":
+		queries := s.state.GlobalQueries
+		if id := q.Get("team_id"); id != "" && id != "0" {
+			t := s.team(id)
+			if t == nil {
+				writeError(w, http.StatusNotFound, "team not found")
+				return
+			}
+			queries = t.Queries
+		}
+		items, _ := paginate(queries, q)
+		writeJSON(w, map[string]any{"queries": items})
+	case rest == "software/titles":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			items, more := paginate(t.SoftwareTitles, q)
+			writeJSON(w, map[string]any{"software_titles": items, "meta": meta(more)})
+		})
+	case len(seg) == 3 && seg[0] == "software" && seg[1] == "titles":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			detail, ok := titleDetail(t, seg[2])
+			if !ok {
+				writeError(w, http.StatusNotFound, "software title not found")
+				return
+			}
+			writeJSON(w, map[string]any{"software_title": detail})
+		})
+	case rest == "vpp_tokens":
+		writeJSON(w, map[string]any{"vpp_tokens": s.state.VPPTokens})
+	case rest == "software/app_store_apps":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			writeJSON(w, map[string]any{"app_store_apps": t.VPPApps})
+		})
+	case rest == "software/fleet_maintained_apps":
+		items, more := paginate(s.state.FleetMaintainedCatalog, q)
+		writeJSON(w, map[string]any{"fleet_maintained_apps": items, "meta": meta(more)})
+	case rest == "labels":
+		items, _ := paginate(s.state.Labels, q)
+		writeJSON(w, map[string]any{"labels": items})
+	case len(seg) == 3 && seg[0] == "labels" && seg[2] == "hosts":
+		label := s.label(seg[1])
+		if label == nil {
+			writeError(w, http.StatusNotFound, "label not found")
+			return
+		}
+		items, _ := paginate(label.Hosts, q)
+		writeJSON(w, map[string]any{"hosts": items})
+	case len(seg) == 3 && seg[0] == "hosts" && seg[1] == "identifier":
+		host := s.host(seg[2])
+		if host == nil {
+			writeError(w, http.StatusNotFound, "host not found")
+			return
+		}
+		writeJSON(w, map[string]any{"host": host})
+	case rest == "configuration_profiles":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			items, more := paginate(t.Profiles, q)
+			writeJSON(w, map[string]any{"profiles": items, "meta": meta(more)})
+		})
+	case len(seg) == 2 && seg[0] == "configuration_profiles" && q.Get("alt") == "media":
+		s.download(w, func(t *api.Team) (string, bool) {
+			for _, p := range t.Profiles {
+				if p.ProfileUUID == seg[1] {
+					return p.Content, true
+				}
+			}
+			return "", false
+		})
+	case rest == "mdm/apple/profiles":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			profiles := []map[string]any{}
+			for _, p := range t.Profiles {
+				id, err := strconv.ParseUint(p.ProfileUUID, 10, 64)
+				if p.Platform == "darwin" && err == nil {
+					profiles = append(profiles, map[string]any{"profile_id": id, "name": p.Name})
+				}
+			}
+			writeJSON(w, map[string]any{"profiles": profiles})
+		})
+	case rest == "scripts":
+		s.withTeam(w, q.Get("team_id"), func(t *api.Team) {
+			items, more := paginate(t.Scripts, q)
+			writeJSON(w, map[string]any{"scripts": items, "meta": meta(more)})
+		})
+	case len(seg) == 2 && seg[0] == "scripts" && q.Get("alt") == "media":
+		s.download(w, func(t *api.Team) (string, bool) {
+			for _, sc := range t.Scripts {
+				if strconv.FormatUint(uint64(sc.ID), 10) == seg[1] {
+					return sc.Content, true
+				}
+			}
+			return "", false
+		})
+	default:
+		writeError(w, http.StatusNotFound, "Resource Not Found")
+	}
+}
+
+// teamJSON is a team as /teams returns it. api.Team's other fields are
+// filled from their own endpoints.
+type teamJSON struct {
+	ID       uint             `json:"id"`
+	Name     string           `json:"name"`
+	Software api.TeamSoftware `json:"software"`
+}
+
+// version is the release /version reports: the state's, or one newer than
+// every feature in the client's compatibility table.
+func (s *Server) version() string {
+	if v := s.state.ServerVersion; v != (api.ServerVersion{}) {
+		return v.String()
+	}
+	return "4.70.0"
+}
+
+// team returns the team with the given ID; "" and "0" are "No team". It
+// returns nil for unknown teams.
+func (s *Server) team(id string) *api.Team {
+	if id == "" || id == "0" {
+		if s.state.NoTeam != nil {
+			return s.state.NoTeam
+		}
+		return &api.Team{Name: parser.NoTeamName}
+	}
+	for i := range s.state.Teams {
+		if strconv.FormatUint(uint64(s.state.Teams[i].ID), 10) == id {
+			return &s.state.Teams[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) withTeam(w http.ResponseWriter, id string, fn func(*api.Team)) {
+	t := s.team(id)
+	if t == nil {
+		writeError(w, http.StatusNotFound, "team not found")
+		return
+	}
+	fn(t)
+}
+
+// download serves the first file find returns across every team, as the
+// ?alt=media endpoints do.
+func (s *Server) download(w http.ResponseWriter, find func(*api.Team) (string, bool)) {
+	teams := make([]*api.Team, 0, len(s.state.Teams)+1)
+	for i := range s.state.Teams {
+		teams = append(teams, &s.state.Teams[i])
+	}
+	if s.state.NoTeam != nil {
+		teams = append(teams, s.state.NoTeam)
+	}
+	for _, t := range teams {
+		if content, ok := find(t); ok {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte(content))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "file not found")
+}
+
+func (s *Server) label(id string) *api.Label {
+	for i := range s.state.Labels {
+		if strconv.FormatUint(uint64(s.state.Labels[i].ID), 10) == id {
+			return &s.state.Labels[i]
+		}
+	}
+	return nil
+}
+
+// host finds a host by serial, hostname or UUID among WithHosts and the
+// members of manual labels.
+func (s *Server) host(identifier string) *api.Host {
+	for i := range s.hosts {
+		if s.hosts[i].Matches(identifier) {
+			return &s.hosts[i]
+		}
+	}
+	for _, l := range s.state.Labels {
+		for i := range l.Hosts {
+			if l.Hosts[i].Matches(identifier) {
+				return &l.Hosts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// titleDetail builds the title detail response from the team's title list.
+// Install scripts come from the team's fleet-maintained apps with a
+// matching TitleID.
+func titleDetail(t *api.Team, id string) (*api.SoftwareTitleDetail, bool) {
+	for _, title := range t.SoftwareTitles {
+		if strconv.FormatUint(uint64(title.ID), 10) != id {
+			continue
+		}
+		detail := &api.SoftwareTitleDetail{ID: title.ID, Name: title.Name, AppStoreApp: title.AppStoreApp}
+		if pkg := title.SoftwarePackage; pkg != nil {
+			detail.SoftwarePackage = &api.SoftwareTitleDetailPackage{
+				SelfService:          pkg.SelfService,
+				Platform:             pkg.Platform,
+				FleetMaintainedAppID: pkg.FleetMaintainedAppID,
+			}
+			for _, app := range t.Software.FleetMaintained {
+				if app.TitleID == title.ID {
+					detail.SoftwarePackage.InstallScript = app.InstallScript
+					detail.SoftwarePackage.UninstallScript = app.UninstallScript
+					detail.SoftwarePackage.PreInstallQuery = app.PreInstallQuery
+					detail.SoftwarePackage.PostInstallScript = app.PostInstallScript
+				}
+			}
+		}
+		return detail, true
+	}
+	return nil, false
+}
+
+// paginate returns the page of items the page and per_page parameters ask
+// for, and whether more follow. Without per_page every item is returned.
+func paginate[T any](items []T, q url.Values) ([]T, bool) {
+	perPage, err := strconv.Atoi(q.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		return nonNil(items), false
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+	start := min(max(page, 0)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	return nonNil(items[start:end]), end < len(items)
+}
+
+// nonNil keeps empty lists as [] rather than null, as Fleet sends them.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+func meta(hasNext bool) map[string]bool {
+	return map[string]bool{"has_next_results": hasNext, "has_previous_results": false}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in Fleet's format.
+func writeError(w http.ResponseWriter, status int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"message": reason,
+		"errors":  []map[string]string{{"name": "base", "reason": reason}},
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
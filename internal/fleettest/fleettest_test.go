@@ -0,0 +1,220 @@
+package fleettest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/parser"
+)
+
+func fixtureState() *api.FleetState {
+	catalogID := uint(3)
+	return &api.FleetState{
+		Config:         map[string]any{"org_info": map[string]any{"org_name": "Example"}},
+		GlobalPolicies: []api.Policy{{ID: 1, Name: "Global policy", Query: "SELECT 1;"}},
+		GlobalQueries:  []api.Query{{ID: 1, Name: "Uptime", Query: "SELECT * FROM uptime;", Interval: 3600}},
+		Labels: []api.Label{
+			{ID: 1, Name: "macOS 14+", LabelType: "regular", LabelMembershipType: "dynamic", HostCount: 12},
+			{ID: 2, Name: "Kiosks", LabelType: "regular", LabelMembershipType: "manual",
+				Hosts: []api.Host{{ID: 7, Hostname: "kiosk-01", HardwareSerial: "C02KIOSK1"}}},
+		},
+		FleetMaintainedCatalog: []api.FleetMaintainedApp{{ID: catalogID, SoftwareTitleID: 20, Slug: "cursor/windows", Name: "Cursor", Platform: "windows"}},
+		VPPTokens:              []api.VPPToken{{ID: 1, OrgName: "Example", Teams: []api.VPPTokenTeam{{TeamID: 1, Name: "Workstations"}}}},
+		Teams: []api.Team{{
+			ID:   1,
+			Name: "Workstations",
+			Software: api.TeamSoftware{
+				FleetMaintained: []api.TeamFleetApp{{Slug: "cursor/windows", TitleID: 20, InstallScript: "install-cursor.ps1"}},
+			},
+			Policies: []api.Policy{{ID: 2, Name: "FileVault", Query: "SELECT 1;", Platform: "darwin", Critical: true}},
+			Queries:  []api.Query{{ID: 2, Name: "Disk usage", Query: "SELECT * FROM mounts;", Interval: 600}},
+			Profiles: []api.Profile{
+				{ProfileUUID: "a1", Name: "FileVault", Platform: "darwin"},
+				{ProfileUUID: "w1", Name: "Defender", Platform: "windows", Content: "<Replace>...</Replace>"},
+			},
+			Scripts: []api.Script{{ID: 4, Name: "cleanup.sh", TeamID: 1, Content: "#!/bin/sh\necho hi"}},
+			SoftwareTitles: []api.SoftwareTitle{
+				{ID: 20, Name: "Cursor", HostCount: 3, SoftwarePackage: &api.SoftwareTitlePackageMeta{Name: "cursor.exe", Platform: "windows", FleetMaintainedAppID: &catalogID}},
+				{ID: 21, Name: "Xcode", AppStoreApp: &api.SoftwareTitleAppStore{AppStoreID: "497799835", Platform: "darwin", Categories: []string{"Developer tools"}}},
+			},
+			VPPApps: []api.VPPApp{{AppStoreID: "497799835", Name: "Xcode", Platform: "darwin"}},
+		}},
+		NoTeam: &api.Team{
+			Name:     parser.NoTeamName,
+			Policies: []api.Policy{{ID: 3, Name: "Unassigned", Query: "SELECT 1;"}},
+			Scripts:  []api.Script{{ID: 5, Name: "enroll.sh", Content: "echo enroll"}},
+		},
+	}
+}
+
+var fullScope = api.FetchScope{AllTeams: true, Global: true, Catalog: true, LabelHosts: true}
+
+func TestServerFetchAll(t *testing.T) {
+	many := fixtureState()
+	for i := range 600 {
+		many.Labels = append(many.Labels, api.Label{ID: uint(100 + i), Name: fmt.Sprintf("Label %d", i)})
+	}
+	for i := range 300 {
+		many.Teams[0].SoftwareTitles = append(many.Teams[0].SoftwareTitles, api.SoftwareTitle{ID: uint(1000 + i), Name: fmt.Sprintf("App %d", i)})
+	}
+	observer := api.User{Email: "team@example.com", Teams: []api.UserTeam{{ID: 1, Role: "observer"}}}
+
+	tests := []struct {
+		name  string
+		state *api.FleetState
+		opts  []Option
+		check func(t *testing.T, want, got *api.FleetState, srv *Server)
+	}{
+		{
+			name:  "round trip",
+			state: fixtureState(),
+			check: func(t *testing.T, want, got *api.FleetState, _ *Server) {
+				if !reflect.DeepEqual(got.Config, want.Config) {
+					t.Errorf("config = %v, want %v", got.Config, want.Config)
+				}
+				equal(t, "global policies", got.GlobalPolicies, want.GlobalPolicies)
+				equal(t, "global queries", got.GlobalQueries, want.GlobalQueries)
+				equal(t, "catalog", got.FleetMaintainedCatalog, want.FleetMaintainedCatalog)
+				equal(t, "VPP tokens", got.VPPTokens, want.VPPTokens)
+				equal(t, "Kiosks hosts", got.Labels[1].Hosts, want.Labels[1].Hosts)
+
+				team, wantTeam := got.Teams[0], want.Teams[0]
+				equal(t, "policies", team.Policies, wantTeam.Policies)
+				equal(t, "queries", team.Queries, wantTeam.Queries)
+				equal(t, "profiles", team.Profiles, wantTeam.Profiles)
+				equal(t, "scripts", team.Scripts, wantTeam.Scripts)
+				equal(t, "software titles", team.SoftwareTitles, wantTeam.SoftwareTitles)
+				equal(t, "VPP apps", team.VPPApps, wantTeam.VPPApps)
+				equal(t, "no team policies", got.NoTeam.Policies, want.NoTeam.Policies)
+				equal(t, "no team scripts", got.NoTeam.Scripts, want.NoTeam.Scripts)
+			},
+		},
+		{
+			name:  "pagination",
+			state: many,
+			check: func(t *testing.T, want, got *api.FleetState, srv *Server) {
+				if len(got.Labels) != len(want.Labels) {
+					t.Errorf("got %d labels, want %d", len(got.Labels), len(want.Labels))
+				}
+				if n, wantN := len(got.Teams[0].SoftwareTitles), len(want.Teams[0].SoftwareTitles); n != wantN {
+					t.Errorf("got %d software titles, want %d", n, wantN)
+				}
+				if !slices.ContainsFunc(srv.Requests(), func(r string) bool {
+					return strings.HasPrefix(r, "GET /api/v1/fleet/labels?") && strings.Contains(r, "page=2")
+				}) {
+					t.Error("labels were not fetched in pages")
+				}
+			},
+		},
+		{
+			name:  "permission errors",
+			state: fixtureState(),
+			opts:  []Option{WithStatus(http.StatusForbidden, "/api/v1/fleet/software/titles", "/api/v1/fleet/scripts")},
+			check: func(t *testing.T, _, got *api.FleetState, _ *Server) {
+				team := got.Teams[0]
+				if !team.SoftwareUnavailable || !team.ScriptsUnavailable || team.ProfilesUnavailable {
+					t.Errorf("unavailable: software %v, scripts %v, profiles %v; want true, true, false",
+						team.SoftwareUnavailable, team.ScriptsUnavailable, team.ProfilesUnavailable)
+				}
+			},
+		},
+		{
+			name:  "team user",
+			state: fixtureState(),
+			opts:  []Option{WithUser(observer)},
+			check: func(t *testing.T, _, got *api.FleetState, srv *Server) {
+				if len(got.Teams) != 1 || got.NoTeam != nil || !got.VPPTokensUnavailable {
+					t.Errorf("teams %d, no team %v, VPP tokens unavailable %v; want 1, nil, true", len(got.Teams), got.NoTeam, got.VPPTokensUnavailable)
+				}
+				if slices.ContainsFunc(srv.Requests(), func(r string) bool { return strings.Contains(r, "/vpp_tokens") }) {
+					t.Error("requested /vpp_tokens without a global role")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(tt.state, tt.opts...)
+			defer srv.Close()
+			c := newClient(t, srv, "tok")
+
+			caps, err := c.GetCapabilities(context.Background())
+			if err != nil {
+				t.Fatalf("GetCapabilities: %v", err)
+			}
+			scope := fullScope
+			scope.Capabilities = caps
+			got, err := c.FetchAll(context.Background(), scope)
+			if err != nil {
+				t.Fatalf("FetchAll: %v", err)
+			}
+			tt.check(t, tt.state, got, srv)
+		})
+	}
+}
+
+func TestServerLookups(t *testing.T) {
+	srv := NewServer(fixtureState(),
+		WithToken("tok"),
+		WithHosts(api.Host{ID: 9, Hostname: "mbp-01", HardwareSerial: "C02ABC", Labels: []api.HostLabel{{ID: 1, Name: "macOS 14+"}}}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := newClient(t, srv, "tok")
+
+	tests := []struct {
+		name       string
+		identifier string
+		wantID     uint // 0 for no host
+	}{
+		{name: "extra host", identifier: "C02ABC", wantID: 9},
+		{name: "label member", identifier: "kiosk-01", wantID: 7},
+		{name: "unknown", identifier: "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, err := c.GetHostByIdentifier(ctx, tt.identifier)
+			if err != nil {
+				t.Fatalf("GetHostByIdentifier: %v", err)
+			}
+			var gotID uint
+			if host != nil {
+				gotID = host.ID
+			}
+			if gotID != tt.wantID {
+				t.Errorf("host ID = %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+
+	detail, err := c.GetSoftwareTitleDetail(ctx, 20, 1)
+	if err != nil || detail.SoftwarePackage == nil || detail.SoftwarePackage.InstallScript != "install-cursor.ps1" {
+		t.Errorf("title detail = %+v, %v; want the fleet-maintained app's install script", detail, err)
+	}
+
+	if _, err := newClient(t, srv, "wrong").GetCapabilities(ctx); err == nil || !strings.Contains(err.Error(), "API token rejected") {
+		t.Errorf("wrong token: err = %v, want it rejected", err)
+	}
+}
+
+func newClient(t *testing.T, srv *Server, token string) *api.Client {
+	t.Helper()
+	t.Setenv("FLEET_PLAN_INSECURE", "1")
+	c, err := api.NewClient(srv.URL, token)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func equal[T any](t *testing.T, what string, got, want T) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %+v, want %+v", what, got, want)
+	}
+}
@@ -3,6 +3,7 @@
 package git
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -153,10 +154,10 @@ func (e Env) JobURL() string {
 
 // ChangedFiles returns the list of files changed in the MR/PR.
 // Priority: MR/PR API > git diff > empty (triggers full diff).
-func (e Env) ChangedFiles() ([]string, error) {
+func (e Env) ChangedFiles(ctx context.Context) ([]string, error) {
 	switch e.Platform {
 	case PlatformGitLab:
-		files, err := e.gitLabChangedFiles()
+		files, err := e.gitLabChangedFiles(ctx)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: GitLab MR API unavailable (%v), falling back to git diff\n", err)
 		} else {
@@ -164,7 +165,7 @@ func (e Env) ChangedFiles() ([]string, error) {
 			return files, nil
 		}
 	case PlatformGitHub:
-		files, err := e.gitHubChangedFiles()
+		files, err := e.gitHubChangedFiles(ctx)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: GitHub PR API unavailable (%v), falling back to git diff\n", err)
 		} else {
@@ -173,10 +174,10 @@ func (e Env) ChangedFiles() ([]string, error) {
 		}
 	}
 
-	return e.gitDiffChangedFiles()
+	return e.gitDiffChangedFiles(ctx)
 }
 
-func (e Env) gitLabChangedFiles() ([]string, error) {
+func (e Env) gitLabChangedFiles(ctx context.Context) ([]string, error) {
 	if err := e.gitLabReady(); err != nil {
 		return nil, err
 	}
@@ -184,7 +185,7 @@ func (e Env) gitLabChangedFiles() ([]string, error) {
 	apiURL := fmt.Sprintf("%s/projects/%s/merge_requests/%s/changes?per_page=100",
 		e.GitLabAPIURL, url.PathEscape(e.GitLabProjectID), url.PathEscape(e.GitLabMRIID))
 
-	body, err := doRequest("GET", apiURL, nil, map[string]string{"PRIVATE-TOKEN": e.GitLabToken})
+	body, err := doRequest(ctx, "GET", apiURL, nil, map[string]string{"PRIVATE-TOKEN": e.GitLabToken})
 	if err != nil {
 		return nil, fmt.Errorf("GitLab: %w", err)
 	}
@@ -212,14 +213,14 @@ func (e Env) gitLabChangedFiles() ([]string, error) {
 	return files, nil
 }
 
-func (e Env) gitHubChangedFiles() ([]string, error) {
+func (e Env) gitHubChangedFiles(ctx context.Context) ([]string, error) {
 	if err := e.gitHubReady(); err != nil {
 		return nil, err
 	}
 	// NOTE: pagination is not implemented; only the first 100 changed files are returned.
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls/%s/files?per_page=100", e.GitHubAPIURL, e.GitHubRepo, e.GitHubPRNumber)
 
-	body, err := doRequest("GET", apiURL, nil, githubHeaders(e.GitHubToken))
+	body, err := doRequest(ctx, "GET", apiURL, nil, githubHeaders(e.GitHubToken))
 	if err != nil {
 		return nil, fmt.Errorf("GitHub: %w", err)
 	}
@@ -240,16 +241,16 @@ func (e Env) gitHubChangedFiles() ([]string, error) {
 	return files, nil
 }
 
-func (e Env) gitDiffChangedFiles() ([]string, error) {
+func (e Env) gitDiffChangedFiles(ctx context.Context) ([]string, error) {
 	// Try to fetch the target branch if needed.
 	if e.TargetBranch != "" && validBranch.MatchString(e.TargetBranch) && !strings.Contains(e.TargetBranch, "..") {
-		_ = exec.Command("git", "fetch", "origin", "--depth=200", "--", e.TargetBranch).Run()
+		_ = exec.CommandContext(ctx, "git", "fetch", "origin", "--depth=200", "--", e.TargetBranch).Run()
 	}
 
 	var ref string
 	if e.DiffBaseSHA != "" && validSHA.MatchString(e.DiffBaseSHA) {
 		// Verify the commit is available.
-		if err := exec.Command("git", "cat-file", "-e", e.DiffBaseSHA+"^{commit}").Run(); err == nil {
+		if err := exec.CommandContext(ctx, "git", "cat-file", "-e", e.DiffBaseSHA+"^{commit}").Run(); err == nil {
 			ref = e.DiffBaseSHA + "...HEAD"
 		}
 	}
@@ -260,7 +261,7 @@ func (e Env) gitDiffChangedFiles() ([]string, error) {
 		return nil, fmt.Errorf("no base SHA or target branch available for git diff")
 	}
 
-	out, err := exec.Command("git", "diff", "--name-only", "--", ref).Output()
+	out, err := exec.CommandContext(ctx, "git", "diff", "--name-only", "--", ref).Output()
 	if err != nil {
 		return nil, fmt.Errorf("git diff: %w", err)
 	}
@@ -277,17 +278,17 @@ func (e Env) gitDiffChangedFiles() ([]string, error) {
 // PostOrUpdateComment posts or idempotently updates an MR/PR comment containing marker.
 // If a comment with marker already exists it is replaced; otherwise a new one is created.
 // Returns the comment URL on success.
-func (e Env) PostOrUpdateComment(body, marker string) (string, error) {
+func (e Env) PostOrUpdateComment(ctx context.Context, body, marker string) (string, error) {
 	switch e.Platform {
 	case PlatformGitLab:
-		return e.gitLabPostOrUpdate(body, marker)
+		return e.gitLabPostOrUpdate(ctx, body, marker)
 	case PlatformGitHub:
-		return e.gitHubPostOrUpdate(body, marker)
+		return e.gitHubPostOrUpdate(ctx, body, marker)
 	}
 	return "", fmt.Errorf("unknown CI platform")
 }
 
-func (e Env) gitLabPostOrUpdate(body, marker string) (string, error) {
+func (e Env) gitLabPostOrUpdate(ctx context.Context, body, marker string) (string, error) {
 	if err := e.gitLabReady(); err != nil {
 		return "", fmt.Errorf("skipping MR note: %w", err)
 	}
@@ -297,7 +298,7 @@ func (e Env) gitLabPostOrUpdate(body, marker string) (string, error) {
 		e.GitLabAPIURL, url.PathEscape(e.GitLabProjectID), url.PathEscape(e.GitLabMRIID))
 
 	listURL := notesURL + "?per_page=100&sort=desc&order_by=updated_at"
-	_, method, reqURL, err := findThenRoute(notesURL, listURL, headers, marker, "PUT")
+	_, method, reqURL, err := findThenRoute(ctx, notesURL, listURL, headers, marker, "PUT")
 	if err != nil {
 		return "", fmt.Errorf("listing MR notes: %w", err)
 	}
@@ -310,7 +311,7 @@ func (e Env) gitLabPostOrUpdate(body, marker string) (string, error) {
 		"PRIVATE-TOKEN": e.GitLabToken,
 		"Content-Type":  "application/x-www-form-urlencoded",
 	}
-	respBody, err := doRequest(method, reqURL, strings.NewReader(encoded), writeHeaders)
+	respBody, err := doRequest(ctx, method, reqURL, strings.NewReader(encoded), writeHeaders)
 	if err != nil {
 		return "", err
 	}
@@ -328,7 +329,7 @@ func (e Env) gitLabPostOrUpdate(body, marker string) (string, error) {
 	return commentURL, nil
 }
 
-func (e Env) gitHubPostOrUpdate(body, marker string) (string, error) {
+func (e Env) gitHubPostOrUpdate(ctx context.Context, body, marker string) (string, error) {
 	if err := e.gitHubReady(); err != nil {
 		return "", fmt.Errorf("skipping PR comment: %w", err)
 	}
@@ -338,7 +339,7 @@ func (e Env) gitHubPostOrUpdate(body, marker string) (string, error) {
 		e.GitHubAPIURL, e.GitHubRepo, e.GitHubPRNumber)
 
 	listURL := commentsURL + "?per_page=100"
-	commentID, method, reqURL, err := findThenRoute(commentsURL, listURL, headers, marker, "PATCH")
+	commentID, method, reqURL, err := findThenRoute(ctx, commentsURL, listURL, headers, marker, "PATCH")
 	if err != nil {
 		return "", fmt.Errorf("listing PR comments: %w", err)
 	}
@@ -351,7 +352,7 @@ func (e Env) gitHubPostOrUpdate(body, marker string) (string, error) {
 
 	writeHeaders := githubHeaders(e.GitHubToken)
 	writeHeaders["Content-Type"] = "application/json"
-	respBody, err := doRequest(method, reqURL, strings.NewReader(string(payload)), writeHeaders)
+	respBody, err := doRequest(ctx, method, reqURL, strings.NewReader(string(payload)), writeHeaders)
 	if err != nil {
 		return "", err
 	}
@@ -373,11 +374,11 @@ func (e Env) gitHubPostOrUpdate(body, marker string) (string, error) {
 // doRequest performs an HTTP request with the given method, body, and headers.
 // Response body is limited to maxResponseBody bytes.
 // Returns the response body or an error if the status code is >= 300.
-func doRequest(method, reqURL string, body io.Reader, headers map[string]string) ([]byte, error) {
+func doRequest(ctx context.Context, method, reqURL string, body io.Reader, headers map[string]string) ([]byte, error) {
 	if os.Getenv("FLEET_PLAN_INSECURE") != "1" && !strings.HasPrefix(strings.ToLower(reqURL), "https://") {
 		return nil, fmt.Errorf("refusing API request to non-HTTPS URL: %s", reqURL)
 	}
-	req, err := http.NewRequest(method, reqURL, body)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return nil, err
 	}
@@ -409,8 +410,8 @@ func doRequest(method, reqURL string, body io.Reader, headers map[string]string)
 //
 // NOTE: only the first page of 100 comments is searched. Pagination is not
 // implemented; MRs/PRs with more than 100 comments may create a duplicate.
-func findThenRoute(baseURL, listURL string, headers map[string]string, marker, updateMethod string) (string, string, string, error) {
-	body, err := doRequest("GET", listURL, nil, headers)
+func findThenRoute(ctx context.Context, baseURL, listURL string, headers map[string]string, marker, updateMethod string) (string, string, string, error) {
+	body, err := doRequest(ctx, "GET", listURL, nil, headers)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -0,0 +1,56 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPostOrUpdateCommentContext(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`{"html_url": "https://github.example.com/o/r/pull/7#issuecomment-1"}`))
+	}))
+	defer ts.Close()
+	t.Setenv("FLEET_PLAN_INSECURE", "1")
+
+	env := Env{
+		Platform:       PlatformGitHub,
+		GitHubAPIURL:   ts.URL,
+		GitHubRepo:     "o/r",
+		GitHubPRNumber: "7",
+		GitHubToken:    "tok",
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantErr      error
+		wantRequests int32
+	}{
+		{name: "posts", ctx: context.Background(), wantRequests: 2},
+		{name: "cancelled", ctx: cancelled, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			_, err := env.PostOrUpdateComment(tt.ctx, "plan", "fleet-plan-marker")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/TsekNet/fleet-plan/internal/parser"
 )
 
 // fleetResourcePrefixes lists the directory prefixes for fleet-managed resources.
@@ -130,7 +132,8 @@ func teamsReferencingAny(root string, patterns []string) []string {
 	return names
 }
 
-// readTeamName extracts the name field from a team YAML file.
+// readTeamName extracts the name field from a team YAML file. no-team.yml
+// defaults to "No team" when it has no name.
 func readTeamName(path string) string {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -142,5 +145,8 @@ func readTeamName(path string) string {
 	if err := yaml.Unmarshal(data, &t); err != nil {
 		return ""
 	}
+	if parser.IsNoTeamFile(path) {
+		return parser.NoTeamName
+	}
 	return t.Name
 }
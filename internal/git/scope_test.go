@@ -107,6 +107,17 @@ func TestResolveScope(t *testing.T) {
 			wantChanged:   []string{"teams/infra.yml"},
 			wantTeamCount: 1,
 		},
+		{
+			name: "no-team.yml without name resolves to No team",
+			setup: func(t *testing.T, root string) {
+				os.MkdirAll(filepath.Join(root, "teams"), 0o755)
+				os.WriteFile(filepath.Join(root, "teams", "no-team.yml"),
+					[]byte("policies:\n  - path: ../policies/onboarding.yml\n"), 0o644)
+			},
+			changedFiles:  []string{"policies/onboarding.yml"},
+			wantTeams:     []string{"No team"},
+			wantTeamCount: 1,
+		},
 		{
 			name: "script change finds referencing team",
 			setup: func(t *testing.T, root string) {
@@ -0,0 +1,252 @@
+// Package osquery checks osquery SQL before it reaches hosts: a parser for
+// the SQLite dialect osquery runs, used to catch syntax errors in policies,
+// queries and labels at plan time, and an embedded table schema to catch
+// unknown tables and columns and tables missing on a target platform.
+package osquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokIdent             // bare or quoted identifier
+	tokKeyword           // reserved word; text is upper-cased
+	tokString            // 'single quoted'
+	tokNumber            // integer, decimal, hex
+	tokBlob              // X'ABCD'
+	tokParam             // ?, ?1, :name, @name, $name
+	tokOp                // operators and punctuation
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // keywords upper-cased, quoted identifiers unquoted
+	raw    string // source text, for error messages
+	quoted bool   // identifier was "double quoted", [bracketed] or `backticked`
+	line   int
+	col    int
+}
+
+// reserved are the keywords that cannot appear as bare identifiers in the
+// positions this parser accepts them. SQLite falls back to treating most
+// other keywords as identifiers, so they are lexed as tokIdent and matched
+// by text where the grammar needs them (ASC, OVER, RECURSIVE, ...).
+var reserved = map[string]bool{
+	"ALL": true, "AND": true, "AS": true, "BETWEEN": true, "BY": true,
+	"CASE": true, "CAST": true, "COLLATE": true, "CROSS": true, "DISTINCT": true,
+	"ELSE": true, "END": true, "ESCAPE": true, "EXCEPT": true, "EXISTS": true,
+	"FROM": true, "FULL": true, "GLOB": true, "GROUP": true, "HAVING": true,
+	"IN": true, "INNER": true, "INTERSECT": true, "IS": true, "ISNULL": true,
+	"JOIN": true, "LEFT": true, "LIKE": true, "LIMIT": true, "MATCH": true,
+	"NATURAL": true, "NOT": true, "NOTNULL": true, "NULL": true, "OFFSET": true,
+	"ON": true, "OR": true, "ORDER": true, "OUTER": true, "REGEXP": true,
+	"RIGHT": true, "SELECT": true, "THEN": true, "UNION": true, "USING": true,
+	"VALUES": true, "WHEN": true, "WHERE": true, "WINDOW": true, "WITH": true,
+}
+
+// operators lists multi-character operators longest first so the lexer
+// matches greedily.
+var operators = []string{
+	"->>", "->", "||", "<<", ">>", "<=", ">=", "==", "!=", "<>",
+	"<", ">", "=", "+", "-", "*", "/", "%", "&", "|", "~", "(", ")", ",", ";", ".",
+}
+
+// lex splits sql into tokens, ending with a tokEOF. Lines and columns are
+// 1-based and counted within sql.
+func lex(sql string) ([]token, error) {
+	var toks []token
+	line, col := 1, 1
+	i := 0
+
+	advance := func(n int) {
+		for _, r := range sql[i : i+n] {
+			if r == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+		}
+		i += n
+	}
+
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			advance(1)
+			continue
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			advance(end)
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				// SQLite accepts an unterminated block comment at end of input.
+				advance(len(sql) - i)
+				continue
+			}
+			advance(end + 4)
+			continue
+		}
+
+		start, startLine, startCol := i, line, col
+		emit := func(kind tokenKind, text string, quoted bool) {
+			toks = append(toks, token{kind: kind, text: text, raw: sql[start:i], quoted: quoted, line: startLine, col: startCol})
+		}
+
+		switch {
+		case c == '\'':
+			s, n, ok := scanQuoted(sql[i:], '\'')
+			if !ok {
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: "unrecognized token: unterminated string"}
+			}
+			advance(n)
+			emit(tokString, s, false)
+
+		case c == '"' || c == '`':
+			s, n, ok := scanQuoted(sql[i:], c)
+			if !ok {
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: "unrecognized token: unterminated identifier"}
+			}
+			advance(n)
+			emit(tokIdent, s, true)
+
+		case c == '[':
+			end := strings.IndexByte(sql[i:], ']')
+			if end < 0 {
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: "unrecognized token: unterminated identifier"}
+			}
+			advance(end + 1)
+			emit(tokIdent, sql[start+1:i-1], true)
+
+		case (c == 'x' || c == 'X') && i+1 < len(sql) && sql[i+1] == '\'':
+			_, n, ok := scanQuoted(sql[i+1:], '\'')
+			if !ok {
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: "unrecognized token: unterminated blob"}
+			}
+			advance(n + 1)
+			emit(tokBlob, sql[start:i], false)
+
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			advance(scanNumber(sql[i:]))
+			if i < len(sql) && isIdentChar(sql[i]) {
+				for i < len(sql) && isIdentChar(sql[i]) {
+					advance(1)
+				}
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: fmt.Sprintf("unrecognized token: %q", sql[start:i])}
+			}
+			emit(tokNumber, sql[start:i], false)
+
+		case isIdentStart(c):
+			for i < len(sql) && isIdentChar(sql[i]) {
+				advance(1)
+			}
+			word := sql[start:i]
+			if upper := strings.ToUpper(word); reserved[upper] {
+				emit(tokKeyword, upper, false)
+			} else {
+				emit(tokIdent, word, false)
+			}
+
+		case c == '?':
+			advance(1)
+			for i < len(sql) && isDigit(sql[i]) {
+				advance(1)
+			}
+			emit(tokParam, sql[start:i], false)
+
+		case (c == ':' || c == '@' || c == '$') && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			advance(1)
+			for i < len(sql) && isIdentChar(sql[i]) {
+				advance(1)
+			}
+			emit(tokParam, sql[start:i], false)
+
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(sql[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Line: startLine, Column: startCol, Message: fmt.Sprintf("unrecognized token: %q", string(c))}
+			}
+			advance(len(op))
+			emit(tokOp, op, false)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, line: line, col: col})
+	return toks, nil
+}
+
+// scanQuoted scans a quoted string or identifier starting at s[0] == q, where
+// a doubled quote is an escaped quote. Returns the unquoted text and the
+// number of bytes consumed.
+func scanQuoted(s string, q byte) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != q {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			b.WriteByte(q)
+			i++
+			continue
+		}
+		return b.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+// scanNumber returns the length of the numeric literal at the start of s.
+func scanNumber(s string) int {
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') && isHexDigit(s[2]) {
+		i := 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+		return i
+	}
+	i := 0
+	for i < len(s) && (isDigit(s[i]) || s[i] == '_') {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool    { return c >= '0' && c <= '9' }
+func isHexDigit(c byte) bool { return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+func isIdentChar(c byte) bool { return isIdentStart(c) || isDigit(c) || c == '$' }
@@ -0,0 +1,812 @@
+package osquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is a parse failure at a position within the query text.
+type SyntaxError struct {
+	Line    int // 1-based line within the query
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Query is a parsed osquery SQL string: one or more SELECT statements.
+type Query struct {
+	// Selects holds every SELECT core in the query (compound members,
+	// subqueries, CTE bodies) in source order.
+	Selects []*Select
+	// CTEs holds the lower-cased names defined by WITH clauses.
+	CTEs map[string]bool
+}
+
+// Select is one SELECT core and the names it references.
+type Select struct {
+	Parent  *Select // enclosing SELECT for subqueries, nil at top level
+	Tables  []TableRef
+	Columns []ColumnRef
+	// Aliases holds lower-cased result-column aliases, which ORDER BY,
+	// GROUP BY and HAVING may reference like columns.
+	Aliases map[string]bool
+	// Derived holds lower-cased aliases of FROM-clause subqueries; an
+	// unaliased subquery is recorded under "".
+	Derived map[string]bool
+}
+
+// TableRef is a table or table-valued function in a FROM clause.
+type TableRef struct {
+	Name     string
+	Alias    string
+	Function bool // table-valued function call, e.g. json_each(...)
+	Line     int
+	Column   int
+}
+
+// ColumnRef is a column referenced in an expression.
+type ColumnRef struct {
+	Table  string // qualifier, empty when unqualified
+	Name   string
+	Quoted bool // "quoted": SQLite falls back to a string literal if no column matches
+	// Constraint is set for references in WHERE or JOIN ... ON, which
+	// osquery can push down to a table as a lookup constraint.
+	Constraint bool
+	Line       int
+	Column     int
+}
+
+// Parse parses sql as SQLite SELECT statements, the dialect osquery runs.
+// Errors are *SyntaxError.
+func Parse(sql string) (*Query, error) {
+	toks, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{toks: toks, q: &Query{CTEs: make(map[string]bool)}}
+	if err := p.parseStatements(); err != nil {
+		return nil, err
+	}
+	return p.q, nil
+}
+
+type sqlParser struct {
+	toks       []token
+	pos        int
+	q          *Query
+	scope      *Select
+	constraint bool // parsing a WHERE or ON expression of the current scope
+}
+
+// bail aborts parsing; parseStatements recovers it into an error.
+type bail struct{ err *SyntaxError }
+
+func (p *sqlParser) peek() token { return p.toks[p.pos] }
+func (p *sqlParser) peekAt(n int) token {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+func (p *sqlParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// fail reports a syntax error at t, worded like SQLite's.
+func (p *sqlParser) fail(t token) {
+	if t.kind == tokEOF {
+		panic(bail{&SyntaxError{Line: t.line, Column: t.col, Message: "incomplete input"}})
+	}
+	panic(bail{&SyntaxError{Line: t.line, Column: t.col, Message: fmt.Sprintf("near %q: syntax error", t.raw)}})
+}
+
+// isKw reports whether t is the reserved keyword kw.
+func isKw(t token, kw string) bool { return t.kind == tokKeyword && t.text == kw }
+
+// isWord reports whether t is the non-reserved keyword w (lexed as ident).
+func isWord(t token, w string) bool {
+	return t.kind == tokIdent && !t.quoted && strings.EqualFold(t.text, w)
+}
+
+func isOp(t token, op string) bool { return t.kind == tokOp && t.text == op }
+
+func (p *sqlParser) acceptKw(kw string) bool {
+	if isKw(p.peek(), kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) acceptWord(w string) bool {
+	if isWord(p.peek(), w) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) acceptOp(op string) bool {
+	if isOp(p.peek(), op) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expectKw(kw string) {
+	if !p.acceptKw(kw) {
+		p.fail(p.peek())
+	}
+}
+
+func (p *sqlParser) expectOp(op string) {
+	if !p.acceptOp(op) {
+		p.fail(p.peek())
+	}
+}
+
+func (p *sqlParser) expectIdent() token {
+	t := p.peek()
+	if t.kind != tokIdent {
+		p.fail(t)
+	}
+	return p.next()
+}
+
+// startsSelect reports whether t begins a select statement.
+func startsSelect(t token) bool {
+	return isKw(t, "SELECT") || isKw(t, "WITH") || isKw(t, "VALUES")
+}
+
+func (p *sqlParser) parseStatements() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bail)
+			if !ok {
+				panic(r)
+			}
+			err = b.err
+		}
+	}()
+
+	statements := 0
+	for {
+		for p.acceptOp(";") {
+		}
+		if p.peek().kind == tokEOF {
+			break
+		}
+		if !startsSelect(p.peek()) {
+			p.fail(p.peek())
+		}
+		p.parseSelectStmt()
+		statements++
+		if p.peek().kind != tokEOF && !isOp(p.peek(), ";") {
+			p.fail(p.peek())
+		}
+	}
+	if statements == 0 {
+		t := p.peek()
+		return &SyntaxError{Line: t.line, Column: t.col, Message: "empty query"}
+	}
+	return nil
+}
+
+// parseSelectStmt parses [WITH ...] core (compound-op core)* [ORDER BY] [LIMIT].
+func (p *sqlParser) parseSelectStmt() {
+	if p.acceptKw("WITH") {
+		p.acceptWord("RECURSIVE")
+		for {
+			name := p.expectIdent()
+			p.q.CTEs[strings.ToLower(name.text)] = true
+			if p.acceptOp("(") {
+				p.parseIdentList()
+				p.expectOp(")")
+			}
+			p.expectKw("AS")
+			p.acceptKw("NOT")
+			p.acceptWord("MATERIALIZED")
+			p.expectOp("(")
+			p.parseSelectStmt()
+			p.expectOp(")")
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+
+	last := p.parseSelectCore()
+	for {
+		if p.acceptKw("UNION") {
+			p.acceptKw("ALL")
+		} else if !p.acceptKw("INTERSECT") && !p.acceptKw("EXCEPT") {
+			break
+		}
+		last = p.parseSelectCore()
+	}
+
+	// ORDER BY and LIMIT resolve names against the last core.
+	saved := p.scope
+	p.scope = last
+	if p.acceptKw("ORDER") {
+		p.expectKw("BY")
+		p.parseOrderingTerms()
+	}
+	if p.acceptKw("LIMIT") {
+		p.parseExpr()
+		if p.acceptKw("OFFSET") || p.acceptOp(",") {
+			p.parseExpr()
+		}
+	}
+	p.scope = saved
+}
+
+// parseSelectCore parses SELECT ... [FROM] [WHERE] [GROUP BY] [WINDOW] or
+// VALUES (...), and returns its scope.
+func (p *sqlParser) parseSelectCore() *Select {
+	sel := &Select{Parent: p.scope, Aliases: make(map[string]bool), Derived: make(map[string]bool)}
+	p.q.Selects = append(p.q.Selects, sel)
+	saved, savedConstraint := p.scope, p.constraint
+	p.scope, p.constraint = sel, false
+	defer func() { p.scope, p.constraint = saved, savedConstraint }()
+
+	if p.acceptKw("VALUES") {
+		for {
+			p.expectOp("(")
+			p.parseExprList()
+			p.expectOp(")")
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		return sel
+	}
+
+	p.expectKw("SELECT")
+	if !p.acceptKw("DISTINCT") {
+		p.acceptKw("ALL")
+	}
+	for {
+		p.parseResultColumn()
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if p.acceptKw("FROM") {
+		p.parseJoinClause()
+	}
+	if p.acceptKw("WHERE") {
+		p.parseConstraint()
+	}
+	if p.acceptKw("GROUP") {
+		p.expectKw("BY")
+		p.parseExprList()
+	}
+	if p.acceptKw("HAVING") {
+		p.parseExpr()
+	}
+	if p.acceptKw("WINDOW") {
+		for {
+			p.expectIdent()
+			p.expectKw("AS")
+			p.parseWindowDef()
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	return sel
+}
+
+func (p *sqlParser) parseResultColumn() {
+	if p.acceptOp("*") {
+		return
+	}
+	if p.peek().kind == tokIdent && isOp(p.peekAt(1), ".") && isOp(p.peekAt(2), "*") {
+		p.next()
+		p.next()
+		p.next()
+		return
+	}
+	p.parseExpr()
+	if alias, ok := p.parseAlias(true); ok {
+		p.scope.Aliases[strings.ToLower(alias)] = true
+	}
+}
+
+// parseAlias parses an optional [AS] alias. String literals are accepted as
+// aliases only for result columns, as in SQLite.
+func (p *sqlParser) parseAlias(allowString bool) (string, bool) {
+	if p.acceptKw("AS") {
+		t := p.peek()
+		if t.kind == tokIdent || (allowString && t.kind == tokString) {
+			return p.next().text, true
+		}
+		p.fail(t)
+	}
+	t := p.peek()
+	if t.kind == tokIdent || (allowString && t.kind == tokString) {
+		return p.next().text, true
+	}
+	return "", false
+}
+
+// parseConstraint parses a WHERE or ON expression, marking the columns it
+// references as constraints.
+func (p *sqlParser) parseConstraint() {
+	p.constraint = true
+	p.parseExpr()
+	p.constraint = false
+}
+
+func (p *sqlParser) parseJoinClause() {
+	p.parseTableOrSubquery()
+	for {
+		if p.acceptOp(",") {
+			p.parseTableOrSubquery()
+			continue
+		}
+		if !p.parseJoinOperator() {
+			return
+		}
+		p.parseTableOrSubquery()
+		if p.acceptKw("ON") {
+			p.parseConstraint()
+		} else if p.acceptKw("USING") {
+			p.expectOp("(")
+			p.parseIdentList()
+			p.expectOp(")")
+		}
+	}
+}
+
+// parseJoinOperator consumes [NATURAL] [LEFT|RIGHT|FULL [OUTER]|INNER|CROSS] JOIN.
+func (p *sqlParser) parseJoinOperator() bool {
+	t := p.peek()
+	if !isKw(t, "NATURAL") && !isKw(t, "LEFT") && !isKw(t, "RIGHT") && !isKw(t, "FULL") &&
+		!isKw(t, "INNER") && !isKw(t, "CROSS") && !isKw(t, "JOIN") {
+		return false
+	}
+	p.acceptKw("NATURAL")
+	switch {
+	case p.acceptKw("LEFT"), p.acceptKw("RIGHT"), p.acceptKw("FULL"):
+		p.acceptKw("OUTER")
+	case p.acceptKw("INNER"), p.acceptKw("CROSS"):
+	}
+	p.expectKw("JOIN")
+	return true
+}
+
+func (p *sqlParser) parseTableOrSubquery() {
+	if p.acceptOp("(") {
+		if startsSelect(p.peek()) {
+			p.parseSelectStmt()
+			p.expectOp(")")
+			alias, _ := p.parseAlias(false)
+			p.scope.Derived[strings.ToLower(alias)] = true
+			return
+		}
+		p.parseJoinClause()
+		p.expectOp(")")
+		return
+	}
+
+	name := p.expectIdent()
+	if p.acceptOp(".") {
+		name = p.expectIdent() // schema-qualified: main.table
+	}
+	ref := TableRef{Name: name.text, Line: name.line, Column: name.col}
+	if p.acceptOp("(") {
+		ref.Function = true
+		if !isOp(p.peek(), ")") {
+			p.parseExprList()
+		}
+		p.expectOp(")")
+	}
+	if alias, ok := p.parseTableAlias(); ok {
+		ref.Alias = alias
+	}
+	p.scope.Tables = append(p.scope.Tables, ref)
+	if p.acceptWord("INDEXED") {
+		p.expectKw("BY")
+		p.expectIdent()
+	} else if isKw(p.peek(), "NOT") && isWord(p.peekAt(1), "INDEXED") {
+		p.next()
+		p.next()
+	}
+}
+
+// parseTableAlias is parseAlias without swallowing INDEXED BY.
+func (p *sqlParser) parseTableAlias() (string, bool) {
+	if isWord(p.peek(), "INDEXED") && isKw(p.peekAt(1), "BY") {
+		return "", false
+	}
+	return p.parseAlias(false)
+}
+
+func (p *sqlParser) parseIdentList() {
+	for {
+		p.expectIdent()
+		if !p.acceptOp(",") {
+			return
+		}
+	}
+}
+
+func (p *sqlParser) parseExprList() {
+	for {
+		p.parseExpr()
+		if !p.acceptOp(",") {
+			return
+		}
+	}
+}
+
+func (p *sqlParser) parseOrderingTerms() {
+	for {
+		p.parseExpr()
+		if !p.acceptWord("ASC") {
+			p.acceptWord("DESC")
+		}
+		if p.acceptWord("NULLS") {
+			if !p.acceptWord("FIRST") && !p.acceptWord("LAST") {
+				p.fail(p.peek())
+			}
+		}
+		if !p.acceptOp(",") {
+			return
+		}
+	}
+}
+
+// parseWindowDef parses ( [base] [PARTITION BY ...] [ORDER BY ...] [frame] ).
+// The frame spec is skipped up to the closing parenthesis.
+func (p *sqlParser) parseWindowDef() {
+	p.expectOp("(")
+	if p.peek().kind == tokIdent && !isWord(p.peek(), "PARTITION") && !isWord(p.peek(), "RANGE") &&
+		!isWord(p.peek(), "ROWS") && !isWord(p.peek(), "GROUPS") {
+		p.next()
+	}
+	if p.acceptWord("PARTITION") {
+		p.expectKw("BY")
+		p.parseExprList()
+	}
+	if p.acceptKw("ORDER") {
+		p.expectKw("BY")
+		p.parseOrderingTerms()
+	}
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			p.fail(t)
+		case isOp(t, "("):
+			depth++
+		case isOp(t, ")"):
+			if depth == 0 {
+				p.next()
+				return
+			}
+			depth--
+		}
+		p.next()
+	}
+}
+
+// ---------- Expressions ----------
+
+// parseExpr parses an expression. Precedence, loosest first: OR, AND, NOT,
+// comparison (= IS IN LIKE BETWEEN ...), relational, bitwise, additive,
+// multiplicative, concatenation, COLLATE, unary.
+func (p *sqlParser) parseExpr() { p.parseOr() }
+
+func (p *sqlParser) parseOr() {
+	p.parseAnd()
+	for p.acceptKw("OR") {
+		p.parseAnd()
+	}
+}
+
+func (p *sqlParser) parseAnd() {
+	p.parseNot()
+	for p.acceptKw("AND") {
+		p.parseNot()
+	}
+}
+
+func (p *sqlParser) parseNot() {
+	if p.acceptKw("NOT") {
+		p.parseNot()
+		return
+	}
+	p.parseComparison()
+}
+
+func (p *sqlParser) parseComparison() {
+	p.parseRelational()
+	for {
+		t := p.peek()
+		switch {
+		case isOp(t, "="), isOp(t, "=="), isOp(t, "!="), isOp(t, "<>"):
+			p.next()
+			p.parseRelational()
+		case isKw(t, "IS"):
+			p.next()
+			p.acceptKw("NOT")
+			if p.acceptKw("DISTINCT") {
+				p.expectKw("FROM")
+			}
+			p.parseRelational()
+		case isKw(t, "ISNULL"), isKw(t, "NOTNULL"):
+			p.next()
+		case isKw(t, "NOT") && isKw(p.peekAt(1), "NULL"):
+			p.next()
+			p.next()
+		case isKw(t, "NOT") && isNegatable(p.peekAt(1)):
+			p.next()
+			p.parseNegatable()
+		case isNegatable(t):
+			p.parseNegatable()
+		default:
+			return
+		}
+	}
+}
+
+// isNegatable reports whether t starts an operator that may follow NOT.
+func isNegatable(t token) bool {
+	return isKw(t, "IN") || isKw(t, "LIKE") || isKw(t, "GLOB") || isKw(t, "REGEXP") ||
+		isKw(t, "MATCH") || isKw(t, "BETWEEN")
+}
+
+func (p *sqlParser) parseNegatable() {
+	t := p.next()
+	switch t.text {
+	case "IN":
+		p.parseInRHS()
+	case "BETWEEN":
+		p.parseRelational()
+		p.expectKw("AND")
+		p.parseRelational()
+	default: // LIKE, GLOB, REGEXP, MATCH
+		p.parseRelational()
+		if p.acceptKw("ESCAPE") {
+			p.parseRelational()
+		}
+	}
+}
+
+// parseInRHS parses the right side of IN: (select), (exprs), (), or a table
+// name or table-valued function.
+func (p *sqlParser) parseInRHS() {
+	if p.acceptOp("(") {
+		switch {
+		case isOp(p.peek(), ")"):
+		case startsSelect(p.peek()):
+			p.parseSelectStmt()
+		default:
+			p.parseExprList()
+		}
+		p.expectOp(")")
+		return
+	}
+	p.expectIdent()
+	if p.acceptOp(".") {
+		p.expectIdent()
+	}
+	if p.acceptOp("(") {
+		if !isOp(p.peek(), ")") {
+			p.parseExprList()
+		}
+		p.expectOp(")")
+	}
+}
+
+func (p *sqlParser) parseRelational() {
+	p.parseBinary(p.parseBitwise, "<", "<=", ">", ">=")
+}
+
+func (p *sqlParser) parseBitwise() {
+	p.parseBinary(p.parseAdditive, "&", "|", "<<", ">>")
+}
+
+func (p *sqlParser) parseAdditive() {
+	p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *sqlParser) parseMultiplicative() {
+	p.parseBinary(p.parseConcat, "*", "/", "%")
+}
+
+func (p *sqlParser) parseConcat() {
+	p.parseBinary(p.parseCollate, "||", "->", "->>")
+}
+
+// parseBinary parses operand (op operand)* for a left-associative level.
+func (p *sqlParser) parseBinary(operand func(), ops ...string) {
+	operand()
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range ops {
+			if isOp(t, op) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return
+		}
+		p.next()
+		operand()
+	}
+}
+
+func (p *sqlParser) parseCollate() {
+	p.parseUnary()
+	for p.acceptKw("COLLATE") {
+		p.expectIdent()
+	}
+}
+
+func (p *sqlParser) parseUnary() {
+	if p.acceptOp("-") || p.acceptOp("+") || p.acceptOp("~") {
+		p.parseUnary()
+		return
+	}
+	p.parsePrimary()
+}
+
+// literalWords are identifiers SQLite resolves to values, not columns.
+var literalWords = map[string]bool{
+	"TRUE": true, "FALSE": true, "CURRENT_TIME": true, "CURRENT_DATE": true, "CURRENT_TIMESTAMP": true,
+}
+
+func (p *sqlParser) parsePrimary() {
+	t := p.peek()
+	switch {
+	case t.kind == tokNumber, t.kind == tokString, t.kind == tokBlob, t.kind == tokParam, isKw(t, "NULL"):
+		p.next()
+
+	case isOp(t, "("):
+		p.next()
+		if startsSelect(p.peek()) {
+			p.parseSelectStmt()
+		} else {
+			p.parseExprList() // parenthesized expression or row value
+		}
+		p.expectOp(")")
+
+	case isKw(t, "CAST"):
+		p.next()
+		p.expectOp("(")
+		p.parseExpr()
+		p.expectKw("AS")
+		p.parseTypeName()
+		p.expectOp(")")
+
+	case isKw(t, "EXISTS"):
+		p.next()
+		p.expectOp("(")
+		p.parseSelectStmt()
+		p.expectOp(")")
+
+	case isKw(t, "CASE"):
+		p.next()
+		if !isKw(p.peek(), "WHEN") {
+			p.parseExpr()
+		}
+		if !isKw(p.peek(), "WHEN") {
+			p.fail(p.peek())
+		}
+		for p.acceptKw("WHEN") {
+			p.parseExpr()
+			p.expectKw("THEN")
+			p.parseExpr()
+		}
+		if p.acceptKw("ELSE") {
+			p.parseExpr()
+		}
+		p.expectKw("END")
+
+	case (isKw(t, "LIKE") || isKw(t, "GLOB") || isKw(t, "REGEXP") || isKw(t, "MATCH")) && isOp(p.peekAt(1), "("):
+		// like(), glob() etc. called as plain functions.
+		p.next()
+		p.parseFunctionArgs()
+
+	case t.kind == tokIdent:
+		p.next()
+		if isOp(p.peek(), "(") {
+			p.parseFunctionArgs()
+			p.parseFunctionSuffix()
+			return
+		}
+		if !t.quoted && literalWords[strings.ToUpper(t.text)] {
+			return
+		}
+		ref := ColumnRef{Name: t.text, Quoted: t.quoted, Constraint: p.constraint, Line: t.line, Column: t.col}
+		if p.acceptOp(".") {
+			col := p.expectIdent()
+			if p.acceptOp(".") { // schema.table.column
+				col = p.expectIdent()
+			}
+			ref.Table, ref.Name = ref.Name, col.text
+			ref.Quoted = col.quoted
+		}
+		p.scope.Columns = append(p.scope.Columns, ref)
+
+	default:
+		p.fail(t)
+	}
+}
+
+// parseFunctionArgs parses ( [DISTINCT] args | * ) after a function name.
+func (p *sqlParser) parseFunctionArgs() {
+	p.expectOp("(")
+	switch {
+	case p.acceptOp(")"):
+		return
+	case p.acceptOp("*"):
+	default:
+		p.acceptKw("DISTINCT")
+		p.parseExprList()
+		if p.acceptKw("ORDER") { // ordered aggregates: group_concat(x ORDER BY y)
+			p.expectKw("BY")
+			p.parseOrderingTerms()
+		}
+	}
+	p.expectOp(")")
+}
+
+// parseFunctionSuffix parses FILTER (WHERE ...) and OVER window clauses.
+func (p *sqlParser) parseFunctionSuffix() {
+	if p.acceptWord("FILTER") {
+		p.expectOp("(")
+		p.expectKw("WHERE")
+		p.parseExpr()
+		p.expectOp(")")
+	}
+	if p.acceptWord("OVER") {
+		if p.peek().kind == tokIdent {
+			p.next()
+			return
+		}
+		p.parseWindowDef()
+	}
+}
+
+// parseTypeName parses a CAST target: one or more words with an optional
+// (size) or (precision, scale).
+func (p *sqlParser) parseTypeName() {
+	p.expectIdent()
+	for p.peek().kind == tokIdent {
+		p.next()
+	}
+	if p.acceptOp("(") {
+		p.parseSignedNumber()
+		if p.acceptOp(",") {
+			p.parseSignedNumber()
+		}
+		p.expectOp(")")
+	}
+}
+
+func (p *sqlParser) parseSignedNumber() {
+	if !p.acceptOp("+") {
+		p.acceptOp("-")
+	}
+	if p.peek().kind != tokNumber {
+		p.fail(p.peek())
+	}
+	p.next()
+}
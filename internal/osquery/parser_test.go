@@ -0,0 +1,134 @@
+package osquery
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseValid(t *testing.T) {
+	queries := []string{
+		"SELECT 1 FROM disk_encryption WHERE user_uuid IS NOT '' AND filevault_status = 'on' LIMIT 1;",
+		"select * from os_version;",
+		"SELECT 1 FROM (SELECT COUNT(*) AS n FROM users) WHERE n > 0;",
+		"WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 5) SELECT x FROM counter",
+		"SELECT CASE WHEN version >= '14' THEN 1 ELSE 0 END AS ok, CAST(major AS INTEGER) FROM os_version",
+		"SELECT name FROM apps WHERE bundle_identifier NOT IN (SELECT bundle_id FROM blocked) AND name NOT LIKE '%Helper%'",
+		"SELECT value FROM json_each('[1,2]') j WHERE j.value BETWEEN 1 AND 2",
+		"SELECT name, row_number() OVER (PARTITION BY type ORDER BY name DESC NULLS LAST) FROM users",
+		"SELECT 1 WHERE EXISTS (SELECT 1 FROM processes) OR NOT EXISTS (SELECT 1 FROM users);",
+		"SELECT a.name FROM apps a LEFT OUTER JOIN file f ON f.path = a.path CROSS JOIN users USING (uid)",
+		"SELECT 'it''s' || \"name\" FROM [users] -- trailing comment\n",
+		"/* header */ SELECT COUNT(*) > 0 AS compliant, group_concat(DISTINCT name) FROM users GROUP BY type HAVING COUNT(*) > 1",
+		"SELECT 1 FROM registry WHERE key LIKE 'HKEY_LOCAL_MACHINE\\SOFTWARE\\%' ESCAPE '\\'",
+		"SELECT 0x1F, 1.5e3, .5, X'00ff', ?, :name, -1 FROM time",
+		"SELECT 1; SELECT 2;",
+		"SELECT uid FROM users UNION SELECT uid FROM logged_in_users ORDER BY 1 LIMIT 10 OFFSET 5",
+		"SELECT path FROM file WHERE path IN ('/a', '/b') AND size ISNULL AND mode NOT NULL",
+		"VALUES (1, 2), (3, 4)",
+		"SELECT like('a%', name), glob('*', name) FROM users",
+		"SELECT count(*) FILTER (WHERE uid > 500) FROM users",
+		"SELECT json_extract(data, '$.a') ->> 'b' FROM t WHERE name = 'x' COLLATE NOCASE",
+	}
+	for _, q := range queries {
+		t.Run(q, func(t *testing.T) {
+			if _, err := Parse(q); err != nil {
+				t.Errorf("Parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantLine int
+		wantCol  int
+		wantMsg  string
+	}{
+		{"SELECT * FORM users", 1, 10, `near "FORM": syntax error`},
+		{"SELECT name FORM users", 1, 18, `near "users": syntax error`},
+		{"SELECT * FROM users WHERE", 1, 26, "incomplete input"},
+		{"SELECT 1 FROM users\nWHERE uid = = 1", 2, 13, `near "=": syntax error`},
+		{"SELECT 1 FROM users WHERE name = 'x", 1, 34, "unterminated string"},
+		{"DELETE FROM users", 1, 1, `near "DELETE": syntax error`},
+		{"SELECT (1", 1, 10, "incomplete input"},
+		{"SELECT * FROM users,", 1, 21, "incomplete input"},
+		{"SELECT CASE END", 1, 13, `near "END": syntax error`},
+		{"SELECT 1 FROM users WHERE uid IN 1", 1, 34, `near "1": syntax error`},
+		{"SELECT 12abc", 1, 8, "unrecognized token"},
+		{"  -- only a comment", 1, 20, "empty query"},
+		{"SELECT 1 SELECT 2", 1, 10, `near "SELECT": syntax error`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected SyntaxError, got %v", err)
+			}
+			if se.Line != tt.wantLine || se.Column != tt.wantCol || !strings.Contains(se.Message, tt.wantMsg) {
+				t.Errorf("got %d:%d %q, want %d:%d %q", se.Line, se.Column, se.Message, tt.wantLine, tt.wantCol, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	q, err := Parse(`WITH recent AS (SELECT uid FROM logged_in_users)
+SELECT u.username, "shell", CURRENT_TIMESTAMP, count(*) AS n
+FROM users u JOIN recent r ON r.uid = u.uid, (SELECT 1 AS one) d
+WHERE u.uid IN (SELECT uid FROM user_groups)
+ORDER BY n`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !q.CTEs["recent"] {
+		t.Errorf("CTEs = %v", q.CTEs)
+	}
+	if len(q.Selects) != 4 {
+		t.Fatalf("expected 4 selects (cte, main, derived, IN subquery), got %d", len(q.Selects))
+	}
+
+	main := q.Selects[1]
+	var tables []string
+	for _, tr := range main.Tables {
+		tables = append(tables, tr.Name+"/"+tr.Alias)
+	}
+	if !slices.Equal(tables, []string{"users/u", "recent/r"}) {
+		t.Errorf("tables = %v", tables)
+	}
+	var cols []string
+	for _, c := range main.Columns {
+		cols = append(cols, c.Table+"."+c.Name)
+	}
+	if !slices.Equal(cols, []string{"u.username", ".shell", "r.uid", "u.uid", "u.uid", ".n"}) {
+		t.Errorf("columns = %v", cols)
+	}
+	if !main.Aliases["n"] || !main.Derived["d"] {
+		t.Errorf("aliases = %v, derived = %v", main.Aliases, main.Derived)
+	}
+	if sub := q.Selects[3]; sub.Parent != main || sub.Tables[0].Name != "user_groups" || sub.Tables[0].Line != 4 {
+		t.Errorf("IN subquery: %+v", sub)
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	q, err := Parse("SELECT h.sha256 FROM hash h JOIN file f ON f.path = h.path WHERE f.directory = '/bin' AND h.path IN (SELECT path FROM processes)")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var got []string
+	for _, c := range q.Selects[0].Columns {
+		if c.Constraint {
+			got = append(got, c.Table+"."+c.Name)
+		}
+	}
+	if !slices.Equal(got, []string{"f.path", "h.path", "f.directory", "h.path"}) {
+		t.Errorf("constraint columns = %v", got)
+	}
+	if sub := q.Selects[1]; sub.Columns[0].Constraint {
+		t.Errorf("subquery result column marked as constraint: %+v", sub.Columns[0])
+	}
+}
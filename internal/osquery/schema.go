@@ -0,0 +1,174 @@
+package osquery
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// schema.json lists the osquery core tables and the fleetd extension tables
+// Fleet ships, with the platforms each is available on ("darwin", "linux",
+// "windows", "chrome") and, where known, its columns including hidden ones.
+// A table without a column list is checked for existence and platform only.
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+// Table describes one osquery table.
+type Table struct {
+	Platforms []string `json:"platforms"`
+	Columns   []string `json:"columns,omitempty"`
+}
+
+var tables = loadSchema()
+
+func loadSchema() map[string]Table {
+	var m map[string]Table
+	if err := json.Unmarshal(schemaJSON, &m); err != nil {
+		panic(fmt.Sprintf("osquery: invalid embedded schema: %v", err))
+	}
+	return m
+}
+
+// rowidColumns are the implicit columns SQLite gives every table.
+var rowidColumns = map[string]bool{"rowid": true, "oid": true, "_rowid_": true}
+
+// LookupTable returns the schema entry for a table name.
+func LookupTable(name string) (Table, bool) {
+	t, ok := tables[strings.ToLower(name)]
+	return t, ok
+}
+
+// TableNames returns every table in the schema, sorted.
+func TableNames() []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasColumn reports whether the table has the column. Tables without a
+// column list accept any column.
+func (t Table) hasColumn(name string) bool {
+	if len(t.Columns) == 0 || rowidColumns[strings.ToLower(name)] {
+		return true
+	}
+	return slices.ContainsFunc(t.Columns, func(c string) bool { return strings.EqualFold(c, name) })
+}
+
+// SchemaError is a reference in a parsed query that the schema rejects.
+type SchemaError struct {
+	Line    int // 1-based line within the query
+	Column  int
+	Message string
+	// UnknownTable is set for "no such table" errors so callers can suggest
+	// the closest table name.
+	UnknownTable string
+}
+
+// CheckSchema verifies that every table q reads exists in the schema and is
+// available on each of platforms, and that referenced columns exist. An
+// empty platforms list means the query runs everywhere and skips the
+// platform check. Unqualified columns are checked only when every table in
+// scope has a known column list, since otherwise the column could belong to
+// any of them.
+func CheckSchema(q *Query, platforms []string) []SchemaError {
+	var errs []SchemaError
+	seen := make(map[string]bool)
+	report := func(e SchemaError) {
+		if !seen[e.Message] {
+			seen[e.Message] = true
+			errs = append(errs, e)
+		}
+	}
+
+	for _, sel := range q.Selects {
+		for _, tr := range sel.Tables {
+			name := strings.ToLower(tr.Name)
+			if tr.Function || q.CTEs[name] {
+				continue
+			}
+			t, ok := tables[name]
+			if !ok {
+				report(SchemaError{Line: tr.Line, Column: tr.Column, Message: "no such table: " + tr.Name, UnknownTable: tr.Name})
+				continue
+			}
+			for _, p := range platforms {
+				if !slices.Contains(t.Platforms, p) {
+					report(SchemaError{
+						Line:    tr.Line,
+						Column:  tr.Column,
+						Message: fmt.Sprintf("table %q is not available on %s (available on: %s)", name, p, strings.Join(t.Platforms, ", ")),
+					})
+				}
+			}
+		}
+
+		for _, col := range sel.Columns {
+			if col.Quoted {
+				continue
+			}
+			if col.Table != "" {
+				t, ok := resolveQualifier(q, sel, col.Table)
+				if ok && !t.hasColumn(col.Name) {
+					report(SchemaError{Line: col.Line, Column: col.Column, Message: fmt.Sprintf("no such column: %s.%s", col.Table, col.Name)})
+				}
+				continue
+			}
+			if !unqualifiedColumnExists(q, sel, col.Name) {
+				report(SchemaError{Line: col.Line, Column: col.Column, Message: "no such column: " + col.Name})
+			}
+		}
+	}
+	return errs
+}
+
+// resolveQualifier finds the schema table a column qualifier names, by alias
+// or by table name, in sel or an enclosing SELECT. It reports false when the
+// qualifier names a CTE, subquery or table function, or is not in scope.
+func resolveQualifier(q *Query, sel *Select, qualifier string) (Table, bool) {
+	for s := sel; s != nil; s = s.Parent {
+		if s.Derived[strings.ToLower(qualifier)] {
+			return Table{}, false
+		}
+		for _, tr := range s.Tables {
+			if strings.EqualFold(tr.Alias, qualifier) || (tr.Alias == "" && strings.EqualFold(tr.Name, qualifier)) {
+				if tr.Function || q.CTEs[strings.ToLower(tr.Name)] {
+					return Table{}, false
+				}
+				t, ok := tables[strings.ToLower(tr.Name)]
+				return t, ok
+			}
+		}
+	}
+	return Table{}, false
+}
+
+// unqualifiedColumnExists reports whether an unqualified column could
+// resolve. It errs towards true: result aliases, subqueries, CTEs, table
+// functions and tables without column lists all make the column unknowable.
+func unqualifiedColumnExists(q *Query, sel *Select, name string) bool {
+	lower := strings.ToLower(name)
+	var candidates []Table
+	for s := sel; s != nil; s = s.Parent {
+		if s.Aliases[lower] || len(s.Derived) > 0 {
+			return true
+		}
+		for _, tr := range s.Tables {
+			t, ok := tables[strings.ToLower(tr.Name)]
+			if tr.Function || q.CTEs[strings.ToLower(tr.Name)] || !ok || len(t.Columns) == 0 {
+				return true
+			}
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(candidates, func(t Table) bool { return t.hasColumn(name) })
+}
@@ -0,0 +1,141 @@
+package osquery
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSchemaLoads(t *testing.T) {
+	for _, name := range []string{"apps", "os_version", "programs", "deb_packages", "mdm", "parse_json"} {
+		if _, ok := LookupTable(name); !ok {
+			t.Errorf("table %q missing from schema", name)
+		}
+	}
+	apps, _ := LookupTable("APPS")
+	if !slices.Equal(apps.Platforms, []string{"darwin"}) {
+		t.Errorf("apps platforms = %v", apps.Platforms)
+	}
+	if names := TableNames(); !slices.IsSorted(names) || len(names) < 100 {
+		t.Errorf("TableNames: %d names, sorted=%v", len(names), slices.IsSorted(names))
+	}
+}
+
+// fleetdTables are the extension tables fleetd ships, from fleetd's table
+// registry.
+var fleetdTables = []string{
+	"app_icons", "authdb", "bitlocker_key_protectors", "cis_audit", "codesign",
+	"cryptoinfo", "cryptsetup_status", "csrutil_info", "dataflattentables", "dscl",
+	"executable_hashes", "falcon_kernel_check", "falconctl_options", "file_lines",
+	"filevault_status", "filevault_users", "find_cmd", "firmware_eficheck_integrity_check",
+	"firmwarepasswd", "icloud_private_relay", "macadmins_unified_log", "macos_profiles",
+	"macos_rsr", "macos_user_profiles", "mdm", "mdm_bridge", "munki_info", "munki_installs",
+	"network_quality", "nvram_info", "parse_ini", "parse_json", "parse_jsonl", "parse_xml",
+	"pending_apple_updates", "pmset", "privaterelay", "puppet_facts", "puppet_info",
+	"puppet_logs", "puppet_state", "pwd_policy", "santa_allowed", "santa_denied",
+	"santa_status", "sntp_request", "sofa_security_release_info", "sofa_unpatched_cves",
+	"software_update", "sudo_info", "system_profiler", "tcc_access", "user_login_settings",
+	"windows_update_history",
+}
+
+func TestSchemaCoversFleetdTables(t *testing.T) {
+	// Core tables that were once missing, alongside every fleetd table.
+	core := []string{"suid_bin", "cpu_time", "shortcut_files", "dns_cache", "smbios_tables", "shared_folders"}
+	for _, name := range append(core, fleetdTables...) {
+		if _, ok := LookupTable(name); !ok {
+			t.Errorf("table %q missing from schema", name)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		platforms []string
+		want      []string // message substrings, in order
+		wantPos   [2]int   // line, column of the first error
+	}{
+		{name: "known table and columns", query: "SELECT name, bundle_identifier FROM apps WHERE path LIKE '/Applications/%'", platforms: []string{"darwin"}},
+		{name: "no platform skips platform check", query: "SELECT 1 FROM apps"},
+		{
+			name:      "table missing on target platform",
+			query:     "SELECT 1 FROM apps",
+			platforms: []string{"windows"},
+			want:      []string{`table "apps" is not available on windows (available on: darwin)`},
+			wantPos:   [2]int{1, 15},
+		},
+		{
+			name:      "one error per missing platform",
+			query:     "SELECT 1 FROM programs",
+			platforms: []string{"darwin", "windows", "linux"},
+			want:      []string{"not available on darwin", "not available on linux"},
+		},
+		{
+			name:    "unknown table",
+			query:   "SELECT 1\nFROM appz",
+			want:    []string{"no such table: appz"},
+			wantPos: [2]int{2, 6},
+		},
+		{
+			name:    "unknown qualified column",
+			query:   "SELECT a.bundle_id FROM apps a",
+			want:    []string{"no such column: a.bundle_id"},
+			wantPos: [2]int{1, 8},
+		},
+		{
+			name:  "unknown unqualified column",
+			query: "SELECT 1 FROM os_version WHERE verison >= '14'",
+			want:  []string{"no such column: verison"},
+		},
+		{
+			name:  "column found in any joined table",
+			query: "SELECT username, groupname FROM users JOIN user_groups USING (uid) JOIN groups USING (gid)",
+		},
+		{name: "hidden column", query: "SELECT uid FROM users WHERE include_remote = 1"},
+		{name: "rowid", query: "SELECT rowid FROM users"},
+		{name: "result alias", query: "SELECT count(*) AS n FROM users ORDER BY n"},
+		{name: "quoted identifier may be a string", query: `SELECT 1 FROM users WHERE username = "root"`},
+		{name: "table without column list accepts any column", query: "SELECT anything FROM mdm"},
+		{name: "column that may belong to a subquery", query: "SELECT n, username FROM users, (SELECT 1 AS n)"},
+		{name: "CTE and table function", query: "WITH x AS (SELECT uid FROM users) SELECT x.whatever, value FROM x, json_each('[1]')"},
+		{name: "correlated subquery column", query: "SELECT 1 FROM users u WHERE EXISTS (SELECT 1 FROM user_groups g WHERE g.uid = u.uid AND username != '')"},
+		{
+			name:  "errors deduplicated",
+			query: "SELECT 1 FROM appz UNION SELECT 1 FROM appz",
+			want:  []string{"no such table: appz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got := CheckSchema(q, tt.platforms)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %v", got, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i].Message, want) {
+					t.Errorf("error[%d] = %q, want substring %q", i, got[i].Message, want)
+				}
+			}
+			if tt.wantPos != [2]int{} && (got[0].Line != tt.wantPos[0] || got[0].Column != tt.wantPos[1]) {
+				t.Errorf("position = %d:%d, want %d:%d", got[0].Line, got[0].Column, tt.wantPos[0], tt.wantPos[1])
+			}
+		})
+	}
+}
+
+func TestCheckSchemaUnknownTable(t *testing.T) {
+	q, err := Parse("SELECT 1 FROM Appz")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	got := CheckSchema(q, nil)
+	if len(got) != 1 || got[0].UnknownTable != "Appz" {
+		t.Errorf("got %+v, want UnknownTable Appz", got)
+	}
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/diff"
+)
+
+// accessResources is the column order of the token access matrix.
+var accessResources = []api.Resource{
+	api.ResourceConfig, api.ResourcePolicies, api.ResourceQueries, api.ResourceSoftware,
+	api.ResourceProfiles, api.ResourceScripts, api.ResourceAppStore, api.ResourceLabelHosts,
+}
+
+// accessColumns returns the resources that appear in any result's access,
+// in matrix order. Empty when no result carries access.
+func accessColumns(results []diff.DiffResult) []api.Resource {
+	seen := make(map[api.Resource]bool)
+	for _, r := range results {
+		if r.Access == nil {
+			continue
+		}
+		for _, ra := range r.Access.Resources {
+			seen[ra.Resource] = true
+		}
+	}
+	var cols []api.Resource
+	for _, res := range accessResources {
+		if seen[res] {
+			cols = append(cols, res)
+		}
+	}
+	return cols
+}
+
+// accessCell returns whether a scope covers a resource and can read it.
+func accessCell(a *diff.Access, res api.Resource) (inScope, readable bool) {
+	for _, ra := range a.Resources {
+		if ra.Resource == res {
+			return true, ra.Readable
+		}
+	}
+	return false, false
+}
+
+func accessRole(a *diff.Access) string {
+	if a.Role == "" {
+		return "none"
+	}
+	return a.Role
+}
+
+// renderAccessTerminal renders the token access matrix: one line per scope
+// listing each resource with a check or a cross.
+func renderAccessTerminal(results []diff.DiffResult) string {
+	var lines []string
+	for _, r := range results {
+		if r.Access == nil {
+			continue
+		}
+		scope := "Team: " + r.Team
+		if r.Team == "(global)" {
+			scope = "Global"
+		}
+		var cells []string
+		for _, ra := range r.Access.Resources {
+			if ra.Readable {
+				cells = append(cells, green.Render("✓ ")+string(ra.Resource))
+			} else {
+				cells = append(cells, red.Render("✗ ")+string(ra.Resource))
+			}
+		}
+		lines = append(lines, fmt.Sprintf("  %s (%s): %s", scope, accessRole(r.Access), strings.Join(cells, "  ")))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return bold.Render("Token access:") + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// renderAccessMarkdown renders the token access matrix as a collapsed table
+// with a row per scope and a column per resource type.
+func renderAccessMarkdown(results []diff.DiffResult) string {
+	cols := accessColumns(results)
+	if len(cols) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("<details><summary>Token access</summary>\n\n")
+	sb.WriteString("| Scope | Role |")
+	for _, c := range cols {
+		sb.WriteString(" " + string(c) + " |")
+	}
+	sb.WriteString("\n|---|---|" + strings.Repeat("---|", len(cols)) + "\n")
+	for _, r := range results {
+		if r.Access == nil {
+			continue
+		}
+		scope := r.Team
+		if scope == "(global)" {
+			scope = "Global"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s |", mdEscapeTableCell(scope), accessRole(r.Access)))
+		for _, c := range cols {
+			switch inScope, readable := accessCell(r.Access, c); {
+			case !inScope:
+				sb.WriteString(" |")
+			case readable:
+				sb.WriteString(" ✅ |")
+			default:
+				sb.WriteString(" ❌ |")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n</details>\n")
+	return sb.String()
+}
+
+// JSONAccess is the token's access for one scope in JSON format.
+type JSONAccess struct {
+	Role      string          `json:"role"`
+	Resources map[string]bool `json:"resources"` // resource type -> readable
+}
+
+func convertAccess(a *diff.Access) *JSONAccess {
+	if a == nil {
+		return nil
+	}
+	out := &JSONAccess{Role: a.Role, Resources: make(map[string]bool, len(a.Resources))}
+	for _, ra := range a.Resources {
+		out.Resources[string(ra.Resource)] = ra.Readable
+	}
+	return out
+}
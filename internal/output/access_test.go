@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/diff"
+)
+
+func accessResults() []diff.DiffResult {
+	return []diff.DiffResult{
+		{
+			Team: "(global)",
+			Access: &diff.Access{Role: "gitops", Resources: []diff.ResourceAccess{
+				{Resource: api.ResourceConfig, Readable: true},
+				{Resource: api.ResourceLabelHosts},
+			}},
+		},
+		{
+			Team: "Workstations",
+			Access: &diff.Access{Role: "gitops", Resources: []diff.ResourceAccess{
+				{Resource: api.ResourcePolicies, Readable: true},
+				{Resource: api.ResourceSoftware},
+			}},
+			Policies: diff.ResourceDiff{Added: []diff.ResourceChange{{Name: "Disk encryption"}}},
+		},
+		{
+			Team:   "Servers",
+			Access: &diff.Access{Resources: []diff.ResourceAccess{{Resource: api.ResourcePolicies}}},
+		},
+	}
+}
+
+func TestRenderAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		render  func([]diff.DiffResult) string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "terminal",
+			render: func(r []diff.DiffResult) string { return stripANSI(RenderDiffTerminal(r, false)) },
+			want: []string{
+				"Token access:",
+				"Global (gitops): ✓ config  ✗ label hosts",
+				"Team: Workstations (gitops): ✓ policies  ✗ software",
+				"Team: Servers (none): ✗ policies",
+			},
+		},
+		{
+			name: "markdown",
+			render: func(r []diff.DiffResult) string {
+				return RenderDiffMarkdown(r, MarkdownOptions{})
+			},
+			want: []string{
+				"<details><summary>Token access</summary>",
+				"| Scope | Role | config | policies | software | label hosts |",
+				"| Global | gitops | ✅ | | | ❌ |",
+				"| Workstations | gitops | | ✅ | ❌ | |",
+				"| Servers | none | | ❌ | | |",
+			},
+		},
+		{
+			name: "markdown without changes",
+			render: func(r []diff.DiffResult) string {
+				r[1].Policies = diff.ResourceDiff{}
+				return RenderDiffMarkdown(r, MarkdownOptions{})
+			},
+			want: []string{"No changes detected", "| Global | gitops | ✅ | | | ❌ |"},
+		},
+		{
+			name: "not discovered",
+			render: func(r []diff.DiffResult) string {
+				for i := range r {
+					r[i].Access = nil
+				}
+				return stripANSI(RenderDiffTerminal(r, false)) + RenderDiffMarkdown(r, MarkdownOptions{})
+			},
+			notWant: []string{"Token access"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.render(accessResults())
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output missing %q:\n%s", w, out)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("output contains %q:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderAccessJSON(t *testing.T) {
+	out, err := RenderDiffJSON(accessResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed JSONDiffOutput
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	ws := parsed.Teams[1].Access
+	if ws == nil || ws.Role != "gitops" || !ws.Resources["policies"] || ws.Resources["software"] {
+		t.Errorf("Workstations access = %+v, want gitops with policies readable and software not", ws)
+	}
+
+	out, _ = RenderDiffJSON([]diff.DiffResult{{Team: "Workstations"}})
+	if strings.Contains(out, `"access"`) {
+		t.Errorf("access rendered without discovery:\n%s", out)
+	}
+}
@@ -8,20 +8,30 @@ import (
 
 // JSONDiffOutput is the structured JSON output for AI agents and CI.
 type JSONDiffOutput struct {
-	Teams []JSONTeamDiff `json:"teams"`
+	ServerVersion string         `json:"server_version,omitempty"`
+	Partial       bool           `json:"partial,omitempty"` // some Fleet state was not fetched; see not_fetched
+	Teams         []JSONTeamDiff `json:"teams"`
+}
+
+// JSONOptions adds plan-wide metadata to JSON output.
+type JSONOptions struct {
+	ServerVersion string // Fleet release the plan was computed against
 }
 
 // JSONTeamDiff is a single team's diff in JSON format.
 type JSONTeamDiff struct {
-	Team     string             `json:"team"`
-	Policies JSONResourceDiff   `json:"policies"`
-	Queries  JSONResourceDiff   `json:"queries"`
-	Software JSONResourceDiff   `json:"software"`
-	Profiles JSONResourceDiff   `json:"profiles"`
-	Scripts  JSONResourceDiff   `json:"scripts"`
-	Labels   JSONLabelResult    `json:"labels"`
-	Config   []JSONConfigChange `json:"config,omitempty"`
-	Errors   []string           `json:"errors"`
+	Team            string             `json:"team"`
+	Policies        JSONResourceDiff   `json:"policies"`
+	Queries         JSONResourceDiff   `json:"queries"`
+	Software        JSONResourceDiff   `json:"software"`
+	Profiles        JSONResourceDiff   `json:"profiles"`
+	Scripts         JSONResourceDiff   `json:"scripts"`
+	Labels          JSONLabelResult    `json:"labels"`
+	LabelMembership JSONResourceDiff   `json:"label_membership"`
+	Config          []JSONConfigChange `json:"config,omitempty"`
+	Errors          []string           `json:"errors"`
+	Access          *JSONAccess        `json:"access,omitempty"`
+	NotFetched      []string           `json:"not_fetched,omitempty"`
 }
 
 // JSONConfigChange is a config change in JSON format.
@@ -30,6 +40,8 @@ type JSONConfigChange struct {
 	Key     string `json:"key"`
 	Old     string `json:"old,omitempty"`
 	New     string `json:"new"`
+	Reset   bool   `json:"reset,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
 }
 
 // JSONResourceDiff is a resource diff in JSON format.
@@ -45,6 +57,7 @@ type JSONChange struct {
 	Fields    map[string]JSONField `json:"fields,omitempty"`
 	HostCount uint                 `json:"host_count,omitempty"`
 	Warning   string               `json:"warning,omitempty"`
+	Cost      int                  `json:"cost,omitempty"`
 }
 
 // JSONField is an old/new field value in JSON format.
@@ -64,25 +77,34 @@ type JSONLabel struct {
 	Name         string `json:"name"`
 	HostCount    uint   `json:"host_count,omitempty"`
 	ReferencedBy string `json:"referenced_by,omitempty"`
+	Pending      bool   `json:"pending,omitempty"`
+	Deleted      bool   `json:"deleted,omitempty"`
 }
 
 // RenderDiffJSON renders diff results as structured JSON.
-func RenderDiffJSON(results []diff.DiffResult) (string, error) {
+func RenderDiffJSON(results []diff.DiffResult, opts ...JSONOptions) (string, error) {
 	output := JSONDiffOutput{
-		Teams: make([]JSONTeamDiff, 0, len(results)),
+		Partial: diff.Partial(results),
+		Teams:   make([]JSONTeamDiff, 0, len(results)),
+	}
+	if len(opts) > 0 {
+		output.ServerVersion = opts[0].ServerVersion
 	}
 
 	for _, r := range results {
 		teamDiff := JSONTeamDiff{
-			Team:     r.Team,
-			Policies: convertResourceDiff(r.Policies),
-			Queries:  convertResourceDiff(r.Queries),
-			Software: convertResourceDiff(r.Software),
-			Profiles: convertResourceDiff(r.Profiles),
-			Scripts:  convertResourceDiff(r.Scripts),
-			Labels:   convertLabels(r.Labels),
-			Config:   convertConfigChanges(r.Config),
-			Errors:   r.Errors,
+			Team:            r.Team,
+			Policies:        convertResourceDiff(r.Policies),
+			Queries:         convertResourceDiff(r.Queries),
+			Software:        convertResourceDiff(r.Software),
+			Profiles:        convertResourceDiff(r.Profiles),
+			Scripts:         convertResourceDiff(r.Scripts),
+			Labels:          convertLabels(r.Labels),
+			LabelMembership: convertResourceDiff(r.LabelMembership),
+			Config:          convertConfigChanges(r.Config),
+			Errors:          r.Errors,
+			Access:          convertAccess(r.Access),
+			NotFetched:      convertNotFetched(r),
 		}
 		if teamDiff.Errors == nil {
 			teamDiff.Errors = []string{}
@@ -112,6 +134,7 @@ func convertChanges(changes []diff.ResourceChange) []JSONChange {
 			Name:      c.Name,
 			HostCount: c.HostCount,
 			Warning:   c.Warning,
+			Cost:      c.Cost,
 		}
 		if len(c.Fields) > 0 {
 			jc.Fields = make(map[string]JSONField)
@@ -135,6 +158,8 @@ func convertConfigChanges(changes []diff.ConfigChange) []JSONConfigChange {
 			Key:     c.Key,
 			Old:     c.Old,
 			New:     c.New,
+			Reset:   c.Reset,
+			Removed: c.Removed,
 		})
 	}
 	return result
@@ -150,12 +175,14 @@ func convertLabels(lv diff.LabelValidation) JSONLabelResult {
 			Name:         l.Name,
 			HostCount:    l.HostCount,
 			ReferencedBy: l.ReferencedBy,
+			Pending:      l.Pending,
 		})
 	}
 	for _, l := range lv.Missing {
 		result.Missing = append(result.Missing, JSONLabel{
 			Name:         l.Name,
 			ReferencedBy: l.ReferencedBy,
+			Deleted:      l.Deleted,
 		})
 	}
 	return result
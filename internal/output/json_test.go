@@ -47,6 +47,23 @@ func TestRenderDiffJSON(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "config reset and removed flags",
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				Config: []diff.ConfigChange{
+					{Section: "org_settings", Key: "smtp_settings.port", Old: "25", New: "587", Reset: true},
+					{Section: "org_settings", Key: "org_info.org_name", New: "Acme"},
+					{Section: "agent_options", Key: "config.decorators.load[1]", Old: "SELECT 2", Removed: true},
+				},
+			}},
+			check: func(t *testing.T, output JSONDiffOutput) {
+				cfg := output.Teams[0].Config
+				if len(cfg) != 3 || !cfg[0].Reset || cfg[1].Reset || !cfg[2].Removed {
+					t.Errorf("reset flags: got %+v", cfg)
+				}
+			},
+		},
 		{
 			name: "modified with fields",
 			results: []diff.DiffResult{{
@@ -97,6 +114,21 @@ func TestRenderDiffJSON(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "query cost",
+			results: []diff.DiffResult{{
+				Team: "Workstations",
+				Queries: diff.ResourceDiff{
+					Added: []diff.ResourceChange{{Name: "Sockets", Cost: 240, Warning: "query cost 240 exceeds 100"}},
+				},
+			}},
+			check: func(t *testing.T, output JSONDiffOutput) {
+				added := output.Teams[0].Queries.Added
+				if len(added) != 1 || added[0].Cost != 240 || added[0].Warning == "" {
+					t.Errorf("added: %+v", added)
+				}
+			},
+		},
 		{
 			name: "labels valid and missing",
 			results: []diff.DiffResult{{
@@ -122,6 +154,42 @@ func TestRenderDiffJSON(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "label membership",
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				LabelMembership: diff.ResourceDiff{Modified: []diff.ResourceChange{{
+					Name:      "Kiosks",
+					HostCount: 1,
+					Fields:    map[string]diff.FieldDiff{"hosts removed": {New: "kiosk-02"}},
+				}}},
+			}},
+			check: func(t *testing.T, output JSONDiffOutput) {
+				m := output.Teams[0].LabelMembership.Modified
+				if len(m) != 1 || m[0].Fields["hosts removed"].New != "kiosk-02" || m[0].HostCount != 1 {
+					t.Errorf("label membership: %+v", output.Teams[0].LabelMembership)
+				}
+			},
+		},
+		{
+			name: "labels pending and deleted",
+			results: []diff.DiffResult{{
+				Team: "Endpoints",
+				Labels: diff.LabelValidation{
+					Valid:   []diff.LabelRef{{Name: "New", ReferencedBy: "query: Q", Pending: true}},
+					Missing: []diff.LabelRef{{Name: "Retired", ReferencedBy: "policy: P, profile: Wi-Fi", Deleted: true}},
+				},
+			}},
+			check: func(t *testing.T, output JSONDiffOutput) {
+				labels := output.Teams[0].Labels
+				if len(labels.Valid) != 1 || !labels.Valid[0].Pending {
+					t.Errorf("valid: %+v", labels.Valid)
+				}
+				if len(labels.Missing) != 1 || !labels.Missing[0].Deleted || labels.Missing[0].ReferencedBy != "policy: P, profile: Wi-Fi" {
+					t.Errorf("missing: %+v", labels.Missing)
+				}
+			},
+		},
 		{
 			name: "errors array never null",
 			results: []diff.DiffResult{{
@@ -226,3 +294,30 @@ func TestRenderDiffJSONSpecialCharsRoundtrip(t *testing.T) {
 		t.Error("expected quotes in output")
 	}
 }
+
+func TestRenderDiffJSONServerVersion(t *testing.T) {
+	results := []diff.DiffResult{{Team: "Workstations"}}
+	tests := []struct {
+		name string
+		opts []JSONOptions
+		want string
+	}{
+		{name: "detected", opts: []JSONOptions{{ServerVersion: "4.58.0"}}, want: "4.58.0"},
+		{name: "not detected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := RenderDiffJSON(results, tt.opts...)
+			if err != nil {
+				t.Fatalf("RenderDiffJSON: %v", err)
+			}
+			var out JSONDiffOutput
+			if err := json.Unmarshal([]byte(raw), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.ServerVersion != tt.want {
+				t.Errorf("server_version = %q, want %q", out.ServerVersion, tt.want)
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ type MarkdownOptions struct {
 	Heading string // ## heading text (e.g. "Planned changes for fleet.example.com")
 	Marker  string // HTML comment appended for idempotent MR note updates
 	JobURL  string // CI pipeline/job URL embedded before the marker
+
+	ServerVersion string // Fleet release the plan was computed against
 }
 
 // HasChanges returns true if any DiffResult contains additions, modifications,
@@ -21,7 +23,7 @@ func HasChanges(results []diff.DiffResult) bool {
 	for _, r := range results {
 		if !r.Policies.IsEmpty() || !r.Queries.IsEmpty() ||
 			!r.Software.IsEmpty() || !r.Profiles.IsEmpty() ||
-			!r.Scripts.IsEmpty() ||
+			!r.Scripts.IsEmpty() || !r.LabelMembership.IsEmpty() ||
 			len(r.Config) > 0 || len(r.Errors) > 0 || len(r.Labels.Missing) > 0 {
 			return true
 		}
@@ -29,6 +31,12 @@ func HasChanges(results []diff.DiffResult) bool {
 	return false
 }
 
+func writeServerVersion(sb *strings.Builder, opts MarkdownOptions) {
+	if opts.ServerVersion != "" {
+		sb.WriteString(fmt.Sprintf("\n_Planned against Fleet %s._\n", opts.ServerVersion))
+	}
+}
+
 func writeMarker(sb *strings.Builder, opts MarkdownOptions) {
 	if opts.JobURL != "" {
 		sb.WriteString(fmt.Sprintf("[View pipeline job](%s)\n", opts.JobURL))
@@ -64,8 +72,16 @@ func RenderDiffMarkdown(results []diff.DiffResult, opts MarkdownOptions) string
 	}
 	sb.WriteString("## " + heading + "\n\n")
 
+	if partial := renderPartialMarkdown(results); partial != "" {
+		sb.WriteString(partial + "\n")
+	}
+
 	if !HasChanges(results) {
 		sb.WriteString("No changes detected. Your branch matches the current Fleet state.\n")
+		if access := renderAccessMarkdown(results); access != "" {
+			sb.WriteString("\n" + access)
+		}
+		writeServerVersion(&sb, opts)
 		writeMarker(&sb, opts)
 		return sb.String()
 	}
@@ -84,11 +100,18 @@ func RenderDiffMarkdown(results []diff.DiffResult, opts MarkdownOptions) string
 		}
 
 		for _, c := range result.Config {
-			if c.Old == "" {
+			if c.Removed {
+				rows = append(rows, row{"REMOVED", team, "Config", c.Section + "." + c.Key, mdCodeSpan(c.Old)})
+				totalDeleted++
+			} else if c.Old == "" {
 				rows = append(rows, row{"ADDED", team, "Config", c.Section + "." + c.Key, mdCodeSpan(c.New)})
 				totalAdded++
 			} else {
-				rows = append(rows, row{"MODIFIED", team, "Config", c.Section + "." + c.Key, fmt.Sprintf("%s → %s", mdCodeSpan(c.Old), mdCodeSpan(c.New))})
+				det := fmt.Sprintf("%s → %s", mdCodeSpan(c.Old), mdCodeSpan(c.New))
+				if c.Reset {
+					det += " _(reset to default)_"
+				}
+				rows = append(rows, row{"MODIFIED", team, "Config", c.Section + "." + c.Key, det})
 				totalModified++
 			}
 		}
@@ -102,26 +125,35 @@ func RenderDiffMarkdown(results []diff.DiffResult, opts MarkdownOptions) string
 			{"Software", result.Software},
 			{"Profile", result.Profiles},
 		{"Script", result.Scripts},
+			{"Label hosts", result.LabelMembership},
 		}
 
 		for _, rt := range types {
-			for _, c := range rt.rd.Added {
+			for _, c := range rankByHosts(rt.rd.Added) {
 				det := ""
-				if c.HostCount > 0 {
+				switch {
+				case c.Warning != "":
+					det = "⚠️ " + mdEscapeTableCell(c.Warning)
+				case c.HostCount > 0:
 					det = fmt.Sprintf("~%d hosts", c.HostCount)
 				}
 				rows = append(rows, row{"ADDED", team, rt.name, c.Name, det})
 				totalAdded++
 			}
-			for _, c := range rt.rd.Modified {
+			for _, c := range rankByHosts(rt.rd.Modified) {
 				det := mdFieldDetails(c.Fields)
-				if det == "" && c.Warning != "" {
-					det = c.Warning
+				switch {
+				case det == "" && c.Warning != "":
+					det = mdEscapeTableCell(c.Warning)
+				case c.Warning != "":
+					det += " ⚠️ " + mdEscapeTableCell(c.Warning)
+				case c.HostCount > 0:
+					det += fmt.Sprintf(" (~%d hosts)", c.HostCount)
 				}
 				rows = append(rows, row{"MODIFIED", team, rt.name, c.Name, det})
 				totalModified++
 			}
-			for _, c := range rt.rd.Deleted {
+			for _, c := range rankByHosts(rt.rd.Deleted) {
 				det := ""
 				if c.Warning != "" {
 					det = "⚠️ " + c.Warning
@@ -135,7 +167,7 @@ func RenderDiffMarkdown(results []diff.DiffResult, opts MarkdownOptions) string
 			if _, ok := permissionErrors[e]; ok {
 				continue
 			}
-			errRows = append(errRows, fmt.Sprintf("| ⚠️ | %s | | | %s |", team, e))
+			errRows = append(errRows, fmt.Sprintf("| ⚠️ | %s | | | %s |", team, mdEscapeTableCell(e)))
 		}
 	}
 
@@ -164,6 +196,12 @@ func RenderDiffMarkdown(results []diff.DiffResult, opts MarkdownOptions) string
 		sb.WriteString(fmt.Sprintf("\n⚠️ %s\n", warning))
 	}
 
+	if access := renderAccessMarkdown(results); access != "" {
+		sb.WriteString("\n" + access)
+	}
+
+	writeServerVersion(&sb, opts)
+
 	sb.WriteString("\n> **NOTE:** Unexpected changes? Rebase, or confirm that changes have been deployed to Fleet.\n")
 
 	writeMarker(&sb, opts)
@@ -310,6 +348,9 @@ func buildPermissionWarning(results []diff.DiffResult) string {
 	hasLabels, hasLabelCounts := false, false
 	for _, r := range results {
 		for _, l := range r.Labels.Valid {
+			if l.Pending {
+				continue // not in Fleet yet, no host count to read
+			}
 			hasLabels = true
 			if l.HostCount > 0 {
 				hasLabelCounts = true
@@ -345,9 +386,11 @@ func renderLabelsTable(results []diff.DiffResult) string {
 			}
 		}
 		for _, l := range result.Labels.Missing {
-			if _, ok := missingSeen[l.Name]; !ok {
-				missingSeen[l.Name] = l
+			if seen, ok := missingSeen[l.Name]; ok {
+				l.ReferencedBy = joinReferences(seen.ReferencedBy, l.ReferencedBy)
+				l.Deleted = l.Deleted || seen.Deleted
 			}
+			missingSeen[l.Name] = l
 		}
 	}
 
@@ -365,6 +408,14 @@ func renderLabelsTable(results []diff.DiffResult) string {
 	}
 
 	for _, l := range validLabels {
+		if l.Pending {
+			if anyNonZero {
+				sb.WriteString(fmt.Sprintf("| `%s` _(created by this plan)_ | — |\n", l.Name))
+			} else {
+				sb.WriteString(fmt.Sprintf("| `%s` _(created by this plan)_ |\n", l.Name))
+			}
+			continue
+		}
 		if anyNonZero {
 			sb.WriteString(fmt.Sprintf("| `%s` | %s |\n", l.Name, formatHostCount(l.HostCount)))
 		} else {
@@ -375,10 +426,15 @@ func renderLabelsTable(results []diff.DiffResult) string {
 	missingNames := sortedKeys(missingSeen)
 	for _, name := range missingNames {
 		l := missingSeen[name]
+		status := "**NOT FOUND**"
+		if l.Deleted {
+			status = "**DELETED BY THIS PLAN**"
+		}
+		breaks := mdEscapeTableCell(l.ReferencedBy)
 		if anyNonZero {
-			sb.WriteString(fmt.Sprintf("| `%s` **NOT FOUND** (ref: %s) | — |\n", l.Name, l.ReferencedBy))
+			sb.WriteString(fmt.Sprintf("| `%s` %s (breaks: %s) | — |\n", l.Name, status, breaks))
 		} else {
-			sb.WriteString(fmt.Sprintf("| `%s` **NOT FOUND** (ref: %s) |\n", l.Name, l.ReferencedBy))
+			sb.WriteString(fmt.Sprintf("| `%s` %s (breaks: %s) |\n", l.Name, status, breaks))
 		}
 	}
 
@@ -169,6 +169,34 @@ func TestRenderDiffMarkdown(t *testing.T) {
 			},
 			wantNone: []string{"### Labels", "🏷️", "🚫"},
 		},
+		{
+			name: "manual label membership",
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				LabelMembership: diff.ResourceDiff{Added: []diff.ResourceChange{{
+					Name:   "Kiosks",
+					Fields: map[string]diff.FieldDiff{"hosts added": {New: "kiosk-01"}},
+				}}},
+			}},
+			wantAll: []string{"| ADDED | Global | Label hosts | **Kiosks** |"},
+		},
+		{
+			name: "pending and deleted labels",
+			results: []diff.DiffResult{
+				{Team: "A", Labels: diff.LabelValidation{
+					Valid:   []diff.LabelRef{{Name: "New", Pending: true}},
+					Missing: []diff.LabelRef{{Name: "Retired", ReferencedBy: "query: Q", Deleted: true}},
+				}},
+				{Team: "B", Labels: diff.LabelValidation{
+					Missing: []diff.LabelRef{{Name: "Retired", ReferencedBy: "software: software/zoom.yml"}},
+				}},
+			},
+			wantAll: []string{
+				"| `New` _(created by this plan)_ |",
+				"| `Retired` **DELETED BY THIS PLAN** (breaks: query: Q, software: software/zoom.yml) |",
+			},
+			wantNone: []string{"label host counts"},
+		},
 		{
 			name: "labels without host counts omit Hosts column",
 			results: []diff.DiffResult{{
@@ -218,6 +246,14 @@ func TestRenderDiffMarkdown(t *testing.T) {
 			},
 			wantNone: []string{"**Policies:**", "**Queries:**", "### Full"},
 		},
+		{
+			name: "added warning escaped in table",
+			results: []diff.DiffResult{{
+				Team:     "T",
+				Policies: diff.ResourceDiff{Added: []diff.ResourceChange{{Name: "P", Warning: `invalid SQL: near "||": syntax error`}}},
+			}},
+			wantAll: []string{`| ADDED | T | Policy | **P** | ⚠️ invalid SQL: near "\|\|": syntax error |`},
+		},
 		{
 			name: "config changes in table",
 			results: []diff.DiffResult{{
@@ -225,12 +261,16 @@ func TestRenderDiffMarkdown(t *testing.T) {
 				Config: []diff.ConfigChange{
 					{Section: "org_settings", Key: "enable_host_users", Old: "true", New: "false"},
 					{Section: "org_settings", Key: "new_key", New: "value"},
+					{Section: "org_settings", Key: "smtp_settings.port", Old: "25", New: "587", Reset: true},
+					{Section: "agent_options", Key: "config.decorators.load[1]", Old: "SELECT 2", Removed: true},
 				},
 			}},
 			wantAll: []string{
 				"| MODIFIED | Global | Config | **org_settings.enable_host_users** |",
 				"`true` → `false`",
 				"| ADDED | Global | Config | **org_settings.new_key** |",
+				"`25` → `587` _(reset to default)_",
+				"| REMOVED | Global | Config | **agent_options.config.decorators.load[1]** | `SELECT 2` |",
 			},
 		},
 		{
@@ -313,6 +353,28 @@ func TestRenderDiffMarkdown(t *testing.T) {
 			wantAll:  []string{"..."},
 			wantNone: []string{"SELECT 1 FROM programs"},
 		},
+		{
+			name: "software ranked by hosts with impact warnings",
+			results: []diff.DiffResult{{
+				Team: "T",
+				Software: diff.ResourceDiff{
+					Modified: []diff.ResourceChange{
+						{Name: "fleet app slack/darwin", HostCount: 20, Fields: map[string]diff.FieldDiff{"self_service": {Old: "false", New: "true"}}},
+						{Name: "fleet app zoom/darwin", HostCount: 900, Fields: map[string]diff.FieldDiff{"install_script": {New: "+2/-1"}},
+							Warning: "install_script changed for title installed on 900 hosts"},
+					},
+					Deleted: []diff.ResourceChange{
+						{Name: "software/mac/a.yml", HostCount: 5, Warning: "uninstall availability removed for title installed on 5 hosts"},
+						{Name: "software/mac/b.yml", HostCount: 1200, Warning: "uninstall availability removed for title installed on 1200 hosts"},
+					},
+				},
+			}},
+			wantAll: []string{
+				"**fleet app zoom/darwin** | `install_script`: `+2/-1` ⚠️ install_script changed for title installed on 900 hosts |\n| MODIFIED | T | Software | **fleet app slack/darwin** |",
+				"`self_service`: `false` → `true` (~20 hosts)",
+				"**software/mac/b.yml** | ⚠️ uninstall availability removed for title installed on 1200 hosts |\n| REMOVED | T | Software | **software/mac/a.yml**",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -423,3 +485,17 @@ func TestHasChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderDiffMarkdownServerVersion(t *testing.T) {
+	changed := []diff.DiffResult{{Team: "Workstations", Policies: diff.ResourceDiff{Added: []diff.ResourceChange{{Name: "Disk encryption"}}}}}
+	unchanged := []diff.DiffResult{{Team: "Workstations"}}
+	for _, results := range [][]diff.DiffResult{changed, unchanged} {
+		out := RenderDiffMarkdown(results, MarkdownOptions{ServerVersion: "4.58.0"})
+		if !strings.Contains(out, "_Planned against Fleet 4.58.0._") {
+			t.Errorf("server version missing:\n%s", out)
+		}
+		if out := RenderDiffMarkdown(results, MarkdownOptions{}); strings.Contains(out, "Planned against") {
+			t.Errorf("server version rendered without one:\n%s", out)
+		}
+	}
+}
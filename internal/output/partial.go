@@ -0,0 +1,63 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TsekNet/fleet-plan/internal/diff"
+)
+
+const partialNotice = "the run was interrupted before all Fleet state was fetched. Not compared:"
+
+// notFetchedLines lists each scope with the resources left out of its diff.
+func notFetchedLines(results []diff.DiffResult) []string {
+	var lines []string
+	for _, r := range results {
+		if len(r.NotFetched) == 0 {
+			continue
+		}
+		scope := "Team " + r.Team
+		if r.Team == "(global)" {
+			scope = "Global"
+		}
+		names := make([]string, len(r.NotFetched))
+		for i, res := range r.NotFetched {
+			names[i] = string(res)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", scope, strings.Join(names, ", ")))
+	}
+	return lines
+}
+
+// renderPartialTerminal renders the partial plan banner, or "" when every
+// result is complete.
+func renderPartialTerminal(results []diff.DiffResult) string {
+	lines := notFetchedLines(results)
+	if len(lines) == 0 {
+		return ""
+	}
+	return yellow.Bold(true).Render("⚠ Partial plan: "+partialNotice) + "\n  " + strings.Join(lines, "\n  ") + "\n"
+}
+
+// renderPartialMarkdown renders the partial plan banner as a quote above the
+// table, or "" when every result is complete.
+func renderPartialMarkdown(results []diff.DiffResult) string {
+	lines := notFetchedLines(results)
+	if len(lines) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("> **PARTIAL PLAN:** " + partialNotice + "\n>\n")
+	for _, l := range lines {
+		sb.WriteString("> - " + mdEscapeTableCell(l) + "\n")
+	}
+	return sb.String()
+}
+
+func convertNotFetched(r diff.DiffResult) []string {
+	var out []string
+	for _, res := range r.NotFetched {
+		out = append(out, string(res))
+	}
+	return out
+}
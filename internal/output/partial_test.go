@@ -0,0 +1,102 @@
+package output
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/api"
+	"github.com/TsekNet/fleet-plan/internal/diff"
+)
+
+func partialResults() []diff.DiffResult {
+	return []diff.DiffResult{
+		{
+			Team:       "(global)",
+			NotFetched: []api.Resource{api.ResourceQueries},
+			Errors:     []string{"queries diff skipped: not fetched before the run was interrupted"},
+		},
+		{
+			Team:     "Workstations",
+			Policies: diff.ResourceDiff{Added: []diff.ResourceChange{{Name: "Disk encryption"}}},
+		},
+		{
+			Team:       "Servers",
+			NotFetched: []api.Resource{api.ResourceSoftware, api.ResourceScripts},
+		},
+	}
+}
+
+func TestRenderPartial(t *testing.T) {
+	tests := []struct {
+		name    string
+		render  func([]diff.DiffResult) string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "terminal",
+			render: func(r []diff.DiffResult) string { return stripANSI(RenderDiffTerminal(r, false)) },
+			want: []string{
+				"⚠ Partial plan: the run was interrupted before all Fleet state was fetched. Not compared:\n" +
+					"  Global: queries\n  Team Servers: software, scripts",
+			},
+			notWant: []string{"Team Workstations:"},
+		},
+		{
+			name:   "markdown",
+			render: func(r []diff.DiffResult) string { return RenderDiffMarkdown(r, MarkdownOptions{Heading: "Plan"}) },
+			want: []string{
+				"## Plan\n\n> **PARTIAL PLAN:** the run was interrupted",
+				"> - Global: queries\n> - Team Servers: software, scripts\n",
+				"| ADDED | Workstations | Policy | **Disk encryption** |",
+			},
+		},
+		{
+			name: "complete",
+			render: func(r []diff.DiffResult) string {
+				r = r[1:2]
+				return stripANSI(RenderDiffTerminal(r, false)) + RenderDiffMarkdown(r, MarkdownOptions{})
+			},
+			notWant: []string{"Partial plan", "PARTIAL PLAN"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.render(partialResults())
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("output missing %q:\n%s", w, out)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("output contains %q:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPartialJSON(t *testing.T) {
+	out, err := RenderDiffJSON(partialResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed JSONDiffOutput
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Partial {
+		t.Error("partial = false, want true")
+	}
+	if got := parsed.Teams[2].NotFetched; !slices.Equal(got, []string{"software", "scripts"}) {
+		t.Errorf("Servers not_fetched = %v, want [software scripts]", got)
+	}
+
+	out, _ = RenderDiffJSON(partialResults()[1:2])
+	if strings.Contains(out, `"partial"`) || strings.Contains(out, `"not_fetched"`) {
+		t.Errorf("complete plan marked partial:\n%s", out)
+	}
+}
@@ -46,6 +46,13 @@ func RenderDiffTerminal(results []diff.DiffResult, verbose bool) string {
 	var sb strings.Builder
 	summary := DiffSummary{}
 
+	if partial := renderPartialTerminal(results); partial != "" {
+		sb.WriteString(partial + "\n")
+	}
+	if access := renderAccessTerminal(results); access != "" {
+		sb.WriteString(access + "\n")
+	}
+
 	for _, result := range results {
 		content := renderTeamDiff(result, &summary, verbose)
 		if content != "" {
@@ -105,6 +112,9 @@ func renderTeamDiff(result diff.DiffResult, summary *DiffSummary, verbose bool)
 	if !result.Scripts.IsEmpty() {
 		lines = append(lines, renderResourceDiff("Scripts", result.Scripts, summary, verbose))
 	}
+	if !result.LabelMembership.IsEmpty() {
+		lines = append(lines, renderResourceDiff("Label membership", result.LabelMembership, summary, verbose))
+	}
 
 	for _, e := range result.Errors {
 		display := e
@@ -126,7 +136,15 @@ func renderConfigChanges(changes []diff.ConfigChange, summary *DiffSummary, verb
 	lines = append(lines, bold.Render("  Config:"))
 
 	for _, c := range changes {
-		if c.Old == "" {
+		if c.Removed {
+			summary.Deleted++
+			lines = append(lines, red.Render("    - ")+fmt.Sprintf("%s.%s", c.Section, c.Key))
+			val := fmt.Sprintf("was %q", c.Old)
+			if !verbose {
+				val = truncateToFit(val, maxLineWidth-len(fieldIndent))
+			}
+			lines = append(lines, fieldIndent+dim.Render(val))
+		} else if c.Old == "" {
 			summary.Added++
 			lines = append(lines, green.Render("    + ")+fmt.Sprintf("%s.%s", c.Section, c.Key))
 			val := fmt.Sprintf("= %q", c.New)
@@ -136,7 +154,11 @@ func renderConfigChanges(changes []diff.ConfigChange, summary *DiffSummary, verb
 			lines = append(lines, fieldIndent+dim.Render(val))
 		} else {
 			summary.Modified++
-			lines = append(lines, yellow.Render("    ~ ")+fmt.Sprintf("%s.%s", c.Section, c.Key))
+			name := fmt.Sprintf("%s.%s", c.Section, c.Key)
+			if c.Reset {
+				name += dim.Render(" (reset to default)")
+			}
+			lines = append(lines, yellow.Render("    ~ ")+name)
 			if verbose {
 				lines = append(lines, fieldIndent+dim.Render(fmt.Sprintf("%q ", c.Old))+yellow.Render("→")+dim.Render(fmt.Sprintf(" %q", c.New)))
 			} else {
@@ -168,15 +190,19 @@ func renderResourceDiff(name string, rd diff.ResourceDiff, summary *DiffSummary,
 // renderChangeList renders resource changes with per-field indented lines.
 //
 // Modified items: name on first line, each changed field indented below.
-// Added items: name only (default) or name + proposed fields (verbose).
+// Added items: name only (default) or name + proposed fields (verbose), then
+// any warning.
 // Deleted items: name + host count + warning.
 //
+// Items are ranked by affected hosts, most first.
+//
 // Default mode truncates values to fit 80-char lines and caps at 3 fields.
 // Verbose mode shows all fields with full values.
 func renderChangeList(items []diff.ResourceChange, changeType string, color lipgloss.Style, verbose bool) []string {
 	if len(items) == 0 {
 		return nil
 	}
+	items = rankByHosts(items)
 
 	prefix := map[string]string{"added": "    + ", "modified": "    ~ ", "deleted": "    - "}[changeType]
 
@@ -192,14 +218,23 @@ func renderChangeList(items []diff.ResourceChange, changeType string, color lipg
 			if verbose {
 				lines = append(lines, renderFieldLines(c.Fields, verbose, false)...)
 			}
+			if c.Warning != "" {
+				lines = append(lines, "      "+red.Render("! "+c.Warning))
+			}
 
 		case "modified":
 			line := color.Render(prefix + c.Name)
+			if c.HostCount > 0 {
+				line += dim.Render(fmt.Sprintf(" (~%d hosts)", c.HostCount))
+			}
 			if len(c.Fields) == 0 && c.Warning != "" {
 				line += dim.Render(" (" + c.Warning + ")")
 			}
 			lines = append(lines, line)
 			lines = append(lines, renderFieldLines(c.Fields, verbose, true)...)
+			if len(c.Fields) > 0 && c.Warning != "" {
+				lines = append(lines, "      "+yellow.Render("! "+c.Warning))
+			}
 
 		case "deleted":
 			line := color.Render(prefix + c.Name)
@@ -216,6 +251,15 @@ func renderChangeList(items []diff.ResourceChange, changeType string, color lipg
 	return lines
 }
 
+// rankByHosts returns items ordered by HostCount, highest first. Ties keep
+// their existing (alphabetical) order.
+func rankByHosts(items []diff.ResourceChange) []diff.ResourceChange {
+	ranked := make([]diff.ResourceChange, len(items))
+	copy(ranked, items)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].HostCount > ranked[j].HostCount })
+	return ranked
+}
+
 // renderFieldLines renders field diffs as indented lines under a resource name.
 // showOld controls whether old→new format is used (true for modified) or just new value (false for added).
 func renderFieldLines(fields map[string]diff.FieldDiff, verbose bool, showOld bool) []string {
@@ -354,10 +398,12 @@ func renderLabels(results []diff.DiffResult) string {
 	type labelInfo struct {
 		name      string
 		hostCount uint
+		pending   bool
 	}
 	type missingInfo struct {
 		name         string
 		referencedBy string
+		deleted      bool
 	}
 
 	validSeen := make(map[string]labelInfo)
@@ -366,12 +412,15 @@ func renderLabels(results []diff.DiffResult) string {
 	for _, result := range results {
 		for _, l := range result.Labels.Valid {
 			if _, ok := validSeen[l.Name]; !ok {
-				validSeen[l.Name] = labelInfo{name: l.Name, hostCount: l.HostCount}
+				validSeen[l.Name] = labelInfo{name: l.Name, hostCount: l.HostCount, pending: l.Pending}
 			}
 		}
 		for _, l := range result.Labels.Missing {
-			if _, ok := missingSeen[l.Name]; !ok {
-				missingSeen[l.Name] = missingInfo{name: l.Name, referencedBy: l.ReferencedBy}
+			info := missingSeen[l.Name]
+			missingSeen[l.Name] = missingInfo{
+				name:         l.Name,
+				referencedBy: joinReferences(info.referencedBy, l.ReferencedBy),
+				deleted:      info.deleted || l.Deleted,
 			}
 		}
 	}
@@ -403,19 +452,42 @@ func renderLabels(results []diff.DiffResult) string {
 
 	// Missing labels first (errors are more important)
 	for _, info := range missingList {
-		line := fmt.Sprintf("  - %q (NOT FOUND) referenced by %s", info.name, info.referencedBy)
+		status := "NOT FOUND"
+		if info.deleted {
+			status = "DELETED BY THIS PLAN"
+		}
+		line := fmt.Sprintf("  - %q (%s) breaks %s", info.name, status, info.referencedBy)
 		sb.WriteString(red.Render(line) + "\n")
 	}
 
 	// Valid labels (informational, not a change)
 	for _, info := range validList {
 		line := fmt.Sprintf("  * %q (%d hosts)", info.name, info.hostCount)
+		if info.pending {
+			line = fmt.Sprintf("  * %q (pending, created by this plan)", info.name)
+		}
 		sb.WriteString(dim.Render(line) + "\n")
 	}
 
 	return strings.TrimRight(sb.String(), "\n")
 }
 
+// joinReferences merges two comma-separated resource lists, keeping the
+// first occurrence of each resource.
+func joinReferences(a, b string) string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, list := range []string{a, b} {
+		for _, r := range strings.Split(list, ", ") {
+			if r != "" && !seen[r] {
+				seen[r] = true
+				out = append(out, r)
+			}
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
 func renderSummaryBar(summary DiffSummary) string {
 	parts := []string{}
 	if summary.Added > 0 {
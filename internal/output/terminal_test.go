@@ -24,6 +24,26 @@ func TestRenderDiffTerminal(t *testing.T) {
 			results: nil,
 			wantAll: []string{"no changes"},
 		},
+		{
+			name: "config reset is marked",
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				Config: []diff.ConfigChange{
+					{Section: "org_settings", Key: "smtp_settings.port", Old: "25", New: "587", Reset: true},
+				},
+			}},
+			wantAll: []string{"org_settings.smtp_settings.port (reset to default)", "1 modified"},
+		},
+		{
+			name: "config list element removed",
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				Config: []diff.ConfigChange{
+					{Section: "agent_options", Key: "config.decorators.load[1]", Old: "SELECT 2", Removed: true},
+				},
+			}},
+			wantAll: []string{"- agent_options.config.decorators.load[1]", `was "SELECT 2"`, "1 deleted"},
+		},
 		{
 			name:    "added policies show name only in default mode",
 			verbose: false,
@@ -147,6 +167,51 @@ func TestRenderDiffTerminal(t *testing.T) {
 			}},
 			wantAll: []string{"1 label errors", "NOT FOUND"},
 		},
+		{
+			name:    "missing label lists broken resources across teams",
+			verbose: false,
+			results: []diff.DiffResult{
+				{Team: "A", Labels: diff.LabelValidation{
+					Missing: []diff.LabelRef{{Name: "Ghost", ReferencedBy: "policy: P, query: Q"}},
+				}},
+				{Team: "B", Labels: diff.LabelValidation{
+					Missing: []diff.LabelRef{{Name: "Ghost", ReferencedBy: "policy: P, profile: Wi-Fi"}},
+					Valid:   []diff.LabelRef{{Name: "New", Pending: true}},
+				}},
+			},
+			wantAll: []string{
+				"1 label errors",
+				`"Ghost" (NOT FOUND) breaks policy: P, query: Q, profile: Wi-Fi`,
+				`"New" (pending, created by this plan)`,
+			},
+		},
+		{
+			name:    "manual label membership",
+			verbose: true,
+			results: []diff.DiffResult{{
+				Team: "(global)",
+				LabelMembership: diff.ResourceDiff{Modified: []diff.ResourceChange{{
+					Name:      "Kiosks",
+					HostCount: 2,
+					Fields: map[string]diff.FieldDiff{
+						"hosts added":   {New: "kiosk-03 (C02CCC)"},
+						"hosts removed": {New: "kiosk-02"},
+					},
+				}}},
+			}},
+			wantAll: []string{"Label membership:", "~ Kiosks", "(~2 hosts)", "hosts added", "kiosk-03 (C02CCC)", "hosts removed", "1 modified"},
+		},
+		{
+			name:    "label deleted by this plan",
+			verbose: false,
+			results: []diff.DiffResult{{
+				Team: "A",
+				Labels: diff.LabelValidation{
+					Missing: []diff.LabelRef{{Name: "Retired", ReferencedBy: "query: Q", Deleted: true}},
+				},
+			}},
+			wantAll: []string{`"Retired" (DELETED BY THIS PLAN) breaks query: Q`},
+		},
 		{
 			name:    "multiple resource types in one team",
 			verbose: false,
@@ -322,6 +387,7 @@ func TestRenderChangeList(t *testing.T) {
 			wantAll: []string{"~", "ChangedItem", "key:"},
 		},
 		{name: "deleted with host count", items: []diff.ResourceChange{{Name: "CriticalPolicy", HostCount: 500}}, changeType: "deleted", wantAll: []string{"CriticalPolicy", "500 hosts"}},
+		{name: "added with warning", items: []diff.ResourceChange{{Name: "NewPolicy", Warning: "invalid SQL: query line 1, column 10: near \"FORM\": syntax error"}}, changeType: "added", wantAll: []string{"NewPolicy", "! invalid SQL"}},
 		{name: "deleted with warning", items: []diff.ResourceChange{{Name: "DangerPolicy", Warning: "affects production hosts"}}, changeType: "deleted", wantAll: []string{"DangerPolicy", "affects production hosts"}},
 		{
 			name:       "added verbose shows fields",
@@ -336,6 +402,16 @@ func TestRenderChangeList(t *testing.T) {
 			wantAll:  []string{"+", "NewPolicy"},
 			wantNone: []string{"SELECT 1"},
 		},
+		{
+			name: "modified shows host count and warning alongside fields",
+			items: []diff.ResourceChange{{
+				Name: "fleet app zoom/darwin", HostCount: 900,
+				Fields:  map[string]diff.FieldDiff{"install_script": {New: "+2/-1"}},
+				Warning: "install_script changed for title installed on 900 hosts",
+			}},
+			changeType: "modified",
+			wantAll:    []string{"zoom/darwin (~900 hosts)", "install_script:", "! install_script changed for title installed on 900 hosts"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -525,3 +601,27 @@ func TestRenderSummaryBar(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderChangeListRanksByHosts(t *testing.T) {
+	items := []diff.ResourceChange{
+		{Name: "a-small", HostCount: 3},
+		{Name: "b-none"},
+		{Name: "c-large", HostCount: 4000},
+		{Name: "d-none"},
+	}
+	lines := renderChangeList(items, "deleted", red, false)
+	var names []string
+	for _, l := range lines {
+		l = stripANSI(l)
+		if strings.HasPrefix(l, "    - ") {
+			names = append(names, strings.Fields(l)[1])
+		}
+	}
+	want := []string{"c-large", "a-small", "b-none", "d-none"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("order: got %v, want %v", names, want)
+	}
+	if items[0].Name != "a-small" {
+		t.Error("rankByHosts must not reorder the caller's slice")
+	}
+}
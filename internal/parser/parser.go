@@ -66,6 +66,17 @@ var ValidTopLevelKeys = map[string]bool{
 	"labels":        true,
 }
 
+// NoTeamName is the team name for teams/no-team.yml, which configures hosts
+// not assigned to any team (team_id 0). api.FetchAll uses it for the team 0
+// state, so Diff finds it under the same name.
+const NoTeamName = "No team"
+
+// IsNoTeamFile reports whether path is the "No team" file (teams/no-team.yml).
+func IsNoTeamFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	return base == "no-team.yml" || base == "no-team.yaml"
+}
+
 // Valid label membership types (from server/fleet/labels.go).
 var ValidLabelMembershipTypes = map[string]bool{
 	"dynamic":     true,
@@ -91,6 +102,9 @@ type ParsedGlobal struct {
 	Policies     []ParsedPolicy
 	Queries      []ParsedQuery
 	SourceFile   string
+	// LabelsDeclared is set when default.yml has a labels key. GitOps then
+	// deletes Fleet labels it does not list.
+	LabelsDeclared bool
 }
 
 // ParsedTeam represents a single team's configuration.
@@ -101,9 +115,24 @@ type ParsedTeam struct {
 	Software   ParsedSoftware
 	Profiles   []ParsedProfile
 	Scripts    []ParsedScript
+	Setup      ParsedSetupExperience
+	Labels     []ParsedLabel // team-scoped labels from the team's labels key
 	SourceFile string
 }
 
+// ParsedSetupExperience holds controls.setup_experience (or the older
+// controls.macos_setup): the script and software run during enrollment.
+type ParsedSetupExperience struct {
+	Script   string // resolved path, empty if unset
+	Software []SetupSoftwareRef
+}
+
+// SetupSoftwareRef is one setup-experience software entry.
+type SetupSoftwareRef struct {
+	PackagePath string // resolved path to a software package YAML
+	AppStoreID  string
+}
+
 // ParsedScript represents a script under controls.scripts.
 type ParsedScript struct {
 	Name       string // filename extracted from path (e.g., "foo.ps1")
@@ -122,17 +151,35 @@ type ParsedPolicy struct {
 	Critical         bool     `yaml:"critical"`
 	LabelsIncludeAny []string `yaml:"labels_include_any"`
 	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
-	SourceFile       string   `yaml:"-"`
+	// Automations. Paths are as written, relative to SourceFile.
+	RunScript       *PolicyScriptRef   `yaml:"run_script"`
+	InstallSoftware *PolicySoftwareRef `yaml:"install_software"`
+	SourceFile      string             `yaml:"-"`
+}
+
+// PolicyScriptRef is a policy's run_script automation.
+type PolicyScriptRef struct {
+	Path string `yaml:"path"`
+}
+
+// PolicySoftwareRef is a policy's install_software automation, naming the
+// package by YAML path, App Store ID or installer hash.
+type PolicySoftwareRef struct {
+	PackagePath string `yaml:"package_path"`
+	AppStoreID  string `yaml:"app_store_id"`
+	HashSHA256  string `yaml:"hash_sha256"`
 }
 
 // ParsedQuery represents a query from YAML.
 type ParsedQuery struct {
-	Name       string `yaml:"name"`
-	Query      string `yaml:"query"`
-	Interval   uint   `yaml:"interval"`
-	Platform   string `yaml:"platform"`
-	Logging    string `yaml:"logging"`
-	SourceFile string `yaml:"-"`
+	Name             string   `yaml:"name"`
+	Query            string   `yaml:"query"`
+	Interval         uint     `yaml:"interval"`
+	Platform         string   `yaml:"platform"`
+	Logging          string   `yaml:"logging"`
+	LabelsIncludeAny []string `yaml:"labels_include_any"`
+	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
+	SourceFile       string   `yaml:"-"`
 }
 
 // ParsedSoftware holds all software types for a team.
@@ -144,47 +191,64 @@ type ParsedSoftware struct {
 
 // ParsedSoftwarePackage represents a custom software package.
 type ParsedSoftwarePackage struct {
-	URL         string   `yaml:"url"`
-	HashSHA256  string   `yaml:"hash_sha256"`
-	SelfService bool     `yaml:"self_service"`
-	SourceFile  string   `yaml:"-"`
-	RefPath     string   `yaml:"-"`
-	SourceFiles []string `yaml:"-"` // all referenced file paths (install/uninstall scripts, pre_install_query)
+	URL              string   `yaml:"url"`
+	HashSHA256       string   `yaml:"hash_sha256"`
+	SelfService      bool     `yaml:"self_service"`
+	LabelsIncludeAny []string `yaml:"labels_include_any"`
+	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
+	SourceFile       string   `yaml:"-"`
+	RefPath          string   `yaml:"-"`
+	SourceFiles      []string `yaml:"-"` // all referenced file paths (install/uninstall scripts, pre_install_query)
+	TeamFile         string   `yaml:"-"` // team YAML that references the package
 }
 
 // ParsedFleetApp represents a Fleet-maintained app.
 type ParsedFleetApp struct {
 	Slug              string   `yaml:"slug"`
 	SelfService       bool     `yaml:"self_service"`
+	LabelsIncludeAny  []string `yaml:"labels_include_any"`
+	LabelsExcludeAny  []string `yaml:"labels_exclude_any"`
 	InstallScript     string   `yaml:"-"` // resolved file content
 	UninstallScript   string   `yaml:"-"`
 	PreInstallQuery   string   `yaml:"-"`
 	PostInstallScript string   `yaml:"-"`
 	SourceFiles       []string `yaml:"-"` // all referenced file paths (for changed-file filtering)
+	SourceFile        string   `yaml:"-"` // team YAML that lists the app
 }
 
-// ParsedAppStoreApp represents an App Store app.
+// ParsedAppStoreApp represents an App Store (VPP) app.
 type ParsedAppStoreApp struct {
-	AppStoreID  string `yaml:"app_store_id"`
-	SelfService bool   `yaml:"self_service"`
+	AppStoreID       string   `yaml:"app_store_id"`
+	SelfService      bool     `yaml:"self_service"`
+	Platform         string   `yaml:"platform"` // darwin, ios or ipados; empty means darwin
+	LabelsIncludeAny []string `yaml:"labels_include_any"`
+	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
+	Categories       []string `yaml:"categories"`
+	SourceFile       string   `yaml:"-"`
 }
 
 // ParsedLabel represents a label from YAML.
 type ParsedLabel struct {
-	Name                string `yaml:"name"`
-	Description         string `yaml:"description"`
-	Query               string `yaml:"query"`
-	Platform            string `yaml:"platform"`
-	LabelMembershipType string `yaml:"label_membership_type"`
-	SourceFile          string `yaml:"-"`
+	Name                string   `yaml:"name"`
+	Description         string   `yaml:"description"`
+	Query               string   `yaml:"query"`
+	Platform            string   `yaml:"platform"`
+	LabelMembershipType string   `yaml:"label_membership_type"`
+	Hosts               []string `yaml:"hosts"` // manual labels: serials, hostnames or UUIDs
+	SourceFile          string   `yaml:"-"`
 }
 
 // ParsedProfile represents an MDM profile reference.
 type ParsedProfile struct {
-	Path       string `yaml:"path"`
-	Name       string `yaml:"-"` // extracted from file content (PayloadDisplayName, etc.)
-	Platform   string `yaml:"-"` // inferred from file extension
-	SourceFile string `yaml:"-"`
+	Path             string           `yaml:"path"`
+	Name             string           `yaml:"-"` // extracted from file content (PayloadDisplayName, etc.)
+	Platform         string           `yaml:"-"` // inferred from file extension
+	LabelsIncludeAll []string         `yaml:"labels_include_all"`
+	LabelsIncludeAny []string         `yaml:"labels_include_any"`
+	LabelsExcludeAny []string         `yaml:"labels_exclude_any"`
+	Payloads         []ProfilePayload `yaml:"-"` // Apple payloads (.mobileconfig, .json)
+	CSPItems         []CSPItem        `yaml:"-"` // Windows SyncML items (.xml)
+	SourceFile       string           `yaml:"-"`
 }
 
 // ParseError represents a parse/validation error with file context.
@@ -228,6 +292,8 @@ type rawSoftwareBlock struct {
 type rawFleetApp struct {
 	Slug              string       `yaml:"slug"`
 	SelfService       bool         `yaml:"self_service"`
+	LabelsIncludeAny  []string     `yaml:"labels_include_any"`
+	LabelsExcludeAny  []string     `yaml:"labels_exclude_any"`
 	InstallScript     *rawPathRef  `yaml:"install_script"`
 	UninstallScript   *rawPathRef  `yaml:"uninstall_script"`
 	PreInstallQuery   *rawPathRef  `yaml:"pre_install_query"`
@@ -235,8 +301,10 @@ type rawFleetApp struct {
 }
 
 type rawSoftwareRef struct {
-	Path        string `yaml:"path"`
-	SelfService *bool  `yaml:"self_service"`
+	Path             string   `yaml:"path"`
+	SelfService      *bool    `yaml:"self_service"`
+	LabelsIncludeAny []string `yaml:"labels_include_any"`
+	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
 }
 
 // rawSoftwarePackage captures script path: refs inside a software package YAML file.
@@ -244,6 +312,8 @@ type rawSoftwarePackage struct {
 	URL               string      `yaml:"url"`
 	HashSHA256        string      `yaml:"hash_sha256"`
 	SelfService       bool        `yaml:"self_service"`
+	LabelsIncludeAny  []string    `yaml:"labels_include_any"`
+	LabelsExcludeAny  []string    `yaml:"labels_exclude_any"`
 	InstallScript     *rawPathRef `yaml:"install_script"`
 	UninstallScript   *rawPathRef `yaml:"uninstall_script"`
 	PreInstallQuery   *rawPathRef `yaml:"pre_install_query"`
@@ -258,10 +328,23 @@ type rawControls struct {
 	WindowsSettings struct {
 		CustomSettings []rawProfileRef `yaml:"custom_settings"`
 	} `yaml:"windows_settings"`
+	SetupExperience *rawSetupExperience `yaml:"setup_experience"`
+	MacOSSetup      *rawSetupExperience `yaml:"macos_setup"` // pre-4.6x name for setup_experience
+}
+
+type rawSetupExperience struct {
+	Script   string `yaml:"script"`
+	Software []struct {
+		PackagePath string `yaml:"package_path"`
+		AppStoreID  string `yaml:"app_store_id"`
+	} `yaml:"software"`
 }
 
 type rawProfileRef struct {
-	Path string `yaml:"path"`
+	Path             string   `yaml:"path"`
+	LabelsIncludeAll []string `yaml:"labels_include_all"`
+	LabelsIncludeAny []string `yaml:"labels_include_any"`
+	LabelsExcludeAny []string `yaml:"labels_exclude_any"`
 }
 
 // ---------- Parser ----------
@@ -361,10 +444,24 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 		return nil, []ParseError{{File: path, Message: fmt.Sprintf("YAML parse error: %s", err)}}
 	}
 
+	noTeam := IsNoTeamFile(path)
+	if noTeam && raw.Name == "" {
+		raw.Name = NoTeamName
+	}
 	if raw.Name == "" {
 		errs = append(errs, ParseError{File: path, Message: "missing required 'name' field"})
 		return nil, errs
 	}
+	if noTeam {
+		if !strings.EqualFold(raw.Name, NoTeamName) {
+			errs = append(errs, ParseError{File: path, Message: fmt.Sprintf("no-team.yml must be named %q, got %q", NoTeamName, raw.Name)})
+		}
+		raw.Name = NoTeamName
+		if len(raw.Queries) > 0 {
+			errs = append(errs, ParseError{File: path, Message: fmt.Sprintf("queries are not supported for %q (team_id 0 queries are global; define them in default.yml)", NoTeamName)})
+			raw.Queries = nil
+		}
+	}
 
 	team := &ParsedTeam{
 		Name:       raw.Name,
@@ -408,6 +505,11 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 			if ref.SelfService != nil {
 				pkgs[i].SelfService = *ref.SelfService
 			}
+			if len(ref.LabelsIncludeAny) > 0 || len(ref.LabelsExcludeAny) > 0 {
+				pkgs[i].LabelsIncludeAny = ref.LabelsIncludeAny
+				pkgs[i].LabelsExcludeAny = ref.LabelsExcludeAny
+			}
+			pkgs[i].TeamFile = path
 			// Guard against duplicate package refs in the same team YAML.
 			if canonicalRef != "" {
 				if seenSoftwareRefs[canonicalRef] {
@@ -425,9 +527,19 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 	for _, rawFMA := range raw.Software.FleetMaintained {
 		fma, fmaErrs := resolveFleetApp(root, dir, rawFMA, path)
 		errs = append(errs, fmaErrs...)
+		fma.SourceFile = path
 		team.Software.FleetMaintained = append(team.Software.FleetMaintained, fma)
 	}
-	team.Software.AppStoreApps = raw.Software.AppStoreApps
+	for _, app := range raw.Software.AppStoreApps {
+		app.SourceFile = path
+		switch strings.ToLower(strings.TrimSpace(app.Platform)) {
+		case "", "darwin", "ios", "ipados":
+		default:
+			errs = append(errs, ParseError{File: path, Message: fmt.Sprintf(
+				"app_store_apps: app %q has invalid platform %q (expected darwin, ios or ipados)", app.AppStoreID, app.Platform)})
+		}
+		team.Software.AppStoreApps = append(team.Software.AppStoreApps, app)
+	}
 
 	// Resolve script paths from controls.scripts[].path.
 	// Fleet identifies scripts by filename, which is what the API returns.
@@ -451,6 +563,29 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 		})
 	}
 
+	// Resolve team-scoped labels
+	labels, labelErrs := resolveLabelRefs(root, dir, raw.Labels, path)
+	errs = append(errs, labelErrs...)
+	team.Labels = labels
+
+	// Setup experience: setup_experience wins over the older macos_setup.
+	setup := raw.Controls.SetupExperience
+	if setup == nil {
+		setup = raw.Controls.MacOSSetup
+	}
+	if setup != nil {
+		if setup.Script != "" {
+			team.Setup.Script = filepath.Join(dir, setup.Script)
+		}
+		for _, sw := range setup.Software {
+			ref := SetupSoftwareRef{AppStoreID: sw.AppStoreID}
+			if sw.PackagePath != "" {
+				ref.PackagePath = filepath.Join(dir, sw.PackagePath)
+			}
+			team.Setup.Software = append(team.Setup.Software, ref)
+		}
+	}
+
 	// Resolve profile paths and extract names from file content.
 	// Fleet identifies profiles by the name embedded in the file (e.g.,
 	// PayloadDisplayName for .mobileconfig), NOT by the filename.
@@ -466,12 +601,17 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 		if name == "" {
 			name = profileNameFromFilename(resolved)
 		}
-		team.Profiles = append(team.Profiles, ParsedProfile{
-			Path:       resolved,
-			Name:       name,
-			Platform:   "darwin",
-			SourceFile: path,
-		})
+		profile := ParsedProfile{
+			Path:             resolved,
+			Name:             name,
+			Platform:         "darwin",
+			LabelsIncludeAll: ref.LabelsIncludeAll,
+			LabelsIncludeAny: ref.LabelsIncludeAny,
+			LabelsExcludeAny: ref.LabelsExcludeAny,
+			SourceFile:       path,
+		}
+		errs = append(errs, parseProfileContent(&profile)...)
+		team.Profiles = append(team.Profiles, profile)
 	}
 	for _, ref := range raw.Controls.WindowsSettings.CustomSettings {
 		resolved := filepath.Join(dir, ref.Path)
@@ -485,12 +625,17 @@ func parseTeamFile(root, path string) (*ParsedTeam, []ParseError) {
 		if name == "" {
 			name = profileNameFromFilename(resolved)
 		}
-		team.Profiles = append(team.Profiles, ParsedProfile{
-			Path:       resolved,
-			Name:       name,
-			Platform:   "windows",
-			SourceFile: path,
-		})
+		profile := ParsedProfile{
+			Path:             resolved,
+			Name:             name,
+			Platform:         "windows",
+			LabelsIncludeAll: ref.LabelsIncludeAll,
+			LabelsIncludeAny: ref.LabelsIncludeAny,
+			LabelsExcludeAny: ref.LabelsExcludeAny,
+			SourceFile:       path,
+		}
+		errs = append(errs, parseProfileContent(&profile)...)
+		team.Profiles = append(team.Profiles, profile)
 	}
 
 	return team, errs
@@ -580,10 +725,12 @@ func resolveSoftwareRef(root, baseDir, refPath, parentFile string) ([]ParsedSoft
 	}
 
 	pkg := ParsedSoftwarePackage{
-		URL:         raw.URL,
-		HashSHA256:  raw.HashSHA256,
-		SelfService: raw.SelfService,
-		SourceFile:  resolved,
+		URL:              raw.URL,
+		HashSHA256:       raw.HashSHA256,
+		SelfService:      raw.SelfService,
+		LabelsIncludeAny: raw.LabelsIncludeAny,
+		LabelsExcludeAny: raw.LabelsExcludeAny,
+		SourceFile:       resolved,
 	}
 
 	pkgDir := filepath.Dir(resolved)
@@ -610,8 +757,10 @@ func resolveSoftwareRef(root, baseDir, refPath, parentFile string) ([]ParsedSoft
 func resolveFleetApp(root, baseDir string, raw rawFleetApp, parentFile string) (ParsedFleetApp, []ParseError) {
 	var errs []ParseError
 	fma := ParsedFleetApp{
-		Slug:        raw.Slug,
-		SelfService: raw.SelfService,
+		Slug:             raw.Slug,
+		SelfService:      raw.SelfService,
+		LabelsIncludeAny: raw.LabelsIncludeAny,
+		LabelsExcludeAny: raw.LabelsExcludeAny,
 	}
 
 	readScript := func(ref *rawPathRef, label string) string {
@@ -710,6 +859,7 @@ func parseDefaultFile(root, path string) (*parsedDefault, []ParseError) {
 	}
 
 	global := &ParsedGlobal{SourceFile: path}
+	_, global.LabelsDeclared = rawMap["labels"]
 	var errs []ParseError
 	dir := filepath.Dir(path)
 
@@ -745,15 +895,24 @@ func parseDefaultFile(root, path string) (*parsedDefault, []ParseError) {
 	}
 
 	// Resolve labels
+	labels, labelErrs := resolveLabelRefs(root, dir, rawStruct.Labels, path)
+	errs = append(errs, labelErrs...)
+
+	return &parsedDefault{ParsedGlobal: global, labels: labels}, errs
+}
+
+// resolveLabelRefs reads the label files referenced by a labels: list.
+func resolveLabelRefs(root, dir string, refs []rawPathRef, parentFile string) ([]ParsedLabel, []ParseError) {
 	var labels []ParsedLabel
-	for _, ref := range rawStruct.Labels {
+	var errs []ParseError
+	for _, ref := range refs {
 		if ref.Path == "" {
 			continue
 		}
 		resolved := filepath.Join(dir, ref.Path)
 		if root != "" {
 			if err := safePath(root, resolved); err != nil {
-				errs = append(errs, ParseError{File: path, Message: err.Error()})
+				errs = append(errs, ParseError{File: parentFile, Message: err.Error()})
 				continue
 			}
 		}
@@ -761,7 +920,7 @@ func parseDefaultFile(root, path string) (*parsedDefault, []ParseError) {
 		fileData, err := os.ReadFile(resolved)
 		if err != nil {
 			errs = append(errs, ParseError{
-				File:    path,
+				File:    parentFile,
 				Message: fmt.Sprintf("label path reference %q: %s", ref.Path, err),
 			})
 			continue
@@ -780,8 +939,7 @@ func parseDefaultFile(root, path string) (*parsedDefault, []ParseError) {
 		}
 		labels = append(labels, items...)
 	}
-
-	return &parsedDefault{ParsedGlobal: global, labels: labels}, errs
+	return labels, errs
 }
 
 // ---------- Profile name extraction ----------
@@ -3,6 +3,7 @@ package parser
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -444,6 +445,20 @@ queries: []
 bogus_key: true
 policies: []
 queries: []
+`), 0o644)
+			},
+		},
+		{
+			name:       "invalid app store platform",
+			wantErrMsg: `app "111" has invalid platform "android"`,
+			setup: func(root string) {
+				teamsDir := filepath.Join(root, "teams")
+				os.MkdirAll(teamsDir, 0o755)
+				os.WriteFile(filepath.Join(teamsDir, "mobile.yml"), []byte(`name: Mobile
+software:
+  app_store_apps:
+    - app_store_id: "111"
+      platform: android
 `), 0o644)
 			},
 		},
@@ -626,3 +641,180 @@ team_settings: {}
 		t.Fatalf("expected duplicate software package error, got: %+v", repo.Errors)
 	}
 }
+
+func TestParseAppStoreApps(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "teams"), 0o755)
+	teamFile := filepath.Join(root, "teams", "mobile.yml")
+	os.WriteFile(teamFile, []byte(`name: Mobile
+software:
+  app_store_apps:
+    - app_store_id: "111"
+      platform: ipados
+      self_service: true
+      labels_include_any: ["Field Staff"]
+      labels_exclude_any: ["Kiosks"]
+      categories: ["Productivity"]
+`), 0o644)
+
+	repo, err := ParseRepo(root, nil, "")
+	if err != nil {
+		t.Fatalf("ParseRepo: %v", err)
+	}
+	if len(repo.Errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", repo.Errors)
+	}
+	apps := repo.Teams[0].Software.AppStoreApps
+	if len(apps) != 1 {
+		t.Fatalf("expected 1 app store app, got %d", len(apps))
+	}
+	a := apps[0]
+	if a.Platform != "ipados" || !a.SelfService || a.SourceFile != teamFile {
+		t.Errorf("app: got %+v", a)
+	}
+	if len(a.LabelsIncludeAny) != 1 || len(a.LabelsExcludeAny) != 1 || len(a.Categories) != 1 {
+		t.Errorf("labels/categories: got %+v", a)
+	}
+}
+
+func TestParseNoTeamFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantErrMsg string
+	}{
+		{name: "name defaults to No team", body: "policies: []\n"},
+		{name: "explicit name is normalized", body: "name: no team\n"},
+		{name: "other name rejected", body: "name: Onboarding\n", wantErrMsg: `no-team.yml must be named "No team"`},
+		{name: "queries rejected", body: "queries:\n  - path: ../queries/q.yml\n", wantErrMsg: "queries are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			os.MkdirAll(filepath.Join(root, "teams"), 0o755)
+			os.WriteFile(filepath.Join(root, "teams", "no-team.yml"), []byte(tt.body), 0o644)
+
+			repo, err := ParseRepo(root, []string{"No team"}, "")
+			if err != nil {
+				t.Fatalf("ParseRepo: %v", err)
+			}
+			if len(repo.Teams) != 1 || repo.Teams[0].Name != NoTeamName {
+				t.Fatalf("expected team %q, got %+v", NoTeamName, repo.Teams)
+			}
+			if tt.wantErrMsg == "" {
+				if len(repo.Errors) > 0 {
+					t.Errorf("unexpected errors: %v", repo.Errors)
+				}
+				return
+			}
+			if len(repo.Errors) != 1 || !strings.Contains(repo.Errors[0].Message, tt.wantErrMsg) {
+				t.Errorf("errors: got %v, want %q", repo.Errors, tt.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestParseCrossResourceReferences(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"teams", "policies", "software", "scripts"} {
+		os.MkdirAll(filepath.Join(root, dir), 0o755)
+	}
+	os.WriteFile(filepath.Join(root, "policies", "p.yml"), []byte(`- name: Zoom installed
+  query: SELECT 1 FROM apps WHERE name = 'zoom.us.app';
+  run_script:
+    path: ../scripts/fix.sh
+  install_software:
+    package_path: ../software/zoom.yml
+`), 0o644)
+	os.WriteFile(filepath.Join(root, "software", "zoom.yml"), []byte(`url: https://example.com/zoom.pkg
+labels_include_any: ["Package Label"]
+`), 0o644)
+	teamFile := filepath.Join(root, "teams", "t.yml")
+	os.WriteFile(teamFile, []byte(`name: T
+policies:
+  - path: ../policies/p.yml
+software:
+  packages:
+    - path: ../software/zoom.yml
+      labels_exclude_any: ["Kiosks"]
+  fleet_maintained_apps:
+    - slug: slack/darwin
+      labels_include_any: ["Engineering"]
+controls:
+  macos_setup:
+    script: ../scripts/setup.sh
+    software:
+      - package_path: ../software/zoom.yml
+      - app_store_id: "111"
+`), 0o644)
+	os.WriteFile(filepath.Join(root, "default.yml"), []byte("labels: []\n"), 0o644)
+
+	repo, err := ParseRepo(root, nil, "")
+	if err != nil {
+		t.Fatalf("ParseRepo: %v", err)
+	}
+	if len(repo.Errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", repo.Errors)
+	}
+	team := repo.Teams[0]
+
+	p := team.Policies[0]
+	if p.RunScript == nil || p.RunScript.Path != "../scripts/fix.sh" {
+		t.Errorf("run_script: got %+v", p.RunScript)
+	}
+	if p.InstallSoftware == nil || p.InstallSoftware.PackagePath != "../software/zoom.yml" {
+		t.Errorf("install_software: got %+v", p.InstallSoftware)
+	}
+
+	pkg := team.Software.Packages[0]
+	if len(pkg.LabelsIncludeAny) != 0 || !slices.Equal(pkg.LabelsExcludeAny, []string{"Kiosks"}) || pkg.TeamFile != teamFile {
+		t.Errorf("team entry labels should override the package file: %+v", pkg)
+	}
+	if fma := team.Software.FleetMaintained[0]; !slices.Equal(fma.LabelsIncludeAny, []string{"Engineering"}) || fma.SourceFile != teamFile {
+		t.Errorf("fleet-maintained app: %+v", fma)
+	}
+
+	setup := team.Setup
+	if setup.Script != filepath.Join(root, "scripts", "setup.sh") {
+		t.Errorf("setup script: got %q", setup.Script)
+	}
+	want := []SetupSoftwareRef{{PackagePath: filepath.Join(root, "software", "zoom.yml")}, {AppStoreID: "111"}}
+	if !slices.Equal(setup.Software, want) {
+		t.Errorf("setup software: got %+v", setup.Software)
+	}
+
+	if !repo.Global.LabelsDeclared {
+		t.Error("default.yml with a labels key should set LabelsDeclared")
+	}
+}
+
+func TestParseTeamLabels(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "teams"), 0o755)
+	os.MkdirAll(filepath.Join(root, "labels"), 0o755)
+	labelFile := filepath.Join(root, "labels", "team.yml")
+	os.WriteFile(labelFile, []byte(`- name: Team kiosks
+  label_membership_type: manual
+  hosts: [kiosk-01]
+`), 0o644)
+	teamFile := filepath.Join(root, "teams", "t.yml")
+	os.WriteFile(teamFile, []byte(`name: T
+labels:
+  - path: ../labels/team.yml
+  - path: ../labels/missing.yml
+`), 0o644)
+
+	repo, err := ParseRepo(root, nil, "")
+	if err != nil {
+		t.Fatalf("ParseRepo: %v", err)
+	}
+	team := repo.Teams[0]
+	if len(team.Labels) != 1 || team.Labels[0].Name != "Team kiosks" || team.Labels[0].SourceFile != labelFile {
+		t.Errorf("team labels: got %+v", team.Labels)
+	} else if !slices.Equal(team.Labels[0].Hosts, []string{"kiosk-01"}) {
+		t.Errorf("manual label hosts: got %v", team.Labels[0].Hosts)
+	}
+	if len(repo.Errors) != 1 || repo.Errors[0].File != teamFile || !strings.Contains(repo.Errors[0].Message, `label path reference "../labels/missing.yml"`) {
+		t.Errorf("errors: got %v", repo.Errors)
+	}
+}
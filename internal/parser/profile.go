@@ -0,0 +1,273 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ProfilePayload is a single payload inside an Apple profile: one entry of a
+// .mobileconfig PayloadContent array, or a DDM declaration (.json).
+type ProfilePayload struct {
+	Type       string   // PayloadType (mobileconfig) or Type (DDM)
+	Identifier string   // PayloadIdentifier (mobileconfig) or Identifier (DDM)
+	Keys       []string // setting keys, sorted, excluding Payload* metadata
+}
+
+// CSPItem is one <Item> of a Windows SyncML profile: the verb of its
+// enclosing command, the OMA-URI target, and the value written to it.
+type CSPItem struct {
+	Verb   string // "Replace", "Add" or "Delete"
+	LocURI string
+	Data   string
+}
+
+// parseProfileContent reads a profile file and extracts the settings it
+// configures: payload types/keys for Apple profiles and CSP items for Windows
+// profiles. Unreadable or unparseable Apple profiles yield empty results (they
+// may be signed); malformed Windows profiles are reported as parse errors since
+// Fleet rejects them at apply time.
+func parseProfileContent(p *ParsedProfile) []ParseError {
+	info, err := os.Stat(p.Path)
+	if err != nil || info.Size() > maxProfileSize {
+		return nil
+	}
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil
+	}
+
+	lower := strings.ToLower(p.Path)
+	switch {
+	case strings.HasSuffix(lower, ".mobileconfig"):
+		p.Payloads = mobileconfigPayloads(data)
+	case strings.HasSuffix(lower, ".json"):
+		p.Payloads = declarationPayloads(data)
+	case strings.HasSuffix(lower, ".xml"):
+		items, err := ParseSyncML(data)
+		if err != nil {
+			pe := ParseError{File: p.Path, Message: fmt.Sprintf("invalid SyncML XML: %s", err)}
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				pe.Line = syntaxErr.Line
+				pe.Message = fmt.Sprintf("invalid SyncML XML: %s", syntaxErr.Msg)
+			}
+			return []ParseError{pe}
+		}
+		if len(items) == 0 {
+			return []ParseError{{File: p.Path, Message: "Windows profile has no <Replace> or <Add> items"}}
+		}
+		p.CSPItems = items
+	}
+	return nil
+}
+
+// mobileconfigPayloads returns the entries of the top-level PayloadContent
+// array. Signed (DER-encoded) profiles are not XML and return nil.
+func mobileconfigPayloads(data []byte) []ProfilePayload {
+	root, err := decodePlist(data)
+	if err != nil {
+		return nil
+	}
+	top, ok := root.(map[string]any)
+	if !ok {
+		return nil
+	}
+	content, ok := top["PayloadContent"].([]any)
+	if !ok {
+		return nil
+	}
+
+	var payloads []ProfilePayload
+	for _, item := range content {
+		dict, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		typ, _ := dict["PayloadType"].(string)
+		if typ == "" {
+			continue
+		}
+		ident, _ := dict["PayloadIdentifier"].(string)
+		payloads = append(payloads, ProfilePayload{
+			Type:       typ,
+			Identifier: ident,
+			Keys:       settingKeys(dict),
+		})
+	}
+	return payloads
+}
+
+// declarationPayloads extracts the single declaration from a DDM .json file.
+func declarationPayloads(data []byte) []ProfilePayload {
+	var decl struct {
+		Type       string         `json:"Type"`
+		Identifier string         `json:"Identifier"`
+		Payload    map[string]any `json:"Payload"`
+	}
+	if err := json.Unmarshal(data, &decl); err != nil || decl.Type == "" {
+		return nil
+	}
+	return []ProfilePayload{{
+		Type:       decl.Type,
+		Identifier: decl.Identifier,
+		Keys:       settingKeys(decl.Payload),
+	}}
+}
+
+// settingKeys returns the sorted non-metadata keys of a payload dict.
+func settingKeys(dict map[string]any) []string {
+	var keys []string
+	for k := range dict {
+		if strings.HasPrefix(k, "Payload") {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// syncMLItem mirrors a SyncML <Item> element.
+type syncMLItem struct {
+	Target struct {
+		LocURI string `xml:"LocURI"`
+	} `xml:"Target"`
+	Data string `xml:"Data"`
+}
+
+// ParseSyncML parses a Windows SyncML profile (a sequence of
+// <Replace>/<Add>/<Delete> commands, optionally wrapped in <Atomic>) into its CSP items. It returns an
+// error if the document is not well-formed XML. It is exported so the diff
+// engine can parse profile content downloaded from Fleet the same way.
+func ParseSyncML(data []byte) ([]CSPItem, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []string
+	var items []CSPItem
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "Item" {
+				stack = append(stack, t.Name.Local)
+				continue
+			}
+			var raw syncMLItem
+			if err := dec.DecodeElement(&raw, &t); err != nil {
+				return nil, err
+			}
+			verb := ""
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == "Replace" || stack[i] == "Add" || stack[i] == "Delete" {
+					verb = stack[i]
+					break
+				}
+			}
+			if verb == "" {
+				continue
+			}
+			items = append(items, CSPItem{
+				Verb:   verb,
+				LocURI: strings.TrimSpace(raw.Target.LocURI),
+				Data:   strings.TrimSpace(raw.Data),
+			})
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// ---------- Minimal plist decoder ----------
+
+// decodePlist decodes an XML property list into Go values: <dict> becomes
+// map[string]any, <array> becomes []any, <true/>/<false/> become bool, and
+// every other scalar is kept as its trimmed text.
+func decodePlist(data []byte) (any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "plist" {
+			continue
+		}
+		return decodePlistValue(dec, start)
+	}
+}
+
+func decodePlistValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+		key := ""
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if err := dec.DecodeElement(&key, &t); err != nil {
+						return nil, err
+					}
+					key = strings.TrimSpace(key)
+					continue
+				}
+				v, err := decodePlistValue(dec, t)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = v
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		var arr []any
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				v, err := decodePlistValue(dec, t)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			case xml.EndElement:
+				return arr, nil
+			}
+		}
+	case "true", "false":
+		if err := dec.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	default:
+		var s string
+		if err := dec.DecodeElement(&s, &start); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(s), nil
+	}
+}
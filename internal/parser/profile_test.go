@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/TsekNet/fleet-plan/internal/testutil"
+)
+
+func TestMobileconfigPayloads(t *testing.T) {
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.screensaver</string>
+			<key>PayloadIdentifier</key>
+			<string>com.example.screensaver</string>
+			<key>idleTime</key>
+			<integer>600</integer>
+			<key>askForPassword</key>
+			<true/>
+		</dict>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.security.firewall</string>
+			<key>EnableFirewall</key>
+			<false/>
+			<key>Applications</key>
+			<array>
+				<dict>
+					<key>BundleID</key>
+					<string>com.example.app</string>
+				</dict>
+			</array>
+		</dict>
+	</array>
+	<key>PayloadDisplayName</key>
+	<string>Security Baseline</string>
+</dict>
+</plist>`
+
+	got := mobileconfigPayloads([]byte(plist))
+	if len(got) != 2 {
+		t.Fatalf("expected 2 payloads, got %d: %+v", len(got), got)
+	}
+	if got[0].Type != "com.apple.screensaver" || got[0].Identifier != "com.example.screensaver" {
+		t.Errorf("payload[0]: got %+v", got[0])
+	}
+	if !slices.Equal(got[0].Keys, []string{"askForPassword", "idleTime"}) {
+		t.Errorf("payload[0] keys: got %v", got[0].Keys)
+	}
+	if !slices.Equal(got[1].Keys, []string{"Applications", "EnableFirewall"}) {
+		t.Errorf("payload[1] keys: got %v", got[1].Keys)
+	}
+}
+
+func TestMobileconfigPayloadsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "signed DER blob", data: "\x30\x82\x01\x0a\x02\x82"},
+		{name: "truncated plist", data: `<plist><dict><key>PayloadContent</key><array><dict>`},
+		{name: "no PayloadContent", data: `<plist><dict><key>PayloadType</key><string>Configuration</string></dict></plist>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mobileconfigPayloads([]byte(tt.data)); got != nil {
+				t.Errorf("expected nil, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDeclarationPayloads(t *testing.T) {
+	data := `{"Type":"com.apple.configuration.passcode.settings","Identifier":"passcode","Payload":{"MinimumLength":8,"RequireAlphanumericPasscode":true}}`
+	got := declarationPayloads([]byte(data))
+	if len(got) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(got))
+	}
+	if got[0].Type != "com.apple.configuration.passcode.settings" {
+		t.Errorf("type: got %q", got[0].Type)
+	}
+	if !slices.Equal(got[0].Keys, []string{"MinimumLength", "RequireAlphanumericPasscode"}) {
+		t.Errorf("keys: got %v", got[0].Keys)
+	}
+
+	if got := declarationPayloads([]byte(`{"PayloadDisplayName":"x"}`)); got != nil {
+		t.Errorf("expected nil for JSON without Type, got %+v", got)
+	}
+}
+
+func TestParseSyncML(t *testing.T) {
+	data := `<Replace>
+  <Item>
+    <Meta><Format xmlns="syncml:metinf">int</Format></Meta>
+    <Target><LocURI>./Device/Vendor/MSFT/Policy/Config/DeviceLock/MinDevicePasswordLength</LocURI></Target>
+    <Data>8</Data>
+  </Item>
+</Replace>
+<Atomic>
+  <Add>
+    <Item>
+      <Target><LocURI> ./Device/Vendor/MSFT/Policy/Config/Defender/AllowRealtimeMonitoring </LocURI></Target>
+      <Data><![CDATA[<enabled/>]]></Data>
+    </Item>
+  </Add>
+</Atomic>
+<Delete>
+  <Item><Target><LocURI>./Device/Vendor/MSFT/Policy/Config/DeviceLock/MaxInactivityTimeDeviceLock</LocURI></Target></Item>
+</Delete>`
+	got, err := ParseSyncML([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseSyncML: %v", err)
+	}
+	want := []CSPItem{
+		{Verb: "Replace", LocURI: "./Device/Vendor/MSFT/Policy/Config/DeviceLock/MinDevicePasswordLength", Data: "8"},
+		{Verb: "Add", LocURI: "./Device/Vendor/MSFT/Policy/Config/Defender/AllowRealtimeMonitoring", Data: "<enabled/>"},
+		{Verb: "Delete", LocURI: "./Device/Vendor/MSFT/Policy/Config/DeviceLock/MaxInactivityTimeDeviceLock"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSyncMLMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "unclosed element", data: `<Replace><Item><Target><LocURI>./Device/x</LocURI></Target></Item>`},
+		{name: "mismatched tags", data: `<Replace><Item></Replace></Item>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSyncML([]byte(tt.data)); err == nil {
+				t.Error("expected error for malformed XML")
+			}
+		})
+	}
+}
+
+func TestParseProfileContentWindowsErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		data     string
+		wantMsg  string
+		wantLine int
+	}{
+		{name: "malformed", data: "<Replace>\n<Item>\n</Replace>", wantMsg: "invalid SyncML XML", wantLine: 3},
+		{name: "no items", data: "<Exec></Exec>", wantMsg: "no <Replace> or <Add> items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".xml")
+			os.WriteFile(path, []byte(tt.data), 0o644)
+			p := ParsedProfile{Path: path, Platform: "windows"}
+			errs := parseProfileContent(&p)
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %v", errs)
+			}
+			if !strings.Contains(errs[0].Message, tt.wantMsg) {
+				t.Errorf("message: got %q, want substring %q", errs[0].Message, tt.wantMsg)
+			}
+			if errs[0].Line != tt.wantLine {
+				t.Errorf("line: got %d, want %d", errs[0].Line, tt.wantLine)
+			}
+		})
+	}
+}
+
+// TestParseProfileLabelsAndContent verifies that team YAML label scopes and
+// parsed profile content are attached to ParsedProfile.
+func TestParseProfileLabelsAndContent(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "teams"), 0o755)
+	os.MkdirAll(filepath.Join(root, "profiles"), 0o755)
+	os.WriteFile(filepath.Join(root, "profiles", "lock.xml"), []byte(
+		`<Replace><Item><Target><LocURI>./Device/Vendor/MSFT/Policy/Config/DeviceLock/MaxInactivityTimeDeviceLock</LocURI></Target><Data>5</Data></Item></Replace>`), 0o644)
+	os.WriteFile(filepath.Join(root, "teams", "t.yml"), []byte(`name: T
+controls:
+  windows_settings:
+    custom_settings:
+      - path: ../profiles/lock.xml
+        labels_include_any: ["Laptops"]
+        labels_exclude_any: ["Kiosks"]
+`), 0o644)
+
+	repo, err := ParseRepo(root, nil, "")
+	if err != nil {
+		t.Fatalf("ParseRepo: %v", err)
+	}
+	if len(repo.Errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", repo.Errors)
+	}
+	p := repo.Teams[0].Profiles[0]
+	if !slices.Equal(p.LabelsIncludeAny, []string{"Laptops"}) || !slices.Equal(p.LabelsExcludeAny, []string{"Kiosks"}) {
+		t.Errorf("labels: include_any=%v exclude_any=%v", p.LabelsIncludeAny, p.LabelsExcludeAny)
+	}
+	if len(p.CSPItems) != 1 || p.CSPItems[0].Data != "5" {
+		t.Errorf("expected 1 CSP item with data 5, got %+v", p.CSPItems)
+	}
+}
+
+func TestParseTestdataProfilePayloads(t *testing.T) {
+	root := testutil.TestdataRoot(t)
+
+	repo, err := ParseRepo(root, []string{"Workstations"}, "")
+	if err != nil {
+		t.Fatalf("ParseRepo: %v", err)
+	}
+	p := repo.Teams[0].Profiles[0]
+	if len(p.Payloads) != 1 || p.Payloads[0].Type != "com.apple.TCC.configuration-profile-policy" {
+		t.Errorf("payloads: got %+v", p.Payloads)
+	}
+}